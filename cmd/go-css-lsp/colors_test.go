@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestColorScheme(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "theme.css", ":root { --brand: #0000ff; }\n")
+	const sheet = ".a { color: color-mix(in srgb, var(--brand), white); " +
+		"background: light-dark(white, black); }\n"
+	uri := writeFile(t, dir, "app.css", sheet)
+	doc := protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)}
+
+	tests := []struct {
+		scheme string
+		want   []protocol.Color
+	}{
+		{"", []protocol.Color{
+			{Red: 0.5, Green: 0.5, Blue: 1, Alpha: 1},
+			{Red: 1, Green: 1, Blue: 1, Alpha: 1},
+			{Red: 0, Green: 0, Blue: 0, Alpha: 1},
+		}},
+		{"dark", []protocol.Color{
+			{Red: 0.5, Green: 0.5, Blue: 1, Alpha: 1},
+			{Red: 0, Green: 0, Blue: 0, Alpha: 1},
+		}},
+	}
+	for _, tt := range tests {
+		h := newMultiRootHandler(t, map[string]any{
+			"colorScheme": tt.scheme,
+		}, folder(dir, "app"))
+		openDiagnostics(t, h, uri, sheet)
+		colors, err := h.DocumentColor(
+			context.Background(), &protocol.DocumentColorParams{TextDocument: doc},
+		)
+		if err != nil || len(colors) != len(tt.want) {
+			t.Fatalf("%q: colors = %+v, %v", tt.scheme, colors, err)
+		}
+		for i, c := range colors {
+			if c.Color != tt.want[i] {
+				t.Errorf("%q: color %d = %+v, want %+v",
+					tt.scheme, i, c.Color, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestLowContrast(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "theme.css", ":root { --muted: #999; }\n")
+	const sheet = ".card { background: #fff; }\n" +
+		".card .label { color: var(--muted); }\n" +
+		".note { color: #777; background-color: white; }\n"
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, map[string]any{
+		"lowContrast":   "error",
+		"contrastPairs": map[string]any{".card .label": ".card"},
+	}, folder(dir, "app"))
+
+	var got []string
+	for _, d := range openDiagnostics(t, h, uri, sheet) {
+		if strings.HasPrefix(d.Message, "low contrast") {
+			if d.Severity != protocol.DiagnosticSeverityError {
+				t.Errorf("%q: severity %v", d.Message, d.Severity)
+			}
+			got = append(got, d.Message)
+		}
+	}
+	want := []string{
+		"low contrast 4.47:1 with 'background-color'; AA needs 4.5:1",
+		"low contrast 2.84:1 with the background of '.card'; AA needs 4.5:1",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diagnostics = %q, want %q", got, want)
+	}
+
+	hover, err := h.Hover(context.Background(), &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+			Position:     position(sheet, "#fff"),
+		},
+	})
+	const contrast = "Contrast with the color of `.card .label`: **2.84:1**"
+	if err != nil || hover == nil ||
+		!strings.Contains(hover.Contents.Value, contrast) {
+		t.Errorf("hover = %+v, %v", hover, err)
+	}
+}
+
+func TestColorPresentation(t *testing.T) {
+	dir := t.TempDir()
+	const sheet = ":root { --brand: #f00; }\n" +
+		".a { color: var(--brand, rgb(255 0 0)); }\n" +
+		".b { color: var(--brand); }\n"
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, nil, folder(dir, "app"))
+	openDiagnostics(t, h, uri, sheet)
+
+	presentations := func(ref string) []protocol.ColorPresentation {
+		t.Helper()
+		end := position(sheet, ref)
+		end.Character += uint32(len(ref)) //nolint:gosec
+		items, err := h.ColorPresentation(
+			context.Background(), &protocol.ColorPresentationParams{
+				TextDocument: protocol.TextDocumentIdentifier{
+					URI: protocol.DocumentURI(uri),
+				},
+				Color: protocol.Color{Blue: 1, Alpha: 1},
+				Range: protocol.Range{Start: position(sheet, ref), End: end},
+			},
+		)
+		if err != nil || len(items) == 0 {
+			t.Fatalf("presentations = %+v, %v", items, err)
+		}
+		return items
+	}
+
+	first := presentations("var(--brand, rgb(255 0 0))")[0]
+	fallback := position(sheet, "rgb(255 0 0)")
+	if first.Label != "rgb(0 0 255)" || first.TextEdit == nil ||
+		first.TextEdit.Range.Start != fallback {
+		t.Errorf("first presentation = %+v", first)
+	}
+	if len(first.AdditionalTextEdits) != 0 {
+		t.Errorf("fallback additional edits = %+v", first.AdditionalTextEdits)
+	}
+
+	first = presentations("var(--brand)")[0]
+	def := position(sheet, "#f00")
+	if len(first.AdditionalTextEdits) != 1 ||
+		first.AdditionalTextEdits[0].Range.Start != def ||
+		first.AdditionalTextEdits[0].NewText != "#00f" {
+		t.Errorf("additional edits = %+v", first.AdditionalTextEdits)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/toba/css-lsp/internal/css"
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/workspace"
+)
+
+// defaultFormatOptions are the options of command-line formatting
+// before .editorconfig files apply: an editor's usual defaults.
+var defaultFormatOptions = analyzer.FormatOptions{TabSize: 2, InsertSpaces: true}
+
+// formatFiles formats the stylesheets at paths, writing them to w
+// one after the other, or, with write, back to the files that
+// formatting changes.
+func formatFiles(w io.Writer, paths []string, write bool) error {
+	for _, p := range paths {
+		src, err := os.ReadFile(p) //nolint:gosec // named on the command line
+		if err != nil {
+			return err
+		}
+		out := formatFile(p, src)
+		if !write {
+			if _, err := io.WriteString(w, out); err != nil {
+				return err
+			}
+			continue
+		}
+		if out == string(src) {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, []byte(out), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatFile returns src, the stylesheet at p, formatted with the
+// properties the .editorconfig files above it set.
+func formatFile(p string, src []byte) string {
+	opts := workspace.ResolveEditorConfig(p).FormatOptions(defaultFormatOptions)
+	return analyzer.Format(css.Parse(src).Stylesheet, src, opts)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestFormattingEdits(t *testing.T) {
+	dir := t.TempDir()
+	const sheet = ".a {\n  color: red;\n}\n\n.b {\n  color:blue\n}\n"
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, nil, folder(dir, "app"))
+	openDiagnostics(t, h, uri, sheet)
+
+	edits, err := h.Formatting(context.Background(), &protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+		Options:      protocol.FormattingOptions{TabSize: 2, InsertSpaces: true},
+	})
+	if err != nil || len(edits) != 2 {
+		t.Fatalf("formatting edits = %+v, %v", edits, err)
+	}
+	at := position(sheet, "blue")
+	if e := edits[0]; e.Range.Start != at || e.Range.End != at || e.NewText != " " {
+		t.Errorf("first edit = %+v", e)
+	}
+	at.Character += 4
+	if e := edits[1]; e.Range.Start != at || e.Range.End != at || e.NewText != ";" {
+		t.Errorf("second edit = %+v", e)
+	}
+}
+
+func TestRangeAndOnTypeFormatting(t *testing.T) {
+	dir := t.TempDir()
+	const sheet = `.a{color:red}
+@media print{.b{color:blue}}
+`
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, nil, folder(dir, "app"))
+	openDiagnostics(t, h, uri, sheet)
+	ctx := context.Background()
+	doc := protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)}
+	opts := protocol.FormattingOptions{TabSize: 2, InsertSpaces: true}
+
+	edits, err := h.RangeFormatting(ctx, &protocol.DocumentRangeFormattingParams{
+		TextDocument: doc,
+		Range: protocol.Range{
+			Start: position(sheet, "blue"), End: position(sheet, "blue"),
+		},
+		Options: opts,
+	})
+	if err != nil || len(edits) != 1 {
+		t.Fatalf("range edits = %+v, %v", edits, err)
+	}
+	if e := edits[0]; e.Range.Start != position(sheet, ".b") ||
+		e.NewText != "\n  .b {\n    color: blue;\n  }\n" {
+		t.Errorf("range edit = %+v", e)
+	}
+
+	end := position(sheet, "}\n@media")
+	end.Character++
+	edits, err = h.OnTypeFormatting(ctx, &protocol.DocumentOnTypeFormattingParams{
+		TextDocument: doc, Position: end, Ch: "}", Options: opts,
+	})
+	if err != nil || len(edits) != 1 ||
+		edits[0].NewText != ".a {\n  color: red;\n}\n" {
+		t.Errorf("on-type edits = %+v, %v", edits, err)
+	}
+}
+
+func TestDeclarationOrder(t *testing.T) {
+	dir := t.TempDir()
+	const sheet = ".a {\n  margin: 0px;\n  color: red;\n}\n.b {\n  padding: 0px;\n}\n"
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, map[string]any{
+		"declarationOrder":       "alphabetical",
+		"outOfOrderDeclarations": "warning",
+	}, folder(dir, "app"))
+
+	diags := openDiagnostics(t, h, uri, sheet)
+	if !hasMessage(diags, "property 'color' should come before 'margin'") {
+		t.Errorf("diagnostics = %v", diags)
+	}
+
+	doc := protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)}
+	actions, err := h.CodeAction(context.Background(), &protocol.CodeActionParams{
+		TextDocument: doc,
+		Context: protocol.CodeActionContext{
+			Only: []protocol.CodeActionKind{"source.fixAll"},
+		},
+	})
+	if err != nil || len(actions) != 1 {
+		t.Fatalf("fix-all actions = %+v, %v", actions, err)
+	}
+	// The unit inside the sorted rule is left for the next run.
+	edits := actions[0].Edit.Changes[doc.URI]
+	if len(edits) != 2 || edits[0].NewText != "0" ||
+		edits[1].NewText != "color: red;\n  margin: 0px;" {
+		t.Errorf("fix-all edits = %+v", edits)
+	}
+}
+
+func TestValueNormalization(t *testing.T) {
+	dir := t.TempDir()
+	const sheet = ".a {\n  color: #AABBCC;\n}\n\n.b {\n  margin: .50PX 0EM;\n}\n"
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, map[string]any{
+		"hexCase":           "lower",
+		"hexLength":         "short",
+		"leadingZero":       "add",
+		"trimTrailingZeros": true,
+		"removeZeroUnits":   true,
+		"lowercaseUnits":    true,
+	}, folder(dir, "app"))
+	openDiagnostics(t, h, uri, sheet)
+
+	edits, err := h.Formatting(context.Background(), &protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+		Options:      protocol.FormattingOptions{TabSize: 2, InsertSpaces: true},
+	})
+	if err != nil || len(edits) != 3 {
+		t.Fatalf("formatting edits = %+v, %v", edits, err)
+	}
+	// ".50PX 0EM" keeps its ".5" and becomes "0.5px 0".
+	want := []string{"abc", "0", "px 0"}
+	for i, e := range edits {
+		if e.NewText != want[i] {
+			t.Errorf("edit %d = %+v, want %q", i, e, want[i])
+		}
+	}
+}
+
+func TestSelectorFormatting(t *testing.T) {
+	dir := t.TempDir()
+	const sheet = "a>b:BEFORE, [type=text] {\n  color: red;\n}\n"
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, map[string]any{
+		"formatMode":          "compact",
+		"selectorPerLine":     true,
+		"attributeQuotes":     "single",
+		"lowercasePseudo":     true,
+		"pseudoElementColons": "double",
+	}, folder(dir, "app"))
+	openDiagnostics(t, h, uri, sheet)
+
+	edits, err := h.Formatting(context.Background(), &protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+		Options:      protocol.FormattingOptions{TabSize: 2, InsertSpaces: true},
+	})
+	if err != nil || len(edits) == 0 {
+		t.Fatalf("formatting edits = %+v, %v", edits, err)
+	}
+	got := applyEdits(sheet, edits)
+	const want = "a > b::before,\n[type='text'] { color: red; }\n"
+	if got != want {
+		t.Errorf("formatted = %q, want %q", got, want)
+	}
+}
+
+// applyEdits applies ordered edits to ASCII content.
+func applyEdits(content string, edits []protocol.TextEdit) string {
+	offset := func(p protocol.Position) int {
+		i := 0
+		for range p.Line {
+			i += strings.IndexByte(content[i:], '\n') + 1
+		}
+		return i + int(p.Character)
+	}
+	var b strings.Builder
+	pos := 0
+	for _, e := range edits {
+		b.WriteString(content[pos:offset(e.Range.Start)])
+		b.WriteString(e.NewText)
+		pos = offset(e.Range.End)
+	}
+	b.WriteString(content[pos:])
+	return b.String()
+}
+
+func TestEditorConfigFormatting(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".editorconfig",
+		"root = true\n\n[*.css]\nindent_style = tab\nend_of_line = crlf\n")
+	const sheet = ".a {\n  color: red;\n}\n"
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, nil, folder(dir, "app"))
+	openDiagnostics(t, h, uri, sheet)
+
+	format := func() string {
+		params := &protocol.DocumentFormattingParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+			Options:      protocol.FormattingOptions{TabSize: 2, InsertSpaces: true},
+		}
+		edits, err := h.Formatting(context.Background(), params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return applyEdits(sheet, edits)
+	}
+	if got, want := format(), ".a {\r\n\tcolor: red;\r\n}\r\n"; got != want {
+		t.Errorf("formatted = %q, want %q", got, want)
+	}
+
+	// The parsed file is cached until a watch event reports it
+	// changed.
+	config := writeFile(t, dir, ".editorconfig",
+		"root = true\n\n[*.css]\nindent_style = space\nindent_size = 4\n")
+	if got, want := format(), ".a {\r\n\tcolor: red;\r\n}\r\n"; got != want {
+		t.Errorf("formatted before the watch event = %q, want %q", got, want)
+	}
+	h.DidChangeWatchedFiles(context.Background(), []protocol.FileEvent{
+		{URI: protocol.DocumentURI(config), Type: protocol.FileChangeTypeChanged},
+	})
+	if got, want := format(), ".a {\n    color: red;\n}\n"; got != want {
+		t.Errorf("formatted after the watch event = %q, want %q", got, want)
+	}
+
+	h = newMultiRootHandler(t, map[string]any{"editorConfig": false}, folder(dir, "app"))
+	openDiagnostics(t, h, uri, sheet)
+	if got := format(); got != sheet {
+		t.Errorf("formatted without .editorconfig = %q", got)
+	}
+}
+
+func TestFormatFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".editorconfig",
+		"root = true\n\n[*.css]\nindent_style = tab\nend_of_line = crlf\n")
+	writeFile(t, dir, "a.css", ".a{color:red}")
+	writeFile(t, dir, "b.css", ".b {\r\n\tcolor: blue;\r\n}\r\n")
+	paths := []string{filepath.Join(dir, "a.css"), filepath.Join(dir, "b.css")}
+
+	var out strings.Builder
+	if err := formatFiles(&out, paths, false); err != nil {
+		t.Fatal(err)
+	}
+	want := ".a {\r\n\tcolor: red;\r\n}\r\n.b {\r\n\tcolor: blue;\r\n}\r\n"
+	if out.String() != want {
+		t.Errorf("formatted = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := formatFiles(&out, paths, true); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("-w wrote %q to standard output", out.String())
+	}
+	got, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ".a {\r\n\tcolor: red;\r\n}\r\n"; string(got) != want {
+		t.Errorf("a.css = %q, want %q", got, want)
+	}
+
+	missing := []string{filepath.Join(dir, "none.css")}
+	if err := formatFiles(&out, missing, false); err == nil {
+		t.Error("formatting a missing file should fail")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// position returns the UTF-16 position of the first occurrence of
+// substr in content.
+func position(content, substr string) protocol.Position {
+	before := content[:strings.Index(content, substr)]
+	line := strings.Count(before, "\n")
+	col := before[strings.LastIndex(before, "\n")+1:]
+	return protocol.Position{
+		Line:      uint32(line),             //nolint:gosec
+		Character: uint32(len([]rune(col))), //nolint:gosec
+	}
+}
+
+func TestHTMLDocument(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "tokens.html", "<style>:root { --brand: red; }</style>")
+	const page = `<html>
+<head>
+  <style>
+    a { color: var(--brand); background: var(--missing); }
+  </style>
+</head>
+<body>
+  <p>é</p><p style="colr: blue"></p><p style=""></p>
+</body>
+</html>
+`
+	uri := writeFile(t, dir, "page.html", page)
+	h := newMultiRootHandler(t, warnUndefined, folder(dir, "test"))
+	ctx := context.Background()
+	doc := protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)}
+
+	diags := openDiagnostics(t, h, uri, page)
+	if !hasMessage(diags, "'--missing'") || !hasMessage(diags, "'colr'") ||
+		hasMessage(diags, "'--brand'") || hasMessage(diags, "empty ruleset") {
+		t.Errorf("diagnostics = %v", diags)
+	}
+	for _, d := range diags {
+		if strings.Contains(d.Message, "'colr'") {
+			if want := position(page, "colr"); d.Range.Start != want {
+				t.Errorf("colr at %v, want %v", d.Range.Start, want)
+			}
+		}
+	}
+
+	list, err := h.Completion(ctx, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: doc, Position: position(page, "<body>"),
+		},
+	})
+	if err != nil || list != nil {
+		t.Errorf("completion outside CSS = %v, %v", list, err)
+	}
+	list, err = h.Completion(ctx, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: doc, Position: position(page, "colr"),
+		},
+	})
+	if err != nil || list == nil || len(list.Items) == 0 {
+		t.Errorf("completion in style attribute = %v, %v", list, err)
+	}
+
+	hover, err := h.Hover(ctx, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: doc, Position: position(page, "background"),
+		},
+	})
+	if err != nil || hover == nil {
+		t.Errorf("hover = %v, %v", hover, err)
+	}
+
+	colors, err := h.DocumentColor(ctx, &protocol.DocumentColorParams{
+		TextDocument: doc,
+	})
+	if err != nil || len(colors) != 2 {
+		t.Errorf("colors = %+v, %v", colors, err)
+	}
+
+	edits, err := h.Formatting(ctx, &protocol.DocumentFormattingParams{
+		TextDocument: doc,
+		Options:      protocol.FormattingOptions{TabSize: 2, InsertSpaces: true},
+	})
+	if err != nil || len(edits) != 1 {
+		t.Fatalf("formatting edits = %+v, %v", edits, err)
+	}
+	if !strings.HasPrefix(edits[0].NewText, "\n    a {\n      color: var(--brand);") {
+		t.Errorf("formatted style element = %q", edits[0].NewText)
+	}
+}
+
+func TestMarkupNames(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "index.html", `<main id="app" class="shell"></main>`)
+	writeFile(t, dir, "Card.tsx", `export const Card = () => <div className="card" />;`)
+	const sheet = ".card { } .shell .stale { } #app, #gone { }\n."
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, map[string]any{
+		"unusedSelectors": "warning",
+	}, folder(dir, "app"))
+
+	diags := openDiagnostics(t, h, uri, sheet)
+	if !hasMessage(diags, "class 'stale'") || !hasMessage(diags, "id 'gone'") ||
+		hasMessage(diags, "'card'") || hasMessage(diags, "'shell'") ||
+		hasMessage(diags, "'app'") {
+		t.Errorf("diagnostics = %v", diags)
+	}
+
+	list, err := h.Completion(context.Background(), &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: protocol.DocumentURI(uri),
+			},
+			Position: protocol.Position{Line: 1, Character: 1},
+		},
+	})
+	if err != nil || list == nil {
+		t.Fatalf("completion = %v, %v", list, err)
+	}
+	var labels []string
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+	}
+	if strings.Join(labels, ",") != "card,shell" {
+		t.Errorf("class completions = %q", labels)
+	}
+
+	// Unused selectors are only reported when asked for.
+	h = newTestHandler(t, dir)
+	if diags := openDiagnostics(t, h, uri, sheet); hasMessage(diags, "'stale'") {
+		t.Errorf("diagnostics by default = %v", diags)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css"
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/css-lsp/internal/lineindex"
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+// ResolveImport implements analyzer.ImportIndex. The imports of
+// documents without a path, which can't be resolved, are taken to
+// be found.
+func (f fileIndex) ResolveImport(target string) (string, bool) {
+	if f.path == "" {
+		return "", true
+	}
+	return f.root.resolver.Resolve(f.path, target)
+}
+
+// ImportCycle implements analyzer.ImportIndex.
+func (f fileIndex) ImportCycle(path string) []string {
+	chain := f.root.importGraph().Chain(path, f.path)
+	if chain == nil {
+		return nil
+	}
+	return append([]string{f.path}, chain...)
+}
+
+// ResolveURL implements analyzer.URLIndex. As with imports, the
+// url() references of documents without a path are taken to be
+// found, as are paths starting with "/" when neither a workspace
+// folder nor an asset root says where they start.
+func (f fileIndex) ResolveURL(target string) (string, bool) {
+	if f.path == "" ||
+		strings.HasPrefix(target, "/") && !f.root.resolver.HasURLRoot() {
+		return "", true
+	}
+	return f.root.resolver.ResolveURL(f.path, target)
+}
+
+// missingFileFixes returns quick fixes for the diagnostics of
+// @import and url() links in the document at uri that name no
+// file, each replacing the target with the path of the existing
+// file whose name is closest, written in the same form.
+func (h *cssHandler) missingFileFixes(
+	uri string,
+	ss *parser.Stylesheet,
+	src []byte,
+	ix *lineindex.Index,
+	diags []protocol.Diagnostic,
+) []protocol.CodeAction {
+	from := pathutil.URIToFilePath(uri)
+	if from == "" {
+		return nil
+	}
+	root := h.rootFor(uri)
+	links := css.DocumentLinks(ss, src)
+
+	var actions []protocol.CodeAction
+	for _, d := range diags {
+		if !strings.HasPrefix(d.Message, analyzer.UnresolvedImportPrefix) &&
+			!strings.HasPrefix(d.Message, analyzer.MissingFilePrefix) {
+			continue
+		}
+		line, char := h.protocolPositionToLineChar(ix, d.Range.Start)
+		start := css.LineCharToOffset(src, line, char)
+		i := slices.IndexFunc(links, func(l analyzer.DocumentLink) bool {
+			return l.StartPos == start && l.TargetStart >= 0
+		})
+		if i < 0 {
+			continue
+		}
+		link := links[i]
+		path, ok := root.resolver.ClosestFile(from, link.Target, link.Import)
+		if !ok {
+			continue
+		}
+		target, ok := root.resolver.RewriteLink(from, link.Target, path, path)
+		if !ok {
+			continue
+		}
+		actions = append(actions, protocol.CodeAction{
+			Title:       "Change to '" + target + "'",
+			Kind:        protocol.QuickFix,
+			Diagnostics: []protocol.Diagnostic{d},
+			Edit: &protocol.WorkspaceEdit{
+				Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+					protocol.DocumentURI(uri): {{
+						Range: h.offsetRangeToProtocolRange(
+							ix, link.TargetStart, link.TargetEnd,
+						),
+						NewText: target,
+					}},
+				},
+			},
+		})
+	}
+	return actions
+}
+
+// pathCompletions returns the files and folders that can follow
+// the directory of the url() or @import path being typed in the
+// document at uri, each replacing the segment the cursor is in.
+// Folders come first and end in "/", so that typing on descends
+// into them.
+func (h *cssHandler) pathCompletions(
+	root *workspaceRoot,
+	uri string,
+	ix *lineindex.Index,
+	pc analyzer.PathCompletion,
+) []protocol.CompletionItem {
+	from := pathutil.URIToFilePath(uri)
+	if from == "" && !strings.HasPrefix(pc.Dir, "/") {
+		return nil
+	}
+	rng := h.offsetRangeToProtocolRange(ix, pc.Start, pc.End)
+	entries := root.resolver.PathEntries(from, pc.Dir, pc.Import)
+	items := make([]protocol.CompletionItem, 0, len(entries))
+	for _, e := range entries {
+		item := protocol.CompletionItem{
+			Label:      e.Name,
+			Kind:       protocol.CompletionItemKindFile,
+			FilterText: e.Name,
+			SortText:   "1" + e.Name,
+		}
+		if e.Dir {
+			item.Label += "/"
+			item.Kind = protocol.CompletionItemKindFolder
+			item.SortText = "0" + e.Name
+		}
+		item.TextEdit = &protocol.TextEdit{Range: rng, NewText: item.Label}
+		items = append(items, item)
+	}
+	return items
+}
+
+// --- server.DocumentLinkHandler ---
+
+func (h *cssHandler) DocumentLink(
+	_ context.Context,
+	params *protocol.DocumentLinkParams,
+) ([]protocol.DocumentLink, error) { //nolint:unparam // interface
+	uri := string(params.TextDocument.URI)
+	src := h.getRawFile(uri)
+	if src == nil {
+		return nil, nil
+	}
+	ss := h.getParsedFile(uri)
+	if ss == nil {
+		result := css.Parse(src)
+		ss = result.Stylesheet
+	}
+	ix := h.getLineIndex(uri, src)
+	root := h.rootFor(uri)
+	from := pathutil.URIToFilePath(uri)
+
+	var links []protocol.DocumentLink
+	for _, link := range css.DocumentLinks(ss, src) {
+		target, ok := linkTarget(root, from, link)
+		if !ok {
+			continue
+		}
+		links = append(links, protocol.DocumentLink{
+			Range:  h.offsetRangeToProtocolRange(ix, link.StartPos, link.EndPos),
+			Target: protocol.DocumentURI(target),
+		})
+	}
+	return links, nil
+}
+
+// linkTarget returns the URI a link in the file at from opens.
+// Imports are resolved as @import loads them, and other paths
+// relative to the file or the asset directories or, starting with
+// "/", to the asset directories and the root; links to files that
+// don't exist are dropped, as are data: URLs.
+func linkTarget(
+	root *workspaceRoot,
+	from string,
+	link analyzer.DocumentLink,
+) (string, bool) {
+	target := link.Target
+	switch {
+	case strings.HasPrefix(target, "//"):
+		return "https:" + target, true
+	case analyzer.IsRemoteURL(target):
+		return target, !strings.HasPrefix(strings.ToLower(target), "data:")
+	case from == "":
+		return "", false
+	}
+	path, ok := linkPath(root, from, link)
+	if !ok {
+		return "", false
+	}
+	return pathutil.FilePathToURI(path), true
+}
+
+// linkPath returns the path of the file a local link in the file
+// at from names, if it exists.
+func linkPath(
+	root *workspaceRoot,
+	from string,
+	link analyzer.DocumentLink,
+) (string, bool) {
+	if link.Import {
+		return root.resolver.Resolve(from, link.Target)
+	}
+	return root.resolver.ResolveURL(from, link.Target)
+}
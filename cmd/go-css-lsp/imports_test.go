@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+func TestImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "src/tokens.css", `@import "./main.css"; :root { --brand: red; }`)
+	writeFile(t, dir, "src/unrelated.css", ":root { --stray: blue; }")
+	writeFile(t, dir, "node_modules/kit/package.json", `{"style": "kit.css"}`)
+	kitURI := writeFile(t, dir, "node_modules/kit/kit.css", "")
+	writeFile(t, dir, "img/logo.png", "")
+	const main = `@import "@/tokens.css";
+@import "kit";
+@import "./missing.css";
+a { color: var(--brand); background: var(--stray) url(/img/logo.png); }
+`
+	uri := writeFile(t, dir, "src/main.css", main)
+	h := newMultiRootHandler(t, map[string]any{
+		"importAliases":      map[string]any{"@/": "src/"},
+		"variableScope":      "imports",
+		"undefinedVariables": "warning",
+	}, folder(dir, "app"))
+
+	diags := openDiagnostics(t, h, uri, main)
+	if !hasMessage(diags, "cannot resolve import './missing.css'") ||
+		!hasMessage(diags, "import cycle: main.css -> tokens.css -> main.css") ||
+		hasMessage(diags, "'kit'") || hasMessage(diags, "'--brand'") ||
+		!hasMessage(diags, "'--stray'") {
+		t.Errorf("diagnostics = %v", diags)
+	}
+
+	links, err := h.DocumentLink(context.Background(), &protocol.DocumentLinkParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+	})
+	if err != nil || len(links) != 3 {
+		t.Fatalf("links = %+v, %v", links, err)
+	}
+	if string(links[1].Target) != kitURI ||
+		links[0].Range.Start != position(main, `"@/tokens.css"`) {
+		t.Errorf("links = %+v", links)
+	}
+}
+
+func TestMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "public/logo.png", "")
+	writeFile(t, dir, "src/img/icon.svg", "")
+	writeFile(t, dir, "src/theme.css", "")
+	const main = `@import "./them.css";
+a { background: url(/logo.png) url("img/icno.svg") url(#clip); }
+`
+	uri := writeFile(t, dir, "src/main.css", main)
+	h := newMultiRootHandler(t, map[string]any{
+		"assetRoots":   []any{"public"},
+		"missingFiles": "warning",
+	}, folder(dir, "app"))
+	ctx := context.Background()
+	doc := protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)}
+
+	diags := openDiagnostics(t, h, uri, main)
+	if len(diags) != 2 || !hasMessage(diags, "cannot find file 'img/icno.svg'") ||
+		!hasMessage(diags, "cannot resolve import './them.css'") {
+		t.Fatalf("diagnostics = %v", diags)
+	}
+
+	actions, err := h.CodeAction(ctx, &protocol.CodeActionParams{
+		TextDocument: doc,
+		Range:        diags[0].Range,
+		Context:      protocol.CodeActionContext{Diagnostics: diags},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Change to './theme.css'":  `"./them.css"`,
+		"Change to 'img/icon.svg'": `"img/icno.svg"`,
+	}
+	for _, a := range actions {
+		written, ok := want[a.Title]
+		if !ok {
+			continue
+		}
+		delete(want, a.Title)
+		start := position(main, written)
+		start.Character++
+		edits := a.Edit.Changes[protocol.DocumentURI(uri)]
+		if len(edits) != 1 || edits[0].Range.Start != start {
+			t.Errorf("%s edits = %+v", a.Title, edits)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("missing fixes %v in %+v", want, actions)
+	}
+
+	links, err := h.DocumentLink(ctx, &protocol.DocumentLinkParams{TextDocument: doc})
+	if err != nil || len(links) != 1 ||
+		string(links[0].Target) != pathutil.FilePathToURI(
+			filepath.Join(dir, "public", "logo.png")) {
+		t.Errorf("links = %+v, %v", links, err)
+	}
+}
+
+func TestMissingFilesOutsideFolders(t *testing.T) {
+	dir := t.TempDir()
+	const sheet = `a { background: url(/logo.png) url(img/none.svg); }`
+	uri := writeFile(t, dir, "app.css", sheet)
+	h := newMultiRootHandler(t, map[string]any{"missingFiles": "warning"})
+
+	// Without a folder or asset root, "/" has no meaning to check
+	// against.
+	diags := openDiagnostics(t, h, uri, sheet)
+	if len(diags) != 1 || !hasMessage(diags, "cannot find file 'img/none.svg'") {
+		t.Errorf("diagnostics = %v", diags)
+	}
+
+	h = newMultiRootHandler(t, map[string]any{
+		"assetRoots":   []any{filepath.Join(dir, "public")},
+		"missingFiles": "warning",
+	})
+	diags = openDiagnostics(t, h, uri, sheet)
+	if len(diags) != 2 || !hasMessage(diags, "cannot find file '/logo.png'") {
+		t.Errorf("with an asset root, diagnostics = %v", diags)
+	}
+}
+
+func TestPathCompletion(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "src/img/logo.png", "")
+	writeFile(t, dir, "src/theme.css", "")
+	writeFile(t, dir, "public/fonts/a.woff2", "")
+	const main = `@import "./th";
+a { background: url(img/lo.png); }
+`
+	uri := writeFile(t, dir, "src/main.css", main)
+	h := newMultiRootHandler(t, map[string]any{
+		"assetRoots": []any{"public"},
+	}, folder(dir, "app"))
+	openDiagnostics(t, h, uri, main)
+
+	complete := func(pos protocol.Position) []protocol.CompletionItem {
+		t.Helper()
+		list, err := h.Completion(context.Background(), &protocol.CompletionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{
+					URI: protocol.DocumentURI(uri),
+				},
+				Position: pos,
+			},
+		})
+		if err != nil || list == nil {
+			t.Fatalf("completion = %v, %v", list, err)
+		}
+		return list.Items
+	}
+
+	pos := position(main, `th"`)
+	pos.Character += 2
+	items := complete(pos)
+	if len(items) != 2 || items[0].Label != "img/" || items[1].Label != "theme.css" ||
+		items[1].TextEdit.Range.Start.Character != pos.Character-2 {
+		t.Errorf("import items = %+v", items)
+	}
+
+	pos = position(main, "lo.png")
+	pos.Character++
+	items = complete(pos)
+	if len(items) != 1 || items[0].Label != "logo.png" ||
+		items[0].Kind != protocol.CompletionItemKindFile {
+		t.Fatalf("url items = %+v", items)
+	}
+	if r := items[0].TextEdit.Range; r.Start.Character != pos.Character-1 ||
+		r.End.Character != pos.Character+5 {
+		t.Errorf("url edit range = %+v", r)
+	}
+
+	pos = position(main, "img/")
+	items = complete(pos)
+	if len(items) != 2 || items[0].Label != "fonts/" || items[1].Label != "img/" {
+		t.Errorf("url items at the first segment = %+v", items)
+	}
+}
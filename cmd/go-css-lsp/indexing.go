@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/toba/css-lsp/internal/css"
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/embedded"
+	"github.com/toba/css-lsp/internal/css/workspace"
+	"github.com/toba/css-lsp/internal/server"
+	"github.com/toba/lsp/pathutil"
+)
+
+// indexingTitle is the title of indexing progress reports.
+const indexingTitle = "Indexing CSS"
+
+// --- server.BackgroundHandler ---
+
+// Initialized starts indexing the workspace folders the client
+// opened with. bg is nil when the handler runs outside a server,
+// in which case indexing happens synchronously.
+func (h *cssHandler) Initialized(bg *server.Background) {
+	h.mu.Lock()
+	h.bg = bg
+	roots := append([]*workspaceRoot(nil), h.roots...)
+	h.mu.Unlock()
+	h.indexRoots(roots)
+}
+
+// indexRoots indexes roots in the background, reporting progress
+// to the client. Features keep working on the files indexed so far
+// in the meantime.
+func (h *cssHandler) indexRoots(roots []*workspaceRoot) {
+	if len(roots) == 0 {
+		return
+	}
+	h.indexing.Add(1)
+
+	h.mu.RLock()
+	bg := h.bg
+	h.mu.RUnlock()
+	if bg == nil {
+		h.scanRoots(context.Background(), nil, roots)
+		return
+	}
+	go func() {
+		ctx, work := bg.StartProgress(bg.Context(), indexingTitle)
+		h.scanRoots(ctx, work, roots)
+		bg.WorkspaceChanged()
+	}()
+}
+
+// scanRoots scans each root's files into its index, skipping open
+// documents, whose editor content takes precedence. It stops early
+// when ctx is cancelled.
+func (h *cssHandler) scanRoots(
+	ctx context.Context,
+	work *server.WorkDone,
+	roots []*workspaceRoot,
+) {
+	defer h.indexing.Add(-1)
+
+	for _, r := range roots {
+		var cache *workspace.Cache
+		if r.cacheDir != "" {
+			cache = workspace.OpenCache(r.cacheDir, r.path)
+		}
+		var percentage uint32
+		err := r.index.Scan(ctx, r.filter, workspace.ScanConfig{
+			Cache: cache,
+			Skip: func(uri string) bool {
+				return h.isOpen(uri)
+			},
+			Progress: func(done, total int) {
+				p := uint32(done * 100 / total) //nolint:gosec
+				if work != nil && p != percentage {
+					percentage = p
+					work.Report(fmt.Sprintf("%s: %d/%d files", r.name, done, total), p)
+				}
+			},
+		})
+		if cache != nil {
+			if err := cache.Save(); err != nil {
+				slog.Warn("saving index cache failed", "folder", r.path, "error", err)
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			slog.Warn("indexing workspace folder failed", "folder", r.path, "error", err)
+		}
+	}
+
+	// A document opened while a file was being read may have been
+	// overwritten with its content on disk.
+	h.reindexOpenDocuments()
+	h.mu.Lock()
+	h.versionBase++
+	h.mu.Unlock()
+
+	if work != nil {
+		work.End("")
+	}
+}
+
+// lint returns the diagnostics for a parsed file. Undefined
+// variables and unused selectors are only reported once indexing
+// has finished, since the definitions and markup that use them may
+// not have been found yet; the other checks run against the index
+// as it stands. For HTML documents, doc is the extraction src comes
+// from, and only diagnostics within its regions are kept.
+func (h *cssHandler) lint(
+	uri string,
+	result *css.ParseResult,
+	src []byte,
+	doc *embedded.Document,
+) []analyzer.Diagnostic {
+	root := h.rootFor(uri)
+	opts := root.lintOpts
+	opts.CSSModules = root.isModule(uri)
+	if h.indexing.Load() > 0 {
+		opts.UndefinedVariables = analyzer.UndefinedVariableIgnore
+		opts.UnusedSelectors = analyzer.UnusedSelectorIgnore
+	}
+	diags := css.ParsedDiagnostics(result, src, opts, h.fileIndex(root, uri).indexes())
+	if doc != nil {
+		diags = slices.DeleteFunc(diags, func(d analyzer.Diagnostic) bool {
+			return !doc.Contains(css.LineCharToOffset(src, d.StartLine, d.StartChar))
+		})
+	}
+	return diags
+}
+
+// fileIndex is the index the file at uri is analyzed with: the
+// root's index, answering for that file the questions that depend
+// on where it is, such as what its imports load.
+type fileIndex struct {
+	*workspace.Index
+	h    *cssHandler
+	root *workspaceRoot
+	uri  string
+	path string
+	// bundle, if set, holds the paths of the stylesheets loaded
+	// with the file, the only ones whose custom properties apply.
+	bundle map[string]bool
+}
+
+// fileIndex returns the index to analyze the file at uri with.
+func (h *cssHandler) fileIndex(root *workspaceRoot, uri string) fileIndex {
+	f := fileIndex{
+		Index: root.index,
+		h:     h,
+		root:  root,
+		uri:   uri,
+		path:  pathutil.URIToFilePath(uri),
+	}
+	if root.settings.VariableScope == variableScopeImports && f.path != "" {
+		f.bundle = root.importGraph().Bundle(f.path)
+	}
+	return f
+}
+
+// indexes returns the indexes Analyze checks the file against,
+// all answered by f.
+func (f fileIndex) indexes() analyzer.Indexes {
+	return analyzer.Indexes{
+		Variables: f,
+		Resolver:  f,
+		Markup:    f,
+		Modules:   f,
+		Imports:   f,
+		URLs:      f,
+	}
+}
+
+// HasVariable implements analyzer.VariableIndex.
+func (f fileIndex) HasVariable(name string) bool {
+	if f.bundle == nil {
+		return f.Index.HasVariable(name)
+	}
+	return len(f.LookupDefinitions(name)) > 0
+}
+
+// LookupDefinitions returns the definitions of the custom property
+// that apply to the file.
+func (f fileIndex) LookupDefinitions(name string) []workspace.VariableDefinition {
+	defs := f.Index.LookupDefinitions(name)
+	if f.bundle == nil {
+		return defs
+	}
+	return slices.DeleteFunc(defs, func(d workspace.VariableDefinition) bool {
+		return !f.bundle[pathutil.URIToFilePath(d.URI)]
+	})
+}
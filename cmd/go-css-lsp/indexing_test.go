@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+func TestIndexingDefersUndefinedVariables(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "tokens.css", ":root { --brand: red; }")
+	uri := writeFile(t, dir, "app.css", "")
+
+	h := newCSSHandler()
+	_, err := h.Initialize(context.Background(), &protocol.InitializeParams{
+		InitializationOptions: warnUndefined,
+		WorkspaceFolders: []protocol.WorkspaceFolder{{
+			URI: string(pathutil.FilePathToURI(dir)), Name: "test",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.varIndex.HasVariable("--brand") {
+		t.Fatal("folders should not be indexed before the client is initialized")
+	}
+
+	// While indexing runs, a variable defined in a file that
+	// hasn't been reached yet must not be reported, though checks
+	// that don't depend on the whole workspace still run.
+	h.indexing.Add(1)
+	const src = `@import "./missing.css";
+a { color: var(--brand); background: var(--missing); }`
+	diags := openDiagnostics(t, h, uri, src)
+	if hasMessage(diags, "undefined custom property") ||
+		!hasMessage(diags, "cannot resolve import './missing.css'") {
+		t.Errorf("diagnostics while indexing = %v", diags)
+	}
+	h.indexing.Add(-1)
+
+	h.Initialized(nil)
+	diags = openDiagnostics(t, h, uri, src)
+	if hasMessage(diags, "'--brand'") || !hasMessage(diags, "'--missing'") {
+		t.Errorf("diagnostics after indexing = %v", diags)
+	}
+}
+
+func TestIsOpenEscapedURIs(t *testing.T) {
+	h := newCSSHandler()
+	h.DidOpen(context.Background(), "file:///my%20dir/logo%402x.css", "a { }")
+
+	// Scans and watch events name files as the index does, which
+	// escapes less than clients.
+	for _, uri := range []string{
+		"file:///my%20dir/logo@2x.css",
+		"file:///my dir/logo@2x.css",
+	} {
+		if !h.isOpen(uri) {
+			t.Errorf("%s is not open", uri)
+		}
+	}
+	if h.isOpen("file:///my%20dir/logo.css") {
+		t.Error("logo.css is open")
+	}
+}
+
+func TestIndexCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "tokens.css", ":root { --brand: red; }")
+
+	for _, enabled := range []bool{true, false} {
+		cacheDir := t.TempDir()
+		h := newMultiRootHandler(t,
+			map[string]any{"cacheDirectory": cacheDir, "indexCache": enabled},
+			folder(dir, "test"),
+		)
+		if !h.varIndex.HasVariable("--brand") {
+			t.Fatalf("Files() = %v", h.varIndex.Files())
+		}
+		entries, err := os.ReadDir(cacheDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(entries) == 1; got != enabled {
+			t.Errorf("indexCache %v: cache directory holds %v", enabled, entries)
+		}
+	}
+
+	// The handler keeps no cache by default outside of main.
+	h := newMultiRootHandler(t, nil, folder(dir, "test"))
+	if h.roots[0].cacheDir != "" {
+		t.Errorf("cacheDir = %q", h.roots[0].cacheDir)
+	}
+}
@@ -7,15 +7,19 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/toba/css-lsp/internal/css"
 	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/embedded"
 	"github.com/toba/css-lsp/internal/css/parser"
 	"github.com/toba/css-lsp/internal/css/workspace"
+	"github.com/toba/css-lsp/internal/lineindex"
+	"github.com/toba/css-lsp/internal/server"
 	"github.com/toba/lsp/pathutil"
-	"github.com/toba/lsp/server"
 	"go.lsp.dev/protocol"
 )
 
@@ -24,57 +28,75 @@ var version = "dev"
 
 const serverName = "go-css-lsp"
 
-// ServerSettings holds server-specific configuration from
-// initializationOptions.
-type ServerSettings struct {
-	FormatMode           string `json:"formatMode"`
-	PrintWidth           int    `json:"printWidth"`
-	ExperimentalFeatures string `json:"experimentalFeatures"`
-	DeprecatedFeatures   string `json:"deprecatedFeatures"`
-	UnknownValues        string `json:"unknownValues"`
-	StrictColorNames     bool   `json:"strictColorNames"`
-}
-
 // cssHandler implements server.Handler and optional handler
 // interfaces for the CSS language server.
 type cssHandler struct {
-	mu          sync.RWMutex
-	rootPath    string
+	mu sync.RWMutex
+	// The state of open documents is keyed by workspace.FileURI,
+	// as the workspace index is, so that files found on disk match
+	// open documents whichever characters the client escaped.
 	rawFiles    map[string][]byte
 	parsedFiles map[string]*parser.Stylesheet
+	parseErrors map[string][]*parser.Error
+	lineIndexes map[string]*lineindex.Index
+	wsDiags     map[string]workspaceDiagnostics
+	encoding    lineindex.Encoding
+
+	// embeds holds the CSS extracted from open HTML documents,
+	// whose rawFiles entry is that CSS rather than the HTML.
+	embeds map[string]*embedded.Document
+
+	// settings are the global settings. Workspace folders layer
+	// their entry in folderOptions over them.
+	settings      ServerSettings
+	folderOptions map[string]map[string]any
+
+	// roots are the workspace folders, innermost first. Files
+	// outside every folder use fallback. varIndex is shared by
+	// all roots unless variables are scoped per root.
+	roots       []*workspaceRoot
+	fallback    *workspaceRoot
 	varIndex    *workspace.Index
-	settings    *ServerSettings
-	lintOpts    analyzer.LintOptions
+	versionBase uint64
+	// cacheDir is the default directory for index caches; empty
+	// disables caching unless a root's settings name a directory.
+	cacheDir string
+	// editorConfigs caches the .editorconfig files formatting
+	// reads, until they change on disk.
+	editorConfigs workspace.EditorConfigs
+
+	// bg is set once the client is initialized; indexing counts
+	// the scans in progress.
+	bg       *server.Background
+	indexing atomic.Int32
 }
 
 func newCSSHandler() *cssHandler {
+	varIndex := workspace.NewIndex()
 	return &cssHandler{
 		rawFiles:    make(map[string][]byte),
 		parsedFiles: make(map[string]*parser.Stylesheet),
-		varIndex:    workspace.NewIndex(),
-	}
-}
-
-// modeFromString converts a setting string to a mode enum.
-func modeFromString[T ~int](s string, ignore, err, warn T) T {
-	switch s {
-	case "ignore":
-		return ignore
-	case "error":
-		return err
-	default:
-		return warn
+		parseErrors: make(map[string][]*parser.Error),
+		lineIndexes: make(map[string]*lineindex.Index),
+		wsDiags:     make(map[string]workspaceDiagnostics),
+		embeds:      make(map[string]*embedded.Document),
+		encoding:    lineindex.UTF16,
+		fallback: &workspaceRoot{
+			index:    varIndex,
+			resolver: workspace.NewResolver("", nil, nil, nil),
+		},
+		varIndex: varIndex,
 	}
 }
 
 // offsetRangeToProtocolRange converts byte offsets to a
-// protocol.Range.
-func offsetRangeToProtocolRange(
-	src []byte,
+// protocol.Range in the negotiated position encoding.
+func (h *cssHandler) offsetRangeToProtocolRange(
+	ix *lineindex.Index,
 	start, end int,
 ) protocol.Range {
-	startLine, startChar := css.OffsetToLineChar(src, start)
-	endLine, endChar := css.OffsetToLineChar(src, end)
+	startLine, startChar := ix.Position(start, h.encoding)
+	endLine, endChar := ix.Position(end, h.encoding)
 	return protocol.Range{
 		Start: protocol.Position{
 			Line:      uint32(startLine), //nolint:gosec
@@ -87,12 +109,38 @@ func offsetRangeToProtocolRange(
 	}
 }
 
+// lineCharToProtocolPosition converts a line and byte column, as
+// reported by the analyzer, to a protocol.Position in the
+// negotiated position encoding.
+func (h *cssHandler) lineCharToProtocolPosition(
+	ix *lineindex.Index,
+	line, char int,
+) protocol.Position {
+	line, char = ix.Convert(line, char, lineindex.UTF8, h.encoding)
+	return protocol.Position{
+		Line:      uint32(line), //nolint:gosec
+		Character: uint32(char), //nolint:gosec
+	}
+}
+
+// protocolPositionToLineChar converts a protocol.Position in the
+// negotiated position encoding to the line and byte column the
+// analyzer expects.
+func (h *cssHandler) protocolPositionToLineChar(
+	ix *lineindex.Index,
+	pos protocol.Position,
+) (int, int) {
+	return ix.Convert(
+		int(pos.Line), int(pos.Character), h.encoding, lineindex.UTF8,
+	)
+}
+
 // getParsedFile returns the parsed stylesheet for a URI,
 // protected by a read lock.
 func (h *cssHandler) getParsedFile(uri string) *parser.Stylesheet {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.parsedFiles[uri]
+	return h.parsedFiles[workspace.FileURI(uri)]
 }
 
 // getRawFile returns the raw source for a URI, protected by a
@@ -100,7 +148,59 @@ func (h *cssHandler) getParsedFile(uri string) *parser.Stylesheet {
 func (h *cssHandler) getRawFile(uri string) []byte {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.rawFiles[uri]
+	return h.rawFiles[workspace.FileURI(uri)]
+}
+
+// isOpen reports whether the document at uri is open, whichever
+// characters either URI escapes.
+func (h *cssHandler) isOpen(uri string) bool {
+	return h.getRawFile(uri) != nil
+}
+
+// getLineIndex returns the line index for a URI whose source is
+// src, building one when the stored index is for other content.
+func (h *cssHandler) getLineIndex(uri string, src []byte) *lineindex.Index {
+	uri = workspace.FileURI(uri)
+	h.mu.RLock()
+	ix := h.lineIndexes[uri]
+	stored := h.rawFiles[uri]
+	h.mu.RUnlock()
+	// The stored index is only reused if src is the stored source
+	// itself rather than other content. For HTML documents it was
+	// built from the HTML, whose lines and offsets the extracted
+	// CSS shares.
+	if ix == nil || len(stored) != len(src) ||
+		(len(src) > 0 && &stored[0] != &src[0]) {
+		return lineindex.New(src)
+	}
+	return ix
+}
+
+// getEmbedded returns the CSS extracted from an open HTML
+// document, or nil for stylesheets.
+func (h *cssHandler) getEmbedded(uri string) *embedded.Document {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.embeds[workspace.FileURI(uri)]
+}
+
+// evict forgets the stored state of a document.
+func (h *cssHandler) evict(uri string) {
+	uri = workspace.FileURI(uri)
+	h.mu.Lock()
+	delete(h.rawFiles, uri)
+	delete(h.parsedFiles, uri)
+	delete(h.parseErrors, uri)
+	delete(h.lineIndexes, uri)
+	delete(h.wsDiags, uri)
+	delete(h.embeds, uri)
+	h.mu.Unlock()
+}
+
+// --- server.PositionEncodingHandler ---
+
+func (h *cssHandler) SetPositionEncoding(enc lineindex.Encoding) {
+	h.encoding = enc
 }
 
 // --- server.Handler implementation ---
@@ -109,77 +209,51 @@ func (h *cssHandler) Initialize(
 	_ context.Context,
 	params *protocol.InitializeParams,
 ) (protocol.ServerCapabilities, error) {
-	if params.InitializationOptions != nil {
-		// Decode settings from initializationOptions.
-		// The options arrive as map[string]any from JSON.
-		if opts, ok := params.InitializationOptions.(map[string]any); ok {
-			h.settings = &ServerSettings{}
-			if v, ok := opts["formatMode"].(string); ok {
-				h.settings.FormatMode = v
-			}
-			if v, ok := opts["printWidth"].(float64); ok {
-				h.settings.PrintWidth = int(v)
-			}
-			if v, ok := opts["experimentalFeatures"].(string); ok {
-				h.settings.ExperimentalFeatures = v
-			}
-			if v, ok := opts["deprecatedFeatures"].(string); ok {
-				h.settings.DeprecatedFeatures = v
-			}
-			if v, ok := opts["unknownValues"].(string); ok {
-				h.settings.UnknownValues = v
+	// The options arrive as map[string]any from JSON.
+	if opts, ok := params.InitializationOptions.(map[string]any); ok {
+		decodeSettings(opts, &h.settings)
+		if folders, ok := opts["folders"].(map[string]any); ok {
+			h.folderOptions = make(map[string]map[string]any, len(folders))
+			for key, v := range folders {
+				if fo, ok := v.(map[string]any); ok {
+					h.folderOptions[key] = fo
+				}
 			}
-			if v, ok := opts["strictColorNames"].(bool); ok {
-				h.settings.StrictColorNames = v
-			}
-		}
-
-		if h.settings != nil {
-			h.lintOpts.Experimental = modeFromString(
-				h.settings.ExperimentalFeatures,
-				analyzer.ExperimentalIgnore,
-				analyzer.ExperimentalError,
-				analyzer.ExperimentalWarn,
-			)
-			h.lintOpts.Deprecated = modeFromString(
-				h.settings.DeprecatedFeatures,
-				analyzer.DeprecatedIgnore,
-				analyzer.DeprecatedError,
-				analyzer.DeprecatedWarn,
-			)
-			h.lintOpts.UnknownValues = modeFromString(
-				h.settings.UnknownValues,
-				analyzer.UnknownValueIgnore,
-				analyzer.UnknownValueError,
-				analyzer.UnknownValueWarn,
-			)
-			h.lintOpts.StrictColorNames = h.settings.StrictColorNames
 		}
 	}
-
-	// Extract root URI and scan workspace.
-	var rootURI string
-	if len(params.WorkspaceFolders) > 0 {
-		rootURI = params.WorkspaceFolders[0].URI
-	} else if params.RootURI != "" { //nolint:staticcheck // fallback for older clients
-		rootURI = string(params.RootURI) //nolint:staticcheck // fallback
-	}
-	if rootURI != "" {
-		h.rootPath = pathutil.URIToFilePath(rootURI)
-		if h.rootPath != "" {
-			_ = h.varIndex.ScanWorkspace(h.rootPath)
+	h.fallback.settings = h.settings
+	h.fallback.lintOpts = h.settings.lintOptions()
+	// Without a folder, only absolute asset roots name a directory.
+	var assets []string
+	for _, dir := range h.settings.AssetRoots {
+		if filepath.IsAbs(dir) {
+			assets = append(assets, dir)
 		}
 	}
+	h.fallback.resolver = workspace.NewResolver(
+		"", h.settings.ImportAliases, assets, nil,
+	)
+
+	// The folders are indexed in the background once the client
+	// is initialized.
+	h.addRoots(workspaceFolders(params))
 
 	return protocol.ServerCapabilities{
 		HoverProvider: true,
 		CompletionProvider: &protocol.CompletionOptions{
-			TriggerCharacters: []string{":", "@", ".", "#", "-", " "},
+			TriggerCharacters: []string{
+				":", "@", ".", "#", "-", " ", "/", `"`, "'",
+			},
+		},
+		DefinitionProvider:              true,
+		ReferencesProvider:              true,
+		RenameProvider:                  &protocol.RenameOptions{PrepareProvider: true},
+		DocumentFormattingProvider:      true,
+		DocumentRangeFormattingProvider: true,
+		DocumentOnTypeFormattingProvider: &protocol.DocumentOnTypeFormattingOptions{
+			FirstTriggerCharacter: "}",
+			MoreTriggerCharacter:  []string{";"},
 		},
-		DefinitionProvider:         true,
-		ReferencesProvider:         true,
-		RenameProvider:             &protocol.RenameOptions{PrepareProvider: true},
-		DocumentFormattingProvider: true,
 		CodeActionProvider: &protocol.CodeActionOptions{
 			CodeActionKinds: []protocol.CodeActionKind{
 				protocol.CodeActionKind(analyzer.CodeActionQuickFix),
@@ -201,35 +275,186 @@ func (h *cssHandler) Diagnostics(
 	uri protocol.DocumentURI,
 	content string,
 ) ([]protocol.Diagnostic, error) {
-	src := []byte(content)
-	diags, ss := css.Diagnostics(src, h.lintOpts)
+	// Diagnostics are debounced, so content may already be older
+	// than the state DidChange stored. Prefer the stored parse and
+	// only fall back to parsing content when there is none.
+	key := workspace.FileURI(string(uri))
+	h.mu.RLock()
+	src := h.rawFiles[key]
+	result := &css.ParseResult{
+		Stylesheet: h.parsedFiles[key],
+		Errors:     h.parseErrors[key],
+	}
+	doc := h.embeds[key]
+	h.mu.RUnlock()
 
-	h.mu.Lock()
-	h.rawFiles[string(uri)] = src
-	h.parsedFiles[string(uri)] = ss
-	h.mu.Unlock()
+	if result.Stylesheet == nil {
+		src, result, doc = h.storeDocument(string(uri), []byte(content))
+	}
 
-	h.varIndex.IndexFileWithStylesheet(string(uri), ss, src)
+	diags := h.lint(string(uri), result, src, doc)
+	return h.toProtocolDiagnostics(diags, h.getLineIndex(string(uri), src)), nil
+}
 
-	result := make([]protocol.Diagnostic, len(diags))
+// toProtocolDiagnostics converts analyzer diagnostics to
+// protocol diagnostics in the negotiated position encoding.
+func (h *cssHandler) toProtocolDiagnostics(
+	diags []analyzer.Diagnostic,
+	ix *lineindex.Index,
+) []protocol.Diagnostic {
+	out := make([]protocol.Diagnostic, len(diags))
 	for i, d := range diags {
-		result[i] = protocol.Diagnostic{
+		out[i] = protocol.Diagnostic{
 			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(d.StartLine), //nolint:gosec
-					Character: uint32(d.StartChar), //nolint:gosec
-				},
-				End: protocol.Position{
-					Line:      uint32(d.EndLine), //nolint:gosec
-					Character: uint32(d.EndChar), //nolint:gosec
-				},
+				Start: h.lineCharToProtocolPosition(ix, d.StartLine, d.StartChar),
+				End:   h.lineCharToProtocolPosition(ix, d.EndLine, d.EndChar),
 			},
 			Message:  d.Message,
 			Severity: protocol.DiagnosticSeverity(d.Severity),
 		}
 	}
+	return out
+}
 
-	return result, nil
+// storeDocument parses and stores the full content of a document.
+// For HTML documents, the CSS embedded in content is extracted and
+// returned along with the extraction.
+func (h *cssHandler) storeDocument(
+	uri string,
+	content []byte,
+) ([]byte, *css.ParseResult, *embedded.Document) {
+	if !embedded.IsHTML(uri) {
+		return content, h.storeParse(uri, content, css.Parse(content), nil), nil
+	}
+	doc := embedded.Extract(content)
+	return doc.CSS, h.storeParse(uri, doc.CSS, css.Parse(doc.CSS), doc), doc
+}
+
+// storeParse records the source and parse result for a URI and
+// reindexes it. doc is the extraction src
+// comes from for HTML documents, and nil otherwise.
+func (h *cssHandler) storeParse(
+	uri string,
+	src []byte,
+	result *css.ParseResult,
+	doc *embedded.Document,
+) *css.ParseResult {
+	host := src
+	if doc != nil {
+		host = doc.Host
+	}
+
+	key := workspace.FileURI(uri)
+	h.mu.Lock()
+	h.rawFiles[key] = src
+	h.parsedFiles[key] = result.Stylesheet
+	h.parseErrors[key] = result.Errors
+	h.lineIndexes[key] = lineindex.New(host)
+	if doc != nil {
+		h.embeds[key] = doc
+	} else {
+		delete(h.embeds, key)
+	}
+	h.mu.Unlock()
+
+	h.indexDocument(uri, result.Stylesheet, src, doc)
+	return result
+}
+
+// indexDocument indexes an open document in the index of the root
+// containing it: its custom properties and, for HTML documents
+// the root reads as markup, its class names and ids.
+func (h *cssHandler) indexDocument(
+	uri string,
+	ss *parser.Stylesheet,
+	src []byte,
+	doc *embedded.Document,
+) {
+	r := h.rootFor(uri)
+	r.index.IndexFileWithStylesheet(uri, ss, src)
+	if doc != nil && r.indexesMarkup(uri) {
+		r.index.IndexMarkup(uri, doc.Host)
+	}
+}
+
+// --- server.DocumentSyncHandler ---
+
+func (h *cssHandler) DidOpen(
+	_ context.Context,
+	uri protocol.DocumentURI,
+	content string,
+) {
+	h.storeDocument(string(uri), []byte(content))
+}
+
+// DidChange reparses only the top-level rules touched by each
+// change, starting from the stored parse of the document. HTML
+// documents are extracted and parsed again in full.
+func (h *cssHandler) DidChange(
+	_ context.Context,
+	uri protocol.DocumentURI,
+	changes []server.TextChange,
+	content string,
+) {
+	if embedded.IsHTML(string(uri)) {
+		h.storeDocument(string(uri), []byte(content))
+		return
+	}
+
+	key := workspace.FileURI(string(uri))
+	h.mu.RLock()
+	src := h.rawFiles[key]
+	result := &css.ParseResult{
+		Stylesheet: h.parsedFiles[key],
+		Errors:     h.parseErrors[key],
+	}
+	h.mu.RUnlock()
+
+	if result.Stylesheet == nil {
+		h.storeDocument(string(uri), []byte(content))
+		return
+	}
+
+	for i, c := range changes {
+		n := len(src) - (c.End - c.Start) + len(c.Text)
+		if c.Start < 0 || c.End < c.Start || c.End > len(src) ||
+			(i == len(changes)-1 && n != len(content)) {
+			result = nil
+			break
+		}
+		// Build a new buffer: the previous one may still be in
+		// use by concurrent requests. The last change leaves the
+		// content the server already built, so that is copied.
+		var next []byte
+		if i == len(changes)-1 {
+			next = []byte(content)
+		} else {
+			next = make([]byte, 0, n)
+			next = append(next, src[:c.Start]...)
+			next = append(next, c.Text...)
+			next = append(next, src[c.End:]...)
+		}
+		result = css.Reparse(result, next, parser.Edit{
+			Start:  c.Start,
+			End:    c.End,
+			NewLen: len(c.Text),
+		})
+		src = next
+	}
+
+	if result == nil || len(changes) == 0 {
+		src = []byte(content)
+		result = css.Parse(src)
+	}
+	h.storeParse(string(uri), src, result, nil)
+}
+
+// DidClose evicts the document's state. Unsaved edits are gone,
+// so its custom properties are indexed from disk again, or
+// dropped if it was never saved.
+func (h *cssHandler) DidClose(_ context.Context, uri protocol.DocumentURI) {
+	h.evict(string(uri))
+	h.reindexFromDisk(string(uri))
 }
 
 func (h *cssHandler) Shutdown(context.Context) error {
@@ -252,12 +477,13 @@ func (h *cssHandler) Hover(
 		result := css.Parse(src)
 		ss = result.Stylesheet
 	}
+	ix := h.getLineIndex(uri, src)
+	line, char := h.protocolPositionToLineChar(ix, params.Position)
 
 	hover := css.Hover(
 		ss, src,
-		int(params.Position.Line),      //nolint:gosec
-		int(params.Position.Character), //nolint:gosec
-		h.varIndex,
+		line, char,
+		h.rootFor(uri).hoverOptions(),
 	)
 
 	if !hover.Found {
@@ -271,8 +497,8 @@ func (h *cssHandler) Hover(
 		},
 	}
 	if hover.RangeStart < hover.RangeEnd {
-		r := offsetRangeToProtocolRange(
-			src, hover.RangeStart, hover.RangeEnd,
+		r := h.offsetRangeToProtocolRange(
+			ix, hover.RangeStart, hover.RangeEnd,
 		)
 		result.Range = &r
 	}
@@ -296,12 +522,29 @@ func (h *cssHandler) Completion(
 		result := css.Parse(src)
 		ss = result.Stylesheet
 	}
+	ix := h.getLineIndex(uri, src)
+	line, char := h.protocolPositionToLineChar(ix, params.Position)
+	if doc := h.getEmbedded(uri); doc != nil &&
+		!doc.Contains(css.LineCharToOffset(src, line, char)) {
+		return nil, nil
+	}
 
+	root := h.rootFor(uri)
+	if pc, ok := css.PathCompletionAt(src, line, char); ok {
+		return &protocol.CompletionList{
+			Items: h.pathCompletions(root, uri, ix, pc),
+		}, nil
+	}
+	// Quotes and slashes only start completions in paths.
+	if params.Context != nil &&
+		slices.Contains([]string{"/", `"`, "'"}, params.Context.TriggerCharacter) {
+		return nil, nil
+	}
 	items := css.Completions(
 		ss, src,
-		int(params.Position.Line),      //nolint:gosec
-		int(params.Position.Character), //nolint:gosec
-		h.lintOpts,
+		line, char,
+		root.lintOpts,
+		root.index,
 	)
 
 	lspItems := make([]protocol.CompletionItem, len(items))
@@ -350,16 +593,23 @@ func (h *cssHandler) Definition(
 		result := css.Parse(src)
 		ss = result.Stylesheet
 	}
+	ix := h.getLineIndex(uri, src)
+	line, char := h.protocolPositionToLineChar(ix, params.Position)
+
+	if h.rootFor(uri).isModule(uri) {
+		if locs, ok := h.moduleDefinition(uri, ss, src, ix, line, char); ok {
+			return locs, nil
+		}
+	}
 
 	defResult, found := css.Definition(
 		ss, src,
-		int(params.Position.Line),      //nolint:gosec
-		int(params.Position.Character), //nolint:gosec
+		line, char,
 	)
 
 	if found {
-		targetRange := offsetRangeToProtocolRange(
-			src, defResult.TargetStart, defResult.TargetEnd,
+		targetRange := h.offsetRangeToProtocolRange(
+			ix, defResult.TargetStart, defResult.TargetEnd,
 		)
 		return []protocol.Location{{
 			URI:   params.TextDocument.URI,
@@ -370,14 +620,13 @@ func (h *cssHandler) Definition(
 	// Fall back to workspace index for cross-file lookup.
 	varName, _, _ := css.VarReferenceWithRange(
 		ss, src,
-		int(params.Position.Line),      //nolint:gosec
-		int(params.Position.Character), //nolint:gosec
+		line, char,
 	)
 	if varName == "" {
 		return nil, nil
 	}
 
-	defs := h.varIndex.LookupDefinitions(varName)
+	defs := h.fileIndex(h.rootFor(uri), uri).LookupDefinitions(varName)
 	if len(defs) == 0 {
 		return nil, nil
 	}
@@ -396,8 +645,8 @@ func (h *cssHandler) Definition(
 		}
 	}
 
-	targetRange := offsetRangeToProtocolRange(
-		targetSrc, def.StartPos, def.EndPos,
+	targetRange := h.offsetRangeToProtocolRange(
+		h.getLineIndex(def.URI, targetSrc), def.StartPos, def.EndPos,
 	)
 	return []protocol.Location{{
 		URI:   protocol.DocumentURI(def.URI),
@@ -421,29 +670,148 @@ func (h *cssHandler) Formatting(
 		result := css.Parse(src)
 		ss = result.Stylesheet
 	}
+	ix := h.getLineIndex(uri, src)
 
-	fmtOpts := analyzer.FormatOptions{
-		TabSize:      int(params.Options.TabSize),
-		InsertSpaces: params.Options.InsertSpaces,
-	}
-	if h.settings != nil {
-		switch h.settings.FormatMode {
-		case "compact":
-			fmtOpts.Mode = analyzer.FormatCompact
-		case "preserve":
-			fmtOpts.Mode = analyzer.FormatPreserve
-		case "detect":
-			fmtOpts.Mode = analyzer.FormatDetect
+	fmtOpts := h.formatOptions(uri, params.Options)
+
+	if doc := h.getEmbedded(uri); doc != nil {
+		var edits []protocol.TextEdit
+		for _, e := range embedded.Format(doc, fmtOpts) {
+			edits = append(edits, protocol.TextEdit{
+				Range:   h.offsetRangeToProtocolRange(ix, e.Start, e.End),
+				NewText: e.NewText,
+			})
 		}
-		fmtOpts.PrintWidth = h.settings.PrintWidth
+		return edits, nil
 	}
 
-	formatted := css.FormatDocument(ss, src, fmtOpts)
+	var edits []protocol.TextEdit
+	for _, e := range css.FormatDocument(ss, src, fmtOpts) {
+		edits = append(edits, h.formatEdit(ix, e))
+	}
+	return edits, nil
+}
 
-	return []protocol.TextEdit{{
-		Range:   offsetRangeToProtocolRange(src, 0, len(src)),
-		NewText: formatted,
-	}}, nil
+// --- server.RangeFormattingHandler ---
+
+func (h *cssHandler) RangeFormatting(
+	_ context.Context,
+	params *protocol.DocumentRangeFormattingParams,
+) ([]protocol.TextEdit, error) { //nolint:unparam // interface
+	uri := string(params.TextDocument.URI)
+	src := h.getRawFile(uri)
+	if src == nil {
+		return nil, nil
+	}
+	ix := h.getLineIndex(uri, src)
+	startLine, startChar := h.protocolPositionToLineChar(ix, params.Range.Start)
+	endLine, endChar := h.protocolPositionToLineChar(ix, params.Range.End)
+	fmtOpts := h.formatOptions(uri, params.Options)
+
+	if doc := h.getEmbedded(uri); doc != nil {
+		return h.embeddedFormatEdits(doc, ix, fmtOpts,
+			css.LineCharToOffset(src, startLine, startChar),
+			css.LineCharToOffset(src, endLine, endChar),
+		), nil
+	}
+
+	e, ok := css.FormatRange(
+		h.parsedOrParse(uri, src), src,
+		startLine, startChar, endLine, endChar, fmtOpts,
+	)
+	if !ok {
+		return nil, nil
+	}
+	return []protocol.TextEdit{h.formatEdit(ix, e)}, nil
+}
+
+// --- server.OnTypeFormattingHandler ---
+
+func (h *cssHandler) OnTypeFormatting(
+	_ context.Context,
+	params *protocol.DocumentOnTypeFormattingParams,
+) ([]protocol.TextEdit, error) { //nolint:unparam // interface
+	uri := string(params.TextDocument.URI)
+	src := h.getRawFile(uri)
+	if src == nil || h.getEmbedded(uri) != nil {
+		return nil, nil
+	}
+	ix := h.getLineIndex(uri, src)
+	line, char := h.protocolPositionToLineChar(ix, params.Position)
+	fmtOpts := h.formatOptions(uri, params.Options)
+
+	e, ok := css.FormatOnType(
+		h.parsedOrParse(uri, src), src, line, char, params.Ch, fmtOpts,
+	)
+	if !ok {
+		return nil, nil
+	}
+	return []protocol.TextEdit{h.formatEdit(ix, e)}, nil
+}
+
+// parsedOrParse returns the parsed stylesheet of the document at
+// uri, parsing src if there is none.
+func (h *cssHandler) parsedOrParse(uri string, src []byte) *parser.Stylesheet {
+	if ss := h.getParsedFile(uri); ss != nil {
+		return ss
+	}
+	return css.Parse(src).Stylesheet
+}
+
+// formatOptions returns the formatter options for the document at
+// uri: the settings of its root and the editor's tab preferences,
+// which the .editorconfig files that apply to the document
+// override, though not a printWidth setting.
+func (h *cssHandler) formatOptions(
+	uri string,
+	editor protocol.FormattingOptions,
+) analyzer.FormatOptions {
+	settings := h.rootFor(uri).settings
+	opts := settings.formatOptions(int(editor.TabSize), editor.InsertSpaces)
+	if settings.EditorConfig != nil && !*settings.EditorConfig {
+		return opts
+	}
+	path := pathutil.URIToFilePath(uri)
+	if path == "" {
+		return opts
+	}
+	opts = h.editorConfigs.Resolve(path).FormatOptions(opts)
+	if settings.PrintWidth > 0 {
+		opts.PrintWidth = settings.PrintWidth
+	}
+	return opts
+}
+
+// formatEdit converts a formatter edit to a protocol text edit.
+func (h *cssHandler) formatEdit(
+	ix *lineindex.Index,
+	e analyzer.FormatEdit,
+) protocol.TextEdit {
+	return protocol.TextEdit{
+		Range:   h.offsetRangeToProtocolRange(ix, e.StartPos, e.EndPos),
+		NewText: e.NewText,
+	}
+}
+
+// embeddedFormatEdits formats the style elements and attributes
+// of an HTML document that the range between start and end
+// intersects.
+func (h *cssHandler) embeddedFormatEdits(
+	doc *embedded.Document,
+	ix *lineindex.Index,
+	opts analyzer.FormatOptions,
+	start, end int,
+) []protocol.TextEdit {
+	var edits []protocol.TextEdit
+	for _, e := range embedded.Format(doc, opts) {
+		if e.Start <= end && e.End >= start {
+			edits = append(edits, protocol.TextEdit{
+				Range:   h.offsetRangeToProtocolRange(ix, e.Start, e.End),
+				NewText: e.NewText,
+			})
+		}
+	}
+	return edits
 }
 
 // --- server.CodeActionHandler ---
@@ -463,15 +831,19 @@ func (h *cssHandler) CodeAction(
 		return h.fixAll(params, src), nil
 	}
 
+	ix := h.getLineIndex(uri, src)
+
 	// Convert protocol diagnostics to analyzer diagnostics.
 	var analyzerDiags []analyzer.Diagnostic
 	for _, d := range params.Context.Diagnostics {
+		startLine, startChar := h.protocolPositionToLineChar(ix, d.Range.Start)
+		endLine, endChar := h.protocolPositionToLineChar(ix, d.Range.End)
 		analyzerDiags = append(analyzerDiags, analyzer.Diagnostic{
 			Message:   d.Message,
-			StartLine: int(d.Range.Start.Line),      //nolint:gosec
-			StartChar: int(d.Range.Start.Character), //nolint:gosec
-			EndLine:   int(d.Range.End.Line),        //nolint:gosec
-			EndChar:   int(d.Range.End.Character),   //nolint:gosec
+			StartLine: startLine,
+			StartChar: startChar,
+			EndLine:   endLine,
+			EndChar:   endChar,
 			Severity:  int(d.Severity),
 		})
 	}
@@ -482,8 +854,9 @@ func (h *cssHandler) CodeAction(
 		ss = result.Stylesheet
 	}
 
-	cursorLine := int(params.Range.Start.Line)      //nolint:gosec
-	cursorChar := int(params.Range.Start.Character) //nolint:gosec
+	cursorLine, cursorChar := h.protocolPositionToLineChar(
+		ix, params.Range.Start,
+	)
 
 	actions := css.CodeActions(
 		ss, src, cursorLine, cursorChar, analyzerDiags,
@@ -498,14 +871,12 @@ func (h *cssHandler) CodeAction(
 				Changes: map[protocol.DocumentURI][]protocol.TextEdit{
 					protocol.DocumentURI(uri): {{
 						Range: protocol.Range{
-							Start: protocol.Position{
-								Line:      uint32(a.StartLine), //nolint:gosec
-								Character: uint32(a.StartChar), //nolint:gosec
-							},
-							End: protocol.Position{
-								Line:      uint32(a.EndLine), //nolint:gosec
-								Character: uint32(a.EndChar), //nolint:gosec
-							},
+							Start: h.lineCharToProtocolPosition(
+								ix, a.StartLine, a.StartChar,
+							),
+							End: h.lineCharToProtocolPosition(
+								ix, a.EndLine, a.EndChar,
+							),
 						},
 						NewText: a.ReplaceWith,
 					}},
@@ -513,6 +884,9 @@ func (h *cssHandler) CodeAction(
 			},
 		}
 	}
+	result = append(result, h.missingFileFixes(
+		uri, ss, src, ix, params.Context.Diagnostics,
+	)...)
 
 	return result, nil
 }
@@ -529,23 +903,26 @@ func (h *cssHandler) fixAll(
 	params *protocol.CodeActionParams,
 	src []byte,
 ) []protocol.CodeAction {
-	actions := css.FixAllActions(src, h.lintOpts)
+	actions := css.FixAllActions(
+		src,
+		h.rootFor(string(params.TextDocument.URI)).lintOpts,
+	)
+	if doc := h.getEmbedded(string(params.TextDocument.URI)); doc != nil {
+		actions = slices.DeleteFunc(actions, func(a analyzer.CodeAction) bool {
+			return !doc.Contains(css.LineCharToOffset(src, a.StartLine, a.StartChar))
+		})
+	}
 	if len(actions) == 0 {
 		return nil
 	}
 
+	ix := h.getLineIndex(string(params.TextDocument.URI), src)
 	edits := make([]protocol.TextEdit, len(actions))
 	for i, a := range actions {
 		edits[i] = protocol.TextEdit{
 			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(a.StartLine), //nolint:gosec
-					Character: uint32(a.StartChar), //nolint:gosec
-				},
-				End: protocol.Position{
-					Line:      uint32(a.EndLine), //nolint:gosec
-					Character: uint32(a.EndChar), //nolint:gosec
-				},
+				Start: h.lineCharToProtocolPosition(ix, a.StartLine, a.StartChar),
+				End:   h.lineCharToProtocolPosition(ix, a.EndLine, a.EndChar),
 			},
 			NewText: a.ReplaceWith,
 		}
@@ -579,19 +956,20 @@ func (h *cssHandler) References(
 		result := css.Parse(src)
 		ss = result.Stylesheet
 	}
+	ix := h.getLineIndex(uri, src)
+	line, char := h.protocolPositionToLineChar(ix, params.Position)
 
 	refs := css.References(
 		ss, src,
-		int(params.Position.Line),      //nolint:gosec
-		int(params.Position.Character), //nolint:gosec
+		line, char,
 	)
 
 	result := make([]protocol.Location, len(refs))
 	for i, ref := range refs {
 		result[i] = protocol.Location{
 			URI: params.TextDocument.URI,
-			Range: offsetRangeToProtocolRange(
-				src, ref.StartPos, ref.EndPos,
+			Range: h.offsetRangeToProtocolRange(
+				ix, ref.StartPos, ref.EndPos,
 			),
 		}
 	}
@@ -615,11 +993,19 @@ func (h *cssHandler) Rename(
 		result := css.Parse(src)
 		ss = result.Stylesheet
 	}
+	ix := h.getLineIndex(uri, src)
+	line, char := h.protocolPositionToLineChar(ix, params.Position)
+
+	if root := h.rootFor(uri); root.isModule(uri) {
+		edit, ok := h.moduleRename(root, uri, ss, src, line, char, params.NewName)
+		if ok {
+			return edit, nil
+		}
+	}
 
 	edits := css.Rename(
 		ss, src,
-		int(params.Position.Line),      //nolint:gosec
-		int(params.Position.Character), //nolint:gosec
+		line, char,
 		params.NewName,
 	)
 
@@ -630,8 +1016,8 @@ func (h *cssHandler) Rename(
 	textEdits := make([]protocol.TextEdit, len(edits))
 	for i, e := range edits {
 		textEdits[i] = protocol.TextEdit{
-			Range: offsetRangeToProtocolRange(
-				src, e.StartPos, e.EndPos,
+			Range: h.offsetRangeToProtocolRange(
+				ix, e.StartPos, e.EndPos,
 			),
 			NewText: e.NewText,
 		}
@@ -660,9 +1046,16 @@ func (h *cssHandler) DocumentSymbol(
 		result := css.Parse(src)
 		ss = result.Stylesheet
 	}
+	ix := h.getLineIndex(uri, src)
 
 	symbols := css.DocumentSymbols(ss, src)
-	result := convertSymbols(symbols, src)
+	if doc := h.getEmbedded(uri); doc != nil {
+		// Drop the rules that wrap style attributes.
+		symbols = slices.DeleteFunc(symbols, func(s analyzer.DocumentSymbol) bool {
+			return !doc.Contains(s.SelectionStart)
+		})
+	}
+	result := h.convertSymbols(symbols, ix)
 
 	out := make([]any, len(result))
 	for i, s := range result {
@@ -672,36 +1065,126 @@ func (h *cssHandler) DocumentSymbol(
 	return out, nil
 }
 
-func convertSymbols(
+func (h *cssHandler) convertSymbols(
 	symbols []analyzer.DocumentSymbol,
-	src []byte,
+	ix *lineindex.Index,
 ) []protocol.DocumentSymbol {
 	result := make([]protocol.DocumentSymbol, len(symbols))
 	for i, s := range symbols {
 		result[i] = protocol.DocumentSymbol{
 			Name: s.Name,
 			Kind: protocol.SymbolKind(s.Kind),
-			Range: offsetRangeToProtocolRange(
-				src, s.StartPos, s.EndPos,
+			Range: h.offsetRangeToProtocolRange(
+				ix, s.StartPos, s.EndPos,
 			),
-			SelectionRange: offsetRangeToProtocolRange(
-				src, s.SelectionStart, s.SelectionEnd,
+			SelectionRange: h.offsetRangeToProtocolRange(
+				ix, s.SelectionStart, s.SelectionEnd,
 			),
 		}
 
 		if len(s.Children) > 0 {
-			result[i].Children = convertSymbols(
-				s.Children, src,
+			result[i].Children = h.convertSymbols(
+				s.Children, ix,
 			)
 		}
 	}
 	return result
 }
 
+// --- server.DocumentColorHandler ---
+
+func (h *cssHandler) DocumentColor(
+	_ context.Context,
+	params *protocol.DocumentColorParams,
+) ([]protocol.ColorInformation, error) { //nolint:unparam // interface
+	uri := string(params.TextDocument.URI)
+	src := h.getRawFile(uri)
+	if src == nil {
+		return nil, nil
+	}
+	ss := h.getParsedFile(uri)
+	if ss == nil {
+		result := css.Parse(src)
+		ss = result.Stylesheet
+	}
+	ix := h.getLineIndex(uri, src)
+
+	root := h.rootFor(uri)
+	colors := css.DocumentColorsResolved(
+		ss, src, root.index, root.settings.colorScheme(),
+	)
+	result := make([]protocol.ColorInformation, len(colors))
+	for i, c := range colors {
+		result[i] = protocol.ColorInformation{
+			Range: h.offsetRangeToProtocolRange(ix, c.StartPos, c.EndPos),
+			Color: protocol.Color{
+				Red:   c.Color.Red,
+				Green: c.Color.Green,
+				Blue:  c.Color.Blue,
+				Alpha: c.Color.Alpha,
+			},
+		}
+	}
+
+	return result, nil
+}
+
+// --- server.ColorPresentationHandler ---
+
+func (h *cssHandler) ColorPresentation(
+	_ context.Context,
+	params *protocol.ColorPresentationParams,
+) ([]protocol.ColorPresentation, error) { //nolint:unparam // interface
+	uri := string(params.TextDocument.URI)
+	src := h.getRawFile(uri)
+	if src == nil {
+		return nil, nil
+	}
+	ix := h.getLineIndex(uri, src)
+	startLine, startChar := h.protocolPositionToLineChar(ix, params.Range.Start)
+	endLine, endChar := h.protocolPositionToLineChar(ix, params.Range.End)
+
+	items := css.ColorPresentationsAt(
+		h.parsedOrParse(uri, src), src,
+		startLine, startChar, endLine, endChar,
+		analyzer.Color{
+			Red:   params.Color.Red,
+			Green: params.Color.Green,
+			Blue:  params.Color.Blue,
+			Alpha: params.Color.Alpha,
+		},
+	)
+	edit := func(e analyzer.ColorEdit) protocol.TextEdit {
+		return protocol.TextEdit{
+			Range:   h.offsetRangeToProtocolRange(ix, e.StartPos, e.EndPos),
+			NewText: e.NewText,
+		}
+	}
+	result := make([]protocol.ColorPresentation, len(items))
+	for i, item := range items {
+		e := edit(item.Edit)
+		result[i] = protocol.ColorPresentation{Label: item.Label, TextEdit: &e}
+		for _, a := range item.AdditionalEdits {
+			result[i].AdditionalTextEdits = append(
+				result[i].AdditionalTextEdits, edit(a),
+			)
+		}
+	}
+	return result, nil
+}
+
 func main() {
 	versionFlag := flag.Bool(
 		"version", false, "print the LSP version",
 	)
+	formatFlag := flag.Bool(
+		"format", false,
+		"format the named stylesheets to standard output, "+
+			"as their .editorconfig files set",
+	)
+	writeFlag := flag.Bool(
+		"w", false, "with -format, write the result to the files instead",
+	)
 	flag.Parse()
 
 	if *versionFlag {
@@ -710,8 +1193,18 @@ func main() {
 		)
 		os.Exit(0)
 	}
+	if *formatFlag {
+		if err := formatFiles(os.Stdout, flag.Args(), *writeFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", serverName, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	handler := newCSSHandler()
+	if dir, err := os.UserCacheDir(); err == nil {
+		handler.cacheDir = filepath.Join(dir, serverName)
+	}
 	srv := server.Server{
 		Name:    serverName,
 		Version: version,
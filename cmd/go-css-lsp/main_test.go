@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/toba/css-lsp/internal/server"
+	"go.lsp.dev/protocol"
+)
+
+func TestDidChange(t *testing.T) {
+	const uri = "file:///test/app.css"
+	tests := []struct {
+		name    string
+		changes []server.TextChange
+		content string
+	}{
+		{
+			name:    "single change",
+			changes: []server.TextChange{{Start: 11, End: 14, Text: "blue"}},
+			content: ".a { color: blue; }",
+		},
+		{
+			name: "several changes",
+			changes: []server.TextChange{
+				{Start: 11, End: 14, Text: "blue"},
+				{Start: 1, End: 2, Text: "b"},
+			},
+			content: ".b { color: blue; }",
+		},
+		{
+			name:    "change that disagrees with content",
+			changes: []server.TextChange{{Start: 11, End: 14, Text: "blue"}},
+			content: ".a { color: green; }",
+		},
+		{
+			name:    "change out of range",
+			changes: []server.TextChange{{Start: 11, End: 99, Text: "blue"}},
+			content: ".a { color: blue; }",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newMultiRootHandler(t, nil)
+			ctx := context.Background()
+			h.DidOpen(ctx, uri, ".a { color: red; }")
+			h.DidChange(ctx, uri, tt.changes, tt.content)
+			if got := string(h.getRawFile(uri)); got != tt.content {
+				t.Errorf("source = %q, want %q", got, tt.content)
+			}
+			ss := h.getParsedFile(uri)
+			if ss == nil || len(ss.Children) != 1 ||
+				ss.Children[0].End() != len(tt.content) {
+				t.Errorf("stylesheet does not span %q", tt.content)
+			}
+		})
+	}
+}
+
+// largeStylesheet returns a stylesheet of about the given number
+// of lines.
+func largeStylesheet(lines int) string {
+	var b strings.Builder
+	b.WriteString(":root {\n")
+	for i := range 50 {
+		fmt.Fprintf(&b, "  --color-%d: rgb(%d 0 0);\n", i, i)
+	}
+	b.WriteString("}\n")
+	for i := 0; b.Len() < lines*24; i++ {
+		fmt.Fprintf(&b, ".component-%d {\n", i)
+		fmt.Fprintf(&b, "  color: var(--color-%d);\n", i%50)
+		fmt.Fprintf(&b, "  padding: %dpx %dpx;\n", i%16, i%8)
+		b.WriteString("  border: 1px solid rgb(0 0 0 / 10%);\n")
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// BenchmarkDidChangeDiagnostics measures a keystroke end to end:
+// applying the change to the document and publishing its
+// diagnostics. Keystrokes alternately type and delete a character
+// near the start, so every later rule moves.
+func BenchmarkDidChangeDiagnostics(b *testing.B) {
+	h := newCSSHandler()
+	ctx := context.Background()
+	if _, err := h.Initialize(ctx, &protocol.InitializeParams{}); err != nil {
+		b.Fatal(err)
+	}
+	h.Initialized(nil)
+
+	const uri = "file:///bench/app.css"
+	orig := largeStylesheet(8000)
+	h.DidOpen(ctx, uri, orig)
+	i := strings.Index(orig, "padding: 7px") + len("padding: ")
+	edits := [2]struct {
+		change  server.TextChange
+		content string
+	}{
+		{server.TextChange{Start: i, End: i, Text: "1"}, orig[:i] + "1" + orig[i:]},
+		{server.TextChange{Start: i, End: i + 1}, orig},
+	}
+
+	b.SetBytes(int64(len(orig)))
+	for n := 0; b.Loop(); n++ {
+		e := edits[n%2]
+		h.DidChange(ctx, uri, []server.TextChange{e.change}, e.content)
+		if _, err := h.Diagnostics(ctx, uri, e.content); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
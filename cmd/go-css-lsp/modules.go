@@ -0,0 +1,206 @@
+package main
+
+import (
+	"os"
+
+	"github.com/toba/css-lsp/internal/css"
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/embedded"
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/css-lsp/internal/css/workspace"
+	"github.com/toba/css-lsp/internal/lineindex"
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+// cssModules settings. By default ("auto") only files named like
+// card.module.css are CSS modules.
+const (
+	cssModulesAlways = "always"
+	cssModulesNever  = "never"
+)
+
+// isModule reports whether the root treats the stylesheet at uri
+// as a CSS module.
+func (r *workspaceRoot) isModule(uri string) bool {
+	switch r.settings.CSSModules {
+	case cssModulesAlways:
+		return !embedded.IsHTML(uri)
+	case cssModulesNever:
+		return false
+	default:
+		return analyzer.IsModule(uri)
+	}
+}
+
+// ModuleImported implements analyzer.ModuleIndex.
+func (f fileIndex) ModuleImported() bool {
+	return f.Index.ModuleImported(f.path)
+}
+
+// ModuleClassUsed implements analyzer.ModuleIndex.
+func (f fileIndex) ModuleClassUsed(name string) bool {
+	return f.Index.ModuleClassUsed(f.path, name)
+}
+
+// ResolveModule implements analyzer.ModuleIndex.
+func (f fileIndex) ResolveModule(from string) (*analyzer.Module, bool) {
+	m, ok := f.h.loadModule(workspace.ModulePath(f.uri, from))
+	if !ok {
+		return nil, false
+	}
+	return m.module, true
+}
+
+// moduleFile is a CSS module read from an open document or disk.
+type moduleFile struct {
+	uri    string
+	src    []byte
+	ss     *parser.Stylesheet
+	module *analyzer.Module
+}
+
+// loadModule reads the CSS module at path, preferring the open
+// document's content.
+func (h *cssHandler) loadModule(path string) (*moduleFile, bool) {
+	if path == "" {
+		return nil, false
+	}
+	uri := pathutil.FilePathToURI(path)
+	src := h.getRawFile(uri)
+	var ss *parser.Stylesheet
+	if src != nil {
+		ss = h.getParsedFile(uri)
+	} else {
+		var err error
+		if src, err = os.ReadFile(path); err != nil { //nolint:gosec
+			return nil, false
+		}
+	}
+	if ss == nil {
+		ss = css.Parse(src).Stylesheet
+	}
+	return &moduleFile{
+		uri:    uri,
+		src:    src,
+		ss:     ss,
+		module: analyzer.ParseModule(ss, src),
+	}, true
+}
+
+// readSource returns the content of the file at uri, preferring
+// the open document's.
+func (h *cssHandler) readSource(uri string) ([]byte, bool) {
+	if src := h.getRawFile(uri); src != nil {
+		return src, true
+	}
+	path := pathutil.URIToFilePath(uri)
+	if path == "" {
+		return nil, false
+	}
+	src, err := os.ReadFile(path) //nolint:gosec
+	return src, err == nil
+}
+
+// moduleDefinition returns the definition of the class or @value
+// name at line and char of the CSS module at uri, following
+// composes and @value imports into the module they name.
+func (h *cssHandler) moduleDefinition(
+	uri string,
+	ss *parser.Stylesheet,
+	src []byte,
+	ix *lineindex.Index,
+	line, char int,
+) ([]protocol.Location, bool) {
+	ref, ok := css.ModuleReference(ss, src, line, char)
+	if !ok {
+		return nil, false
+	}
+	if ref.From == "" {
+		loc, ok := analyzer.ParseModule(ss, src).Definition(ref.Name, ref.Value)
+		if !ok {
+			return nil, false
+		}
+		return []protocol.Location{{
+			URI:   protocol.DocumentURI(uri),
+			Range: h.offsetRangeToProtocolRange(ix, loc.StartPos, loc.EndPos),
+		}}, true
+	}
+
+	f, ok := h.loadModule(workspace.ModulePath(uri, ref.From))
+	if !ok {
+		return nil, false
+	}
+	loc, ok := f.module.Definition(ref.Imported, ref.Value)
+	if !ok {
+		return nil, false
+	}
+	return []protocol.Location{{
+		URI: protocol.DocumentURI(f.uri),
+		Range: h.offsetRangeToProtocolRange(
+			h.getLineIndex(f.uri, f.src), loc.StartPos, loc.EndPos,
+		),
+	}}, true
+}
+
+// moduleRename renames the class at line and char of the CSS
+// module at uri, or the class of another module it composes. The
+// class is renamed in its module and wherever the workspace uses
+// it: in scripts importing the module and in other modules'
+// composes declarations.
+func (h *cssHandler) moduleRename(
+	root *workspaceRoot,
+	uri string,
+	ss *parser.Stylesheet,
+	src []byte,
+	line, char int,
+	newName string,
+) (*protocol.WorkspaceEdit, bool) {
+	ref, ok := css.ModuleReference(ss, src, line, char)
+	if !ok || ref.Value {
+		return nil, false
+	}
+
+	target := &moduleFile{uri: uri, src: src, ss: ss}
+	path, name := pathutil.URIToFilePath(uri), ref.Name
+	if ref.From != "" {
+		path, name = workspace.ModulePath(uri, ref.From), ref.Imported
+		if target, ok = h.loadModule(path); !ok {
+			return nil, false
+		}
+	}
+
+	changes := make(map[protocol.DocumentURI][]protocol.TextEdit)
+	add := func(uri string, src []byte, edits []analyzer.RenameEdit) {
+		ix := h.getLineIndex(uri, src)
+		for _, e := range edits {
+			changes[protocol.DocumentURI(uri)] = append(
+				changes[protocol.DocumentURI(uri)],
+				protocol.TextEdit{
+					Range:   h.offsetRangeToProtocolRange(ix, e.StartPos, e.EndPos),
+					NewText: e.NewText,
+				},
+			)
+		}
+	}
+	add(target.uri, target.src,
+		analyzer.ModuleClassEdits(target.ss, target.src, name, newName))
+
+	// Uses are ordered by file, so each file is read once.
+	uses := root.index.ModuleUses(path, name)
+	for i := 0; i < len(uses); {
+		fileURI := uses[i].URI
+		var edits []analyzer.RenameEdit
+		for ; i < len(uses) && uses[i].URI == fileURI; i++ {
+			edits = append(edits, uses[i].RenameEdit(newName))
+		}
+		if fileSrc, ok := h.readSource(fileURI); ok {
+			add(fileURI, fileSrc, edits)
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, false
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}, true
+}
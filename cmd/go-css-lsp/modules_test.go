@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestCSSModules(t *testing.T) {
+	dir := t.TempDir()
+	baseURI := writeFile(t, dir, "base.module.css", ".base { }\n.spare { }\n")
+	scriptURI := writeFile(t, dir, "Card.tsx",
+		`import s from "./card.module.css";
+export const Card = () => <div className={s.card} />;
+`)
+	const card = `.card { composes: base from "./base.module.css"; }
+.title { composes: card missing; }
+.gone { composes: x from "./nowhere.module.css"; }
+`
+	uri := writeFile(t, dir, "card.module.css", card)
+	h := newMultiRootHandler(t, map[string]any{
+		"unusedSelectors": "warning",
+	}, folder(dir, "app"))
+	ctx := context.Background()
+	doc := protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)}
+
+	diags := openDiagnostics(t, h, uri, card)
+	if !hasMessage(diags, "'missing' is not defined") ||
+		!hasMessage(diags, "cannot find module './nowhere.module.css'") ||
+		!hasMessage(diags, "'gone' is not used") ||
+		hasMessage(diags, "'card' is not used") ||
+		hasMessage(diags, "composes") {
+		t.Errorf("diagnostics = %v", diags)
+	}
+
+	locs, err := h.Definition(ctx, &protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: doc, Position: position(card, "base from"),
+		},
+	})
+	if err != nil || len(locs) != 1 || string(locs[0].URI) != baseURI ||
+		locs[0].Range.Start != (protocol.Position{Line: 0, Character: 1}) {
+		t.Errorf("definition = %+v, %v", locs, err)
+	}
+
+	edit, err := h.Rename(ctx, &protocol.RenameParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: doc, Position: position(card, "card {"),
+		},
+		NewName: "big-card",
+	})
+	if err != nil || edit == nil {
+		t.Fatalf("rename = %v, %v", edit, err)
+	}
+	if got := edit.Changes[protocol.DocumentURI(uri)]; len(got) != 2 {
+		t.Errorf("module edits = %+v", got)
+	}
+	got := edit.Changes[protocol.DocumentURI(scriptURI)]
+	if len(got) != 1 || got[0].NewText != `["big-card"]` {
+		t.Errorf("script edits = %+v", got)
+	}
+
+	// Outside module mode, composes is an unknown property.
+	h = newMultiRootHandler(t, map[string]any{
+		"cssModules": "never",
+	}, folder(dir, "app"))
+	diags = openDiagnostics(t, h, uri, card)
+	if !hasMessage(diags, "'composes'") || hasMessage(diags, "not defined") {
+		t.Errorf("diagnostics without CSS modules = %v", diags)
+	}
+}
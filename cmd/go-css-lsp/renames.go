@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+
+	"github.com/toba/css-lsp/internal/css"
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/embedded"
+	"github.com/toba/css-lsp/internal/css/workspace"
+	"github.com/toba/css-lsp/internal/lineindex"
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+// --- server.FileRenameHandler ---
+
+// WillRenameFiles updates the @import and url() links that name
+// files or folders about to be renamed, and the relative links of
+// the renamed stylesheets themselves, in every indexed or open
+// document.
+func (h *cssHandler) WillRenameFiles(
+	_ context.Context,
+	params *protocol.RenameFilesParams,
+) (*protocol.WorkspaceEdit, error) { //nolint:unparam // interface
+	var moves []workspace.FileMove
+	for _, f := range params.Files {
+		m := workspace.FileMove{
+			Old: pathutil.URIToFilePath(f.OldURI),
+			New: pathutil.URIToFilePath(f.NewURI),
+		}
+		if m.Old != "" && m.New != "" {
+			moves = append(moves, m)
+		}
+	}
+	if len(moves) == 0 {
+		return nil, nil
+	}
+
+	changes := make(map[protocol.DocumentURI][]protocol.TextEdit)
+	for _, uri := range h.linkingFiles() {
+		if edits := h.renameLinks(uri, moves); len(edits) > 0 {
+			changes[protocol.DocumentURI(uri)] = edits
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
+
+// linkingFiles returns the URIs of the indexed stylesheets and
+// HTML files and of the open documents, sorted and each listed
+// once: both are keyed by workspace.FileURI.
+func (h *cssHandler) linkingFiles() []string {
+	var uris []string
+	for _, idx := range h.indexes() {
+		uris = append(uris, idx.Files()...)
+	}
+	h.mu.RLock()
+	for uri := range h.rawFiles {
+		uris = append(uris, uri)
+	}
+	h.mu.RUnlock()
+	slices.Sort(uris)
+	return slices.Compact(uris)
+}
+
+// renameLinks returns the edits to the links of the file at uri
+// that moves make necessary.
+func (h *cssHandler) renameLinks(
+	uri string,
+	moves []workspace.FileMove,
+) []protocol.TextEdit {
+	from := pathutil.URIToFilePath(uri)
+	if from == "" {
+		return nil
+	}
+	src, ix, ok := h.linkSource(uri, from)
+	if !ok {
+		return nil
+	}
+	root := h.rootFor(uri)
+	newFrom, _ := workspace.MovedPath(moves, from)
+
+	var edits []protocol.TextEdit
+	for _, link := range css.DocumentLinks(css.Parse(src).Stylesheet, src) {
+		if link.TargetStart < 0 || analyzer.IsRemoteURL(link.Target) {
+			continue
+		}
+		path, ok := linkPath(root, from, link)
+		if !ok {
+			continue
+		}
+		newPath, moved := workspace.MovedPath(moves, path)
+		if !moved && newFrom == from {
+			continue
+		}
+		target, ok := root.resolver.RewriteLink(newFrom, link.Target, path, newPath)
+		if !ok {
+			continue
+		}
+		edits = append(edits, protocol.TextEdit{
+			Range: h.offsetRangeToProtocolRange(
+				ix, link.TargetStart, link.TargetEnd,
+			),
+			NewText: target,
+		})
+	}
+	return edits
+}
+
+// linkSource returns the CSS of the file at uri, which for HTML is
+// the CSS extracted from it, and the file's line index, preferring
+// the open document's content.
+func (h *cssHandler) linkSource(
+	uri, path string,
+) ([]byte, *lineindex.Index, bool) {
+	if src := h.getRawFile(uri); src != nil {
+		return src, h.getLineIndex(uri, src), true
+	}
+	src, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, nil, false
+	}
+	ix := lineindex.New(src)
+	if embedded.IsHTML(uri) {
+		src = embedded.Extract(src).CSS
+	}
+	return src, ix, true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+func TestWillRenameFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "img/logo.png", "")
+	const main = `@import "./base.css";
+@import "./base";
+@import "@/base.css";
+.logo { background: url("/img/logo.png?v=2") url(../img/logo.png); }
+`
+	mainURI := writeFile(t, dir, "src/main.css", main)
+	baseURI := writeFile(t, dir, "src/base.css",
+		`a { background: url('../img/logo.png'); }`)
+	h := newMultiRootHandler(t, map[string]any{
+		"importAliases": map[string]any{"@/": "src/"},
+	}, folder(dir, "app"))
+	openDiagnostics(t, h, mainURI, main)
+
+	path := func(name string) protocol.DocumentURI {
+		return protocol.DocumentURI(pathutil.FilePathToURI(
+			filepath.Join(dir, filepath.FromSlash(name))))
+	}
+	edit, err := h.WillRenameFiles(context.Background(), &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{
+			{OldURI: string(path("img")), NewURI: string(path("assets/images"))},
+			{OldURI: baseURI, NewURI: string(path("src/theme/base.css"))},
+		},
+	})
+	if err != nil || edit == nil {
+		t.Fatalf("edit = %v, %v", edit, err)
+	}
+
+	want := map[string]string{
+		`"./base.css"`:        "./theme/base.css",
+		`"./base"`:            "./theme/base",
+		`"@/base.css"`:        "@/theme/base.css",
+		`"/img/logo.png?v=2"`: "/assets/images/logo.png?v=2",
+		`(../img/logo.png)`:   "../assets/images/logo.png",
+	}
+	got := edit.Changes[protocol.DocumentURI(mainURI)]
+	if len(got) != len(want) {
+		t.Fatalf("main.css edits = %+v", got)
+	}
+	for written, text := range want {
+		start := position(main, written)
+		start.Character++
+		found := false
+		for _, e := range got {
+			found = found || e.Range.Start == start && e.NewText == text
+		}
+		if !found {
+			t.Errorf("no edit of %s to %q in %+v", written, text, got)
+		}
+	}
+
+	// The moved stylesheet's own relative links follow it.
+	got = edit.Changes[protocol.DocumentURI(baseURI)]
+	if len(got) != 1 || got[0].NewText != "../../assets/images/logo.png" {
+		t.Errorf("base.css edits = %+v", got)
+	}
+}
+
+func TestWillRenameFilesEscapedPaths(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my dir@2x")
+	writeFile(t, dir, "img/logo.png", "")
+	const main = `a { background: url(img/logo.png); }`
+	mainURI := writeFile(t, dir, "main.css", main)
+	h := newMultiRootHandler(t, nil, folder(dir, "app"))
+	// The client escapes "@", which the index doesn't.
+	openDiagnostics(t, h, strings.ReplaceAll(mainURI, "@", "%40"), main)
+
+	img := pathutil.FilePathToURI(filepath.Join(dir, "img"))
+	edit, err := h.WillRenameFiles(context.Background(), &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{
+			OldURI: img,
+			NewURI: pathutil.FilePathToURI(filepath.Join(dir, "assets")),
+		}},
+	})
+	if err != nil || edit == nil {
+		t.Fatalf("edit = %v, %v", edit, err)
+	}
+	got := edit.Changes[protocol.DocumentURI(mainURI)]
+	if len(edit.Changes) != 1 || len(got) != 1 || got[0].NewText != "assets/logo.png" {
+		t.Errorf("changes = %+v", edit.Changes)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/embedded"
+	"github.com/toba/css-lsp/internal/css/workspace"
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+// variableScopeRoot is the variableScope setting that gives each
+// workspace folder its own index, so custom properties only
+// resolve within the folder. By default ("workspace") every
+// folder shares one index.
+const variableScopeRoot = "root"
+
+// variableScopeImports is the variableScope setting that resolves
+// custom properties only within the stylesheets loaded together
+// through @import rules.
+const variableScopeImports = "imports"
+
+// workspaceRoot is a workspace folder together with the
+// configuration and variable index that apply to files inside it.
+type workspaceRoot struct {
+	uri      string
+	name     string
+	path     string
+	settings ServerSettings
+	lintOpts analyzer.LintOptions
+	index    *workspace.Index
+	filter   *workspace.Filter
+	// cacheDir is where the root's index cache is kept, or "" if
+	// it has none.
+	cacheDir string
+	resolver *workspace.Resolver
+
+	// graph is the import graph as of index version graphVersion.
+	graphMu      sync.Mutex
+	graph        *workspace.ImportGraph
+	graphVersion uint64
+}
+
+// contains reports whether the file at path is inside the root.
+func (r *workspaceRoot) contains(path string) bool {
+	if r.path == "" {
+		return false
+	}
+	return path == r.path ||
+		strings.HasPrefix(path, r.path+string(filepath.Separator))
+}
+
+// hoverOptions returns the options hovers of files in the root
+// are given: its index resolves their variables, light-dark()
+// follows the colorScheme setting and the contrast of the
+// contrastPairs selectors is shown.
+func (r *workspaceRoot) hoverOptions() analyzer.HoverOptions {
+	return analyzer.HoverOptions{
+		Resolver:      r.index,
+		Scheme:        r.settings.colorScheme(),
+		ContrastPairs: r.lintOpts.Contrast.Pairs,
+	}
+}
+
+// indexesMarkup reports whether the root indexes the class names
+// and ids of the file at uri. Without a filter, only HTML
+// documents are read as markup.
+func (r *workspaceRoot) indexesMarkup(uri string) bool {
+	if r.filter == nil {
+		return embedded.IsHTML(uri)
+	}
+	return r.filter.IsMarkup(pathutil.URIToFilePath(uri))
+}
+
+// importGraph returns the import graph of the files in the root's
+// index, rebuilding it when the index has changed.
+func (r *workspaceRoot) importGraph() *workspace.ImportGraph {
+	r.graphMu.Lock()
+	defer r.graphMu.Unlock()
+	if v := r.index.Version(); r.graph == nil || v != r.graphVersion {
+		r.graph, r.graphVersion = r.index.ImportGraph(r.resolver), v
+	}
+	return r.graph
+}
+
+// newRoot creates the root for a workspace folder. Settings for
+// the folder, looked up by name or URI in the "folders"
+// initialization option, are layered over the global settings.
+func (h *cssHandler) newRoot(folder protocol.WorkspaceFolder) *workspaceRoot {
+	r := &workspaceRoot{
+		uri:      folder.URI,
+		name:     folder.Name,
+		path:     pathutil.URIToFilePath(folder.URI),
+		settings: h.settings,
+		index:    h.varIndex,
+	}
+	if opts, ok := h.folderOptions[folder.Name]; ok {
+		decodeSettings(opts, &r.settings)
+	}
+	if opts, ok := h.folderOptions[folder.URI]; ok {
+		decodeSettings(opts, &r.settings)
+	}
+	r.lintOpts = r.settings.lintOptions()
+	if r.path != "" {
+		r.filter = workspace.NewFilter(r.path, r.settings.scanOptions())
+		r.cacheDir = r.settings.cacheDir(h.cacheDir)
+	}
+	r.resolver = workspace.NewResolver(
+		r.path, r.settings.ImportAliases, r.settings.AssetRoots, r.filter,
+	)
+	if h.settings.VariableScope == variableScopeRoot {
+		r.index = workspace.NewIndex()
+	}
+	return r
+}
+
+// addRoots adds the given folders to the workspace and returns
+// the roots created for them, which still need to be indexed.
+// Folders that are already present are ignored.
+func (h *cssHandler) addRoots(folders []protocol.WorkspaceFolder) []*workspaceRoot {
+	var added []*workspaceRoot
+	for _, f := range folders {
+		r := h.newRoot(f)
+		if r.path == "" {
+			continue
+		}
+		h.mu.Lock()
+		if slices.ContainsFunc(h.roots, func(o *workspaceRoot) bool {
+			return o.path == r.path
+		}) {
+			h.mu.Unlock()
+			continue
+		}
+		h.roots = append(h.roots, r)
+		// Nested folders come first so that a file belongs to the
+		// innermost folder containing it.
+		slices.SortFunc(h.roots, func(a, b *workspaceRoot) int {
+			return cmp.Compare(len(b.path), len(a.path))
+		})
+		h.versionBase++
+		h.mu.Unlock()
+		added = append(added, r)
+	}
+	return added
+}
+
+// removeRoots drops the given folders from the workspace along
+// with the variables indexed from files that no other folder
+// covers.
+func (h *cssHandler) removeRoots(folders []protocol.WorkspaceFolder) {
+	for _, f := range folders {
+		path := pathutil.URIToFilePath(f.URI)
+
+		h.mu.Lock()
+		i := slices.IndexFunc(h.roots, func(r *workspaceRoot) bool {
+			return r.path == path
+		})
+		if i < 0 {
+			h.mu.Unlock()
+			continue
+		}
+		removed := h.roots[i]
+		h.roots = slices.Delete(h.roots, i, i+1)
+		h.versionBase++
+		h.mu.Unlock()
+
+		if removed.index != h.varIndex {
+			// The folder's isolated index goes away with it. Its
+			// version is folded into the base so that the
+			// workspace version keeps increasing.
+			h.mu.Lock()
+			h.versionBase += removed.index.Version()
+			h.mu.Unlock()
+			continue
+		}
+		for _, uri := range indexedFiles(h.varIndex) {
+			p := pathutil.URIToFilePath(uri)
+			if removed.contains(p) && h.rootFor(uri) == h.fallback {
+				h.varIndex.RemoveFile(uri)
+			}
+		}
+	}
+}
+
+// rootFor returns the innermost workspace root containing uri,
+// or the fallback root for files outside every folder.
+func (h *cssHandler) rootFor(uri string) *workspaceRoot {
+	path := pathutil.URIToFilePath(uri)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, r := range h.roots {
+		if r.contains(path) {
+			return r
+		}
+	}
+	return h.fallback
+}
+
+// indexes returns every distinct variable index in use.
+func (h *cssHandler) indexes() []*workspace.Index {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := []*workspace.Index{h.fallback.index}
+	for _, r := range h.roots {
+		if !slices.Contains(out, r.index) {
+			out = append(out, r.index)
+		}
+	}
+	return out
+}
+
+// indexedFiles returns the URIs of the stylesheets and markup
+// files in idx, sorted.
+func indexedFiles(idx *workspace.Index) []string {
+	uris := append(idx.Files(), idx.MarkupFiles()...)
+	slices.Sort(uris)
+	return slices.Compact(uris)
+}
+
+// removeFromIndexes drops uri from every variable index.
+func (h *cssHandler) removeFromIndexes(uri string) {
+	for _, idx := range h.indexes() {
+		idx.RemoveFile(uri)
+	}
+}
+
+// reindexOpenDocuments moves the variables and markup names of
+// open documents to the index of the root that now contains them. Scanning a folder
+// indexes files as saved on disk, so this also restores the
+// editor's unsaved content.
+func (h *cssHandler) reindexOpenDocuments() {
+	h.mu.RLock()
+	open := make(map[string][]byte, len(h.rawFiles))
+	for uri, src := range h.rawFiles {
+		open[uri] = src
+	}
+	h.mu.RUnlock()
+
+	for uri, src := range open {
+		target := h.rootFor(uri).index
+		for _, idx := range h.indexes() {
+			if idx != target {
+				idx.RemoveFile(uri)
+			}
+		}
+		if ss := h.getParsedFile(uri); ss != nil {
+			h.indexDocument(uri, ss, src, h.getEmbedded(uri))
+		}
+	}
+}
+
+// rescanRoot re-reads the .gitignore files of a root, drops files
+// they now exclude from its index and reindexes the root to pick
+// up the ones they no longer exclude.
+func (h *cssHandler) rescanRoot(r *workspaceRoot) {
+	r.filter.Reload()
+	r.resolver.Refresh()
+	for _, uri := range indexedFiles(r.index) {
+		if h.rootFor(uri) != r || h.isOpen(uri) {
+			continue
+		}
+		if !r.filter.Includes(pathutil.URIToFilePath(uri)) {
+			r.index.RemoveFile(uri)
+		}
+	}
+	h.indexRoots([]*workspaceRoot{r})
+}
+
+// --- server.WorkspaceFoldersHandler ---
+
+// DidChangeWorkspaceFolders forgets removed folders and indexes
+// added ones.
+func (h *cssHandler) DidChangeWorkspaceFolders(
+	_ context.Context,
+	added, removed []protocol.WorkspaceFolder,
+) {
+	h.removeRoots(removed)
+	roots := h.addRoots(added)
+	h.reindexOpenDocuments()
+	h.indexRoots(roots)
+}
+
+// workspaceFolders returns the folders a client opened with,
+// falling back to the root URI of clients that predate
+// multi-root workspaces.
+func workspaceFolders(params *protocol.InitializeParams) []protocol.WorkspaceFolder {
+	if len(params.WorkspaceFolders) > 0 {
+		return params.WorkspaceFolders
+	}
+	rootURI := string(params.RootURI) //nolint:staticcheck // fallback for older clients
+	if rootURI == "" {
+		return nil
+	}
+	return []protocol.WorkspaceFolder{{
+		URI:  rootURI,
+		Name: filepath.Base(pathutil.URIToFilePath(rootURI)),
+	}}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+// folder returns a workspace folder for dir.
+func folder(dir, name string) protocol.WorkspaceFolder {
+	return protocol.WorkspaceFolder{
+		URI:  string(pathutil.FilePathToURI(dir)),
+		Name: name,
+	}
+}
+
+// newMultiRootHandler returns a handler initialized on folders
+// with the given initialization options.
+func newMultiRootHandler(
+	t *testing.T,
+	opts map[string]any,
+	folders ...protocol.WorkspaceFolder,
+) *cssHandler {
+	t.Helper()
+	h := newCSSHandler()
+	_, err := h.Initialize(context.Background(), &protocol.InitializeParams{
+		InitializationOptions: opts,
+		WorkspaceFolders:      folders,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Initialized(nil)
+	return h
+}
+
+// openDiagnostics opens uri with content and returns its
+// diagnostics.
+func openDiagnostics(
+	t *testing.T,
+	h *cssHandler,
+	uri, content string,
+) []protocol.Diagnostic {
+	t.Helper()
+	ctx := context.Background()
+	h.DidOpen(ctx, protocol.DocumentURI(uri), content)
+	diags, err := h.Diagnostics(ctx, protocol.DocumentURI(uri), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return diags
+}
+
+func TestMultiRootScansEveryFolder(t *testing.T) {
+	base := t.TempDir()
+	tokens, app := filepath.Join(base, "tokens"), filepath.Join(base, "app")
+	writeFile(t, tokens, "tokens.css", ":root { --brand: red; }")
+	uri := writeFile(t, app, "app.css", "")
+
+	tests := []struct {
+		scope     string
+		undefined bool
+	}{
+		{"", false},
+		{"workspace", false},
+		{"root", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			h := newMultiRootHandler(t,
+				map[string]any{
+					"variableScope":      tt.scope,
+					"undefinedVariables": "warning",
+				},
+				folder(tokens, "tokens"), folder(app, "app"),
+			)
+			diags := openDiagnostics(t, h, uri, "a { color: var(--brand); }")
+			if got := hasMessage(diags, "'--brand'"); got != tt.undefined {
+				t.Errorf("--brand undefined = %v, want %v: %v", got, tt.undefined, diags)
+			}
+		})
+	}
+}
+
+func TestMultiRootFolderSettings(t *testing.T) {
+	base := t.TempDir()
+	strict, loose := filepath.Join(base, "strict"), filepath.Join(base, "loose")
+	strictURI := writeFile(t, strict, "a.css", "")
+	looseURI := writeFile(t, loose, "a.css", "")
+
+	h := newMultiRootHandler(t,
+		map[string]any{
+			"undefinedVariables": "error",
+			"folders": map[string]any{
+				"loose": map[string]any{"undefinedVariables": "ignore"},
+			},
+		},
+		folder(strict, "strict"), folder(loose, "loose"),
+	)
+
+	const src = "a { color: var(--missing); }"
+	diags := openDiagnostics(t, h, strictURI, src)
+	if len(diags) != 1 || diags[0].Severity != protocol.DiagnosticSeverityError {
+		t.Errorf("strict folder diagnostics = %v, want one error", diags)
+	}
+	if diags := openDiagnostics(t, h, looseURI, src); len(diags) != 0 {
+		t.Errorf("loose folder diagnostics = %v, want none", diags)
+	}
+}
+
+func TestMultiRootNestedFolderWins(t *testing.T) {
+	outer := t.TempDir()
+	inner := filepath.Join(outer, "packages", "ui")
+	uri := writeFile(t, inner, "button.css", "")
+
+	h := newMultiRootHandler(t,
+		map[string]any{
+			"folders": map[string]any{
+				"ui": map[string]any{"undefinedVariables": "ignore"},
+			},
+		},
+		folder(outer, "outer"), folder(inner, "ui"),
+	)
+	if root := h.rootFor(uri); root.name != "ui" {
+		t.Fatalf("rootFor = %q, want ui", root.name)
+	}
+	if diags := openDiagnostics(t, h, uri, "a { color: var(--x); }"); len(diags) != 0 {
+		t.Errorf("diagnostics = %v, want inner folder settings", diags)
+	}
+}
+
+func TestDidChangeWorkspaceFolders(t *testing.T) {
+	for _, scope := range []string{"workspace", "root"} {
+		t.Run(scope, func(t *testing.T) {
+			base := t.TempDir()
+			tokens, app := filepath.Join(base, "tokens"), filepath.Join(base, "app")
+			writeFile(t, tokens, "tokens.css", ":root { --brand: red; }")
+			writeFile(t, app, "app.css", "")
+
+			ctx := context.Background()
+			h := newMultiRootHandler(t,
+				map[string]any{"variableScope": scope},
+				folder(app, "app"),
+			)
+
+			before := h.WorkspaceVersion()
+			added := []protocol.WorkspaceFolder{folder(tokens, "tokens")}
+			h.DidChangeWorkspaceFolders(ctx, added, nil)
+			if h.WorkspaceVersion() == before {
+				t.Error("adding a folder should change the workspace version")
+			}
+			if !h.rootFor(string(pathutil.FilePathToURI(tokens)) + "/tokens.css").
+				index.HasVariable("--brand") {
+				t.Error("added folder was not scanned")
+			}
+
+			before = h.WorkspaceVersion()
+			h.DidChangeWorkspaceFolders(ctx, nil, added)
+			if h.WorkspaceVersion() == before {
+				t.Error("removing a folder should change the workspace version")
+			}
+			for _, idx := range h.indexes() {
+				if idx.HasVariable("--brand") {
+					t.Error("variables of a removed folder should be forgotten")
+				}
+			}
+			if len(h.roots) != 1 || h.roots[0].name != "app" {
+				t.Errorf("roots = %v, want only app", h.roots)
+			}
+		})
+	}
+}
+
+func TestDidChangeWorkspaceFoldersKeepsOpenDocuments(t *testing.T) {
+	base := t.TempDir()
+	tokens, app := filepath.Join(base, "tokens"), filepath.Join(base, "app")
+	uri := writeFile(t, tokens, "tokens.css", ":root { --disk: red; }")
+
+	ctx := context.Background()
+	h := newMultiRootHandler(t,
+		map[string]any{"variableScope": "root"},
+		folder(app, "app"),
+	)
+	h.DidOpen(ctx, protocol.DocumentURI(uri), ":root { --buffer: red; }")
+	added := []protocol.WorkspaceFolder{folder(tokens, "tokens")}
+	h.DidChangeWorkspaceFolders(ctx, added, nil)
+
+	root := h.rootFor(uri)
+	if root.name != "tokens" {
+		t.Fatalf("rootFor = %q, want tokens", root.name)
+	}
+	if !root.index.HasVariable("--buffer") || root.index.HasVariable("--disk") {
+		t.Error("the open buffer should take precedence over the scanned file")
+	}
+	if h.fallback.index.HasVariable("--buffer") {
+		t.Error("the document should have moved out of the fallback index")
+	}
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/workspace"
+)
+
+// ServerSettings holds server-specific configuration from
+// initializationOptions.
+type ServerSettings struct {
+	FormatMode           string `json:"formatMode"`
+	PrintWidth           int    `json:"printWidth"`
+	ExperimentalFeatures string `json:"experimentalFeatures"`
+	DeprecatedFeatures   string `json:"deprecatedFeatures"`
+	UnknownValues        string `json:"unknownValues"`
+	UndefinedVariables   string `json:"undefinedVariables"`
+	UnusedSelectors      string `json:"unusedSelectors"`
+	StrictColorNames     bool   `json:"strictColorNames"`
+	ColorScheme          string `json:"colorScheme"`
+	VariableScope        string `json:"variableScope"`
+	CSSModules           string `json:"cssModules"`
+	ModuleReferences     string `json:"moduleReferences"`
+
+	// Contrast between the colors and backgrounds of rules.
+	LowContrast       string            `json:"lowContrast"`
+	ContrastLevel     string            `json:"contrastLevel"`
+	ContrastAlgorithm string            `json:"contrastAlgorithm"`
+	ContrastPairs     map[string]string `json:"contrastPairs"`
+
+	// Declaration order, which the formatter sorts by and
+	// outOfOrderDeclarations checks.
+	DeclarationOrder       string   `json:"declarationOrder"`
+	PropertyOrder          []string `json:"propertyOrder"`
+	OutOfOrderDeclarations string   `json:"outOfOrderDeclarations"`
+
+	// Value normalizations of the formatter.
+	HexCase           string `json:"hexCase"`
+	HexLength         string `json:"hexLength"`
+	LeadingZero       string `json:"leadingZero"`
+	TrimTrailingZeros bool   `json:"trimTrailingZeros"`
+	RemoveZeroUnits   bool   `json:"removeZeroUnits"`
+	Quotes            string `json:"quotes"`
+	URLQuotes         string `json:"urlQuotes"`
+	LowercaseKeywords bool   `json:"lowercaseKeywords"`
+	LowercaseUnits    bool   `json:"lowercaseUnits"`
+	ColorNotation     string `json:"colorNotation"`
+
+	// Selector layout and normalizations of the formatter.
+	SelectorPerLine     bool   `json:"selectorPerLine"`
+	WrapSelectors       bool   `json:"wrapSelectors"`
+	SelectorSpacing     bool   `json:"selectorSpacing"`
+	AttributeQuotes     string `json:"attributeQuotes"`
+	LowercasePseudo     bool   `json:"lowercasePseudo"`
+	PseudoElementColons string `json:"pseudoElementColons"`
+
+	// Import and url() resolution.
+	ImportAliases     map[string]string `json:"importAliases"`
+	AssetRoots        []string          `json:"assetRoots"`
+	MissingFiles      string            `json:"missingFiles"`
+	UnresolvedImports string            `json:"unresolvedImports"`
+	ImportCycles      string            `json:"importCycles"`
+
+	// Workspace scanning.
+	Include          []string `json:"include"`
+	Exclude          []string `json:"exclude"`
+	Extensions       []string `json:"extensions"`
+	MarkupExtensions []string `json:"markupExtensions"`
+	UseGitignore     *bool    `json:"useGitignore"`
+	EditorConfig     *bool    `json:"editorConfig"`
+	FollowSymlinks   bool     `json:"followSymlinks"`
+	MaxFileSize      int64    `json:"maxFileSize"`
+	IndexCache       *bool    `json:"indexCache"`
+	CacheDirectory   string   `json:"cacheDirectory"`
+}
+
+// decodeSettings overlays the settings present in opts onto s.
+// The options arrive as map[string]any from JSON; keys that are
+// missing or of the wrong type leave s unchanged.
+func decodeSettings(opts map[string]any, s *ServerSettings) {
+	if v, ok := opts["formatMode"].(string); ok {
+		s.FormatMode = v
+	}
+	if v, ok := opts["printWidth"].(float64); ok {
+		s.PrintWidth = int(v)
+	}
+	if v, ok := opts["experimentalFeatures"].(string); ok {
+		s.ExperimentalFeatures = v
+	}
+	if v, ok := opts["deprecatedFeatures"].(string); ok {
+		s.DeprecatedFeatures = v
+	}
+	if v, ok := opts["unknownValues"].(string); ok {
+		s.UnknownValues = v
+	}
+	if v, ok := opts["undefinedVariables"].(string); ok {
+		s.UndefinedVariables = v
+	}
+	if v, ok := opts["unusedSelectors"].(string); ok {
+		s.UnusedSelectors = v
+	}
+	if v, ok := opts["strictColorNames"].(bool); ok {
+		s.StrictColorNames = v
+	}
+	if v, ok := opts["colorScheme"].(string); ok {
+		s.ColorScheme = v
+	}
+	if v, ok := opts["lowContrast"].(string); ok {
+		s.LowContrast = v
+	}
+	if v, ok := opts["contrastLevel"].(string); ok {
+		s.ContrastLevel = v
+	}
+	if v, ok := opts["contrastAlgorithm"].(string); ok {
+		s.ContrastAlgorithm = v
+	}
+	if v, ok := stringMap(opts["contrastPairs"]); ok {
+		s.ContrastPairs = v
+	}
+	if v, ok := opts["variableScope"].(string); ok {
+		s.VariableScope = v
+	}
+	if v, ok := opts["cssModules"].(string); ok {
+		s.CSSModules = v
+	}
+	if v, ok := opts["moduleReferences"].(string); ok {
+		s.ModuleReferences = v
+	}
+	if v, ok := opts["declarationOrder"].(string); ok {
+		s.DeclarationOrder = v
+	}
+	if v, ok := stringList(opts["propertyOrder"]); ok {
+		s.PropertyOrder = v
+	}
+	if v, ok := opts["outOfOrderDeclarations"].(string); ok {
+		s.OutOfOrderDeclarations = v
+	}
+	if v, ok := opts["hexCase"].(string); ok {
+		s.HexCase = v
+	}
+	if v, ok := opts["hexLength"].(string); ok {
+		s.HexLength = v
+	}
+	if v, ok := opts["leadingZero"].(string); ok {
+		s.LeadingZero = v
+	}
+	if v, ok := opts["trimTrailingZeros"].(bool); ok {
+		s.TrimTrailingZeros = v
+	}
+	if v, ok := opts["removeZeroUnits"].(bool); ok {
+		s.RemoveZeroUnits = v
+	}
+	if v, ok := opts["quotes"].(string); ok {
+		s.Quotes = v
+	}
+	if v, ok := opts["urlQuotes"].(string); ok {
+		s.URLQuotes = v
+	}
+	if v, ok := opts["lowercaseKeywords"].(bool); ok {
+		s.LowercaseKeywords = v
+	}
+	if v, ok := opts["lowercaseUnits"].(bool); ok {
+		s.LowercaseUnits = v
+	}
+	if v, ok := opts["colorNotation"].(string); ok {
+		s.ColorNotation = v
+	}
+	if v, ok := opts["selectorPerLine"].(bool); ok {
+		s.SelectorPerLine = v
+	}
+	if v, ok := opts["wrapSelectors"].(bool); ok {
+		s.WrapSelectors = v
+	}
+	if v, ok := opts["selectorSpacing"].(bool); ok {
+		s.SelectorSpacing = v
+	}
+	if v, ok := opts["attributeQuotes"].(string); ok {
+		s.AttributeQuotes = v
+	}
+	if v, ok := opts["lowercasePseudo"].(bool); ok {
+		s.LowercasePseudo = v
+	}
+	if v, ok := opts["pseudoElementColons"].(string); ok {
+		s.PseudoElementColons = v
+	}
+	if v, ok := stringMap(opts["importAliases"]); ok {
+		s.ImportAliases = v
+	}
+	if v, ok := stringList(opts["assetRoots"]); ok {
+		s.AssetRoots = v
+	}
+	if v, ok := opts["missingFiles"].(string); ok {
+		s.MissingFiles = v
+	}
+	if v, ok := opts["unresolvedImports"].(string); ok {
+		s.UnresolvedImports = v
+	}
+	if v, ok := opts["importCycles"].(string); ok {
+		s.ImportCycles = v
+	}
+	if v, ok := stringList(opts["include"]); ok {
+		s.Include = v
+	}
+	if v, ok := stringList(opts["exclude"]); ok {
+		s.Exclude = v
+	}
+	if v, ok := stringList(opts["extensions"]); ok {
+		s.Extensions = v
+	}
+	if v, ok := stringList(opts["markupExtensions"]); ok {
+		s.MarkupExtensions = v
+	}
+	if v, ok := opts["useGitignore"].(bool); ok {
+		s.UseGitignore = &v
+	}
+	if v, ok := opts["editorConfig"].(bool); ok {
+		s.EditorConfig = &v
+	}
+	if v, ok := opts["followSymlinks"].(bool); ok {
+		s.FollowSymlinks = v
+	}
+	if v, ok := opts["maxFileSize"].(float64); ok {
+		s.MaxFileSize = int64(v)
+	}
+	if v, ok := opts["indexCache"].(bool); ok {
+		s.IndexCache = &v
+	}
+	if v, ok := opts["cacheDirectory"].(string); ok {
+		s.CacheDirectory = v
+	}
+}
+
+// stringList converts a JSON array of strings. Elements that
+// aren't strings are dropped.
+func stringList(v any) ([]string, bool) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	out := make([]string, 0, len(list))
+	for _, e := range list {
+		if s, ok := e.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out, true
+}
+
+// stringMap converts a JSON object with string values. Entries
+// whose values aren't strings are dropped.
+func stringMap(v any) (map[string]string, bool) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	out := make(map[string]string, len(obj))
+	for k, e := range obj {
+		if s, ok := e.(string); ok {
+			out[k] = s
+		}
+	}
+	return out, true
+}
+
+// scanOptions converts the settings to workspace scan options.
+func (s *ServerSettings) scanOptions() workspace.ScanOptions {
+	return workspace.ScanOptions{
+		Include:         s.Include,
+		Exclude:         s.Exclude,
+		Extensions:      s.Extensions,
+		Markup:          s.MarkupExtensions,
+		IgnoreGitignore: s.UseGitignore != nil && !*s.UseGitignore,
+		FollowSymlinks:  s.FollowSymlinks,
+		MaxFileSize:     s.MaxFileSize,
+	}
+}
+
+// cacheDir returns the directory to keep the index cache in, or
+// "" if caching is disabled. def is used unless the settings name
+// a directory.
+func (s *ServerSettings) cacheDir(def string) string {
+	switch {
+	case s.IndexCache != nil && !*s.IndexCache:
+		return ""
+	case s.CacheDirectory != "":
+		return s.CacheDirectory
+	default:
+		return def
+	}
+}
+
+// lintOptions converts the settings to analyzer lint options.
+func (s *ServerSettings) lintOptions() analyzer.LintOptions {
+	opts := analyzer.LintOptions{
+		Experimental: modeFromString(
+			s.ExperimentalFeatures,
+			analyzer.ExperimentalIgnore,
+			analyzer.ExperimentalError,
+			analyzer.ExperimentalWarn,
+		),
+		Deprecated: modeFromString(
+			s.DeprecatedFeatures,
+			analyzer.DeprecatedIgnore,
+			analyzer.DeprecatedError,
+			analyzer.DeprecatedWarn,
+		),
+		UnknownValues: modeFromString(
+			s.UnknownValues,
+			analyzer.UnknownValueIgnore,
+			analyzer.UnknownValueError,
+			analyzer.UnknownValueWarn,
+		),
+		UnresolvedImports: modeFromString(
+			s.UnresolvedImports,
+			analyzer.UnresolvedImportIgnore,
+			analyzer.UnresolvedImportError,
+			analyzer.UnresolvedImportWarn,
+		),
+		ImportCycles: modeFromString(
+			s.ImportCycles,
+			analyzer.ImportCycleIgnore,
+			analyzer.ImportCycleError,
+			analyzer.ImportCycleWarn,
+		),
+		ModuleReferences: modeFromString(
+			s.ModuleReferences,
+			analyzer.ModuleReferenceIgnore,
+			analyzer.ModuleReferenceError,
+			analyzer.ModuleReferenceWarn,
+		),
+		StrictColorNames: s.StrictColorNames,
+		Order:            s.declarationOrder(),
+		Contrast:         s.contrastOptions(),
+	}
+	// Unlike the other checks, undefined variables, unused
+	// selectors, missing files, declaration order and contrast are
+	// only reported when asked for.
+	if s.UndefinedVariables != "" {
+		opts.UndefinedVariables = modeFromString(
+			s.UndefinedVariables,
+			analyzer.UndefinedVariableIgnore,
+			analyzer.UndefinedVariableError,
+			analyzer.UndefinedVariableWarn,
+		)
+	}
+	if s.UnusedSelectors != "" {
+		opts.UnusedSelectors = modeFromString(
+			s.UnusedSelectors,
+			analyzer.UnusedSelectorIgnore,
+			analyzer.UnusedSelectorError,
+			analyzer.UnusedSelectorWarn,
+		)
+	}
+	if s.MissingFiles != "" {
+		opts.MissingFiles = modeFromString(
+			s.MissingFiles,
+			analyzer.MissingFileIgnore,
+			analyzer.MissingFileError,
+			analyzer.MissingFileWarn,
+		)
+	}
+	if s.OutOfOrderDeclarations != "" {
+		opts.OutOfOrder = modeFromString(
+			s.OutOfOrderDeclarations,
+			analyzer.OutOfOrderIgnore,
+			analyzer.OutOfOrderError,
+			analyzer.OutOfOrderWarn,
+		)
+	}
+	if s.LowContrast != "" {
+		opts.LowContrast = modeFromString(
+			s.LowContrast,
+			analyzer.ContrastIgnore,
+			analyzer.ContrastError,
+			analyzer.ContrastWarn,
+		)
+	}
+	return opts
+}
+
+// contrastOptions converts the contrast settings. Pairs map the
+// selector of a text color to that of the background it is shown
+// over, and are sorted so diagnostics come in a stable order.
+func (s *ServerSettings) contrastOptions() analyzer.ContrastOptions {
+	opts := analyzer.ContrastOptions{
+		APCA:   s.ContrastAlgorithm == "apca",
+		Scheme: s.colorScheme(),
+	}
+	if s.ContrastLevel == "AAA" {
+		opts.Level = analyzer.ContrastAAA
+	}
+	for _, fg := range slices.Sorted(maps.Keys(s.ContrastPairs)) {
+		opts.Pairs = append(opts.Pairs, analyzer.ContrastPair{
+			Foreground: fg,
+			Background: s.ContrastPairs[fg],
+		})
+	}
+	return opts
+}
+
+// colorScheme converts the colorScheme setting: "light" or
+// "dark" has light-dark() take that color; otherwise both of its
+// colors get swatches.
+func (s *ServerSettings) colorScheme() analyzer.ColorScheme {
+	switch s.ColorScheme {
+	case "light":
+		return analyzer.ColorSchemeLight
+	case "dark":
+		return analyzer.ColorSchemeDark
+	}
+	return analyzer.ColorSchemeBoth
+}
+
+// declarationOrder converts the declaration order settings. A
+// property list alone asks for it to be the order.
+func (s *ServerSettings) declarationOrder() analyzer.DeclarationOrder {
+	order := analyzer.DeclarationOrder{Properties: s.PropertyOrder}
+	switch s.DeclarationOrder {
+	case "alphabetical":
+		order.Strategy = analyzer.OrderAlphabetical
+	case "concentric":
+		order.Strategy = analyzer.OrderConcentric
+	case "custom":
+		order.Strategy = analyzer.OrderCustom
+	case "":
+		if len(s.PropertyOrder) > 0 {
+			order.Strategy = analyzer.OrderCustom
+		}
+	}
+	return order
+}
+
+// formatOptions returns the formatter options for the settings
+// combined with the editor's tab preferences.
+func (s *ServerSettings) formatOptions(
+	tabSize int,
+	insertSpaces bool,
+) analyzer.FormatOptions {
+	opts := analyzer.FormatOptions{
+		TabSize:      tabSize,
+		InsertSpaces: insertSpaces,
+		PrintWidth:   s.PrintWidth,
+		Order:        s.declarationOrder(),
+		Values:       s.valueOptions(),
+		Selectors:    s.selectorOptions(),
+	}
+	switch s.FormatMode {
+	case "compact":
+		opts.Mode = analyzer.FormatCompact
+	case "preserve":
+		opts.Mode = analyzer.FormatPreserve
+	case "detect":
+		opts.Mode = analyzer.FormatDetect
+	}
+	return opts
+}
+
+// valueOptions converts the value normalization settings.
+// Unrecognized strings leave values as written.
+func (s *ServerSettings) valueOptions() analyzer.ValueOptions {
+	opts := analyzer.ValueOptions{
+		TrimTrailingZeros: s.TrimTrailingZeros,
+		RemoveZeroUnits:   s.RemoveZeroUnits,
+		LowercaseKeywords: s.LowercaseKeywords,
+		LowercaseUnits:    s.LowercaseUnits,
+	}
+	switch s.HexCase {
+	case "lower":
+		opts.HexCase = analyzer.HexLower
+	case "upper":
+		opts.HexCase = analyzer.HexUpper
+	}
+	switch s.HexLength {
+	case "short":
+		opts.HexLength = analyzer.HexShort
+	case "long":
+		opts.HexLength = analyzer.HexLong
+	}
+	switch s.LeadingZero {
+	case "add":
+		opts.LeadingZero = analyzer.LeadingZeroAdd
+	case "remove":
+		opts.LeadingZero = analyzer.LeadingZeroRemove
+	}
+	opts.Quotes = quoteStyle(s.Quotes)
+	switch s.URLQuotes {
+	case "quoted":
+		opts.URLQuotes = analyzer.URLQuoted
+	case "unquoted":
+		opts.URLQuotes = analyzer.URLUnquoted
+	}
+	switch s.ColorNotation {
+	case "hex":
+		opts.Colors = analyzer.ColorHex
+	case "rgb":
+		opts.Colors = analyzer.ColorRGB
+	case "hsl":
+		opts.Colors = analyzer.ColorHSL
+	case "hwb":
+		opts.Colors = analyzer.ColorHWB
+	case "lab":
+		opts.Colors = analyzer.ColorLab
+	case "lch":
+		opts.Colors = analyzer.ColorLCH
+	case "oklab":
+		opts.Colors = analyzer.ColorOKLab
+	case "oklch":
+		opts.Colors = analyzer.ColorOKLCH
+	}
+	return opts
+}
+
+// selectorOptions converts the selector settings. Unrecognized
+// strings leave selectors as written.
+func (s *ServerSettings) selectorOptions() analyzer.SelectorOptions {
+	opts := analyzer.SelectorOptions{
+		OnePerLine:      s.SelectorPerLine,
+		Wrap:            s.WrapSelectors,
+		Spacing:         s.SelectorSpacing,
+		AttributeQuotes: quoteStyle(s.AttributeQuotes),
+		LowercasePseudo: s.LowercasePseudo,
+	}
+	switch s.PseudoElementColons {
+	case "double":
+		opts.PseudoElements = analyzer.PseudoColonsDouble
+	case "single":
+		opts.PseudoElements = analyzer.PseudoColonsSingle
+	}
+	return opts
+}
+
+// quoteStyle converts a quote setting.
+func quoteStyle(s string) analyzer.QuoteStyle {
+	switch s {
+	case "double":
+		return analyzer.QuoteDouble
+	case "single":
+		return analyzer.QuoteSingle
+	}
+	return analyzer.QuoteAsIs
+}
+
+// modeFromString converts a setting string to a mode enum.
+func modeFromString[T ~int](s string, ignore, err, warn T) T {
+	switch s {
+	case "ignore":
+		return ignore
+	case "error":
+		return err
+	default:
+		return warn
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/toba/css-lsp/internal/css/workspace"
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+// --- server.WatchedFilesHandler ---
+
+// WatchPatterns returns the files whose changes on disk affect
+// the workspace index: stylesheets and markup with any of the
+// configured extensions, and .gitignore files. Images and fonts
+// are watched too, for the files url() quick fixes suggest, and
+// .editorconfig files, for the options of formatting.
+func (h *cssHandler) WatchPatterns() []string {
+	patterns := []string{"**/*.css"}
+	add := func(exts []string) {
+		for _, ext := range exts {
+			if p := "**/*" + ext; !slices.Contains(patterns, p) {
+				patterns = append(patterns, p)
+			}
+		}
+	}
+	h.mu.RLock()
+	for _, r := range h.roots {
+		add(r.filter.Extensions())
+	}
+	h.mu.RUnlock()
+	add(workspace.AssetExtensions())
+	return append(patterns,
+		"**/"+workspace.GitignoreFile, "**/"+workspace.EditorConfigFile,
+	)
+}
+
+// DidChangeWatchedFiles keeps the workspace index current with
+// changes made outside the editor. Open documents are skipped:
+// the editor's buffer takes precedence over the file on disk.
+func (h *cssHandler) DidChangeWatchedFiles(
+	_ context.Context,
+	changes []protocol.FileEvent,
+) {
+	var rescan []*workspaceRoot
+	for _, c := range changes {
+		uri := string(c.URI)
+		path := pathutil.URIToFilePath(uri)
+		if path == "" {
+			continue
+		}
+		if filepath.Base(path) == workspace.EditorConfigFile {
+			h.editorConfigs.Forget(path)
+			continue
+		}
+		root := h.rootFor(uri)
+		if filepath.Base(path) == workspace.GitignoreFile {
+			if root.filter != nil && !slices.Contains(rescan, root) {
+				rescan = append(rescan, root)
+			}
+			continue
+		}
+		if c.Type != protocol.FileChangeTypeChanged {
+			root.resolver.Refresh()
+		}
+		if root.filter != nil && !root.filter.IsStylesheet(path) &&
+			!root.filter.IsMarkup(path) {
+			continue
+		}
+		if h.isOpen(uri) {
+			continue
+		}
+
+		h.evict(uri)
+		switch c.Type {
+		case protocol.FileChangeTypeCreated, protocol.FileChangeTypeChanged:
+			h.reindexFromDisk(uri)
+		case protocol.FileChangeTypeDeleted:
+			h.removeFromIndexes(uri)
+		}
+	}
+	for _, r := range rescan {
+		h.rescanRoot(r)
+	}
+}
+
+// reindexFromDisk indexes the custom properties or markup names of
+// the file at uri as saved on disk, or removes it from the index
+// if it can't be read or its workspace folder doesn't index it.
+func (h *cssHandler) reindexFromDisk(uri string) {
+	path := pathutil.URIToFilePath(uri)
+	root := h.rootFor(uri)
+	if path == "" || (root.filter != nil && !root.filter.Includes(path)) {
+		h.removeFromIndexes(uri)
+		return
+	}
+	src, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		h.removeFromIndexes(uri)
+		return
+	}
+	if root.filter == nil || root.filter.IsStylesheet(path) {
+		root.index.IndexFile(uri, src)
+	}
+	if root.indexesMarkup(uri) {
+		root.index.IndexMarkup(uri, src)
+	}
+}
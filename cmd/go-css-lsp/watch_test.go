@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestDidChangeWatchedFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokens := writeFile(t, dir, "tokens.css", ":root { --old: red; }")
+	h := newTestHandler(t, dir)
+	ctx := context.Background()
+
+	// Changed on disk.
+	writeFile(t, dir, "tokens.css", ":root { --new: red; }")
+	// Created on disk.
+	theme := writeFile(t, dir, "theme.css", ":root { --theme: blue; }")
+	// Created in a directory scanning skips.
+	vendored := writeFile(t, dir, "node_modules/pkg/pkg.css", ":root { --pkg: 1; }")
+
+	h.DidChangeWatchedFiles(ctx, []protocol.FileEvent{
+		{Type: protocol.FileChangeTypeChanged, URI: protocol.DocumentURI(tokens)},
+		{Type: protocol.FileChangeTypeCreated, URI: protocol.DocumentURI(theme)},
+		{Type: protocol.FileChangeTypeCreated, URI: protocol.DocumentURI(vendored)},
+	})
+
+	if h.varIndex.HasVariable("--old") || !h.varIndex.HasVariable("--new") {
+		t.Error("changed file was not re-indexed")
+	}
+	if !h.varIndex.HasVariable("--theme") {
+		t.Error("created file was not indexed")
+	}
+	if h.varIndex.HasVariable("--pkg") {
+		t.Error("file in a skipped directory was indexed")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "theme.css")); err != nil {
+		t.Fatal(err)
+	}
+	h.DidChangeWatchedFiles(ctx, []protocol.FileEvent{
+		{Type: protocol.FileChangeTypeDeleted, URI: protocol.DocumentURI(theme)},
+	})
+	if h.varIndex.HasVariable("--theme") {
+		t.Error("deleted file is still indexed")
+	}
+}
+
+func TestDidChangeWatchedFilesEscapedPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my dir")
+	theme := writeFile(t, dir, "theme.css", ":root { --old: red; }")
+	if !strings.Contains(theme, "my%20dir") {
+		t.Fatalf("URI %s is not escaped", theme)
+	}
+	h := newTestHandler(t, dir)
+	ctx := context.Background()
+
+	writeFile(t, dir, "theme.css", ":root { --new: red; }")
+	h.DidChangeWatchedFiles(ctx, []protocol.FileEvent{
+		{Type: protocol.FileChangeTypeChanged, URI: protocol.DocumentURI(theme)},
+	})
+	if files := h.varIndex.Files(); !slices.Equal(files, []string{theme}) {
+		t.Errorf("indexed files = %q, want %q", files, theme)
+	}
+	if h.varIndex.HasVariable("--old") || !h.varIndex.HasVariable("--new") {
+		t.Error("changed file was not re-indexed")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "theme.css")); err != nil {
+		t.Fatal(err)
+	}
+	h.DidChangeWatchedFiles(ctx, []protocol.FileEvent{
+		{Type: protocol.FileChangeTypeDeleted, URI: protocol.DocumentURI(theme)},
+	})
+	if h.varIndex.HasVariable("--new") {
+		t.Error("deleted file is still indexed")
+	}
+}
+
+func TestDidChangeWatchedFilesSkipsOpenDocuments(t *testing.T) {
+	dir := t.TempDir()
+	tokens := writeFile(t, dir, "tokens.css", ":root { --disk: red; }")
+	h := newTestHandler(t, dir)
+	ctx := context.Background()
+
+	h.DidOpen(ctx, protocol.DocumentURI(tokens), ":root { --buffer: red; }")
+	h.DidChangeWatchedFiles(ctx, []protocol.FileEvent{
+		{Type: protocol.FileChangeTypeChanged, URI: protocol.DocumentURI(tokens)},
+	})
+
+	if !h.varIndex.HasVariable("--buffer") || h.varIndex.HasVariable("--disk") {
+		t.Error("the open buffer should take precedence over the file on disk")
+	}
+}
+
+func TestDidCloseEvictsAndReindexesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	tokens := writeFile(t, dir, "tokens.css", ":root { --saved: red; }")
+	h := newTestHandler(t, dir)
+	ctx := context.Background()
+	uri := protocol.DocumentURI(tokens)
+
+	h.DidOpen(ctx, uri, ":root { --unsaved: red; }")
+	h.DidClose(ctx, uri)
+
+	if h.getRawFile(tokens) != nil || h.getParsedFile(tokens) != nil {
+		t.Error("closed document is still cached")
+	}
+	if h.varIndex.HasVariable("--unsaved") || !h.varIndex.HasVariable("--saved") {
+		t.Error("closing should discard unsaved definitions")
+	}
+
+	untitled := protocol.DocumentURI("untitled:Untitled-1")
+	h.DidOpen(ctx, untitled, ":root { --scratch: red; }")
+	h.DidClose(ctx, untitled)
+	if h.varIndex.HasVariable("--scratch") {
+		t.Error("closing an unsaved document should drop its definitions")
+	}
+}
+
+func TestScanSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".gitignore", "build/\n")
+	writeFile(t, dir, "build/out.css", ":root { --build: 1; }")
+	writeFile(t, dir, "src/a.pcss", ":root { --post: 1; }")
+	writeFile(t, dir, "src/big.css", ":root { --big: 1; }"+strings.Repeat(" ", 100))
+	writeFile(t, dir, "legacy/old.css", ":root { --legacy: 1; }")
+
+	h := newMultiRootHandler(t,
+		map[string]any{
+			"extensions":  []any{"pcss"},
+			"exclude":     []any{"legacy/"},
+			"maxFileSize": float64(64),
+		},
+		folder(dir, "test"),
+	)
+	for name, want := range map[string]bool{
+		"--build": false, "--post": true, "--big": false, "--legacy": false,
+	} {
+		if got := h.varIndex.HasVariable(name); got != want {
+			t.Errorf("%s indexed = %v, want %v", name, got, want)
+		}
+	}
+	// Images and fonts follow the indexed files; .editorconfig
+	// files come last.
+	if patterns := h.WatchPatterns(); len(patterns) < 8 || !slices.Equal(
+		patterns[:6],
+		[]string{
+			"**/*.css", "**/*.html", "**/*.htm", "**/*.pcss",
+			"**/*.jsx", "**/*.tsx",
+		},
+	) || !slices.Contains(patterns, "**/*.png") ||
+		!slices.Equal(
+			patterns[len(patterns)-2:],
+			[]string{"**/.gitignore", "**/.editorconfig"},
+		) {
+		t.Errorf("WatchPatterns = %v", patterns)
+	}
+
+	h = newMultiRootHandler(t,
+		map[string]any{"useGitignore": false},
+		folder(dir, "test"),
+	)
+	if !h.varIndex.HasVariable("--build") {
+		t.Error("useGitignore false should index ignored files")
+	}
+}
+
+func TestDidChangeWatchedGitignore(t *testing.T) {
+	dir := t.TempDir()
+	gitignore := writeFile(t, dir, ".gitignore", "")
+	writeFile(t, dir, "gen/tokens.css", ":root { --gen: 1; }")
+	writeFile(t, dir, "app.css", ":root { --app: 1; }")
+	h := newTestHandler(t, dir)
+	ctx := context.Background()
+
+	if !h.varIndex.HasVariable("--gen") {
+		t.Fatal("expected gen/tokens.css to be indexed at first")
+	}
+
+	writeFile(t, dir, ".gitignore", "gen/\n")
+	h.DidChangeWatchedFiles(ctx, []protocol.FileEvent{
+		{Type: protocol.FileChangeTypeChanged, URI: protocol.DocumentURI(gitignore)},
+	})
+	if h.varIndex.HasVariable("--gen") || !h.varIndex.HasVariable("--app") {
+		t.Error("newly ignored file should be dropped from the index")
+	}
+
+	if err := os.Remove(filepath.Join(dir, ".gitignore")); err != nil {
+		t.Fatal(err)
+	}
+	h.DidChangeWatchedFiles(ctx, []protocol.FileEvent{
+		{Type: protocol.FileChangeTypeDeleted, URI: protocol.DocumentURI(gitignore)},
+	})
+	if !h.varIndex.HasVariable("--gen") {
+		t.Error("file no longer ignored should be indexed again")
+	}
+}
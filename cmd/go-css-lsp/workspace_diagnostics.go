@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/toba/css-lsp/internal/css"
+	"github.com/toba/css-lsp/internal/css/embedded"
+	"github.com/toba/css-lsp/internal/lineindex"
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+// workspaceDiagnostics caches the diagnostics of a file that
+// isn't open. They stay valid while the file is unchanged on
+// disk and the workspace index reports the same version.
+type workspaceDiagnostics struct {
+	modTime time.Time
+	size    int64
+	version uint64
+	diags   []protocol.Diagnostic
+}
+
+// --- server.InterFileHandler ---
+
+// WorkspaceVersion changes whenever the set of custom properties
+// defined in the workspace does, which can change undefined
+// variable diagnostics in any file. Adding or removing a
+// workspace folder changes it too.
+func (h *cssHandler) WorkspaceVersion() uint64 {
+	h.mu.RLock()
+	version := h.versionBase
+	h.mu.RUnlock()
+	for _, idx := range h.indexes() {
+		version += idx.Version()
+	}
+	return version
+}
+
+// --- server.WorkspaceDiagnosticsHandler ---
+
+// WorkspaceDiagnostics lints every indexed workspace file that
+// isn't open, reading it from disk.
+func (h *cssHandler) WorkspaceDiagnostics(
+	ctx context.Context,
+) (map[protocol.DocumentURI][]protocol.Diagnostic, error) {
+	version := h.WorkspaceVersion()
+	out := make(map[protocol.DocumentURI][]protocol.Diagnostic)
+
+	var files []string
+	for _, idx := range h.indexes() {
+		files = append(files, idx.Files()...)
+	}
+	slices.Sort(files)
+
+	for _, uri := range slices.Compact(files) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if h.isOpen(uri) {
+			continue
+		}
+		diags, ok := h.fileDiagnostics(uri, version)
+		if ok {
+			out[protocol.DocumentURI(uri)] = diags
+		}
+	}
+
+	return out, nil
+}
+
+// fileDiagnostics returns the diagnostics for a file on disk,
+// reusing cached results while they remain valid.
+func (h *cssHandler) fileDiagnostics(
+	uri string,
+	version uint64,
+) ([]protocol.Diagnostic, bool) {
+	path := pathutil.URIToFilePath(uri)
+	if path == "" {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+
+	h.mu.RLock()
+	cached, ok := h.wsDiags[uri]
+	h.mu.RUnlock()
+	if ok && cached.version == version && cached.size == info.Size() &&
+		cached.modTime.Equal(info.ModTime()) {
+		return cached.diags, true
+	}
+
+	src, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, false
+	}
+	ix := lineindex.New(src)
+	var doc *embedded.Document
+	if embedded.IsHTML(uri) {
+		doc = embedded.Extract(src)
+		src = doc.CSS
+	}
+	diags := h.toProtocolDiagnostics(h.lint(uri, css.Parse(src), src, doc), ix)
+
+	h.mu.Lock()
+	h.wsDiags[uri] = workspaceDiagnostics{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		version: version,
+		diags:   diags,
+	}
+	h.mu.Unlock()
+
+	return diags, true
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toba/lsp/pathutil"
+	"go.lsp.dev/protocol"
+)
+
+// writeFile writes content to name under dir and returns its URI.
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return pathutil.FilePathToURI(path)
+}
+
+// newTestHandler returns a handler initialized on dir.
+func newTestHandler(t *testing.T, dir string) *cssHandler {
+	t.Helper()
+	h := newCSSHandler()
+	_, err := h.Initialize(context.Background(), &protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{
+			URI: string(pathutil.FilePathToURI(dir)), Name: "test",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Initialized(nil)
+	return h
+}
+
+// warnUndefined are initialization options that opt in to
+// undefined variable diagnostics.
+var warnUndefined = map[string]any{"undefinedVariables": "warning"}
+
+func hasMessage(diags []protocol.Diagnostic, substr string) bool {
+	for _, d := range diags {
+		if strings.Contains(d.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWorkspaceDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	tokens := writeFile(t, dir, "tokens.css", ":root { --brand: red; }")
+	app := writeFile(t, dir, "app.css",
+		"a { color: var(--brand); background: var(--missing); colr: red; }")
+	open := writeFile(t, dir, "open.css", "a { colr: red; }")
+
+	h := newMultiRootHandler(t, warnUndefined, folder(dir, "test"))
+	h.DidOpen(context.Background(), protocol.DocumentURI(open), "a { }")
+
+	all, err := h.WorkspaceDiagnostics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := all[protocol.DocumentURI(open)]; ok {
+		t.Error("open documents should not be linted from disk")
+	}
+	if diags, ok := all[protocol.DocumentURI(tokens)]; !ok || len(diags) != 0 {
+		t.Errorf("tokens.css diagnostics = %v", diags)
+	}
+
+	diags := all[protocol.DocumentURI(app)]
+	if !hasMessage(diags, "unknown property 'colr'") {
+		t.Errorf("expected unknown property in unopened file, got %v", diags)
+	}
+	if !hasMessage(diags, "undefined custom property '--missing'") {
+		t.Errorf("expected undefined --missing, got %v", diags)
+	}
+	if hasMessage(diags, "'--brand'") {
+		t.Errorf("--brand is defined in tokens.css, got %v", diags)
+	}
+}
+
+func TestWorkspaceDiagnosticsFollowDependencies(t *testing.T) {
+	dir := t.TempDir()
+	app := writeFile(t, dir, "app.css", "a { color: var(--brand); }")
+	tokens := writeFile(t, dir, "tokens.css", ":root { --other: red; }")
+
+	h := newMultiRootHandler(t, warnUndefined, folder(dir, "test"))
+	ctx := context.Background()
+
+	all, _ := h.WorkspaceDiagnostics(ctx)
+	if !hasMessage(all[protocol.DocumentURI(app)], "'--brand'") {
+		t.Fatal("expected --brand to be undefined at first")
+	}
+
+	// Defining the variable in an open document changes the
+	// workspace version and clears the finding elsewhere.
+	before := h.WorkspaceVersion()
+	h.DidOpen(ctx, protocol.DocumentURI(tokens), ":root { --brand: red; }")
+	if h.WorkspaceVersion() == before {
+		t.Error("expected workspace version to change")
+	}
+
+	all, _ = h.WorkspaceDiagnostics(ctx)
+	if hasMessage(all[protocol.DocumentURI(app)], "'--brand'") {
+		t.Error("expected --brand to be defined after the edit")
+	}
+}
+
+func TestWorkspaceDiagnosticsSkipEscapedOpenDocuments(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my dir@2x")
+	open := writeFile(t, dir, "open.css", "a { colr: red; }")
+	h := newTestHandler(t, dir)
+
+	// Clients escape more than the index does, "@" included.
+	client := strings.ReplaceAll(open, "@", "%40")
+	h.DidOpen(context.Background(), protocol.DocumentURI(client), "a { }")
+
+	all, err := h.WorkspaceDiagnostics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Errorf("open documents should not be linted from disk, got %v", all)
+	}
+}
@@ -18,6 +18,7 @@ const (
 	KindValue    = 12
 	KindFunction = 3
 	KindColor    = 16
+	KindClass    = 7
 )
 
 // SymbolKind mirrors LSP SymbolKind values.
@@ -76,12 +77,129 @@ const (
 	UnknownValueError
 )
 
+// UndefinedVariableMode controls how var() references to
+// custom properties that are defined nowhere in the workspace
+// are reported.
+type UndefinedVariableMode int
+
+const (
+	// UndefinedVariableIgnore suppresses undefined variable
+	// diagnostics (default).
+	UndefinedVariableIgnore UndefinedVariableMode = iota
+	// UndefinedVariableWarn emits a warning diagnostic.
+	UndefinedVariableWarn
+	// UndefinedVariableError treats undefined variables as
+	// errors.
+	UndefinedVariableError
+)
+
+// UnresolvedImportMode controls how @import rules whose
+// stylesheet can't be found are reported.
+type UnresolvedImportMode int
+
+const (
+	// UnresolvedImportWarn emits a warning diagnostic (default).
+	UnresolvedImportWarn UnresolvedImportMode = iota
+	// UnresolvedImportIgnore suppresses unresolved import
+	// diagnostics.
+	UnresolvedImportIgnore
+	// UnresolvedImportError treats unresolved imports as errors.
+	UnresolvedImportError
+)
+
+// ImportCycleMode controls how @import rules that lead back to
+// the stylesheet being analyzed are reported.
+type ImportCycleMode int
+
+const (
+	// ImportCycleWarn emits a warning diagnostic (default).
+	ImportCycleWarn ImportCycleMode = iota
+	// ImportCycleIgnore suppresses import cycle diagnostics.
+	ImportCycleIgnore
+	// ImportCycleError treats import cycles as errors.
+	ImportCycleError
+)
+
+// ModuleReferenceMode controls how a CSS module's references to
+// other modules are reported when the module can't be found or
+// doesn't define the class or value named.
+type ModuleReferenceMode int
+
+const (
+	// ModuleReferenceWarn emits a warning diagnostic (default).
+	ModuleReferenceWarn ModuleReferenceMode = iota
+	// ModuleReferenceIgnore suppresses module reference
+	// diagnostics.
+	ModuleReferenceIgnore
+	// ModuleReferenceError treats broken module references as
+	// errors.
+	ModuleReferenceError
+)
+
+// UnusedSelectorMode controls how class and id selectors that
+// no markup in the workspace uses are reported.
+type UnusedSelectorMode int
+
+const (
+	// UnusedSelectorIgnore suppresses unused selector
+	// diagnostics (default).
+	UnusedSelectorIgnore UnusedSelectorMode = iota
+	// UnusedSelectorWarn emits a warning diagnostic.
+	UnusedSelectorWarn
+	// UnusedSelectorError treats unused selectors as errors.
+	UnusedSelectorError
+)
+
+// MissingFileMode controls how url() references to files that
+// don't exist are reported.
+type MissingFileMode int
+
+const (
+	// MissingFileIgnore suppresses missing file diagnostics
+	// (default).
+	MissingFileIgnore MissingFileMode = iota
+	// MissingFileWarn emits a warning diagnostic.
+	MissingFileWarn
+	// MissingFileError treats missing files as errors.
+	MissingFileError
+)
+
+// OutOfOrderMode controls how declarations out of the configured
+// order are reported.
+type OutOfOrderMode int
+
+const (
+	// OutOfOrderIgnore suppresses declaration order diagnostics
+	// (default).
+	OutOfOrderIgnore OutOfOrderMode = iota
+	// OutOfOrderWarn emits a warning diagnostic.
+	OutOfOrderWarn
+	// OutOfOrderError treats declarations out of order as errors.
+	OutOfOrderError
+)
+
 // LintOptions configures analyzer behavior.
 type LintOptions struct {
-	Experimental     ExperimentalMode
-	Deprecated       DeprecatedMode
-	UnknownValues    UnknownValueMode
-	StrictColorNames bool
+	Experimental       ExperimentalMode
+	Deprecated         DeprecatedMode
+	UnknownValues      UnknownValueMode
+	UndefinedVariables UndefinedVariableMode
+	UnusedSelectors    UnusedSelectorMode
+	MissingFiles       MissingFileMode
+	UnresolvedImports  UnresolvedImportMode
+	ImportCycles       ImportCycleMode
+	ModuleReferences   ModuleReferenceMode
+	OutOfOrder         OutOfOrderMode
+	LowContrast        ContrastMode
+	StrictColorNames   bool
+	// Contrast configures the check that LowContrast reports.
+	Contrast ContrastOptions
+	// Order is the declaration order that OutOfOrder checks.
+	Order DeclarationOrder
+	// CSSModules analyzes the stylesheet as a CSS module: composes
+	// and @value are understood, and classes are local unless made
+	// global with :global.
+	CSSModules bool
 }
 
 // Diagnostic represents a diagnostic message.
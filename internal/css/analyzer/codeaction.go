@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/toba/css-lsp/internal/css/data"
+	"github.com/toba/css-lsp/internal/css/parser"
 )
 
 // CodeActionKind constants.
@@ -85,7 +86,7 @@ func findSimilarProperties(name string) []string {
 	var candidates []scored
 
 	for _, prop := range data.AllProperties() {
-		d := editDistance(name, prop.Name)
+		d := EditDistance(name, prop.Name)
 		// Only suggest if distance is small relative to name
 		// length
 		maxDist := max(len(name)/3, 2)
@@ -121,6 +122,30 @@ func FindFixAllActions(diags []Diagnostic) []CodeAction {
 	return actions
 }
 
+// FindDeclarationOrderActions returns the code actions that put
+// each run of declarations out of order in order.
+func FindDeclarationOrderActions(
+	ss *parser.Stylesheet,
+	src []byte,
+	order DeclarationOrder,
+) []CodeAction {
+	var actions []CodeAction
+	for _, fix := range DeclarationOrderFixes(ss, src, order) {
+		line, char := OffsetToLineChar(src, fix.StartPos)
+		endLine, endChar := OffsetToLineChar(src, fix.EndPos)
+		actions = append(actions, CodeAction{
+			Title:       "Sort declarations",
+			Kind:        CodeActionQuickFix,
+			StartLine:   line,
+			StartChar:   char,
+			EndLine:     endLine,
+			EndChar:     endChar,
+			ReplaceWith: fix.NewText,
+		})
+	}
+	return actions
+}
+
 // fixForDiagnostic returns a code action if the diagnostic is
 // auto-fixable.
 func fixForDiagnostic(d Diagnostic) (CodeAction, bool) {
@@ -138,9 +163,9 @@ func fixForDiagnostic(d Diagnostic) (CodeAction, bool) {
 	return CodeAction{}, false
 }
 
-// editDistance computes the Levenshtein distance between two
+// EditDistance computes the Levenshtein distance between two
 // strings.
-func editDistance(a, b string) int {
+func EditDistance(a, b string) int {
 	la := len(a)
 	lb := len(b)
 
@@ -63,10 +63,10 @@ func TestEditDistance(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got := editDistance(tt.a, tt.b)
+		got := EditDistance(tt.a, tt.b)
 		if got != tt.want {
 			t.Errorf(
-				"editDistance(%q, %q) = %d, want %d",
+				"EditDistance(%q, %q) = %d, want %d",
 				tt.a, tt.b, got, tt.want,
 			)
 		}
@@ -10,12 +10,22 @@ import (
 	"github.com/toba/css-lsp/internal/css/scanner"
 )
 
-// Color represents an RGBA color with components in [0,1].
+// Color represents an RGBA color with components in [0,1]. The
+// components are sRGB; a color written in another space also
+// keeps that space and its coordinates there, so converting it
+// doesn't lose what lies beyond the sRGB gamut.
 type Color struct {
 	Red   float64
 	Green float64
 	Blue  float64
 	Alpha float64
+	// Space is the space the color was written in: "lab", "lch",
+	// "oklab", "oklch" or a predefined space of color(), such as
+	// "display-p3". It is empty for the sRGB notations.
+	Space string
+	// Coords are the channels of the color in Space as written,
+	// unclamped, with percentages resolved.
+	Coords [3]float64
 }
 
 // DocumentColor represents a color found in a document with
@@ -52,23 +62,36 @@ func (d *depthLimitedResolver) ResolveVariable(
 	return d.inner.ResolveVariable(name)
 }
 
+// colorContext is what the colors of a value depend on besides
+// its tokens.
+type colorContext struct {
+	// resolver, if set, resolves var() references.
+	resolver VariableResolver
+	// scheme is the color scheme light-dark() follows; with
+	// ColorSchemeBoth, both of its colors are found.
+	scheme ColorScheme
+}
+
 // FindDocumentColors returns all colors found in the CSS
 // document.
 func FindDocumentColors(
 	ss *parser.Stylesheet,
 	src []byte,
 ) []DocumentColor {
-	return FindDocumentColorsResolved(ss, src, nil)
+	return FindDocumentColorsResolved(ss, src, nil, ColorSchemeBoth)
 }
 
 // FindDocumentColorsResolved returns all colors found in the
 // CSS document, resolving var() references through the given
-// resolver.
+// resolver, which may be nil, and taking the colors of
+// light-dark() that scheme asks for.
 func FindDocumentColorsResolved(
 	ss *parser.Stylesheet,
 	src []byte,
 	resolver VariableResolver,
+	scheme ColorScheme,
 ) []DocumentColor {
+	cc := colorContext{resolver: resolver, scheme: scheme}
 	var colors []DocumentColor
 
 	parser.Walk(ss, func(n parser.Node) bool {
@@ -82,7 +105,7 @@ func FindDocumentColorsResolved(
 		colors = append(
 			colors,
 			findColorsInTokens(
-				decl.Value.Tokens, src, resolver,
+				decl.Value.Tokens, src, cc,
 			)...,
 		)
 		return true
@@ -94,7 +117,7 @@ func FindDocumentColorsResolved(
 func findColorsInTokens(
 	tokens []scanner.Token,
 	src []byte,
-	resolver VariableResolver,
+	cc colorContext,
 ) []DocumentColor {
 	var colors []DocumentColor
 
@@ -130,21 +153,48 @@ func findColorsInTokens(
 				"hsl", "hsla",
 				"hwb",
 				"lab", "lch",
-				"oklab", "oklch":
+				"oklab", "oklch",
+				"color":
 				if dc, ok := parseColorFunction(
-					name, tokens[i:], src, resolver,
+					name, tokens[i:], src, cc,
 				); ok {
 					colors = append(colors, dc)
 					// Skip past the closing paren to
 					// avoid matching inner tokens.
 					i = skipPastCloseParen(tokens, i)
 				}
+			case "color-mix":
+				if dc, ok := parseColorMix(
+					tokens[i:], src, cc,
+				); ok {
+					colors = append(colors, dc)
+					i = skipPastCloseParen(tokens, i)
+				}
+			case "light-dark":
+				if cc.scheme != ColorSchemeBoth {
+					if dc, ok := lightDarkColor(
+						tokens[i:], src, cc,
+					); ok {
+						colors = append(colors, dc)
+						i = skipPastCloseParen(tokens, i)
+					}
+					continue
+				}
+				both, found := parseLightDark(
+					tokens[i:], src, cc,
+				)
+				for k, dc := range both {
+					if found[k] {
+						colors = append(colors, dc)
+					}
+				}
+				i = skipPastCloseParen(tokens, i)
 			case "var":
-				if resolver == nil {
+				if cc.resolver == nil {
 					continue
 				}
 				if dc, ok := resolveVarColor(
-					tokens[i:], resolver,
+					tokens[i:], cc,
 				); ok {
 					colors = append(colors, dc)
 					i = skipPastCloseParen(tokens, i)
@@ -183,7 +233,7 @@ func skipPastCloseParen(
 // token.
 func resolveVarColor(
 	tokens []scanner.Token,
-	resolver VariableResolver,
+	cc colorContext,
 ) (DocumentColor, bool) {
 	if len(tokens) < 2 {
 		return DocumentColor{}, false
@@ -225,7 +275,7 @@ resolve:
 		return DocumentColor{}, false
 	}
 
-	rawValue, ok := resolver.ResolveVariable(varName)
+	rawValue, ok := cc.resolver.ResolveVariable(varName)
 	if !ok {
 		return DocumentColor{}, false
 	}
@@ -235,19 +285,22 @@ resolve:
 	// resolution while preventing infinite recursion.
 	valTokens := scanner.ScanAll([]byte(rawValue))
 	var remaining int
-	if dl, ok := resolver.(*depthLimitedResolver); ok {
+	if dl, ok := cc.resolver.(*depthLimitedResolver); ok {
 		remaining = dl.depth
 	} else {
 		remaining = maxVarDepth
 	}
 	nextResolver := &depthLimitedResolver{
-		inner: resolver,
+		inner: cc.resolver,
 		depth: remaining,
 	}
-	if dl, ok := resolver.(*depthLimitedResolver); ok {
+	if dl, ok := cc.resolver.(*depthLimitedResolver); ok {
 		nextResolver.inner = dl.inner
 	}
-	resolved := findColorsInTokens(valTokens, []byte(rawValue), nextResolver)
+	resolved := findColorsInTokens(
+		valTokens, []byte(rawValue),
+		colorContext{resolver: nextResolver, scheme: cc.scheme},
+	)
 	if len(resolved) == 0 {
 		return DocumentColor{}, false
 	}
@@ -357,12 +410,12 @@ func parseColorFunction(
 	name string,
 	tokens []scanner.Token,
 	src []byte,
-	resolver VariableResolver,
+	cc colorContext,
 ) (DocumentColor, bool) {
 	// Check for relative color syntax: func(from <origin> ...)
 	if isRelativeColor(tokens) {
 		return parseRelativeColor(
-			name, tokens, src, resolver,
+			name, tokens, src, cc,
 		)
 	}
 
@@ -372,6 +425,7 @@ func parseColorFunction(
 	var args []float64
 	var isPercent []bool
 	hasSlash := false
+	space := "" // the predefined space of color()
 
 	negateNext := false
 	for j := 1; j < len(tokens); j++ {
@@ -409,6 +463,21 @@ func parseColorFunction(
 			case "-":
 				negateNext = true
 			}
+		case scanner.Dimension:
+			v, ok := angleDegrees(tok.Value)
+			if !ok {
+				return DocumentColor{}, false
+			}
+			if negateNext {
+				v = -v
+				negateNext = false
+			}
+			args = append(args, v)
+			isPercent = append(isPercent, false)
+		case scanner.Ident:
+			if name == "color" && space == "" && len(args) == 0 {
+				space = strings.ToLower(tok.Value)
+			}
 		case scanner.Whitespace, scanner.Comma:
 			continue
 		default:
@@ -440,6 +509,8 @@ done:
 		c, ok = buildOklab(args, isPercent)
 	case "oklch":
 		c, ok = buildOklch(args, isPercent)
+	case "color":
+		c, ok = buildPredefined(space, args, isPercent)
 	default:
 		return DocumentColor{}, false
 	}
@@ -455,6 +526,26 @@ done:
 	}, true
 }
 
+// angleDegrees converts an angle, such as "0.5turn", to degrees.
+func angleDegrees(text string) (float64, bool) {
+	num, unit := splitNumber(text)
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch strings.ToLower(unit) {
+	case "deg":
+		return v, true
+	case "grad":
+		return v * 0.9, true
+	case "rad":
+		return v * 180 / math.Pi, true
+	case "turn":
+		return v * 360, true
+	}
+	return 0, false
+}
+
 func buildRGB(
 	args []float64,
 	isPercent []bool,
@@ -611,13 +702,11 @@ func buildLab(
 		}
 	}
 
-	r, g, bl := labToSRGB(lVal, a, b)
-	return Color{
-		Red:   clamp01(r),
-		Green: clamp01(g),
-		Blue:  clamp01(bl),
-		Alpha: clamp01(alpha),
-	}, true
+	return mapGamut(Color{
+		Alpha:  clamp01(alpha),
+		Space:  "lab",
+		Coords: [3]float64{lVal, a, b},
+	}), true
 }
 
 // buildLCH converts CIE LCH to sRGB.
@@ -639,18 +728,11 @@ func buildLCH(
 		}
 	}
 
-	// LCH to Lab
-	hRad := hue * math.Pi / 180.0
-	a := chroma * math.Cos(hRad)
-	b := chroma * math.Sin(hRad)
-
-	r, g, bl := labToSRGB(lVal, a, b)
-	return Color{
-		Red:   clamp01(r),
-		Green: clamp01(g),
-		Blue:  clamp01(bl),
-		Alpha: clamp01(alpha),
-	}, true
+	return mapGamut(Color{
+		Alpha:  clamp01(alpha),
+		Space:  "lch",
+		Coords: [3]float64{lVal, chroma, hue},
+	}), true
 }
 
 // buildOklab converts Oklab to sRGB.
@@ -674,13 +756,11 @@ func buildOklab(
 		}
 	}
 
-	r, g, bl := oklabToSRGB(lVal, a, b)
-	return Color{
-		Red:   clamp01(r),
-		Green: clamp01(g),
-		Blue:  clamp01(bl),
-		Alpha: clamp01(alpha),
-	}, true
+	return mapGamut(Color{
+		Alpha:  clamp01(alpha),
+		Space:  "oklab",
+		Coords: [3]float64{lVal, a, b},
+	}), true
 }
 
 // buildOklch converts Oklch to sRGB.
@@ -704,25 +784,16 @@ func buildOklch(
 		}
 	}
 
-	// Oklch to Oklab
-	hRad := hue * math.Pi / 180.0
-	a := chroma * math.Cos(hRad)
-	b := chroma * math.Sin(hRad)
-
-	r, g, bl := oklabToSRGB(lVal, a, b)
-	return Color{
-		Red:   clamp01(r),
-		Green: clamp01(g),
-		Blue:  clamp01(bl),
-		Alpha: clamp01(alpha),
-	}, true
+	return mapGamut(Color{
+		Alpha:  clamp01(alpha),
+		Space:  "oklch",
+		Coords: [3]float64{lVal, chroma, hue},
+	}), true
 }
 
-// labToSRGB converts CIE Lab to linear sRGB via XYZ D65.
-func labToSRGB(
-	lVal, a, b float64,
-) (float64, float64, float64) {
-	// Lab to XYZ (D65 white point)
+// labToXYZ converts CIE Lab, relative to D50, to XYZ (D65) by
+// Bradford adaptation.
+func labToXYZ(lVal, a, b float64) [3]float64 {
 	fy := (lVal + 16.0) / 116.0
 	fx := a/500.0 + fy
 	fz := fy - b/200.0
@@ -747,22 +818,18 @@ func labToSRGB(
 		z = (116.0*fz - 16.0) / kappa
 	}
 
-	// D65 white point
-	x *= 0.95047
-	z *= 1.08883
-
-	// XYZ to linear sRGB
-	r := x*3.2404542 - y*1.5371385 - z*0.4985314
-	g := -x*0.9692660 + y*1.8760108 + z*0.0415560
-	bl := x*0.0556434 - y*0.2040259 + z*1.0572252
+	return d50ToD65.apply([3]float64{x * d50White[0], y, z * d50White[2]})
+}
 
-	return linearToSRGB(r), linearToSRGB(g), linearToSRGB(bl)
+// lchToAB converts the chroma and hue, in degrees, of LCH or
+// Oklch to the a and b axes of Lab or Oklab.
+func lchToAB(chroma, hue float64) (float64, float64) {
+	hRad := hue * math.Pi / 180.0
+	return chroma * math.Cos(hRad), chroma * math.Sin(hRad)
 }
 
-// oklabToSRGB converts Oklab to sRGB.
-func oklabToSRGB(
-	lVal, a, b float64,
-) (float64, float64, float64) {
+// oklabToLinear converts Oklab to linear sRGB, unclamped.
+func oklabToLinear(lVal, a, b float64) [3]float64 {
 	// Oklab to LMS
 	l := lVal + 0.3963377774*a + 0.2158037573*b
 	m := lVal - 0.1055613458*a - 0.0638541728*b
@@ -774,11 +841,11 @@ func oklabToSRGB(
 	s = s * s * s
 
 	// LMS to linear sRGB
-	r := +4.0767416621*l - 3.3077115913*m + 0.2309699292*s
-	g := -1.2684380046*l + 2.6097574011*m - 0.3413193965*s
-	bl := -0.0041960863*l - 0.7034186147*m + 1.7076147010*s
-
-	return linearToSRGB(r), linearToSRGB(g), linearToSRGB(bl)
+	return [3]float64{
+		+4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s,
+	}
 }
 
 // linearToSRGB applies sRGB gamma.
@@ -847,30 +914,13 @@ func srgbToLinear(c float64) float64 {
 	return math.Pow((c+0.055)/1.055, 2.4)
 }
 
-// srgbToXYZ converts linear sRGB to CIE XYZ (D65).
-func srgbToXYZ(
-	r, g, b float64,
-) (float64, float64, float64) {
-	rl := srgbToLinear(r)
-	gl := srgbToLinear(g)
-	bl := srgbToLinear(b)
-
-	x := 0.4124564*rl + 0.3575761*gl + 0.1804375*bl
-	y := 0.2126729*rl + 0.7151522*gl + 0.0721750*bl
-	z := 0.0193339*rl + 0.1191920*gl + 0.9503041*bl
-
-	return x, y, z
-}
-
-// srgbToLab converts sRGB [0,1] to CIE Lab.
-func srgbToLab(
-	r, g, b float64,
-) (float64, float64, float64) {
-	x, y, z := srgbToXYZ(r, g, b)
-
-	// D65 white point
-	x /= 0.95047
-	z /= 1.08883
+// xyzToLab converts CIE XYZ (D65) to CIE Lab, relative to D50,
+// by Bradford adaptation.
+func xyzToLab(xyz [3]float64) (float64, float64, float64) {
+	xyz = d65ToD50.apply(xyz)
+	x := xyz[0] / d50White[0]
+	y := xyz[1]
+	z := xyz[2] / d50White[2]
 
 	const epsilon = 216.0 / 24389.0
 	const kappa = 24389.0 / 27.0
@@ -893,14 +943,10 @@ func srgbToLab(
 	return lVal, aVal, bVal
 }
 
-// srgbToOklab converts sRGB [0,1] to Oklab.
-func srgbToOklab(
-	r, g, b float64,
+// linearToOklab converts linear sRGB, in gamut or not, to Oklab.
+func linearToOklab(
+	rl, gl, bl float64,
 ) (float64, float64, float64) {
-	rl := srgbToLinear(r)
-	gl := srgbToLinear(g)
-	bl := srgbToLinear(b)
-
 	l := 0.4122214708*rl + 0.5363325363*gl + 0.0514459929*bl
 	m := 0.2119034982*rl + 0.6806995451*gl + 0.1073969566*bl
 	s := 0.0883024619*rl + 0.2817188376*gl + 0.6299787005*bl
@@ -940,10 +986,10 @@ func colorToHWB(c Color) [4]float64 {
 	return [4]float64{h * 360, w * 100, bk * 100, c.Alpha}
 }
 
-// colorToLab decomposes sRGB Color to [L,a,b,alpha] in
-// CIE Lab native ranges.
+// colorToLab decomposes a Color to [L,a,b,alpha] in CIE Lab
+// native ranges, from the coordinates it was written with.
 func colorToLab(c Color) [4]float64 {
-	l, a, b := srgbToLab(c.Red, c.Green, c.Blue)
+	l, a, b := xyzToLab(c.xyz())
 	return [4]float64{l, a, b, c.Alpha}
 }
 
@@ -958,25 +1004,27 @@ func labToLCH(l, a, b float64) (float64, float64, float64) {
 	return l, c, h
 }
 
-// colorToLCH decomposes sRGB Color to [L,C,H,alpha] in
-// CIE LCH native ranges.
+// colorToLCH decomposes a Color to [L,C,H,alpha] in CIE LCH
+// native ranges, from the coordinates it was written with.
 func colorToLCH(c Color) [4]float64 {
-	l, a, b := srgbToLab(c.Red, c.Green, c.Blue)
+	l, a, b := xyzToLab(c.xyz())
 	lch, ch, h := labToLCH(l, a, b)
 	return [4]float64{lch, ch, h, c.Alpha}
 }
 
-// colorToOklab decomposes sRGB Color to [L,a,b,alpha] in
-// Oklab native ranges.
+// colorToOklab decomposes a Color to [L,a,b,alpha] in Oklab
+// native ranges, from the coordinates it was written with.
 func colorToOklab(c Color) [4]float64 {
-	l, a, b := srgbToOklab(c.Red, c.Green, c.Blue)
+	rgb := xyzToLinearSRGB.apply(c.xyz())
+	l, a, b := linearToOklab(rgb[0], rgb[1], rgb[2])
 	return [4]float64{l, a, b, c.Alpha}
 }
 
-// colorToOklch decomposes sRGB Color to [L,C,H,alpha] in
-// Oklch native ranges.
+// colorToOklch decomposes a Color to [L,C,H,alpha] in Oklch
+// native ranges, from the coordinates it was written with.
 func colorToOklch(c Color) [4]float64 {
-	l, a, b := srgbToOklab(c.Red, c.Green, c.Blue)
+	rgb := xyzToLinearSRGB.apply(c.xyz())
+	l, a, b := linearToOklab(rgb[0], rgb[1], rgb[2])
 	lch, ch, h := labToLCH(l, a, b)
 	return [4]float64{lch, ch, h, c.Alpha}
 }
@@ -1023,14 +1071,15 @@ func isRelativeColor(tokens []scanner.Token) bool {
 }
 
 // parseOriginColor parses the origin color in a relative color
-// expression. It handles: hex, named color, color function, or
+// expression, or a color argument of color-mix() or
+// light-dark(). It handles: hex, named color, color function, or
 // var(). Returns the color, the token index after the origin,
 // and success.
 func parseOriginColor(
 	tokens []scanner.Token,
 	start int,
 	src []byte,
-	resolver VariableResolver,
+	cc colorContext,
 ) (Color, int, bool) {
 	for i := start; i < len(tokens); i++ {
 		tok := tokens[i]
@@ -1047,8 +1096,9 @@ func parseOriginColor(
 			return c, i + 1, true
 
 		case scanner.Ident:
-			c, ok := namedColorMap[strings.ToLower(tok.Value)]
-			if !ok {
+			name := strings.ToLower(tok.Value)
+			c, ok := namedColorMap[name]
+			if !ok || name == "currentcolor" {
 				return Color{}, i, false
 			}
 			return c, i + 1, true
@@ -1057,7 +1107,7 @@ func parseOriginColor(
 			name := strings.ToLower(tok.Value)
 			switch name {
 			case "rgb", "rgba", "hsl", "hsla", "hwb",
-				"lab", "lch", "oklab", "oklch":
+				"lab", "lch", "oklab", "oklch", "color":
 				j := skipPastCloseParen(tokens, i)
 				if j == i {
 					return Color{}, i, false
@@ -1066,15 +1116,30 @@ func parseOriginColor(
 					name,
 					tokens[i:j+1],
 					src,
-					resolver,
+					cc,
 				)
 				if !ok {
 					return Color{}, j + 1, false
 				}
 				return dc.Color, j + 1, true
 
+			case "color-mix", "light-dark":
+				j := skipPastCloseParen(tokens, i)
+				if j == i {
+					return Color{}, i, false
+				}
+				parse := parseColorMix
+				if name == "light-dark" {
+					parse = lightDarkColor
+				}
+				dc, ok := parse(tokens[i:j+1], src, cc)
+				if !ok {
+					return Color{}, j + 1, false
+				}
+				return dc.Color, j + 1, true
+
 			case "var":
-				if resolver == nil {
+				if cc.resolver == nil {
 					return Color{}, i, false
 				}
 				j := skipPastCloseParen(tokens, i)
@@ -1083,7 +1148,7 @@ func parseOriginColor(
 				}
 				dc, ok := resolveVarColor(
 					tokens[i:j+1],
-					resolver,
+					cc,
 				)
 				if !ok {
 					return Color{}, j + 1, false
@@ -1103,7 +1168,7 @@ func parseRelativeColor(
 	name string,
 	tokens []scanner.Token,
 	src []byte,
-	resolver VariableResolver,
+	cc colorContext,
 ) (DocumentColor, bool) {
 	channels, ok := colorSpaceChannels[name]
 	if !ok {
@@ -1130,7 +1195,7 @@ func parseRelativeColor(
 
 	// Parse origin color.
 	origin, nextIdx, ok := parseOriginColor(
-		tokens, i, src, resolver,
+		tokens, i, src, cc,
 	)
 	if !ok {
 		return DocumentColor{}, false
@@ -1340,64 +1405,13 @@ func parseChannelExpr(
 }
 
 // ColorPresentation returns alternative representations of a
-// color value.
+// color value: hex, rgb(), hsl(), hwb(), lab(), lch(), oklab(),
+// oklch() and color(display-p3), then its name if one is exactly
+// the color. The hex, rgb(), hsl() and hwb() ones use the
+// gamut-mapped channels; the others are converted from the
+// coordinates the color was written with.
 func ColorPresentation(c Color) []string {
-	r := int(math.Round(c.Red * 255))
-	g := int(math.Round(c.Green * 255))
-	b := int(math.Round(c.Blue * 255))
-
-	var presentations []string
-
-	// Hex
-	if c.Alpha >= 1.0 {
-		presentations = append(presentations,
-			"#"+hexStr(r)+hexStr(g)+hexStr(b),
-		)
-	} else {
-		a := int(math.Round(c.Alpha * 255))
-		presentations = append(presentations,
-			"#"+hexStr(r)+hexStr(g)+hexStr(b)+hexStr(a),
-		)
-	}
-
-	// RGB (modern space-separated syntax)
-	if c.Alpha >= 1.0 {
-		presentations = append(presentations,
-			"rgb("+strconv.Itoa(r)+" "+
-				strconv.Itoa(g)+" "+
-				strconv.Itoa(b)+")",
-		)
-	} else {
-		presentations = append(presentations,
-			"rgb("+strconv.Itoa(r)+" "+
-				strconv.Itoa(g)+" "+
-				strconv.Itoa(b)+" / "+
-				formatAlpha(c.Alpha)+")",
-		)
-	}
-
-	// HSL (modern space-separated syntax)
-	h, s, l := rgbToHSL(c.Red, c.Green, c.Blue)
-	hDeg := int(math.Round(h * 360))
-	sPct := int(math.Round(s * 100))
-	lPct := int(math.Round(l * 100))
-
-	if c.Alpha >= 1.0 {
-		presentations = append(presentations,
-			"hsl("+strconv.Itoa(hDeg)+" "+
-				strconv.Itoa(sPct)+"% "+
-				strconv.Itoa(lPct)+"%)",
-		)
-	} else {
-		presentations = append(presentations,
-			"hsl("+strconv.Itoa(hDeg)+" "+
-				strconv.Itoa(sPct)+"% "+
-				strconv.Itoa(lPct)+"% / "+
-				formatAlpha(c.Alpha)+")",
-		)
-	}
-
-	return presentations
+	return colorPresentations(c, colorStyle{})
 }
 
 func hexStr(v int) string {
@@ -1408,11 +1422,6 @@ func hexStr(v int) string {
 	return s
 }
 
-func formatAlpha(a float64) string {
-	pct := int(math.Round(a * 100))
-	return strconv.Itoa(pct) + "%"
-}
-
 func rgbToHSL(
 	r, g, b float64,
 ) (float64, float64, float64) {
@@ -1538,15 +1547,17 @@ func init() {
 	namedColorMap = make(map[string]Color, len(data.NamedColors))
 	for _, name := range data.NamedColors {
 		if c, ok := namedColorRGBA[name]; ok {
-			namedColorMap[name] = c
+			namedColorMap[name] = Color{
+				Red: c[0], Green: c[1], Blue: c[2], Alpha: c[3],
+			}
 		}
 	}
 }
 
-// namedColorRGBA provides RGB values for named colors.
+// namedColorRGBA provides RGBA values for named colors.
 // This covers a representative set; transparent and
 // currentcolor are handled specially.
-var namedColorRGBA = map[string]Color{
+var namedColorRGBA = map[string][4]float64{
 	"black":                {0, 0, 0, 1},
 	"silver":               {0.753, 0.753, 0.753, 1},
 	"gray":                 {0.502, 0.502, 0.502, 1},
@@ -0,0 +1,363 @@
+package analyzer
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/scanner"
+)
+
+// ColorScheme selects which color of light-dark() colors are
+// found with.
+type ColorScheme int
+
+const (
+	// ColorSchemeBoth shows a swatch for each color of
+	// light-dark() (default). Where light-dark() is an input of
+	// another color, as in color-mix(), it is its light color, as
+	// it is for pages without a color-scheme.
+	ColorSchemeBoth ColorScheme = iota
+	// ColorSchemeLight takes the light color of light-dark().
+	ColorSchemeLight
+	// ColorSchemeDark takes the dark color of light-dark().
+	ColorSchemeDark
+)
+
+// hueMethods are the hue interpolation methods of color-mix().
+var hueMethods = []string{"shorter", "longer", "increasing", "decreasing"}
+
+// polarHue maps the polar interpolation spaces of color-mix() to
+// the index of their hue channel.
+var polarHue = map[string]int{"hsl": 0, "hwb": 0, "lch": 2, "oklch": 2}
+
+// isMixSpace reports whether color-mix() interpolates in space.
+func isMixSpace(space string) bool {
+	if _, ok := predefinedSpaces[space]; ok {
+		return true
+	}
+	_, ok := polarHue[space]
+	return ok || space == "lab" || space == "oklab"
+}
+
+// parseColorMix evaluates a color-mix() call. tokens[0] must be
+// the color-mix( function token. Inputs may be any color this
+// package finds, var() references resolved through cc
+// included. Without an interpolation method, colors mix in
+// Oklab.
+func parseColorMix(
+	tokens []scanner.Token,
+	src []byte,
+	cc colorContext,
+) (DocumentColor, bool) {
+	end := skipPastCloseParen(tokens, 0)
+	if end == 0 {
+		return DocumentColor{}, false
+	}
+	tokens = tokens[:end+1]
+
+	space, method := "oklab", "shorter"
+	i := skipWhitespace(tokens, 1)
+	if isIdent(tokens[i], "in") {
+		i = skipWhitespace(tokens, i+1)
+		if tokens[i].Kind != scanner.Ident {
+			return DocumentColor{}, false
+		}
+		space = strings.ToLower(tokens[i].Value)
+		if !isMixSpace(space) {
+			return DocumentColor{}, false
+		}
+		i = skipWhitespace(tokens, i+1)
+		if tokens[i].Kind == scanner.Ident {
+			method = strings.ToLower(tokens[i].Value)
+			i = skipWhitespace(tokens, i+1)
+			if _, polar := polarHue[space]; !polar ||
+				!slices.Contains(hueMethods, method) ||
+				!isIdent(tokens[i], "hue") {
+				return DocumentColor{}, false
+			}
+			i = skipWhitespace(tokens, i+1)
+		}
+		if tokens[i].Kind != scanner.Comma {
+			return DocumentColor{}, false
+		}
+		i++
+	}
+
+	var colors [2]Color
+	var pcts [2]float64
+	var given [2]bool
+	for k := range colors {
+		pct := func() bool {
+			i = skipWhitespace(tokens, i)
+			if tokens[i].Kind != scanner.Percentage || given[k] {
+				return true
+			}
+			v, err := strconv.ParseFloat(tokens[i].Value, 64)
+			if err != nil || v < 0 || v > 100 {
+				return false
+			}
+			pcts[k], given[k] = v, true
+			i = skipWhitespace(tokens, i+1)
+			return true
+		}
+		if !pct() {
+			return DocumentColor{}, false
+		}
+		c, next, ok := parseOriginColor(tokens, i, src, cc)
+		if !ok {
+			return DocumentColor{}, false
+		}
+		colors[k] = c
+		i = next
+		if !pct() {
+			return DocumentColor{}, false
+		}
+		want := scanner.Comma
+		if k == len(colors)-1 {
+			want = scanner.ParenClose
+		}
+		if tokens[i].Kind != want {
+			return DocumentColor{}, false
+		}
+		i++
+	}
+
+	switch {
+	case !given[0] && !given[1]:
+		pcts = [2]float64{50, 50}
+	case !given[1]:
+		pcts[1] = 100 - pcts[0]
+	case !given[0]:
+		pcts[0] = 100 - pcts[1]
+	}
+	sum := pcts[0] + pcts[1]
+	if sum == 0 {
+		return DocumentColor{}, false
+	}
+
+	c, ok := mixColors(
+		space, method, colors, pcts[0]/sum, pcts[1]/sum,
+	)
+	if !ok {
+		return DocumentColor{}, false
+	}
+	if sum < 100 {
+		c.Alpha *= sum / 100
+	}
+	return DocumentColor{
+		Color:    c,
+		StartPos: tokens[0].Offset,
+		EndPos:   tokens[end].End,
+	}, true
+}
+
+// mixColors interpolates two colors in space with premultiplied
+// alpha, taking p1 of the first and p2 of the second, which sum
+// to 1. Hues interpolate by method; a hue that is powerless,
+// that of a gray converted to the space, takes the other's.
+func mixColors(
+	space, method string,
+	colors [2]Color,
+	p1, p2 float64,
+) (Color, bool) {
+	a := mixCoords(colors[0], space)
+	b := mixCoords(colors[1], space)
+	hue, polar := polarHue[space]
+	if polar {
+		missingA := colors[0].Space != space && powerlessHue(space, a)
+		missingB := colors[1].Space != space && powerlessHue(space, b)
+		switch {
+		case missingA && !missingB:
+			a[hue] = b[hue]
+		case missingB && !missingA:
+			b[hue] = a[hue]
+		}
+		a[hue], b[hue] = fixupHues(a[hue], b[hue], method)
+	}
+
+	alphaA, alphaB := colors[0].Alpha, colors[1].Alpha
+	alpha := alphaA*p1 + alphaB*p2
+	var out [3]float64
+	for i := range out {
+		switch {
+		case polar && i == hue:
+			out[i] = math.Mod(a[i]*p1+b[i]*p2, 360)
+		case alpha == 0:
+			out[i] = a[i]*p1 + b[i]*p2
+		default:
+			out[i] = (a[i]*alphaA*p1 + b[i]*alphaB*p2) / alpha
+		}
+	}
+	return colorInSpace(space, out, alpha)
+}
+
+// mixCoords returns the channels of c in the interpolation space,
+// in the ranges of its function: hues in degrees, and saturation,
+// lightness, whiteness and blackness as percentages.
+func mixCoords(c Color, space string) [3]float64 {
+	if c.Space == space {
+		return c.Coords
+	}
+	if _, ok := predefinedSpaces[space]; ok {
+		return c.coordsIn(space)
+	}
+	d := colorSpaceDecompose[space](c)
+	return [3]float64{d[0], d[1], d[2]}
+}
+
+// powerlessHue reports whether the hue of coords, channels in the
+// polar space, is powerless: the color is a gray. The second
+// channel is saturation or chroma, except in HWB.
+func powerlessHue(space string, coords [3]float64) bool {
+	const epsilon = 1e-4
+	if space == "hwb" {
+		return coords[1]+coords[2] >= 100-epsilon
+	}
+	return coords[1] < epsilon
+}
+
+// fixupHues adjusts two hues, in degrees, for interpolation by the
+// hue method, so the way from one to the other goes the way it
+// asks for.
+func fixupHues(h1, h2 float64, method string) (float64, float64) {
+	h1 = math.Mod(math.Mod(h1, 360)+360, 360)
+	h2 = math.Mod(math.Mod(h2, 360)+360, 360)
+	d := h2 - h1
+	switch method {
+	case "shorter":
+		if d > 180 {
+			h1 += 360
+		} else if d < -180 {
+			h2 += 360
+		}
+	case "longer":
+		if d > 0 && d < 180 {
+			h1 += 360
+		} else if d > -180 && d <= 0 {
+			h2 += 360
+		}
+	case "increasing":
+		if d < 0 {
+			h2 += 360
+		}
+	case "decreasing":
+		if d > 0 {
+			h1 += 360
+		}
+	}
+	return h1, h2
+}
+
+// colorInSpace builds the color with the channels in the
+// interpolation space, in the ranges mixCoords returns.
+func colorInSpace(space string, coords [3]float64, alpha float64) (Color, bool) {
+	args := []float64{coords[0], coords[1], coords[2], alpha}
+	isPercent := []bool{false, false, false, false}
+	switch space {
+	case "hsl":
+		return buildHSL(args, []bool{false, true, true, false}, true)
+	case "hwb":
+		return buildHWB(args, []bool{false, true, true, false}, true)
+	case "lab":
+		return buildLab(args, isPercent)
+	case "lch":
+		return buildLCH(args, isPercent)
+	case "oklab":
+		return buildOklab(args, isPercent)
+	case "oklch":
+		return buildOklch(args, isPercent)
+	}
+	return buildPredefined(space, args, isPercent)
+}
+
+// parseLightDark parses the two colors of a light-dark() call,
+// each with the range of its argument, and whether each is a
+// color. tokens[0] must be the light-dark( function token.
+func parseLightDark(
+	tokens []scanner.Token,
+	src []byte,
+	cc colorContext,
+) ([2]DocumentColor, [2]bool) {
+	var colors [2]DocumentColor
+	var found [2]bool
+	end := skipPastCloseParen(tokens, 0)
+	if end == 0 {
+		return colors, found
+	}
+	tokens = tokens[:end+1]
+
+	i := 1
+	for k := range colors {
+		i = skipWhitespace(tokens, i)
+		if c, next, ok := parseOriginColor(tokens, i, src, cc); ok {
+			colors[k] = DocumentColor{
+				Color:    c,
+				StartPos: tokens[i].Offset,
+				EndPos:   tokens[next-1].End,
+			}
+			found[k] = true
+		}
+		i = nextArgument(tokens, i)
+	}
+	return colors, found
+}
+
+// lightDarkColor returns the color of a light-dark() call under
+// the color scheme of cc, spanning the call.
+func lightDarkColor(
+	tokens []scanner.Token,
+	src []byte,
+	cc colorContext,
+) (DocumentColor, bool) {
+	colors, found := parseLightDark(tokens, src, cc)
+	k := 0
+	if cc.scheme == ColorSchemeDark {
+		k = 1
+	}
+	if !found[k] {
+		return DocumentColor{}, false
+	}
+	return DocumentColor{
+		Color:    colors[k].Color,
+		StartPos: tokens[0].Offset,
+		EndPos:   tokens[skipPastCloseParen(tokens, 0)].End,
+	}, true
+}
+
+// skipWhitespace returns the index of the first token from i on
+// that isn't whitespace, or the last index.
+func skipWhitespace(tokens []scanner.Token, i int) int {
+	for i < len(tokens)-1 && tokens[i].Kind == scanner.Whitespace {
+		i++
+	}
+	return min(i, len(tokens)-1)
+}
+
+// nextArgument returns the index after the comma ending the
+// function argument at tokens[i], or the last index.
+func nextArgument(tokens []scanner.Token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].Kind {
+		case scanner.Function, scanner.ParenOpen:
+			depth++
+		case scanner.ParenClose:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case scanner.Comma:
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// isIdent reports whether tok is the identifier name, in any case.
+func isIdent(tok scanner.Token, name string) bool {
+	return tok.Kind == scanner.Ident && strings.EqualFold(tok.Value, name)
+}
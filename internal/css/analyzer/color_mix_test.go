@@ -0,0 +1,277 @@
+package analyzer
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+// TestColorMix checks color-mix() against test vectors of the
+// CSS Color 5 test suite.
+func TestColorMix(t *testing.T) {
+	const a, b = "hsl(120deg 10% 20%)", "hsl(30deg 30% 40%)"
+	tests := []struct {
+		src  string
+		want string // rgb() presentation
+	}{
+		{"color-mix(in hsl, " + a + ", " + b + ")", "rgb(84 92 61)"},
+		{"color-mix(in hsl, " + a + " 25%, " + b + ")", "rgb(112 106 67)"},
+		{"color-mix(in hsl, 25% " + a + ", " + b + ")", "rgb(112 106 67)"},
+		{"color-mix(in hsl, " + a + ", 25% " + b + ")", "rgb(61 73 54)"},
+		{"color-mix(in hsl, " + a + " 30%, " + b + " 90%)", "rgb(112 106 67)"},
+		{"color-mix(in hsl, " + a + " 25%, " + b + " 25%)", "rgb(84 92 61 / 50%)"},
+		{"color-mix(in hsl, " + a + " 0%, " + b + ")", "rgb(133 102 71)"},
+		{
+			"color-mix(in hsl, hsl(120deg 10% 20% / .4), hsl(30deg 30% 40% / .8))",
+			"rgb(95 105 65 / 60%)",
+		},
+		{
+			"color-mix(in hsl, hsl(40deg 50% 50%), hsl(60deg 50% 50%))",
+			"rgb(191 170 64)",
+		},
+		{
+			"color-mix(in hsl, hsl(60deg 50% 50%), hsl(40deg 50% 50%))",
+			"rgb(191 170 64)",
+		},
+		{
+			"color-mix(in hsl shorter hue, hsl(50deg 50% 50%), hsl(330deg 50% 50%))",
+			"rgb(191 85 64)",
+		},
+		{
+			"color-mix(in hsl longer hue, hsl(40deg 50% 50%), hsl(60deg 50% 50%))",
+			"rgb(64 85 191)",
+		},
+		{
+			"color-mix(in hsl increasing hue, hsl(60deg 50% 50%), hsl(40deg 50% 50%))",
+			"rgb(64 85 191)",
+		},
+		{
+			"color-mix(in hsl decreasing hue, hsl(40deg 50% 50%), hsl(60deg 50% 50%))",
+			"rgb(64 85 191)",
+		},
+		{
+			"color-mix(in hwb, hwb(120deg 10% 20%), hwb(30deg 30% 40%))",
+			"rgb(147 179 51)",
+		},
+		{"color-mix(in srgb, red, blue)", "rgb(128 0 128)"},
+		{"color-mix(in srgb, transparent, red)", "rgb(255 0 0 / 50%)"},
+	}
+	for _, tt := range tests {
+		dc, ok := mixIn(t, tt.src, nil)
+		if !ok {
+			t.Errorf("%s: no color", tt.src)
+			continue
+		}
+		if got := ColorPresentation(dc.Color)[1]; got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestColorMix_Spaces(t *testing.T) {
+	tests := []struct {
+		src    string
+		space  string
+		coords [3]float64
+		alpha  float64
+	}{
+		{"color-mix(in srgb, color(srgb .1 .2 .3 / .5), color(srgb .5 .6 .7))",
+			"srgb", [3]float64{0.366667, 0.466667, 0.566667}, 0.75},
+		{"color-mix(in lab, lab(10 20 30), lab(50 60 70))",
+			"lab", [3]float64{30, 40, 50}, 1},
+		{"color-mix(in lab, lab(10 20 30) 25%, lab(50 60 70))",
+			"lab", [3]float64{40, 50, 60}, 1},
+		{"color-mix(in lab, lab(10 20 30 / .4), lab(50 60 70 / .8))",
+			"lab", [3]float64{36.666667, 46.666667, 56.666667}, 0.6},
+		{"color-mix(in lch, lch(10 20 30deg), lch(50 60 70deg))",
+			"lch", [3]float64{30, 40, 50}, 1},
+		{"color-mix(in lch, lch(10 20 30deg) 25%, lch(50 60 70deg))",
+			"lch", [3]float64{40, 50, 60}, 1},
+		// Hue is not premultiplied.
+		{"color-mix(in lch, lch(10 20 30deg / .4), lch(50 60 70deg / .8))",
+			"lch", [3]float64{36.666667, 46.666667, 50}, 0.6},
+		{"color-mix(in xyz-d50, color(xyz-d50 .1 .2 .3), color(xyz-d50 .5 .6 .7))",
+			"xyz-d50", [3]float64{0.3, 0.4, 0.5}, 1},
+		{"color-mix(in xyz, color(xyz .1 .2 .3 / .5), color(xyz .5 .6 .7))",
+			"xyz", [3]float64{0.366667, 0.466667, 0.566667}, 0.75},
+		{"color-mix(in oklch, oklch(0.1 0.2 30deg), oklch(0.5 0.6 70deg))",
+			"oklch", [3]float64{0.3, 0.4, 50}, 1},
+		{"color-mix(in xyz, color(xyz .1 .2 .3) 25%, color(xyz .5 .6 .7))",
+			"xyz", [3]float64{0.4, 0.5, 0.6}, 1},
+		{"color-mix(in display-p3, color(display-p3 0 1 0), color(display-p3 1 0 0))",
+			"display-p3", [3]float64{0.5, 0.5, 0}, 1},
+		// The powerless hue of white takes that of blue.
+		{"color-mix(in oklch, white, oklch(0.5 0.2 264))",
+			"oklch", [3]float64{0.75, 0.1, 264}, 1},
+		// Without an interpolation method, colors mix in Oklab.
+		{"color-mix(oklab(0.2 0.1 0), oklab(0.4 0.3 0))",
+			"oklab", [3]float64{0.3, 0.2, 0}, 1},
+	}
+	for _, tt := range tests {
+		dc, ok := mixIn(t, tt.src, nil)
+		if !ok {
+			t.Errorf("%s: no color", tt.src)
+			continue
+		}
+		c := dc.Color
+		if c.Space != tt.space {
+			t.Errorf("%s: space %q, want %q", tt.src, c.Space, tt.space)
+		}
+		for i := range 3 {
+			if math.Abs(c.Coords[i]-tt.coords[i]) > 1e-4 {
+				t.Errorf("%s: coords %v, want %v", tt.src, c.Coords, tt.coords)
+				break
+			}
+		}
+		if math.Abs(c.Alpha-tt.alpha) > 1e-6 {
+			t.Errorf("%s: alpha %f, want %f", tt.src, c.Alpha, tt.alpha)
+		}
+	}
+}
+
+// TestColorMix_SRGBInLab checks sRGB colors mixed in Lab, LCH and
+// XYZ against the values of the CSS Color 4 conversions, whose Lab
+// is relative to D50.
+func TestColorMix_SRGBInLab(t *testing.T) {
+	tests := []struct {
+		src    string
+		space  string
+		coords [3]float64
+	}{
+		{"color-mix(in lab, red, blue)", "lab", [3]float64{41.93, 74.55, -21.07}},
+		{"color-mix(in lch, red, blue)", "lch", [3]float64{41.93, 119.02, 351.11}},
+		{"color-mix(in lab, white, black)", "lab", [3]float64{50, 0, 0}},
+		{"color-mix(in xyz, red, blue)", "xyz", [3]float64{0.2964, 0.1424, 0.4849}},
+		{"color-mix(in xyz-d50, red, blue)", "xyz-d50",
+			[3]float64{0.2896, 0.1416, 0.3640}},
+	}
+	for _, tt := range tests {
+		dc, ok := mixIn(t, tt.src, nil)
+		if !ok {
+			t.Errorf("%s: no color", tt.src)
+			continue
+		}
+		c := dc.Color
+		if c.Space != tt.space {
+			t.Errorf("%s: space %q, want %q", tt.src, c.Space, tt.space)
+		}
+		for i := range 3 {
+			if math.Abs(c.Coords[i]-tt.coords[i]) > 0.01 {
+				t.Errorf("%s: coords %v, want %v", tt.src, c.Coords, tt.coords)
+				break
+			}
+		}
+	}
+}
+
+func TestColorMix_Invalid(t *testing.T) {
+	for _, src := range []string{
+		"color-mix(in nowhere, red, blue)",
+		"color-mix(in srgb longer hue, red, blue)",
+		"color-mix(in hsl sideways hue, red, blue)",
+		"color-mix(in srgb, red 0%, blue 0%)",
+		"color-mix(in srgb, red 120%, blue)",
+		"color-mix(in srgb, red)",
+		"color-mix(in srgb, red, currentcolor)",
+	} {
+		if dc, ok := mixIn(t, src, nil); ok {
+			t.Errorf("%s: got %v, want no color", src, dc.Color)
+		}
+	}
+}
+
+func TestColorMix_Variables(t *testing.T) {
+	resolver := mapResolver{"--brand": "#0000ff"}
+	dc, ok := mixIn(t,
+		"color-mix(in srgb, var(--brand) 25%, color-mix(in srgb, red, red))",
+		resolver,
+	)
+	if !ok {
+		t.Fatal("expected a color")
+	}
+	if got := ColorPresentation(dc.Color)[1]; got != "rgb(191 0 64)" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestLightDark(t *testing.T) {
+	src := []byte(".a { color: light-dark(#fff, var(--dark)); }")
+	ss, _ := parser.Parse(src)
+	resolver := mapResolver{"--dark": "#111"}
+
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
+	if len(colors) != 2 {
+		t.Fatalf("expected 2 colors, got %d", len(colors))
+	}
+	if got := string(src[colors[0].StartPos:colors[0].EndPos]); got != "#fff" {
+		t.Errorf("light color spans %q", got)
+	}
+	if got := string(src[colors[1].StartPos:colors[1].EndPos]); got != "var(--dark)" {
+		t.Errorf("dark color spans %q", got)
+	}
+	assertColorClose(t, colors[1].Color, 0.067, 0.067, 0.067, 1)
+
+	colors = FindDocumentColorsResolved(ss, src, resolver, ColorSchemeDark)
+	if len(colors) != 1 {
+		t.Fatalf("dark scheme: expected 1 color, got %d", len(colors))
+	}
+	got := string(src[colors[0].StartPos:colors[0].EndPos])
+	if got != "light-dark(#fff, var(--dark))" {
+		t.Errorf("dark scheme: color spans %q", got)
+	}
+	assertColorClose(t, colors[0].Color, 0.067, 0.067, 0.067, 1)
+
+	// Mixed, light-dark() takes the scheme's color, or the light
+	// one without a scheme.
+	src = []byte(".a { color: color-mix(in srgb, light-dark(white, black), red); }")
+	ss, _ = parser.Parse(src)
+	for scheme, want := range map[ColorScheme]string{
+		ColorSchemeBoth: "rgb(255 128 128)",
+		ColorSchemeDark: "rgb(128 0 0)",
+	} {
+		colors := FindDocumentColorsResolved(ss, src, nil, scheme)
+		if len(colors) == 0 {
+			t.Errorf("scheme %d: no color", scheme)
+			continue
+		}
+		if got := ColorPresentation(colors[0].Color)[1]; got != want {
+			t.Errorf("scheme %d: got %s, want %s", scheme, got, want)
+		}
+	}
+}
+
+func TestHoverColorMix(t *testing.T) {
+	src := []byte(".a { color: color-mix(in srgb, red, blue); " +
+		"background: light-dark(white, black); }")
+	ss, _ := parser.Parse(src)
+
+	hr := Hover(ss, src, strings.Index(string(src), "color-mix")+2, HoverOptions{})
+	if !strings.Contains(hr.Content, "Computes to `rgb(128 0 128)`") {
+		t.Errorf("color-mix hover: %q", hr.Content)
+	}
+	hr = Hover(ss, src, strings.Index(string(src), "light-dark")+2, HoverOptions{})
+	if !strings.Contains(hr.Content, "Light: `rgb(255 255 255)`") ||
+		!strings.Contains(hr.Content, "Dark: `rgb(0 0 0)`") {
+		t.Errorf("light-dark hover: %q", hr.Content)
+	}
+}
+
+// mixIn returns the color of the color value src, found as the
+// value of a declaration, and whether it spans the whole value.
+func mixIn(
+	t *testing.T,
+	value string,
+	resolver VariableResolver,
+) (DocumentColor, bool) {
+	t.Helper()
+	src := []byte(".a { color: " + value + "; }")
+	ss, _ := parser.Parse(src)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
+	if len(colors) == 0 {
+		return DocumentColor{}, false
+	}
+	dc := colors[0]
+	return dc, dc.StartPos == 12 && dc.EndPos == 12+len(value)
+}
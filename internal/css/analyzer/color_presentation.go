@@ -0,0 +1,581 @@
+package analyzer
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/data"
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/css-lsp/internal/css/scanner"
+)
+
+// ColorEdit writes a presentation of a color over the source
+// between StartPos and EndPos.
+type ColorEdit struct {
+	StartPos int
+	EndPos   int
+	NewText  string
+}
+
+// ColorPresentationItem is a way to write a color, with the edit
+// that writes it where the color is and any further edits, such
+// as to the definition of a custom property the color comes from.
+type ColorPresentationItem struct {
+	Label           string
+	Edit            ColorEdit
+	AdditionalEdits []ColorEdit
+}
+
+// colorStyle is how a color is written: its notation and the
+// precision and form of its channels and alpha.
+type colorStyle struct {
+	// notation is "hex", "named", "color" or the name of a color
+	// function without its legacy "a".
+	notation string
+	// name is the function name as written, such as "rgba".
+	name string
+	// space is the predefined space of color().
+	space string
+	// legacy separates channels with commas.
+	legacy bool
+	// upper writes hex digits in uppercase, and short writes them
+	// in three or four digits when that loses nothing.
+	upper, short bool
+	channels     [3]numberStyle
+	alpha        numberStyle
+	// hasAlpha writes the alpha even when the color is opaque.
+	hasAlpha bool
+}
+
+// numberStyle is how a channel or the alpha is written.
+type numberStyle struct {
+	decimals int
+	// keep is how many decimals trailing zeros are kept to, as
+	// authored.
+	keep    int
+	percent bool
+	// unit is the angle unit of a hue, or "" for degrees as a
+	// bare number.
+	unit string
+}
+
+// presentationNotations are the notations colors are presented
+// in, in order; named colors follow when one is exact.
+var presentationNotations = []string{
+	"hex", "rgb", "hsl", "hwb", "lab", "lch", "oklab", "oklch", "color",
+}
+
+// percentReference maps each color function to the value that
+// 100% stands for in each of its channels.
+var percentReference = map[string][3]float64{
+	"rgb":   {255, 255, 255},
+	"hsl":   {1, 100, 100},
+	"hwb":   {1, 100, 100},
+	"lab":   {100, 125, 125},
+	"lch":   {100, 150, 1},
+	"oklab": {1, 0.4, 0.4},
+	"oklch": {1, 0.4, 1},
+	"color": {1, 1, 1},
+}
+
+// minDecimals maps the color functions whose channels span about
+// one unit to the fewest decimals each is written with as a
+// number, so that integers written for the ends of a range don't
+// round every other color to them.
+var minDecimals = map[string][3]int{
+	"oklab": {2, 3, 3},
+	"oklch": {2, 3, 0},
+	"color": {3, 3, 3},
+}
+
+// defaultStyle returns the style of a presentation in notation
+// when none is authored: channels rounded to the precision each
+// space needs and trailing zeros dropped, saturation, lightness,
+// whiteness and blackness as percentages, and alpha as a whole
+// percentage.
+func defaultStyle(notation string) colorStyle {
+	st := colorStyle{notation: notation, name: notation}
+	decimals := [3]int{}
+	switch notation {
+	case "lab", "lch":
+		decimals = [3]int{2, 2, 2}
+	case "oklab":
+		decimals = [3]int{4, 4, 4}
+	case "oklch":
+		decimals = [3]int{4, 4, 2}
+	case "color":
+		st.space = "display-p3"
+		decimals = [3]int{4, 4, 4}
+	}
+	for i := range st.channels {
+		st.channels[i].decimals = decimals[i]
+	}
+	if notation == "hsl" || notation == "hwb" {
+		st.channels[1].percent = true
+		st.channels[2].percent = true
+	}
+	st.alpha = numberStyle{percent: true}
+	return st
+}
+
+// authoredStyle returns the style of the color written in text,
+// and whether it is in a notation colors can be presented in.
+// Channels written as none, or in relative colors, keep the
+// default style. Channels of about one unit, and alphas written
+// as numbers, keep enough decimals to tell colors apart.
+func authoredStyle(text string) (colorStyle, bool) {
+	tokens := scanner.ScanAll([]byte(text))
+	if len(tokens) == 0 {
+		return colorStyle{}, false
+	}
+	tok := tokens[0]
+	switch tok.Kind {
+	case scanner.Hash:
+		if _, ok := parseHexColor(tok.Value); !ok {
+			return colorStyle{}, false
+		}
+		st := defaultStyle("hex")
+		st.upper = strings.ToLower(tok.Value) != tok.Value
+		st.short = len(tok.Value) <= 4
+		return st, true
+	case scanner.Ident:
+		if _, ok := namedColorMap[strings.ToLower(tok.Value)]; !ok {
+			return colorStyle{}, false
+		}
+		return colorStyle{notation: "named"}, true
+	case scanner.Function:
+	default:
+		return colorStyle{}, false
+	}
+
+	name := strings.ToLower(tok.Value)
+	notation := strings.TrimSuffix(name, "a")
+	if notation != "rgb" && notation != "hsl" {
+		notation = name
+	}
+	if _, ok := percentReference[notation]; !ok {
+		return colorStyle{}, false
+	}
+	st := defaultStyle(notation)
+	st.name = tok.Value
+	k, inAlpha, spaceSeen := 0, false, notation != "color"
+	for _, t := range tokens[1:] {
+		var ns *numberStyle
+		switch {
+		case inAlpha:
+			ns = &st.alpha
+		case k < len(st.channels):
+			ns = &st.channels[k]
+		}
+		switch t.Kind {
+		case scanner.Ident:
+			value := strings.ToLower(t.Value)
+			switch {
+			case value == "from":
+				return st, true
+			case !spaceSeen:
+				if _, ok := predefinedSpaces[value]; !ok {
+					return colorStyle{}, false
+				}
+				st.space, spaceSeen = value, true
+				continue
+			case value == "none":
+			default:
+				continue
+			}
+		case scanner.Number, scanner.Percentage, scanner.Dimension:
+			if ns != nil {
+				number, unit := splitDimension(t.Value)
+				*ns = numberStyle{
+					decimals: decimalPlaces(number),
+					keep:     decimalPlaces(number),
+					percent:  t.Kind == scanner.Percentage,
+					unit:     strings.ToLower(unit),
+				}
+				if inAlpha {
+					st.hasAlpha = true
+				}
+			}
+		case scanner.Comma:
+			st.legacy = true
+			if k == len(st.channels) {
+				inAlpha = true
+			}
+			continue
+		case scanner.Delim:
+			if t.Value == "/" {
+				inAlpha = true
+			}
+			continue
+		default:
+			continue
+		}
+		if !inAlpha {
+			k++
+		}
+	}
+	for i := range st.channels {
+		if ns := &st.channels[i]; !ns.percent {
+			ns.decimals = max(ns.decimals, minDecimals[notation][i])
+		}
+	}
+	switch {
+	case st.legacy && !st.hasAlpha:
+		st.alpha = numberStyle{decimals: 2}
+	case !st.alpha.percent:
+		st.alpha.decimals = max(st.alpha.decimals, 2)
+	}
+	return st, true
+}
+
+// splitDimension splits the text of a dimension into its number
+// and unit.
+func splitDimension(text string) (string, string) {
+	i := len(text)
+	for i > 0 && (text[i-1] >= 'a' && text[i-1] <= 'z' ||
+		text[i-1] >= 'A' && text[i-1] <= 'Z') {
+		i--
+	}
+	return text[:i], text[i:]
+}
+
+// decimalPlaces returns the number of digits after the point of
+// a number as written.
+func decimalPlaces(number string) int {
+	_, frac, ok := strings.Cut(number, ".")
+	if !ok {
+		return 0
+	}
+	if i := strings.IndexAny(frac, "eE"); i >= 0 {
+		frac = frac[:i]
+	}
+	return len(frac)
+}
+
+// formatNumber rounds v to the decimals of the style, never
+// writing "-0".
+func formatNumber(v float64, ns numberStyle) string {
+	scale := math.Pow(10, float64(ns.decimals))
+	v = math.Round(v*scale) / scale
+	if v == 0 {
+		v = 0
+	}
+	text := strconv.FormatFloat(v, 'f', -1, 64)
+	if ns.keep > 0 {
+		whole, frac, _ := strings.Cut(text, ".")
+		if len(frac) < ns.keep {
+			frac += strings.Repeat("0", ns.keep-len(frac))
+		}
+		text = whole + "." + frac
+	}
+	return text
+}
+
+// hueTurn maps angle units to the value of a full turn in them.
+var hueTurn = map[string]float64{
+	"": 360, "deg": 360, "grad": 400, "rad": 2 * math.Pi, "turn": 1,
+}
+
+// formatHue writes a hue, in degrees, in the unit of the style,
+// within one turn.
+func formatHue(deg float64, ns numberStyle) string {
+	turn, ok := hueTurn[ns.unit]
+	if !ok {
+		ns.unit = ""
+		turn = 360
+	}
+	v := math.Mod(math.Mod(deg, 360)+360, 360) / 360 * turn
+	scale := math.Pow(10, float64(ns.decimals))
+	if math.Round(v*scale)/scale >= turn {
+		v -= turn
+	}
+	return formatNumber(v, ns) + ns.unit
+}
+
+// formatColor writes c in the style, reporting false for a named
+// color when no name is exactly c.
+func formatColor(c Color, st colorStyle) (string, bool) {
+	switch st.notation {
+	case "hex":
+		return formatHex(c, st), true
+	case "named":
+		return colorName(c)
+	}
+
+	var coords [3]float64
+	switch st.notation {
+	case "color":
+		coords = c.coordsIn(st.space)
+	default:
+		d := colorSpaceDecompose[st.notation](c)
+		coords = [3]float64{d[0], d[1], d[2]}
+	}
+	hue, polar := polarHue[st.notation]
+	if st.notation == "lch" || st.notation == "oklch" {
+		ns, chroma := st.channels[1], coords[1]
+		if ns.percent {
+			chroma = chroma / percentReference[st.notation][1] * 100
+		}
+		if math.Round(chroma*math.Pow(10, float64(ns.decimals))) == 0 {
+			coords[hue] = 0 // powerless
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(st.name + "(")
+	if st.notation == "color" {
+		sb.WriteString(st.space + " ")
+	}
+	sep := " "
+	if st.legacy {
+		sep = ", "
+	}
+	for i, v := range coords {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		ns := st.channels[i]
+		switch {
+		case polar && i == hue:
+			sb.WriteString(formatHue(v, ns))
+		case ns.percent:
+			ref := percentReference[st.notation][i]
+			sb.WriteString(formatNumber(v/ref*100, ns) + "%")
+		default:
+			sb.WriteString(formatNumber(v, ns))
+		}
+	}
+	if c.Alpha < 1 || st.hasAlpha {
+		if st.legacy {
+			sb.WriteString(", ")
+		} else {
+			sb.WriteString(" / ")
+		}
+		if st.alpha.percent {
+			sb.WriteString(formatNumber(c.Alpha*100, st.alpha) + "%")
+		} else {
+			sb.WriteString(formatNumber(c.Alpha, st.alpha))
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String(), true
+}
+
+// formatHex writes c as a hex color, with an alpha byte if it
+// isn't opaque.
+func formatHex(c Color, st colorStyle) string {
+	bytes := []int{
+		int(math.Round(c.Red * 255)),
+		int(math.Round(c.Green * 255)),
+		int(math.Round(c.Blue * 255)),
+	}
+	if c.Alpha < 1 {
+		bytes = append(bytes, int(math.Round(c.Alpha*255)))
+	}
+	short := st.short
+	for _, b := range bytes {
+		short = short && b%17 == 0
+	}
+	var sb strings.Builder
+	sb.WriteByte('#')
+	for _, b := range bytes {
+		if short {
+			sb.WriteString(strconv.FormatInt(int64(b/17), 16))
+		} else {
+			sb.WriteString(hexStr(b))
+		}
+	}
+	if st.upper {
+		return strings.ToUpper(sb.String())
+	}
+	return sb.String()
+}
+
+// colorName returns the first named color that is exactly c, to
+// eight bits a channel.
+func colorName(c Color) (string, bool) {
+	for _, name := range data.NamedColors {
+		if n, ok := namedColorMap[name]; ok && sameColor(n, c) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// sameColor reports whether the sRGB channels and alpha of a and b
+// are equal at eight bits a channel, as a color picker returns
+// them.
+func sameColor(a, b Color) bool {
+	to8 := func(v float64) int { return int(math.Round(v * 255)) }
+	return to8(a.Red) == to8(b.Red) && to8(a.Green) == to8(b.Green) &&
+		to8(a.Blue) == to8(b.Blue) && to8(a.Alpha) == to8(b.Alpha)
+}
+
+// literalColor returns the color of text when it is a single
+// color, as its swatch shows it.
+func literalColor(text string) (Color, bool) {
+	src := []byte(text)
+	colors := findColorsInTokens(scanner.ScanAll(src), src, colorContext{})
+	if len(colors) != 1 || colors[0].StartPos != 0 ||
+		colors[0].EndPos != len(src) {
+		return Color{}, false
+	}
+	return colors[0].Color, true
+}
+
+// colorPresentations writes c in each notation, the authored one
+// first in its style and the others in their default styles.
+func colorPresentations(c Color, authored colorStyle) []string {
+	var presentations []string
+	add := func(st colorStyle) {
+		if text, ok := formatColor(c, st); ok {
+			presentations = append(presentations, text)
+		}
+	}
+	if authored.notation != "" {
+		add(authored)
+	}
+	for _, notation := range append(presentationNotations, "named") {
+		st := defaultStyle(notation)
+		if notation == authored.notation && st.space == authored.space {
+			continue
+		}
+		add(st)
+	}
+	return presentations
+}
+
+// ColorPresentationsAt returns the ways to write c in place of
+// the color found between start and end, the notation it is
+// written in first, with the precision and alpha style it is
+// written with. When c is the color the swatch shows for it, the
+// color as written comes first, so that accepting the picker
+// unchanged keeps a color beyond sRGB. For a var() reference
+// with a fallback color, the edits rewrite only the fallback;
+// without one, the reference stays and the edits rewrite the
+// definition of the custom property in the stylesheet, if it is a
+// color. A reference with neither has nothing to edit and gets no
+// presentations.
+func ColorPresentationsAt(
+	ss *parser.Stylesheet,
+	src []byte,
+	start, end int,
+	c Color,
+) []ColorPresentationItem {
+	if start < 0 || end > len(src) || start >= end {
+		return nil
+	}
+	edit := ColorEdit{StartPos: start, EndPos: end}
+	target := edit
+	var def *ColorEdit
+	tokens := scanner.ScanAll(src[start:end])
+	if len(tokens) > 0 && tokens[0].Kind == scanner.Function &&
+		strings.EqualFold(tokens[0].Value, VarFunctionName) {
+		if fallback, ok := varFallbackColor(tokens, src[start:end]); ok {
+			edit = ColorEdit{
+				StartPos: start + fallback.StartPos,
+				EndPos:   start + fallback.EndPos,
+			}
+			target = edit
+		} else {
+			d, ok := customPropertyColor(ss, src, varName(tokens))
+			if !ok {
+				return nil
+			}
+			// The reference stays; only the definition changes.
+			edit.NewText = string(src[start:end])
+			target = d
+			def = &d
+		}
+	}
+	literal := string(src[target.StartPos:target.EndPos])
+	authored, _ := authoredStyle(literal)
+	presentations := colorPresentations(c, authored)
+	if swatch, ok := literalColor(literal); ok && sameColor(swatch, c) {
+		presentations = append([]string{literal}, slices.DeleteFunc(
+			presentations, func(p string) bool { return p == literal },
+		)...)
+	}
+
+	var items []ColorPresentationItem
+	for _, text := range presentations {
+		item := ColorPresentationItem{Label: text, Edit: edit}
+		if item.Edit.NewText == "" {
+			item.Edit.NewText = text
+		}
+		if def != nil {
+			item.AdditionalEdits = []ColorEdit{
+				{StartPos: def.StartPos, EndPos: def.EndPos, NewText: text},
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// varName returns the custom property a var() call, tokens[0],
+// refers to.
+func varName(tokens []scanner.Token) string {
+	i := skipWhitespace(tokens, 1)
+	if tokens[i].Kind != scanner.Ident {
+		return ""
+	}
+	return tokens[i].Value
+}
+
+// varFallbackColor returns the range, relative to src, of the
+// fallback of a var() call, tokens[0], when it is a single color.
+func varFallbackColor(tokens []scanner.Token, src []byte) (ColorEdit, bool) {
+	end := skipPastCloseParen(tokens, 0)
+	if end == 0 {
+		return ColorEdit{}, false
+	}
+	comma := nextArgument(tokens, 1)
+	if comma >= end || tokens[comma-1].Kind != scanner.Comma {
+		return ColorEdit{}, false
+	}
+	first := skipWhitespace(tokens[:end], comma)
+	last := end - 1
+	for last > first && tokens[last].Kind == scanner.Whitespace {
+		last--
+	}
+	if first == end {
+		return ColorEdit{}, false
+	}
+	colors := findColorsInTokens(tokens[first:last+1], src, colorContext{})
+	if len(colors) != 1 || colors[0].StartPos != tokens[first].Offset ||
+		colors[0].EndPos != tokens[last].End {
+		return ColorEdit{}, false
+	}
+	return ColorEdit{StartPos: colors[0].StartPos, EndPos: colors[0].EndPos}, true
+}
+
+// customPropertyColor returns the range of the value of the last
+// declaration of the custom property in the stylesheet, when it
+// is a single color.
+func customPropertyColor(
+	ss *parser.Stylesheet,
+	src []byte,
+	name string,
+) (ColorEdit, bool) {
+	var found ColorEdit
+	ok := false
+	if name == "" || ss == nil {
+		return found, false
+	}
+	parser.Walk(ss, func(n parser.Node) bool {
+		decl, isDecl := n.(*parser.Declaration)
+		if !isDecl || decl.Property.Value != name || decl.Value == nil {
+			return true
+		}
+		colors := findColorsInTokens(decl.Value.Tokens, src, colorContext{})
+		if _, single := declarationColor(decl, src, colorContext{}); single {
+			found = ColorEdit{StartPos: colors[0].StartPos, EndPos: colors[0].EndPos}
+			ok = true
+		}
+		return true
+	})
+	return found, ok
+}
@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestColorPresentationsAt_Authored(t *testing.T) {
+	blue := Color{Red: 0.2, Green: 0.4, Blue: 0.6, Alpha: 0.75}
+	white := Color{Red: 1, Green: 1, Blue: 1, Alpha: 1}
+	tests := []struct {
+		src   string
+		c     Color
+		first string
+	}{
+		{"#F00", blue, "#336699BF"},
+		{"#F00", white, "#FFF"},
+		{"#ff0000", white, "#ffffff"},
+		{"red", white, "white"},
+		{"red", blue, "#336699bf"},
+		{"rgba(255, 0, 0, 0.5)", blue, "rgba(51, 102, 153, 0.75)"},
+		{"rgb(255, 0, 0)", white, "rgb(255, 255, 255)"},
+		{"hsl(0.25turn 50% 50%)", blue, "hsl(0.58turn 50% 40% / 75%)"},
+		{"HWB(none 10% 10%)", blue, "HWB(210 20% 40% / 75%)"},
+		{"lab(50.0 20 30)", blue, "lab(41.5 -5 -33 / 75%)"},
+		{"oklch(62% 0.190 250deg / 0.5)", blue, "oklch(50% 0.099 250deg / 0.75)"},
+		{"oklch(0.50 0.100 10.0)", white, "oklch(1.00 0.000 0.0)"},
+		{"color(rec2020 0 1 0)", blue, "color(rec2020 0.25 0.337 0.538 / 75%)"},
+		{"rgb(from red r g b)", blue, "rgb(51 102 153 / 75%)"},
+		{"color-mix(in srgb, red, blue)", blue, "#336699bf"},
+	}
+	for _, tt := range tests {
+		src := []byte(tt.src)
+		items := ColorPresentationsAt(nil, src, 0, len(src), tt.c)
+		if len(items) == 0 {
+			t.Errorf("%s: no presentations", tt.src)
+			continue
+		}
+		if items[0].Label != tt.first {
+			t.Errorf("%s: first = %s, want %s", tt.src, items[0].Label, tt.first)
+		}
+		e := items[0].Edit
+		if e.StartPos != 0 || e.EndPos != len(src) || e.NewText != tt.first {
+			t.Errorf("%s: edit = %+v", tt.src, e)
+		}
+		var labels []string
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		if slices.Contains(labels[1:], labels[0]) {
+			t.Errorf("%s: duplicate presentations %q", tt.src, labels)
+		}
+	}
+}
+
+func TestColorPresentationsAt_Unchanged(t *testing.T) {
+	for _, text := range []string{
+		"color(display-p3 0 1 0)",
+		"oklch(90% 0.4 140)",
+		"lab(50 90 0 / 0.5)",
+		"hsl(0.25turn 50% 50%)",
+	} {
+		src := []byte(text)
+		swatch, ok := literalColor(text)
+		if !ok {
+			t.Fatalf("%s: not a color", text)
+		}
+		picked := Color{
+			Red: swatch.Red, Green: swatch.Green, Blue: swatch.Blue,
+			Alpha: swatch.Alpha,
+		}
+		items := ColorPresentationsAt(nil, src, 0, len(src), picked)
+		if len(items) == 0 {
+			t.Errorf("%s: no presentations", text)
+			continue
+		}
+		if items[0].Label != text || items[0].Edit.NewText != text {
+			t.Errorf("%s: first = %+v", text, items[0])
+		}
+		for _, item := range items[1:] {
+			if item.Label == text {
+				t.Errorf("%s: presented twice", text)
+			}
+		}
+	}
+}
+
+func TestColorPresentationsAt_Variables(t *testing.T) {
+	src := []byte(`:root { --brand: rgb(255 0 0); --other: var(--brand); }
+.a { color: var(--brand, #F00); }
+.b { color: var(--brand); }
+.c { color: var(--other); }`)
+	ss := parseCSS(t, src)
+	green := Color{Green: 1, Alpha: 1}
+	def := ColorEdit{
+		StartPos: indexOf(src, "rgb(255 0 0)"),
+		EndPos:   indexOf(src, "rgb(255 0 0)") + len("rgb(255 0 0)"),
+		NewText:  "rgb(0 255 0)",
+	}
+
+	ref := "var(--brand, #F00)"
+	start := indexOf(src, ref)
+	items := ColorPresentationsAt(ss, src, start, start+len(ref), green)
+	if len(items) == 0 {
+		t.Fatal("fallback: no presentations")
+	}
+	fallback := indexOf(src, "#F00")
+	want := ColorEdit{StartPos: fallback, EndPos: fallback + 4, NewText: "#0F0"}
+	if items[0].Label != "#0F0" || items[0].Edit != want {
+		t.Errorf("fallback: got %+v", items[0])
+	}
+	if items[0].AdditionalEdits != nil {
+		t.Errorf("fallback: additional edits %+v", items[0].AdditionalEdits)
+	}
+
+	ref = "var(--brand);"
+	start = indexOf(src, ".b {") + indexOf(src[indexOf(src, ".b {"):], ref)
+	items = ColorPresentationsAt(ss, src, start, start+len(ref)-1, green)
+	if len(items) == 0 {
+		t.Fatal("reference: no presentations")
+	}
+	want = ColorEdit{
+		StartPos: start, EndPos: start + len(ref) - 1, NewText: "var(--brand)",
+	}
+	if items[0].Label != "rgb(0 255 0)" || items[0].Edit != want {
+		t.Errorf("reference: got %+v", items[0])
+	}
+	def.NewText = "rgb(0 255 0)"
+	if !slices.Equal(items[0].AdditionalEdits, []ColorEdit{def}) {
+		t.Errorf("reference: additional edits %+v", items[0].AdditionalEdits)
+	}
+
+	ref = "var(--other)"
+	start = indexOf(src, ".c {") + indexOf(src[indexOf(src, ".c {"):], ref)
+	items = ColorPresentationsAt(ss, src, start, start+len(ref), green)
+	if items != nil {
+		t.Errorf("unresolvable reference: got %+v", items)
+	}
+}
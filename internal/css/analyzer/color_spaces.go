@@ -0,0 +1,399 @@
+package analyzer
+
+import "math"
+
+// matrix3 is a 3×3 matrix converting between linear color spaces.
+type matrix3 [3][3]float64
+
+// apply returns m·v.
+func (m matrix3) apply(v [3]float64) [3]float64 {
+	var out [3]float64
+	for i := range m {
+		out[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+	}
+	return out
+}
+
+// mul returns m·n, the conversion applying n and then m.
+func (m matrix3) mul(n matrix3) matrix3 {
+	var out matrix3
+	for i := range 3 {
+		for j := range 3 {
+			out[i][j] = m[i][0]*n[0][j] + m[i][1]*n[1][j] + m[i][2]*n[2][j]
+		}
+	}
+	return out
+}
+
+// d50White is the D50 white point in CIE XYZ, which lab() and
+// lch() are relative to, from its chromaticity.
+var d50White = [3]float64{
+	0.3457 / 0.3585, 1, (1 - 0.3457 - 0.3585) / 0.3585,
+}
+
+// The conversions between linear sRGB and CIE XYZ with a D65
+// white point, which every space converts through.
+var (
+	linearSRGBToXYZ = matrix3{
+		{506752.0 / 1228815, 87881.0 / 245763, 12673.0 / 70218},
+		{87098.0 / 409605, 175762.0 / 245763, 12673.0 / 175545},
+		{7918.0 / 409605, 87881.0 / 737289, 1001167.0 / 1053270},
+	}
+	xyzToLinearSRGB = matrix3{
+		{12831.0 / 3959, -329.0 / 214, -1974.0 / 3959},
+		{-851781.0 / 878810, 1648619.0 / 878810, 36519.0 / 878810},
+		{705.0 / 12673, -2585.0 / 12673, 705.0 / 667},
+	}
+)
+
+// The Bradford chromatic adaptations between the D50 and D65
+// white points.
+var (
+	d50ToD65 = matrix3{
+		{0.955473421488075, -0.02309845494876471, 0.06325924320057072},
+		{-0.0283697093338637, 1.0099953980813041, 0.021041441191917323},
+		{0.012314014864481998, -0.020507649298898964, 1.330365926242124},
+	}
+	d65ToD50 = matrix3{
+		{1.0479297925449969, 0.022946870601609652, -0.05019226628920524},
+		{0.02962780877005599, 0.9904344267538799, -0.017073799063418826},
+		{-0.009243040646204504, 0.015055191490298152, 0.7518742814281371},
+	}
+)
+
+// The conversions between ProPhoto RGB, whose white point is D50,
+// and CIE XYZ with a D50 white point.
+var (
+	prophotoToXYZD50 = matrix3{
+		{0.7977666449006423, 0.13518129740053308, 0.0313477341283922},
+		{0.2880748288194013, 0.711835234241873, 0.00008993693872564},
+		{0, 0, 0.8251046025104602},
+	}
+	xyzD50ToProphoto = matrix3{
+		{1.3457868816471583, -0.25557208737979464, -0.05110186497554526},
+		{-0.5446307051249019, 1.5082477428451468, 0.02052744743642139},
+		{0, 0, 1.2119675456389452},
+	}
+)
+
+// predefinedSpace is a color space of the color() function: the
+// transfer functions of its channels and its conversions to and
+// from CIE XYZ with a D65 white point.
+type predefinedSpace struct {
+	// toLinear and fromLinear decode and encode a channel; both
+	// are nil for the linear spaces.
+	toLinear   func(float64) float64
+	fromLinear func(float64) float64
+	toXYZ      matrix3
+	fromXYZ    matrix3
+}
+
+// identity3 is the conversion of the XYZ spaces with a D65 white
+// point to themselves.
+var identity3 = matrix3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+// predefinedSpaces maps the names of the predefined color spaces
+// of CSS Color 4 to their conversions.
+var predefinedSpaces = map[string]predefinedSpace{
+	"srgb": {
+		toLinear:   srgbDecode,
+		fromLinear: srgbEncode,
+		toXYZ:      linearSRGBToXYZ,
+		fromXYZ:    xyzToLinearSRGB,
+	},
+	"srgb-linear": {
+		toXYZ:   linearSRGBToXYZ,
+		fromXYZ: xyzToLinearSRGB,
+	},
+	"display-p3": {
+		toLinear:   srgbDecode,
+		fromLinear: srgbEncode,
+		toXYZ: matrix3{
+			{608311.0 / 1250200, 189793.0 / 714400, 198249.0 / 1000160},
+			{35783.0 / 156275, 247089.0 / 357200, 198249.0 / 2500400},
+			{0, 32229.0 / 714400, 5220557.0 / 5000800},
+		},
+		fromXYZ: matrix3{
+			{446124.0 / 178915, -333277.0 / 357830, -72051.0 / 178915},
+			{-14852.0 / 17905, 63121.0 / 35810, 423.0 / 17905},
+			{11844.0 / 330415, -50337.0 / 660830, 316169.0 / 330415},
+		},
+	},
+	"a98-rgb": {
+		toLinear:   gammaTransfer(563.0 / 256),
+		fromLinear: gammaTransfer(256.0 / 563),
+		toXYZ: matrix3{
+			{573536.0 / 994567, 263643.0 / 1420810, 187206.0 / 994567},
+			{591459.0 / 1989134, 6239551.0 / 9945670, 374412.0 / 4972835},
+			{53769.0 / 1989134, 351524.0 / 4972835, 4929758.0 / 4972835},
+		},
+		fromXYZ: matrix3{
+			{1829569.0 / 896150, -506331.0 / 896150, -308931.0 / 896150},
+			{-851781.0 / 878810, 1648619.0 / 878810, 36519.0 / 878810},
+			{16779.0 / 1248040, -147721.0 / 1248040, 1266979.0 / 1248040},
+		},
+	},
+	"prophoto-rgb": {
+		toLinear:   prophotoDecode,
+		fromLinear: prophotoEncode,
+		toXYZ:      d50ToD65.mul(prophotoToXYZD50),
+		fromXYZ:    xyzD50ToProphoto.mul(d65ToD50),
+	},
+	"rec2020": {
+		toLinear:   rec2020Decode,
+		fromLinear: rec2020Encode,
+		toXYZ: matrix3{
+			{63426534.0 / 99577255, 20160776.0 / 139408157, 47086771.0 / 278816314},
+			{26158966.0 / 99577255, 472592308.0 / 697040785, 8267143.0 / 139408157},
+			{0, 19567812.0 / 697040785, 295819943.0 / 278816314},
+		},
+		fromXYZ: matrix3{
+			{30757411.0 / 17917100, -6372589.0 / 17917100, -4539589.0 / 17917100},
+			{-19765991.0 / 29648200, 47925759.0 / 29648200, 467509.0 / 29648200},
+			{792561.0 / 44930125, -1921689.0 / 44930125, 42328811.0 / 44930125},
+		},
+	},
+	"xyz":     {toXYZ: identity3, fromXYZ: identity3},
+	"xyz-d65": {toXYZ: identity3, fromXYZ: identity3},
+	"xyz-d50": {toXYZ: d50ToD65, fromXYZ: d65ToD50},
+}
+
+// srgbDecode and srgbEncode are the sRGB transfer functions,
+// extended to negative channels by symmetry.
+func srgbDecode(c float64) float64 {
+	return math.Copysign(srgbToLinear(math.Abs(c)), c)
+}
+
+func srgbEncode(c float64) float64 {
+	return math.Copysign(linearToSRGB(math.Abs(c)), c)
+}
+
+// gammaTransfer returns the transfer function raising channels to
+// the power gamma, keeping their sign.
+func gammaTransfer(gamma float64) func(float64) float64 {
+	return func(c float64) float64 {
+		return math.Copysign(math.Pow(math.Abs(c), gamma), c)
+	}
+}
+
+// prophotoDecode and prophotoEncode are the ProPhoto RGB transfer
+// functions, linear near black.
+func prophotoDecode(c float64) float64 {
+	if math.Abs(c) <= 16.0/512 {
+		return c / 16
+	}
+	return math.Copysign(math.Pow(math.Abs(c), 1.8), c)
+}
+
+func prophotoEncode(c float64) float64 {
+	if math.Abs(c) >= 1.0/512 {
+		return math.Copysign(math.Pow(math.Abs(c), 1/1.8), c)
+	}
+	return 16 * c
+}
+
+// The constants of the Rec. 2020 transfer function.
+const (
+	rec2020Alpha = 1.09929682680944
+	rec2020Beta  = 0.018053968510807
+)
+
+// rec2020Decode and rec2020Encode are the Rec. 2020 transfer
+// functions, linear near black.
+func rec2020Decode(c float64) float64 {
+	if math.Abs(c) < rec2020Beta*4.5 {
+		return c / 4.5
+	}
+	v := (math.Abs(c) + rec2020Alpha - 1) / rec2020Alpha
+	return math.Copysign(math.Pow(v, 1/0.45), c)
+}
+
+func rec2020Encode(c float64) float64 {
+	if math.Abs(c) > rec2020Beta {
+		v := rec2020Alpha*math.Pow(math.Abs(c), 0.45) - (rec2020Alpha - 1)
+		return math.Copysign(v, c)
+	}
+	return 4.5 * c
+}
+
+// buildPredefined converts a color of the color() function in the
+// named predefined space to sRGB. Channels are 0-1, or
+// percentages of 1; they are kept as written, beyond the gamut of
+// the space too, in the returned color.
+func buildPredefined(
+	space string,
+	args []float64,
+	isPercent []bool,
+) (Color, bool) {
+	if _, ok := predefinedSpaces[space]; !ok {
+		return Color{}, false
+	}
+	var coords [3]float64
+	for i := range coords {
+		coords[i] = args[i]
+		if isPercent[i] {
+			coords[i] /= 100.0
+		}
+	}
+
+	alpha := 1.0
+	if len(args) >= 4 {
+		alpha = args[3]
+		if isPercent[3] {
+			alpha /= 100.0
+		}
+	}
+
+	return mapGamut(Color{
+		Alpha:  clamp01(alpha),
+		Space:  space,
+		Coords: coords,
+	}), true
+}
+
+// xyz returns c in CIE XYZ with a D65 white point, computed from
+// the coordinates it was written with.
+func (c Color) xyz() [3]float64 {
+	k := c.Coords
+	switch c.Space {
+	case "lab":
+		return labToXYZ(k[0], k[1], k[2])
+	case "lch":
+		a, b := lchToAB(k[1], k[2])
+		return labToXYZ(k[0], a, b)
+	case "oklab":
+		return linearSRGBToXYZ.apply(oklabToLinear(k[0], k[1], k[2]))
+	case "oklch":
+		a, b := lchToAB(k[1], k[2])
+		return linearSRGBToXYZ.apply(oklabToLinear(k[0], a, b))
+	}
+	if sp, ok := predefinedSpaces[c.Space]; ok {
+		if sp.toLinear != nil {
+			for i := range k {
+				k[i] = sp.toLinear(k[i])
+			}
+		}
+		return sp.toXYZ.apply(k)
+	}
+	return linearSRGBToXYZ.apply([3]float64{
+		srgbToLinear(c.Red), srgbToLinear(c.Green), srgbToLinear(c.Blue),
+	})
+}
+
+// coordsIn returns the channels of c in the named predefined
+// space, unclamped, so colors beyond its gamut keep their values.
+func (c Color) coordsIn(space string) [3]float64 {
+	switch {
+	case c.Space == space:
+		return c.Coords
+	case c.Space == "" && space == "srgb":
+		return [3]float64{c.Red, c.Green, c.Blue}
+	}
+	sp := predefinedSpaces[space]
+	k := sp.fromXYZ.apply(c.xyz())
+	if sp.fromLinear != nil {
+		for i := range k {
+			k[i] = sp.fromLinear(k[i])
+		}
+	}
+	return k
+}
+
+// The just noticeable difference, in deltaEOK, and the chroma
+// precision of CSS Color 4 gamut mapping.
+const (
+	gamutJND     = 0.02
+	gamutEpsilon = 0.0001
+)
+
+// mapGamut sets the sRGB channels of c, the swatch shown for it,
+// from the coordinates it was written with. Colors beyond sRGB
+// are mapped into it as CSS Color 4 does, lowering their OKLCH
+// chroma until clipping them changes them by less than a just
+// noticeable difference, so they keep their lightness and hue.
+func mapGamut(c Color) Color {
+	if c.Space == "srgb" && inSRGBGamut(c.Coords) {
+		// Taken as is, without the rounding of converting.
+		c.Red = clamp01(c.Coords[0])
+		c.Green = clamp01(c.Coords[1])
+		c.Blue = clamp01(c.Coords[2])
+		return c
+	}
+	rgb := xyzToLinearSRGB.apply(c.xyz())
+	l, a, b := linearToOklab(rgb[0], rgb[1], rgb[2])
+	switch {
+	case l >= 1:
+		rgb = [3]float64{1, 1, 1}
+	case l <= 0:
+		rgb = [3]float64{0, 0, 0}
+	case !inSRGBGamut(rgb):
+		rgb = reduceChroma(l, a, b)
+	}
+	c.Red = clamp01(linearToSRGB(clamp01(rgb[0])))
+	c.Green = clamp01(linearToSRGB(clamp01(rgb[1])))
+	c.Blue = clamp01(linearToSRGB(clamp01(rgb[2])))
+	return c
+}
+
+// reduceChroma returns the linear sRGB color, clipped to the
+// gamut, that the CSS Color 4 binary search on the chroma of the
+// Oklab color finds.
+func reduceChroma(l, a, b float64) [3]float64 {
+	chroma := math.Hypot(a, b)
+	// clip returns the color at the chroma and its clipped form,
+	// with the deltaEOK between them.
+	clip := func(ch float64) ([3]float64, [3]float64, float64) {
+		ca, cb := a*ch/chroma, b*ch/chroma
+		cur := oklabToLinear(l, ca, cb)
+		clipped := [3]float64{
+			clamp01(cur[0]), clamp01(cur[1]), clamp01(cur[2]),
+		}
+		cl, ca2, cb2 := linearToOklab(clipped[0], clipped[1], clipped[2])
+		return cur, clipped, math.Sqrt(
+			(l-cl)*(l-cl) + (ca-ca2)*(ca-ca2) + (cb-cb2)*(cb-cb2),
+		)
+	}
+
+	_, clipped, e := clip(chroma)
+	if e < gamutJND {
+		return clipped
+	}
+	lo, hi := 0.0, chroma
+	loInGamut := true
+	for hi-lo > gamutEpsilon {
+		mid := (lo + hi) / 2
+		cur, cl, e := clip(mid)
+		if loInGamut && inSRGBGamut(cur) {
+			lo = mid
+			continue
+		}
+		clipped = cl
+		switch {
+		case e >= gamutJND:
+			hi = mid
+		case gamutJND-e < gamutEpsilon:
+			return clipped
+		default:
+			loInGamut = false
+			lo = mid
+		}
+	}
+	return clipped
+}
+
+// inSRGB reports whether c, as written, lies within sRGB.
+func (c Color) inSRGB() bool {
+	return c.Space == "" || inSRGBGamut(c.coordsIn("srgb"))
+}
+
+// inSRGBGamut reports whether the sRGB channels, linear or not,
+// are all within [0,1], give or take rounding.
+func inSRGBGamut(rgb [3]float64) bool {
+	const tolerance = 1e-6
+	for _, v := range rgb {
+		if v < -tolerance || v > 1+tolerance {
+			return false
+		}
+	}
+	return true
+}
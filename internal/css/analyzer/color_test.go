@@ -1,7 +1,9 @@
 package analyzer
 
 import (
+	"fmt"
 	"math"
+	"slices"
 	"strings"
 	"testing"
 
@@ -164,57 +166,38 @@ func TestFindDocumentColors_MultipleDeclarations(t *testing.T) {
 
 func TestColorPresentation(t *testing.T) {
 	c := Color{Red: 1.0, Green: 0.0, Blue: 0.0, Alpha: 1.0}
-	presentations := ColorPresentation(c)
-
-	if len(presentations) != 3 {
-		t.Fatalf(
-			"expected 3 presentations, got %d",
-			len(presentations),
-		)
-	}
-
-	if presentations[0] != "#ff0000" {
-		t.Errorf("expected #ff0000, got %s", presentations[0])
-	}
-	if presentations[1] != "rgb(255 0 0)" {
-		t.Errorf(
-			"expected rgb(255 0 0), got %s",
-			presentations[1],
-		)
-	}
-	if presentations[2] != "hsl(0 100% 50%)" {
-		t.Errorf(
-			"expected hsl(0 100%% 50%%), got %s",
-			presentations[2],
-		)
+	want := []string{
+		"#ff0000",
+		"rgb(255 0 0)",
+		"hsl(0 100% 50%)",
+		"hwb(0 0% 0%)",
+		"lab(54.29 80.8 69.89)",
+		"lch(54.29 106.84 40.86)",
+		"oklab(0.628 0.2249 0.1258)",
+		"oklch(0.628 0.2577 29.23)",
+		"color(display-p3 0.9175 0.2003 0.1386)",
+		"red",
+	}
+	if got := ColorPresentation(c); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
 	}
 }
 
 func TestColorPresentation_WithAlpha(t *testing.T) {
 	c := Color{Red: 1.0, Green: 0.0, Blue: 0.0, Alpha: 0.5}
-	presentations := ColorPresentation(c)
-
-	if len(presentations) != 3 {
-		t.Fatalf(
-			"expected 3 presentations, got %d",
-			len(presentations),
-		)
-	}
-
-	if presentations[0] != "#ff000080" {
-		t.Errorf("expected #ff000080, got %s", presentations[0])
-	}
-	if presentations[1] != "rgb(255 0 0 / 50%)" {
-		t.Errorf(
-			"expected rgb(255 0 0 / 50%%), got %s",
-			presentations[1],
-		)
-	}
-	if presentations[2] != "hsl(0 100% 50% / 50%)" {
-		t.Errorf(
-			"expected hsl(0 100%% 50%% / 50%%), got %s",
-			presentations[2],
-		)
+	want := []string{
+		"#ff000080",
+		"rgb(255 0 0 / 50%)",
+		"hsl(0 100% 50% / 50%)",
+		"hwb(0 0% 0% / 50%)",
+		"lab(54.29 80.8 69.89 / 50%)",
+		"lch(54.29 106.84 40.86 / 50%)",
+		"oklab(0.628 0.2249 0.1258 / 50%)",
+		"oklch(0.628 0.2577 29.23 / 50%)",
+		"color(display-p3 0.9175 0.2003 0.1386 / 50%)",
+	}
+	if got := ColorPresentation(c); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
 	}
 }
 
@@ -247,6 +230,43 @@ func TestFindDocumentColors_LchFunction(t *testing.T) {
 	}
 }
 
+func TestLabD50(t *testing.T) {
+	// Values from CSS Color 4, whose Lab is relative to D50.
+	tests := []struct {
+		rgb [3]float64
+		lab [3]float64
+	}{
+		{[3]float64{255, 0, 0}, [3]float64{54.2905, 80.8049, 69.891}},
+		{[3]float64{0, 0, 255}, [3]float64{29.5683, 68.2874, -112.0294}},
+		{[3]float64{0, 128, 0}, [3]float64{46.2775, -47.5521, 48.5837}},
+		{[3]float64{255, 255, 255}, [3]float64{100, 0, 0}},
+		{[3]float64{187.24, 87.75, 70.47}, [3]float64{50, 40, 30}},
+	}
+	near := func(a, b [3]float64, tolerance float64) bool {
+		for i := range a {
+			if math.Abs(a[i]-b[i]) > tolerance {
+				return false
+			}
+		}
+		return true
+	}
+	for _, tt := range tests {
+		c := Color{
+			Red: tt.rgb[0] / 255, Green: tt.rgb[1] / 255, Blue: tt.rgb[2] / 255,
+			Alpha: 1,
+		}
+		if d := colorToLab(c); !near([3]float64{d[0], d[1], d[2]}, tt.lab, 0.02) {
+			t.Errorf("rgb%v: lab = %v, want %v", tt.rgb, d, tt.lab)
+		}
+		src := fmt.Sprintf("lab(%g %g %g)", tt.lab[0], tt.lab[1], tt.lab[2])
+		got, ok := literalColor(src)
+		rgb := [3]float64{got.Red * 255, got.Green * 255, got.Blue * 255}
+		if !ok || !near(rgb, tt.rgb, 0.05) {
+			t.Errorf("%s: rgb = %v, want %v", src, rgb, tt.rgb)
+		}
+	}
+}
+
 func TestFindDocumentColors_OklabFunction(t *testing.T) {
 	src := []byte(`.foo { color: oklab(0.5 0.1 -0.1); }`)
 	ss, _ := parser.Parse(src)
@@ -516,7 +536,7 @@ func TestFindDocumentColorsWithVar(t *testing.T) {
 			"--primary": "#ff0000",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 1 {
 		t.Fatalf("expected 1 color, got %d", len(colors))
@@ -530,7 +550,7 @@ func TestFindDocumentColorsVarUnknown(t *testing.T) {
 	resolver := &mockResolver{
 		vars: map[string]string{},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 0 {
 		t.Fatalf("expected 0 colors, got %d", len(colors))
@@ -545,7 +565,7 @@ func TestFindDocumentColorsVarNonColor(t *testing.T) {
 			"--spacing": "10px",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 0 {
 		t.Fatalf("expected 0 colors, got %d", len(colors))
@@ -563,7 +583,7 @@ func TestFindDocumentColorsVarNested(t *testing.T) {
 			"--real":  "#00ff00",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 1 {
 		t.Fatalf("expected 1 color, got %d", len(colors))
@@ -581,7 +601,7 @@ func TestFindDocumentColorsVarTripleChain(t *testing.T) {
 			"--c": "#0000ff",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 1 {
 		t.Fatalf("expected 1 color, got %d", len(colors))
@@ -598,7 +618,7 @@ func TestFindDocumentColorsVarCircular(t *testing.T) {
 			"--b": "var(--a)",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 0 {
 		t.Fatalf("expected 0 colors, got %d", len(colors))
@@ -614,7 +634,7 @@ func TestFindDocumentColorsVarChainNamed(t *testing.T) {
 			"--y": "red",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 1 {
 		t.Fatalf("expected 1 color, got %d", len(colors))
@@ -631,7 +651,7 @@ func TestFindDocumentColorsVarChainRGB(t *testing.T) {
 			"--y": "rgb(0, 128, 255)",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 1 {
 		t.Fatalf("expected 1 color, got %d", len(colors))
@@ -647,7 +667,7 @@ func TestFindDocumentColorsVarRGBValue(t *testing.T) {
 			"--bg": "rgb(0, 255, 0)",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 1 {
 		t.Fatalf("expected 1 color, got %d", len(colors))
@@ -663,7 +683,7 @@ func TestFindDocumentColorsVarNamedColor(t *testing.T) {
 			"--accent": "blue",
 		},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 1 {
 		t.Fatalf("expected 1 color, got %d", len(colors))
@@ -768,7 +788,7 @@ func TestRelativeColor_VarOrigin(t *testing.T) {
 	resolver := &mockResolver{
 		vars: map[string]string{"--c": "#ff0000"},
 	}
-	colors := FindDocumentColorsResolved(ss, src, resolver)
+	colors := FindDocumentColorsResolved(ss, src, resolver, ColorSchemeBoth)
 
 	if len(colors) != 1 {
 		t.Fatalf("expected 1 color, got %d", len(colors))
@@ -1023,3 +1043,114 @@ func TestFindColorCodeActions_Alpha(t *testing.T) {
 		}
 	}
 }
+
+func TestFindDocumentColors_PredefinedSpaces(t *testing.T) {
+	tests := []struct {
+		src        string
+		r, g, b, a float64
+		space      string
+		coords     [3]float64
+	}{
+		{"color(srgb 1 0 0)", 1, 0, 0, 1, "srgb", [3]float64{1, 0, 0}},
+		{"color(srgb-linear 0.2 0.2 0.2)", 0.485, 0.485, 0.485, 1,
+			"srgb-linear", [3]float64{0.2, 0.2, 0.2}},
+		{"color(display-p3 100% 50% 0 / 50%)", 1, 0.49, 0, 0.5,
+			"display-p3", [3]float64{1, 0.5, 0}},
+		{"COLOR(Display-P3 0.5 0.5 0.5)", 0.5, 0.5, 0.5, 1,
+			"display-p3", [3]float64{0.5, 0.5, 0.5}},
+		{"color(xyz 0.9505 1 1.089)", 1, 1, 1, 1,
+			"xyz", [3]float64{0.9505, 1, 1.089}},
+		{"color(xyz-d50 0.9642 1 0.8252)", 1, 1, 1, 1,
+			"xyz-d50", [3]float64{0.9642, 1, 0.8252}},
+		{"color(prophoto-rgb 0.5 0.5 0.5)", 0.572, 0.572, 0.572, 1,
+			"prophoto-rgb", [3]float64{0.5, 0.5, 0.5}},
+		{"color(a98-rgb 0 0 0)", 0, 0, 0, 1,
+			"a98-rgb", [3]float64{0, 0, 0}},
+		{"color(rec2020 1.2 -0.1 0)", 1, 0.528, 0.514, 1,
+			"rec2020", [3]float64{1.2, -0.1, 0}},
+	}
+	for _, tt := range tests {
+		src := []byte(".a { color: " + tt.src + "; }")
+		ss, _ := parser.Parse(src)
+		colors := FindDocumentColors(ss, src)
+		if len(colors) != 1 {
+			t.Fatalf("%s: expected 1 color, got %d", tt.src, len(colors))
+		}
+		c := colors[0].Color
+		assertColorClose(t, c, tt.r, tt.g, tt.b, tt.a)
+		if c.Space != tt.space || c.Coords != tt.coords {
+			t.Errorf("%s: got %s %v, want %s %v",
+				tt.src, c.Space, c.Coords, tt.space, tt.coords)
+		}
+	}
+
+	src := []byte(".a { color: color(unknown 1 0 0); }")
+	ss, _ := parser.Parse(src)
+	if colors := FindDocumentColors(ss, src); len(colors) != 0 {
+		t.Errorf("unknown space: expected no colors, got %v", colors)
+	}
+}
+
+func TestFindDocumentColors_GamutMapped(t *testing.T) {
+	// Far beyond sRGB: clipping each channel would shift the hue,
+	// while gamut mapping keeps the lightness and hue.
+	src := []byte(`.a { color: oklch(0.7 0.4 150); }`)
+	ss, _ := parser.Parse(src)
+	colors := FindDocumentColors(ss, src)
+	if len(colors) != 1 {
+		t.Fatalf("expected 1 color, got %d", len(colors))
+	}
+	c := colors[0].Color
+	swatch := colorToOklch(Color{
+		Red: c.Red, Green: c.Green, Blue: c.Blue, Alpha: 1,
+	})
+	if math.Abs(swatch[0]-0.7) > 0.02 || math.Abs(swatch[2]-150) > 3 {
+		t.Errorf("swatch oklch = %v, want lightness 0.7, hue 150", swatch)
+	}
+	if swatch[1] >= 0.4 {
+		t.Errorf("swatch chroma = %f, want less than 0.4", swatch[1])
+	}
+	if c.Coords != [3]float64{0.7, 0.4, 150} {
+		t.Errorf("coords = %v", c.Coords)
+	}
+}
+
+func TestColorPresentation_DisplayP3(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"color(display-p3 0.1 1.2 -0.3)", "color(display-p3 0.1 1.2 -0.3)"},
+		{"#ffffff", "color(display-p3 1 1 1)"},
+		{"color(rec2020 0 1 0)", "color(display-p3 -0.5677 1.0326 -0.15)"},
+		{"oklch(0.7 0.4 150 / 25%)", "color(display-p3 -0.419 0.8201 -0.2103 / 25%)"},
+	}
+	for _, tt := range tests {
+		src := []byte(".a { color: " + tt.src + "; }")
+		ss, _ := parser.Parse(src)
+		colors := FindDocumentColors(ss, src)
+		if len(colors) != 1 {
+			t.Fatalf("%s: expected 1 color, got %d", tt.src, len(colors))
+		}
+		if got := ColorPresentation(colors[0].Color)[8]; got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPredefinedSpaces_RoundTrip(t *testing.T) {
+	for name, sp := range predefinedSpaces {
+		m := sp.fromXYZ.mul(sp.toXYZ)
+		for i := range 3 {
+			for j := range 3 {
+				want := 0.0
+				if i == j {
+					want = 1
+				}
+				if math.Abs(m[i][j]-want) > 1e-9 {
+					t.Errorf("%s: from·to = %v, want identity", name, m)
+				}
+			}
+		}
+	}
+}
@@ -7,12 +7,15 @@ import (
 	"github.com/toba/css-lsp/internal/css/parser"
 )
 
-// Complete returns completion items for the given byte offset.
+// Complete returns completion items for the given byte offset. An
+// optional MarkupIndex supplies the class names and ids to
+// complete after "." and "#" in selectors.
 func Complete(
 	ss *parser.Stylesheet,
 	src []byte,
 	offset int,
 	opts LintOptions,
+	markup ...MarkupIndex,
 ) []CompletionItem {
 	if ss == nil {
 		return nil
@@ -37,6 +40,11 @@ func Complete(
 		return completePseudoElements(ctx.prefix, tag, tagDep)
 	case contextSelector:
 		return completeSelectorStart(ctx.prefix)
+	case contextClassName, contextIDName:
+		if len(markup) == 0 || markup[0] == nil {
+			return nil
+		}
+		return completeMarkupNames(ctx, markup[0])
 	case contextMediaFeature:
 		return completeMediaFeatures(ctx.prefix)
 	case contextMediaValue:
@@ -59,6 +67,8 @@ const (
 	contextSelector
 	contextMediaFeature
 	contextMediaValue
+	contextClassName
+	contextIDName
 )
 
 type completionContext struct {
@@ -149,8 +159,13 @@ func determineContext(
 			}
 		}
 
-		// Inside block but not in a value = property context
+		// Inside block but not in a value = property context,
+		// unless a nested or unterminated selector names a class
+		// or id
 		prefix := extractWordPrefix(src, offset)
+		if kind, ok := markupNameContext(src, offset-len(prefix)); ok {
+			return completionContext{kind: kind, prefix: prefix}
+		}
 		return completionContext{
 			kind:   contextProperty,
 			prefix: prefix,
@@ -159,12 +174,34 @@ func determineContext(
 
 	// Top level = selector context
 	prefix := extractWordPrefix(src, offset)
+	if kind, ok := markupNameContext(src, offset-len(prefix)); ok {
+		return completionContext{kind: kind, prefix: prefix}
+	}
 	return completionContext{
 		kind:   contextSelector,
 		prefix: prefix,
 	}
 }
 
+// markupNameContext reports whether the selector name starting at
+// offset follows "." or "#", which makes it a class name or id.
+func markupNameContext(src []byte, offset int) (contextKind, bool) {
+	if offset == 0 {
+		return contextUnknown, false
+	}
+	switch src[offset-1] {
+	case '.':
+		// "1.5" is a number, not a class.
+		if offset >= 2 && src[offset-2] >= '0' && src[offset-2] <= '9' {
+			return contextUnknown, false
+		}
+		return contextClassName, true
+	case '#':
+		return contextIDName, true
+	}
+	return contextUnknown, false
+}
+
 // tagCompletionItem annotates a completion item with
 // experimental/deprecated markers based on its status.
 func tagCompletionItem(
@@ -379,6 +416,30 @@ func completeSelectorStart(
 	return items
 }
 
+// completeMarkupNames returns the class names or ids used in the
+// workspace's markup that start with the context's prefix.
+func completeMarkupNames(
+	ctx completionContext,
+	idx MarkupIndex,
+) []CompletionItem {
+	names, detail := idx.Classes(), "class used in markup"
+	if ctx.kind == contextIDName {
+		names, detail = idx.IDs(), "id used in markup"
+	}
+	var items []CompletionItem
+	for _, name := range names {
+		if !strings.HasPrefix(name, ctx.prefix) {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label:  name,
+			Kind:   KindClass,
+			Detail: detail,
+		})
+	}
+	return items
+}
+
 func completeTopLevel(
 	prefix string, tagExperimental, tagDeprecated bool,
 ) []CompletionItem {
@@ -1,6 +1,7 @@
 package analyzer
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/toba/css-lsp/internal/css/parser"
@@ -327,3 +328,42 @@ func TestCompleteDeprecatedPropertyNotTaggedWhenIgnored(
 	}
 	t.Error("expected 'clip' in property completions")
 }
+
+func TestCompleteMarkupNames(t *testing.T) {
+	idx := markupIndex{
+		classes: []string{"card", "cart", "lead"},
+		ids:     []string{"main"},
+	}
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{".ca", []string{"card", "cart"}},
+		{"a { } div.", []string{"card", "cart", "lead"}},
+		{"#", []string{"main"}},
+		{"p, #ma", []string{"main"}},
+	}
+	for _, tt := range tests {
+		src := []byte(tt.src)
+		ss, _ := parser.Parse(src)
+		items := Complete(ss, src, len(src), LintOptions{}, idx)
+		var got []string
+		for _, item := range items {
+			if item.Kind != KindClass {
+				t.Errorf("%q: %s has kind %d", tt.src, item.Label, item.Kind)
+			}
+			got = append(got, item.Label)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: completions = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+
+	// Without markup, there is nothing to offer after "." and no
+	// element names either.
+	src := []byte(".ca")
+	ss, _ := parser.Parse(src)
+	if items := Complete(ss, src, len(src), LintOptions{}); len(items) != 0 {
+		t.Errorf("completions without markup = %+v", items)
+	}
+}
@@ -0,0 +1,422 @@
+package analyzer
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/css-lsp/internal/css/scanner"
+)
+
+// ContrastMode controls how text colors with too little contrast
+// against their background are reported.
+type ContrastMode int
+
+const (
+	// ContrastIgnore suppresses contrast diagnostics (default).
+	ContrastIgnore ContrastMode = iota
+	// ContrastWarn emits a warning diagnostic.
+	ContrastWarn
+	// ContrastError treats low contrast as an error.
+	ContrastError
+)
+
+// ContrastLevel is the conformance level contrast is checked
+// against.
+type ContrastLevel int
+
+const (
+	// ContrastAA asks for 4.5:1, or APCA Lc 60 (default).
+	ContrastAA ContrastLevel = iota
+	// ContrastAAA asks for 7:1, or APCA Lc 75.
+	ContrastAAA
+)
+
+// String returns the name of the level.
+func (l ContrastLevel) String() string {
+	if l == ContrastAAA {
+		return "AAA"
+	}
+	return "AA"
+}
+
+// wcagMinimum and apcaMinimum are the least contrast each level
+// accepts for body text, as a WCAG 2.x ratio and an APCA Lc.
+var (
+	wcagMinimum = [...]float64{ContrastAA: 4.5, ContrastAAA: 7}
+	apcaMinimum = [...]float64{ContrastAA: 60, ContrastAAA: 75}
+)
+
+// ContrastPair names rules whose colors are checked together
+// besides those of a single ruleset: text of the Foreground
+// selector is taken to be shown over the background of the
+// Background selector.
+type ContrastPair struct {
+	Foreground string
+	Background string
+}
+
+// ContrastOptions configures the contrast check.
+type ContrastOptions struct {
+	// Level is the conformance level contrast must reach.
+	Level ContrastLevel
+	// APCA checks the APCA lightness contrast rather than the
+	// WCAG 2.x ratio.
+	APCA bool
+	// Pairs are the selectors checked against each other.
+	Pairs []ContrastPair
+	// Scheme is the color scheme light-dark() colors are checked
+	// in; with ColorSchemeBoth, each of its colors is.
+	Scheme ColorScheme
+}
+
+// contrastCheck is the contrast between the color of one
+// declaration and the background of another.
+type contrastCheck struct {
+	fg, bg *parser.Declaration
+	// fgSelector and bgSelector are the selectors of a pair the
+	// declarations belong to, or "" for a single ruleset.
+	fgSelector, bgSelector string
+	// scheme is the color scheme the colors are those of, or
+	// ColorSchemeBoth when they don't depend on it.
+	scheme ColorScheme
+	ratio  float64
+	lc     float64
+}
+
+// contrastChecks returns the contrast of each ruleset that sets
+// both a color and a background color, and of the rulesets of
+// each selector pair, in the color schemes that scheme asks for.
+// Colors are resolved through the resolver, which may be nil, and
+// only backgrounds that are opaque are checked.
+func contrastChecks(
+	ss *parser.Stylesheet,
+	src []byte,
+	pairs []ContrastPair,
+	scheme ColorScheme,
+	resolver VariableResolver,
+) []contrastCheck {
+	var checks []contrastCheck
+	bySelector := make(map[string][]*parser.Ruleset)
+	parser.Walk(ss, func(n parser.Node) bool {
+		rs, ok := n.(*parser.Ruleset)
+		if !ok {
+			return true
+		}
+		fg := lastDeclaration(rs, "color")
+		bg := lastDeclaration(rs, "background-color", "background")
+		if fg != nil && bg != nil {
+			checks = append(
+				checks, contrastBetween(fg, bg, src, scheme, resolver)...,
+			)
+		}
+		if len(pairs) > 0 && rs.Selectors != nil {
+			for _, sel := range rs.Selectors.Selectors {
+				text := normalizeSelector(string(src[sel.StartPos:sel.EndPos]))
+				bySelector[text] = append(bySelector[text], rs)
+			}
+		}
+		return true
+	})
+
+	for _, p := range pairs {
+		fgSel, bgSel := normalizeSelector(p.Foreground), normalizeSelector(p.Background)
+		for _, fgRule := range bySelector[fgSel] {
+			fg := lastDeclaration(fgRule, "color")
+			if fg == nil {
+				continue
+			}
+			for _, bgRule := range bySelector[bgSel] {
+				bg := lastDeclaration(bgRule, "background-color", "background")
+				if bg == nil || bgRule == fgRule {
+					continue
+				}
+				for _, c := range contrastBetween(fg, bg, src, scheme, resolver) {
+					c.fgSelector, c.bgSelector = fgSel, bgSel
+					checks = append(checks, c)
+				}
+			}
+		}
+	}
+	return checks
+}
+
+// contrastBetween returns the contrast between the colors of fg
+// and bg in each color scheme that scheme asks for, once if they
+// are the same in both.
+func contrastBetween(
+	fg, bg *parser.Declaration,
+	src []byte,
+	scheme ColorScheme,
+	resolver VariableResolver,
+) []contrastCheck {
+	schemes := []ColorScheme{scheme}
+	if scheme == ColorSchemeBoth {
+		schemes = []ColorScheme{ColorSchemeLight, ColorSchemeDark}
+	}
+	var checks []contrastCheck
+	var first [2]Color
+	resolved := 0
+	for _, s := range schemes {
+		cc := colorContext{resolver: resolver, scheme: s}
+		fc, ok := declarationColor(fg, src, cc)
+		if !ok {
+			continue
+		}
+		bc, ok := declarationColor(bg, src, cc)
+		if !ok || bc.Alpha < 1 {
+			continue
+		}
+		resolved++
+		if resolved > 1 && first == [2]Color{fc, bc} {
+			continue
+		}
+		first = [2]Color{fc, bc}
+		fc = composite(fc, bc)
+		checks = append(checks, contrastCheck{
+			fg:     fg,
+			bg:     bg,
+			scheme: s,
+			ratio:  contrastRatio(fc, bc),
+			lc:     apcaContrast(fc, bc),
+		})
+	}
+	if len(checks) == 1 && resolved == len(schemes) {
+		checks[0].scheme = ColorSchemeBoth
+	}
+	return checks
+}
+
+// lastDeclaration returns the last declaration of the ruleset
+// itself, not of rules nested in it, that sets any of the
+// properties, or nil.
+func lastDeclaration(rs *parser.Ruleset, props ...string) *parser.Declaration {
+	var found *parser.Declaration
+	for _, d := range rs.Declarations() {
+		for _, p := range props {
+			if strings.EqualFold(d.Property.Value, p) {
+				found = d
+			}
+		}
+	}
+	return found
+}
+
+// declarationColor returns the color a declaration sets, when its
+// value is nothing but a single color.
+func declarationColor(
+	decl *parser.Declaration,
+	src []byte,
+	cc colorContext,
+) (Color, bool) {
+	if decl.Value == nil {
+		return Color{}, false
+	}
+	var first, last *scanner.Token
+	for i := range decl.Value.Tokens {
+		if tok := &decl.Value.Tokens[i]; tok.Kind != scanner.Whitespace {
+			if first == nil {
+				first = tok
+			}
+			last = tok
+		}
+	}
+	if first == nil {
+		return Color{}, false
+	}
+	colors := findColorsInTokens(decl.Value.Tokens, src, cc)
+	if len(colors) != 1 || colors[0].StartPos != first.Offset ||
+		colors[0].EndPos != last.End {
+		return Color{}, false
+	}
+	return colors[0].Color, true
+}
+
+// normalizeSelector collapses the whitespace of a selector, so
+// selectors can be compared as text.
+func normalizeSelector(sel string) string {
+	return strings.Join(strings.Fields(sel), " ")
+}
+
+// composite returns fg drawn over the opaque color bg.
+func composite(fg, bg Color) Color {
+	a := fg.Alpha
+	return Color{
+		Red:   fg.Red*a + bg.Red*(1-a),
+		Green: fg.Green*a + bg.Green*(1-a),
+		Blue:  fg.Blue*a + bg.Blue*(1-a),
+		Alpha: 1,
+	}
+}
+
+// relativeLuminance returns the WCAG relative luminance of c.
+func relativeLuminance(c Color) float64 {
+	return 0.2126*srgbToLinear(c.Red) +
+		0.7152*srgbToLinear(c.Green) +
+		0.0722*srgbToLinear(c.Blue)
+}
+
+// contrastRatio returns the WCAG 2.x contrast ratio between two
+// opaque colors, from 1 to 21.
+func contrastRatio(a, b Color) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	return (max(la, lb) + 0.05) / (min(la, lb) + 0.05)
+}
+
+// apcaContrast returns the APCA lightness contrast (APCA-W3
+// 0.0.98G) of opaque text over an opaque background: positive for
+// dark text on a light background, negative for light text on a
+// dark one, and about 106 at most.
+func apcaContrast(text, bg Color) float64 {
+	const (
+		blackThreshold = 0.022
+		blackClamp     = 1.414
+		scale          = 1.14
+		offset         = 0.027
+		lowClip        = 0.1
+		minDelta       = 0.0005
+	)
+	luminance := func(c Color) float64 {
+		y := 0.2126729*math.Pow(c.Red, 2.4) +
+			0.7151522*math.Pow(c.Green, 2.4) +
+			0.0721750*math.Pow(c.Blue, 2.4)
+		if y < blackThreshold {
+			y += math.Pow(blackThreshold-y, blackClamp)
+		}
+		return y
+	}
+	yText, yBg := luminance(text), luminance(bg)
+	if math.Abs(yBg-yText) < minDelta {
+		return 0
+	}
+	if yBg > yText {
+		sapc := (math.Pow(yBg, 0.56) - math.Pow(yText, 0.57)) * scale
+		if sapc < lowClip {
+			return 0
+		}
+		return (sapc - offset) * 100
+	}
+	sapc := (math.Pow(yBg, 0.65) - math.Pow(yText, 0.62)) * scale
+	if sapc > -lowClip {
+		return 0
+	}
+	return (sapc + offset) * 100
+}
+
+// formatRatio formats a contrast ratio, rounded down so a ratio
+// just short of a minimum doesn't show as reaching it.
+func formatRatio(ratio float64) string {
+	return strconv.FormatFloat(math.Floor(ratio*100)/100, 'f', -1, 64) + ":1"
+}
+
+// formatLc formats an APCA lightness contrast, rounded toward
+// zero.
+func formatLc(lc float64) string {
+	return "Lc " + strconv.FormatFloat(math.Trunc(lc*10)/10, 'f', -1, 64)
+}
+
+// schemeSuffix names the color scheme of a check, if it depends
+// on one.
+func schemeSuffix(s ColorScheme) string {
+	switch s {
+	case ColorSchemeLight:
+		return " in light mode"
+	case ColorSchemeDark:
+		return " in dark mode"
+	}
+	return ""
+}
+
+// background describes what the foreground of the check is shown
+// over, quoting names with q.
+func (c contrastCheck) background(q string) string {
+	if c.bgSelector != "" {
+		return "the background of " + q + c.bgSelector + q
+	}
+	return q + c.bg.Property.Value + q
+}
+
+// foreground describes the text color of the check, quoting names
+// with q.
+func (c contrastCheck) foreground(q string) string {
+	if c.fgSelector != "" {
+		return "the color of " + q + c.fgSelector + q
+	}
+	return q + c.fg.Property.Value + q
+}
+
+// checkContrast reports color declarations whose contrast with
+// their background is below the configured level.
+func (a *diagAnalyzer) checkContrast(
+	ss *parser.Stylesheet,
+	resolver VariableResolver,
+) {
+	if a.opts.LowContrast == ContrastIgnore {
+		return
+	}
+	sev := SeverityWarning
+	if a.opts.LowContrast == ContrastError {
+		sev = SeverityError
+	}
+	opts := a.opts.Contrast
+	for _, c := range contrastChecks(ss, a.src, opts.Pairs, opts.Scheme, resolver) {
+		contrast, required := formatRatio(c.ratio), formatRatio(wcagMinimum[opts.Level])
+		low := c.ratio < wcagMinimum[opts.Level]
+		if opts.APCA {
+			contrast, required = formatLc(c.lc), formatLc(apcaMinimum[opts.Level])
+			low = math.Abs(c.lc) < apcaMinimum[opts.Level]
+		}
+		if !low {
+			continue
+		}
+		a.addDiag(
+			LowContrastMessage(
+				contrast, c.background("'")+schemeSuffix(c.scheme),
+				opts.Level.String(), required,
+			),
+			c.fg.Offset(), c.fg.End(), sev,
+		)
+	}
+}
+
+// hoverContrast returns the contrast of the color or background
+// declaration decl with what it is paired with, as paragraphs to
+// append to its hover, in the color scheme and with the selector
+// pairs of opts.
+func hoverContrast(
+	ss *parser.Stylesheet,
+	src []byte,
+	decl *parser.Declaration,
+	opts HoverOptions,
+) string {
+	switch strings.ToLower(decl.Property.Value) {
+	case "color", "background-color", "background":
+	default:
+		return ""
+	}
+	var b strings.Builder
+	checks := contrastChecks(ss, src, opts.ContrastPairs, opts.Scheme, opts.Resolver)
+	for _, c := range checks {
+		var other string
+		switch decl {
+		case c.fg:
+			other = c.background("`")
+		case c.bg:
+			other = c.foreground("`")
+		default:
+			continue
+		}
+		levels := "fails AA and AAA"
+		switch {
+		case c.ratio >= wcagMinimum[ContrastAAA]:
+			levels = "passes AA and AAA"
+		case c.ratio >= wcagMinimum[ContrastAA]:
+			levels = "passes AA, fails AAA"
+		}
+		b.WriteString("\n\nContrast with " + other + schemeSuffix(c.scheme) +
+			": **" + formatRatio(c.ratio) + "** (" + levels + "), APCA " +
+			formatLc(c.lc))
+	}
+	return b.String()
+}
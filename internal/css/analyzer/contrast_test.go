@@ -0,0 +1,205 @@
+package analyzer
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// contrastIndex is a VariableResolver backed by a map.
+type contrastIndex map[string]string
+
+func (m contrastIndex) ResolveVariable(name string) (string, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+func TestContrastRatio(t *testing.T) {
+	tests := []struct {
+		fg, bg string
+		ratio  float64
+		lc     float64
+	}{
+		{"#000", "#fff", 21, 106.04},
+		{"#fff", "#000", 21, -107.88},
+		{"#777", "#fff", 4.48, 71.11},
+		{"#767676", "#fff", 4.54, 71.57},
+		{"#fff", "#fff", 1, 0},
+		{"rgb(0 0 0 / 50%)", "#fff", 3.98, 67.13},
+	}
+	for _, tt := range tests {
+		src := []byte("a { color: " + tt.fg + "; background: " + tt.bg + "; }")
+		checks := contrastChecks(parseCSS(t, src), src, nil, ColorSchemeBoth, nil)
+		if len(checks) != 1 {
+			t.Fatalf("%s on %s: got %d checks", tt.fg, tt.bg, len(checks))
+		}
+		c := checks[0]
+		if math.Abs(c.ratio-tt.ratio) > 0.01 || math.Abs(c.lc-tt.lc) > 0.01 {
+			t.Errorf("%s on %s: got %.2f:1, Lc %.2f; want %.2f:1, Lc %.2f",
+				tt.fg, tt.bg, c.ratio, c.lc, tt.ratio, tt.lc)
+		}
+	}
+}
+
+func TestAnalyzeLowContrast(t *testing.T) {
+	src := []byte(`.a { color: #777; background-color: #fff; }
+.b { color: #767676; background-color: white; }
+.c { color: #555; background: url(x.png) #fff; }
+.d { color: #777; background: rgb(255 255 255 / 50%); }
+.e { color: #777; }`)
+	ss := parseCSS(t, src)
+
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
+	if countPrefix(diags, "low contrast") != 0 {
+		t.Error("contrast should not be checked unless asked for")
+	}
+
+	diags = Analyze(ss, src, LintOptions{LowContrast: ContrastWarn}, Indexes{})
+	msg := LowContrastMessage("4.47:1", "'background-color'", "AA", "4.5:1")
+	d, ok := findDiagnostic(diags, msg)
+	if !ok {
+		t.Fatalf("expected %q, got %v", msg, diags)
+	}
+	if d.Severity != SeverityWarning || d.StartLine != 0 ||
+		d.StartChar != indexOf(src, "color") {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+	if n := countPrefix(diags, "low contrast"); n != 1 {
+		t.Errorf("got %d contrast diagnostics, want 1", n)
+	}
+
+	diags = Analyze(ss, src, LintOptions{
+		LowContrast: ContrastError,
+		Contrast:    ContrastOptions{Level: ContrastAAA},
+	}, Indexes{})
+	if n := countPrefix(diags, "low contrast"); n != 2 {
+		t.Errorf("AAA: got %d contrast diagnostics, want 2", n)
+	}
+	msg = LowContrastMessage("4.54:1", "'background-color'", "AAA", "7:1")
+	if d, ok := findDiagnostic(diags, msg); !ok || d.Severity != SeverityError {
+		t.Errorf("expected error %q, got %v", msg, diags)
+	}
+}
+
+func TestAnalyzeLowContrast_APCA(t *testing.T) {
+	src := []byte(`.a { color: #777; background: #fff; }
+.b { color: #999; background: #fff; }`)
+	ss := parseCSS(t, src)
+	diags := Analyze(ss, src, LintOptions{
+		LowContrast: ContrastWarn,
+		Contrast:    ContrastOptions{APCA: true},
+	}, Indexes{})
+	msg := LowContrastMessage("Lc 54.6", "'background'", "AA", "Lc 60")
+	if _, ok := findDiagnostic(diags, msg); !ok {
+		t.Errorf("expected %q, got %v", msg, diags)
+	}
+	if n := countPrefix(diags, "low contrast"); n != 1 {
+		t.Errorf("got %d contrast diagnostics, want 1", n)
+	}
+}
+
+func TestAnalyzeLowContrast_Variables(t *testing.T) {
+	src := []byte(`.a { color: var(--text); background: var(--surface); }
+.b { color: light-dark(#000, #666); background: light-dark(#fff, #333); }`)
+	ss := parseCSS(t, src)
+	idx := contrastIndex{"--text": "#999", "--surface": "#fff"}
+	opts := LintOptions{LowContrast: ContrastWarn}
+
+	diags := Analyze(ss, src, opts, Indexes{Resolver: idx})
+	for _, msg := range []string{
+		LowContrastMessage("2.84:1", "'background'", "AA", "4.5:1"),
+		LowContrastMessage("2.2:1", "'background' in dark mode", "AA", "4.5:1"),
+	} {
+		if _, ok := findDiagnostic(diags, msg); !ok {
+			t.Errorf("expected %q, got %v", msg, diags)
+		}
+	}
+	if n := countPrefix(diags, "low contrast"); n != 2 {
+		t.Errorf("got %d contrast diagnostics, want 2", n)
+	}
+
+	opts.Contrast.Scheme = ColorSchemeLight
+	diags = Analyze(ss, src, opts, Indexes{Resolver: idx})
+	if n := countPrefix(diags, "low contrast"); n != 1 {
+		t.Errorf("light scheme: got %d contrast diagnostics, want 1", n)
+	}
+}
+
+func TestAnalyzeLowContrast_Pairs(t *testing.T) {
+	src := []byte(`.card  >  .label { color: #aaa; }
+.card, .panel { background-color: #fff; }
+.other { background-color: #000; }`)
+	ss := parseCSS(t, src)
+	diags := Analyze(ss, src, LintOptions{
+		LowContrast: ContrastWarn,
+		Contrast: ContrastOptions{Pairs: []ContrastPair{
+			{Foreground: ".card > .label", Background: ".card"},
+		}},
+	}, Indexes{})
+	msg := LowContrastMessage(
+		"2.32:1", "the background of '.card'", "AA", "4.5:1",
+	)
+	if _, ok := findDiagnostic(diags, msg); !ok {
+		t.Errorf("expected %q, got %v", msg, diags)
+	}
+	if n := countPrefix(diags, "low contrast"); n != 1 {
+		t.Errorf("got %d contrast diagnostics, want 1", n)
+	}
+}
+
+func TestHoverContrast(t *testing.T) {
+	src := []byte(`.a { color: #777; background-color: #fff; }
+.label { color: #000; }
+.card { background: light-dark(#fff, #222); }`)
+	ss := parseCSS(t, src)
+
+	h := Hover(ss, src, indexOf(src, "color"), HoverOptions{})
+	want := "Contrast with `background-color`: **4.47:1** " +
+		"(fails AA and AAA), APCA Lc 71.1"
+	if !h.Found || !strings.Contains(h.Content, want) {
+		t.Errorf("color hover: got %q, want %q in it", h.Content, want)
+	}
+
+	h = Hover(ss, src, indexOf(src, "#fff"), HoverOptions{})
+	want = "Contrast with `color`: **4.47:1** (fails AA and AAA), APCA Lc 71.1"
+	if !h.Found || h.Content != want {
+		t.Errorf("background value hover: got %q, want %q", h.Content, want)
+	}
+	if start := indexOf(src, "background-color"); h.RangeStart != start {
+		t.Errorf("range start = %d, want %d", h.RangeStart, start)
+	}
+
+	opts := HoverOptions{ContrastPairs: []ContrastPair{
+		{Foreground: ".label", Background: ".card"},
+	}}
+	h = Hover(ss, src, indexOf(src, "#000"), opts)
+	for _, want := range []string{
+		"Contrast with the background of `.card` in light mode: **21:1** " +
+			"(passes AA and AAA), APCA Lc 106",
+		"Contrast with the background of `.card` in dark mode: **1.31:1** " +
+			"(fails AA and AAA), APCA Lc 0",
+	} {
+		if !strings.Contains(h.Content, want) {
+			t.Errorf("pair hover: got %q, want %q in it", h.Content, want)
+		}
+	}
+
+	opts.Scheme = ColorSchemeDark
+	h = Hover(ss, src, indexOf(src, "#000"), opts)
+	if strings.Contains(h.Content, "light mode") ||
+		!strings.Contains(h.Content, "Contrast with the background of `.card`: ") {
+		t.Errorf("dark scheme hover: got %q", h.Content)
+	}
+}
+
+// countPrefix returns the number of diagnostics whose message
+// starts with prefix.
+func countPrefix(diags []Diagnostic, prefix string) int {
+	n := 0
+	for _, d := range diags {
+		if strings.HasPrefix(d.Message, prefix) {
+			n++
+		}
+	}
+	return n
+}
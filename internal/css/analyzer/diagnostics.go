@@ -1,12 +1,14 @@
 package analyzer
 
 import (
+	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/toba/css-lsp/internal/css/data"
 	"github.com/toba/css-lsp/internal/css/parser"
 	"github.com/toba/css-lsp/internal/css/scanner"
+	"github.com/toba/css-lsp/internal/lineindex"
 )
 
 // vendorPrefixes lists common CSS vendor prefixes.
@@ -23,17 +25,64 @@ func hasVendorPrefix(name string) bool {
 	return false
 }
 
-// Analyze returns diagnostics for the parsed stylesheet.
+// Indexes are what Analyze knows of the workspace beyond the
+// stylesheet. A check that needs an index it isn't given is
+// skipped.
+type Indexes struct {
+	// Variables tells which custom properties the workspace
+	// defines, so var() references to others are reported.
+	Variables VariableIndex
+	// Resolver resolves the var() references in the colors the
+	// contrast check compares.
+	Resolver VariableResolver
+	// Markup tells which classes and ids the workspace's markup
+	// uses, so selectors of others are reported.
+	Markup MarkupIndex
+	// Modules, for a CSS module, resolves composes and @value
+	// imports from other modules and tells which local classes
+	// importers use.
+	Modules ModuleIndex
+	// Imports resolves @import rules, so those that load nothing
+	// or lead back to the stylesheet are reported.
+	Imports ImportIndex
+	// URLs resolves url() references, so those naming files that
+	// don't exist are reported.
+	URLs URLIndex
+}
+
+// Analyze returns diagnostics for the parsed stylesheet, running
+// the checks opts enables that the given indexes allow.
 func Analyze(
 	ss *parser.Stylesheet,
 	src []byte,
 	opts LintOptions,
+	ix Indexes,
 ) []Diagnostic {
 	if ss == nil {
 		return nil
 	}
 	a := &diagAnalyzer{src: src, opts: opts}
+	var modules ModuleIndex
+	if opts.CSSModules {
+		a.module = ParseModule(ss, src)
+		modules = ix.Modules
+	}
 	a.analyzeStylesheet(ss)
+	a.checkDeclarationOrder(ss)
+	if a.module != nil {
+		a.checkModule(modules)
+	}
+	a.checkContrast(ss, ix.Resolver)
+	if ix.Variables != nil {
+		a.checkUndefinedVariables(ss, ix.Variables)
+	}
+	a.checkUnusedSelectors(ss, ix.Markup, modules)
+	if ix.Imports != nil {
+		a.checkImports(ss, ix.Imports)
+	}
+	if ix.URLs != nil {
+		a.checkURLs(ss, ix.URLs)
+	}
 	return a.diags
 }
 
@@ -41,13 +90,21 @@ type diagAnalyzer struct {
 	src   []byte
 	opts  LintOptions
 	diags []Diagnostic
+	// lines converts diagnostic offsets to positions; it is built
+	// on the first diagnostic.
+	lines *lineindex.Index
+	// module is set when analyzing a CSS module.
+	module *Module
 }
 
 func (a *diagAnalyzer) addDiag(
 	msg string, startOff, endOff, severity int,
 ) {
-	line, char := OffsetToLineChar(a.src, startOff)
-	endLine, endChar := OffsetToLineChar(a.src, endOff)
+	if a.lines == nil {
+		a.lines = lineindex.New(a.src)
+	}
+	line, char := a.lines.Position(startOff, lineindex.UTF8)
+	endLine, endChar := a.lines.Position(endOff, lineindex.UTF8)
 	a.diags = append(a.diags, Diagnostic{
 		Message:   msg,
 		StartLine: line,
@@ -104,6 +161,9 @@ func (a *diagAnalyzer) analyzeDeclaration(
 	}
 
 	// Check for unknown properties
+	if a.module != nil && strings.EqualFold(propName, composesProperty) {
+		return
+	}
 	if !data.IsKnownProperty(propName) {
 		a.addDiag(
 			UnknownPropertyMessage(propName),
@@ -231,25 +291,7 @@ func (a *diagAnalyzer) checkUnknownValues(
 		propName == "transition-property" ||
 		propName == "will-change"
 
-	// Build lookup set for property values
-	validValues := make(map[string]bool, len(prop.Values)+len(data.GlobalValues))
-	for _, v := range prop.Values {
-		validValues[v] = true
-	}
-	for _, v := range data.GlobalValues {
-		validValues[v] = true
-	}
-
-	// Merge longhand values for shorthand properties.
-	if longhands, ok := data.ShorthandLonghands[propName]; ok {
-		for _, lh := range longhands {
-			if lhProp := data.LookupProperty(lh); lhProp != nil {
-				for _, v := range lhProp.Values {
-					validValues[v] = true
-				}
-			}
-		}
-	}
+	validValues := propertyValues(propName)
 
 	sev := SeverityWarning
 	if a.opts.UnknownValues == UnknownValueError {
@@ -274,6 +316,11 @@ func (a *diagAnalyzer) checkUnknownValues(
 		if !a.opts.StrictColorNames && isNamedColor(val) {
 			continue
 		}
+		if a.module != nil {
+			if _, ok := a.module.Value(tok.Value); ok {
+				continue
+			}
+		}
 		a.addDiag(
 			UnknownValueMessage(tok.Value, propName),
 			tok.Offset, tok.End,
@@ -282,13 +329,322 @@ func (a *diagAnalyzer) checkUnknownValues(
 	}
 }
 
+// checkUndefinedVariables reports var() references to custom
+// properties that are declared neither in the stylesheet nor
+// anywhere in the workspace. References with a fallback value
+// are valid either way and are skipped.
+func (a *diagAnalyzer) checkUndefinedVariables(
+	ss *parser.Stylesheet,
+	idx VariableIndex,
+) {
+	if a.opts.UndefinedVariables == UndefinedVariableIgnore {
+		return
+	}
+	sev := SeverityWarning
+	if a.opts.UndefinedVariables == UndefinedVariableError {
+		sev = SeverityError
+	}
+
+	local := make(map[string]bool)
+	parser.Walk(ss, func(n parser.Node) bool {
+		if decl, ok := n.(*parser.Declaration); ok &&
+			IsCustomProperty(decl.Property.Value) {
+			local[decl.Property.Value] = true
+		}
+		return true
+	})
+
+	parser.Walk(ss, func(n parser.Node) bool {
+		decl, ok := n.(*parser.Declaration)
+		if !ok || decl.Value == nil {
+			return true
+		}
+		tokens := decl.Value.Tokens
+		for _, ref := range findVarRefs(tokens) {
+			ident := tokens[ref.identIdx]
+			if local[ident.Value] || hasVarFallback(tokens, ref) ||
+				idx.HasVariable(ident.Value) {
+				continue
+			}
+			a.addDiag(
+				UndefinedVariableMessage(ident.Value),
+				ident.Offset, ident.End,
+				sev,
+			)
+		}
+		return true
+	})
+}
+
+// checkUnusedSelectors reports class and id selectors whose names
+// appear in none of the workspace's markup, and in CSS modules,
+// local classes that no importer of the module uses. Nothing is
+// reported while no markup is indexed, or for modules, while
+// nothing imports the module. Local ids of modules are not
+// checked.
+func (a *diagAnalyzer) checkUnusedSelectors(
+	ss *parser.Stylesheet,
+	markup MarkupIndex,
+	modules ModuleIndex,
+) {
+	if a.opts.UnusedSelectors == UnusedSelectorIgnore {
+		return
+	}
+	checkMarkup := markup != nil && markup.HasMarkup()
+	checkModule := modules != nil && a.module != nil && modules.ModuleImported()
+	if !checkMarkup && !checkModule {
+		return
+	}
+	sev := SeverityWarning
+	if a.opts.UnusedSelectors == UnusedSelectorError {
+		sev = SeverityError
+	}
+
+	parser.Walk(ss, func(n parser.Node) bool {
+		rs, ok := n.(*parser.Ruleset)
+		if !ok || rs.Selectors == nil {
+			return true
+		}
+		for _, sel := range rs.Selectors.Selectors {
+			walkSelectorNames(sel, a.module != nil, func(name selectorName) {
+				tok := name.tok
+				switch {
+				case name.local:
+					if checkModule && name.class && !modules.ModuleClassUsed(tok.Value) &&
+						!a.module.composedLocally(tok.Value) {
+						a.addDiag(UnusedModuleClassMessage(tok.Value),
+							name.start, tok.End, sev)
+					}
+				case !checkMarkup:
+				case name.class:
+					if !markup.HasClass(tok.Value) {
+						a.addDiag(UnusedClassMessage(tok.Value),
+							name.start, tok.End, sev)
+					}
+				case !markup.HasID(tok.Value):
+					a.addDiag(UnusedIDMessage(tok.Value), tok.Offset, tok.End, sev)
+				}
+			})
+		}
+		return true
+	})
+}
+
+// checkDeclarationOrder reports, for each run of declarations out
+// of order, the first declaration that belongs earlier.
+func (a *diagAnalyzer) checkDeclarationOrder(ss *parser.Stylesheet) {
+	if a.opts.OutOfOrder == OutOfOrderIgnore {
+		return
+	}
+	sev := SeverityWarning
+	if a.opts.OutOfOrder == OutOfOrderError {
+		sev = SeverityError
+	}
+	for _, fix := range DeclarationOrderFixes(ss, a.src, a.opts.Order) {
+		a.addDiag(
+			OutOfOrderMessage(fix.Decl.Property.Value, fix.Before.Property.Value),
+			fix.Decl.Offset(), fix.Decl.End(), sev,
+		)
+	}
+}
+
+// checkModule validates the composes declarations and @value
+// imports of a CSS module. Imports from other modules are checked
+// only with an index to resolve them, and only for relative paths;
+// packages are left to the bundler. Broken references are reported
+// as ModuleReferences asks.
+func (a *diagAnalyzer) checkModule(modules ModuleIndex) {
+	report := a.opts.ModuleReferences != ModuleReferenceIgnore
+	sev := SeverityWarning
+	if a.opts.ModuleReferences == ModuleReferenceError {
+		sev = SeverityError
+	}
+	resolved := make(map[string]*Module)
+	resolve := func(from string, start, end int) (*Module, bool) {
+		if !strings.HasPrefix(from, "./") && !strings.HasPrefix(from, "../") {
+			return nil, false
+		}
+		m, ok := resolved[from]
+		if !ok {
+			m, _ = modules.ResolveModule(from)
+			resolved[from] = m
+		}
+		if m == nil && report {
+			a.addDiag(UnresolvedModuleMessage(from), start, end, sev)
+		}
+		return m, m != nil
+	}
+
+	for _, c := range a.module.Composes {
+		if c.Class == "" {
+			a.addDiag(
+				ComposesSelectorMsg,
+				c.Decl.Property.Offset, c.Decl.Property.End,
+				SeverityError,
+			)
+		}
+		var target *Module
+		switch c.From {
+		case composesGlobal:
+			continue
+		case "":
+			target = a.module
+		default:
+			if modules == nil {
+				continue
+			}
+			var ok bool
+			if target, ok = resolve(c.From, c.FromStart, c.FromEnd); !ok {
+				continue
+			}
+		}
+		if !report {
+			continue
+		}
+		for _, tok := range c.Names {
+			if !target.HasClass(tok.Value) {
+				a.addDiag(
+					UndefinedModuleClassMessage(tok.Value, c.From),
+					tok.Offset, tok.End,
+					sev,
+				)
+			}
+		}
+	}
+
+	if modules == nil || !report {
+		return
+	}
+	for _, v := range a.module.Values {
+		if v.From == "" {
+			continue
+		}
+		target, ok := resolve(v.From, v.FromStart, v.FromEnd)
+		if !ok {
+			continue
+		}
+		if _, ok := target.Value(v.Imported); !ok {
+			a.addDiag(
+				UndefinedModuleValueMessage(v.Imported, v.From),
+				v.Start, v.End,
+				sev,
+			)
+		}
+	}
+}
+
+// checkImports reports @import rules whose stylesheet can't be
+// found, and those leading back to the stylesheet being analyzed.
+// Remote URLs are not checked.
+func (a *diagAnalyzer) checkImports(ss *parser.Stylesheet, imports ImportIndex) {
+	unresolved := a.opts.UnresolvedImports != UnresolvedImportIgnore
+	cycles := a.opts.ImportCycles != ImportCycleIgnore
+	if !unresolved && !cycles {
+		return
+	}
+	unresolvedSev, cycleSev := SeverityWarning, SeverityWarning
+	if a.opts.UnresolvedImports == UnresolvedImportError {
+		unresolvedSev = SeverityError
+	}
+	if a.opts.ImportCycles == ImportCycleError {
+		cycleSev = SeverityError
+	}
+	for _, link := range FindDocumentLinks(ss, a.src) {
+		if !link.Import || IsRemoteURL(link.Target) {
+			continue
+		}
+		path, ok := imports.ResolveImport(link.Target)
+		if !ok {
+			if unresolved {
+				a.addDiag(UnresolvedImportMessage(link.Target),
+					link.StartPos, link.EndPos, unresolvedSev)
+			}
+			continue
+		}
+		if !cycles {
+			continue
+		}
+		if cycle := imports.ImportCycle(path); cycle != nil {
+			names := make([]string, len(cycle))
+			for i, p := range cycle {
+				names[i] = filepath.Base(p)
+			}
+			a.addDiag(ImportCycleMessage(names),
+				link.StartPos, link.EndPos, cycleSev)
+		}
+	}
+}
+
+// checkURLs reports url() references to files that don't exist.
+// Remote and data: URLs are not checked, nor are references to
+// fragments of the document itself, such as url(#clip).
+func (a *diagAnalyzer) checkURLs(ss *parser.Stylesheet, urls URLIndex) {
+	if a.opts.MissingFiles == MissingFileIgnore {
+		return
+	}
+	sev := SeverityWarning
+	if a.opts.MissingFiles == MissingFileError {
+		sev = SeverityError
+	}
+	for _, link := range FindDocumentLinks(ss, a.src) {
+		if link.Import || IsRemoteURL(link.Target) ||
+			strings.HasPrefix(link.Target, "#") {
+			continue
+		}
+		if _, ok := urls.ResolveURL(link.Target); !ok {
+			a.addDiag(MissingFileMessage(link.Target),
+				link.StartPos, link.EndPos, sev)
+		}
+	}
+}
+
+// isClassDot reports whether parts[i] is the "." of a class
+// selector, directly followed by the class name.
+func isClassDot(parts []parser.SelectorPart, i int) bool {
+	tok := parts[i].Token
+	if tok.Kind != scanner.Delim || tok.Value != "." || i+1 >= len(parts) {
+		return false
+	}
+	next := parts[i+1].Token
+	return next.Kind == scanner.Ident && next.Offset == tok.End
+}
+
+// propertyValues returns the keywords a property takes: its own,
+// those of its longhands if it is a shorthand, and the global ones.
+func propertyValues(propName string) map[string]bool {
+	var own []string
+	if prop := data.LookupProperty(propName); prop != nil {
+		own = prop.Values
+	}
+	values := make(map[string]bool, len(own)+len(data.GlobalValues))
+	for _, v := range own {
+		values[v] = true
+	}
+	for _, v := range data.GlobalValues {
+		values[v] = true
+	}
+
+	// Merge longhand values for shorthand properties.
+	if longhands, ok := data.ShorthandLonghands[propName]; ok {
+		for _, lh := range longhands {
+			if lhProp := data.LookupProperty(lh); lhProp != nil {
+				for _, v := range lhProp.Values {
+					values[v] = true
+				}
+			}
+		}
+	}
+	return values
+}
+
 // isNamedColor returns true if the value is a CSS named color.
 func isNamedColor(val string) bool {
 	return slices.Contains(data.NamedColors, val)
 }
 
 func (a *diagAnalyzer) analyzeAtRule(rule *parser.AtRule) {
-	if !data.IsKnownAtRule(rule.Name) {
+	isValue := a.module != nil && strings.EqualFold(rule.Name, valueAtRule)
+	if !isValue && !data.IsKnownAtRule(rule.Name) {
 		a.addDiag(
 			UnknownAtRuleMessage(rule.Name),
 			rule.Offset(), rule.Offset()+len(rule.Name)+1,
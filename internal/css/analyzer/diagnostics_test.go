@@ -1,11 +1,15 @@
 package analyzer
 
-import "testing"
+import (
+	"slices"
+	"strings"
+	"testing"
+)
 
 func TestAnalyzeUnknownProperty(t *testing.T) {
 	src := []byte(`body { colo: red; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, UnknownPropertyMessage("colo"),
@@ -17,7 +21,7 @@ func TestAnalyzeUnknownProperty(t *testing.T) {
 func TestAnalyzeKnownProperty(t *testing.T) {
 	src := []byte(`body { color: red; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, UnknownPropertyMessage("color"),
@@ -29,7 +33,7 @@ func TestAnalyzeKnownProperty(t *testing.T) {
 func TestAnalyzeDuplicateProperty(t *testing.T) {
 	src := []byte(`body { color: red; color: blue; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, DuplicatePropertyMessage("color"),
@@ -41,7 +45,7 @@ func TestAnalyzeDuplicateProperty(t *testing.T) {
 func TestAnalyzeEmptyRuleset(t *testing.T) {
 	src := []byte(`body { }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, EmptyRulesetMsg,
@@ -53,7 +57,7 @@ func TestAnalyzeEmptyRuleset(t *testing.T) {
 func TestAnalyzeUnknownAtRule(t *testing.T) {
 	src := []byte(`@foobar { }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, UnknownAtRuleMessage("foobar"),
@@ -65,7 +69,7 @@ func TestAnalyzeUnknownAtRule(t *testing.T) {
 func TestAnalyzeZeroWithUnit(t *testing.T) {
 	src := []byte(`body { margin: 0px; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	found := false
 	for _, d := range diags {
@@ -82,7 +86,7 @@ func TestAnalyzeZeroWithUnit(t *testing.T) {
 func TestAnalyzeZeroWithUnit_TimeAllowed(t *testing.T) {
 	src := []byte(`body { transition-duration: 0s; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == "unnecessary unit: '0s' can be written as '0'" {
@@ -94,7 +98,7 @@ func TestAnalyzeZeroWithUnit_TimeAllowed(t *testing.T) {
 func TestAnalyzeImportant(t *testing.T) {
 	src := []byte(`body { color: red !important; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, AvoidImportantMsg,
@@ -106,7 +110,7 @@ func TestAnalyzeImportant(t *testing.T) {
 func TestAnalyzeVendorPrefix(t *testing.T) {
 	src := []byte(`body { -webkit-transform: rotate(0); }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, VendorPrefixMessage("-webkit-transform"),
@@ -118,7 +122,7 @@ func TestAnalyzeVendorPrefix(t *testing.T) {
 func TestAnalyzeCustomProperty(t *testing.T) {
 	src := []byte(`:root { --my-color: blue; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, UnknownPropertyMessage("--my-color"),
@@ -134,7 +138,7 @@ func TestAnalyzeNesting_NoFalsePositives(t *testing.T) {
 	.child { font-size: 14px; }
 }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	// Should not flag nested selectors as unknown properties
 	for _, d := range diags {
@@ -147,7 +151,7 @@ func TestAnalyzeNesting_NoFalsePositives(t *testing.T) {
 func TestAnalyzeNesting_NestedNotEmpty(t *testing.T) {
 	src := []byte(`.parent { &:hover { color: blue; } }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	// Parent has a nested rule, so it's not empty
 	if _, ok := findDiagnostic(
@@ -160,7 +164,7 @@ func TestAnalyzeNesting_NestedNotEmpty(t *testing.T) {
 func TestAnalyzeNesting_NestedAtRule(t *testing.T) {
 	src := []byte(`.parent { @media (hover) { color: blue; } }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	// Should not produce unknown at-rule for @media
 	if _, ok := findDiagnostic(
@@ -173,7 +177,7 @@ func TestAnalyzeNesting_NestedAtRule(t *testing.T) {
 func TestAnalyzeUnknownValue_Warn(t *testing.T) {
 	src := []byte(`body { justify-content: banana; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -186,7 +190,7 @@ func TestAnalyzeUnknownValue_Warn(t *testing.T) {
 func TestAnalyzeUnknownValue_ValidValue(t *testing.T) {
 	src := []byte(`body { justify-content: center; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -199,7 +203,7 @@ func TestAnalyzeUnknownValue_ValidValue(t *testing.T) {
 func TestAnalyzeUnknownValue_GlobalValue(t *testing.T) {
 	src := []byte(`body { justify-content: inherit; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -214,7 +218,7 @@ func TestAnalyzeUnknownValue_NamedColorLenient(t *testing.T) {
 	// accepted for any property
 	src := []byte(`body { justify-content: red; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -229,7 +233,7 @@ func TestAnalyzeUnknownValue_NamedColorStrict(t *testing.T) {
 	ss := parseCSS(t, src)
 	diags := Analyze(ss, src, LintOptions{
 		StrictColorNames: true,
-	})
+	}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -249,7 +253,7 @@ func TestAnalyzeUnknownValue_ColorPropertyAcceptsColor(
 	ss := parseCSS(t, src)
 	diags := Analyze(ss, src, LintOptions{
 		StrictColorNames: true,
-	})
+	}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -264,7 +268,7 @@ func TestAnalyzeUnknownValue_Ignore(t *testing.T) {
 	ss := parseCSS(t, src)
 	diags := Analyze(ss, src, LintOptions{
 		UnknownValues: UnknownValueIgnore,
-	})
+	}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -279,7 +283,7 @@ func TestAnalyzeUnknownValue_Error(t *testing.T) {
 	ss := parseCSS(t, src)
 	diags := Analyze(ss, src, LintOptions{
 		UnknownValues: UnknownValueError,
-	})
+	}, Indexes{})
 
 	d, ok := findDiagnostic(
 		diags,
@@ -298,7 +302,7 @@ func TestAnalyzeUnknownValue_VarSkipped(t *testing.T) {
 		`body { justify-content: var(--x); }`,
 	)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == UnknownValueMessage(
@@ -312,7 +316,7 @@ func TestAnalyzeUnknownValue_VarSkipped(t *testing.T) {
 func TestAnalyzeUnknownValue_CustomProperty(t *testing.T) {
 	src := []byte(`:root { --foo: banana; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == UnknownValueMessage(
@@ -330,7 +334,7 @@ func TestAnalyzeUnknownValue_UnknownProperty(t *testing.T) {
 	// Unknown properties should not also get value diagnostics
 	src := []byte(`body { foobar: banana; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -348,7 +352,7 @@ func TestAnalyzeUnknownValue_NoValuesProperty(t *testing.T) {
 	// should skip value validation
 	src := []byte(`body { border: banana; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -366,7 +370,7 @@ func TestAnalyzeUnknownValue_TransitionProperty(t *testing.T) {
 		`body { transition: border-color 0.15s, background 0.15s, color 0.15s; }`,
 	)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == UnknownValueMessage("border-color", "transition") ||
@@ -384,7 +388,7 @@ func TestAnalyzeUnknownValue_TransitionPropertyProp(
 		`body { transition-property: opacity, transform; }`,
 	)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == UnknownValueMessage("opacity", "transition-property") ||
@@ -400,7 +404,7 @@ func TestAnalyzeUnknownValue_TransitionPropertyProp(
 func TestAnalyzeUnknownValue_WillChange(t *testing.T) {
 	src := []byte(`body { will-change: opacity, transform; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == UnknownValueMessage("opacity", "will-change") ||
@@ -420,7 +424,7 @@ func TestAnalyzeUnknownValue_TransitionFakeProperty(
 		`body { transition: fake-not-a-property 0.15s; }`,
 	)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -438,7 +442,7 @@ func TestAnalyzeUnknownValue_TransitionFakeProperty(
 func TestAnalyzeUnknownValue_AnimationName(t *testing.T) {
 	src := []byte(`body { animation-name: myAnimation; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == UnknownValueMessage("myAnimation", "animation-name") {
@@ -452,7 +456,7 @@ func TestAnalyzeUnknownValue_Animation(t *testing.T) {
 		`body { animation: mySlide 0.3s ease-in; }`,
 	)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == UnknownValueMessage("mySlide", "animation") {
@@ -464,7 +468,7 @@ func TestAnalyzeUnknownValue_Animation(t *testing.T) {
 func TestAnalyzeUnknownValue_OutlineNone(t *testing.T) {
 	src := []byte(`body { outline: none; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -477,7 +481,7 @@ func TestAnalyzeUnknownValue_OutlineNone(t *testing.T) {
 func TestAnalyzeUnknownValue_PointerEventsAuto(t *testing.T) {
 	src := []byte(`body { pointer-events: auto; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -490,7 +494,7 @@ func TestAnalyzeUnknownValue_PointerEventsAuto(t *testing.T) {
 func TestAnalyzeUnknownValue_WhiteSpaceInvalid(t *testing.T) {
 	src := []byte(`body { white-space: banana; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -503,7 +507,7 @@ func TestAnalyzeUnknownValue_WhiteSpaceInvalid(t *testing.T) {
 func TestAnalyzeUnknownValue_WhiteSpaceNowrap(t *testing.T) {
 	src := []byte(`body { white-space: nowrap; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -516,7 +520,7 @@ func TestAnalyzeUnknownValue_WhiteSpaceNowrap(t *testing.T) {
 func TestAnalyzeUnknownValue_GridAreaIdent(t *testing.T) {
 	src := []byte(`body { grid-area: header; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -531,7 +535,7 @@ func TestAnalyzeUnknownValue_FontFamily(t *testing.T) {
 		`body { font-family: system-ui, -apple-system, Roboto, sans-serif; }`,
 	)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, d := range diags {
 		if d.Message == UnknownValueMessage("system-ui", "font-family") ||
@@ -545,7 +549,7 @@ func TestAnalyzeUnknownValue_FontFamily(t *testing.T) {
 func TestAnalyzeUnknownValue_FontFamilyGeneric(t *testing.T) {
 	src := []byte(`body { font-family: monospace; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -560,7 +564,7 @@ func TestAnalyzeUnknownValue_BackgroundShorthand(t *testing.T) {
 		`.x { background: url(/img.svg) no-repeat 0.5rem center / 1rem; }`,
 	)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	for _, val := range []string{"no-repeat", "center"} {
 		if _, ok := findDiagnostic(
@@ -580,7 +584,7 @@ func TestAnalyzeUnknownValue_BackgroundShorthandInvalid(
 ) {
 	src := []byte(`.x { background: banana; }`)
 	ss := parseCSS(t, src)
-	diags := Analyze(ss, src, LintOptions{})
+	diags := Analyze(ss, src, LintOptions{}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags,
@@ -597,7 +601,7 @@ func TestAnalyzeDeprecatedProperty_Warn(t *testing.T) {
 	ss := parseCSS(t, src)
 	diags := Analyze(ss, src, LintOptions{
 		Deprecated: DeprecatedWarn,
-	})
+	}, Indexes{})
 
 	d, ok := findDiagnostic(
 		diags, DeprecatedPropertyMessage("clip"),
@@ -615,7 +619,7 @@ func TestAnalyzeDeprecatedProperty_Ignore(t *testing.T) {
 	ss := parseCSS(t, src)
 	diags := Analyze(ss, src, LintOptions{
 		Deprecated: DeprecatedIgnore,
-	})
+	}, Indexes{})
 
 	if _, ok := findDiagnostic(
 		diags, DeprecatedPropertyMessage("clip"),
@@ -629,7 +633,7 @@ func TestAnalyzeDeprecatedProperty_Error(t *testing.T) {
 	ss := parseCSS(t, src)
 	diags := Analyze(ss, src, LintOptions{
 		Deprecated: DeprecatedError,
-	})
+	}, Indexes{})
 
 	d, ok := findDiagnostic(
 		diags, DeprecatedPropertyMessage("clip"),
@@ -641,3 +645,179 @@ func TestAnalyzeDeprecatedProperty_Error(t *testing.T) {
 		t.Errorf("expected error severity, got %d", d.Severity)
 	}
 }
+
+// mapVariableIndex is a VariableIndex backed by a set of names.
+type mapVariableIndex map[string]bool
+
+func (m mapVariableIndex) HasVariable(name string) bool {
+	return m[name]
+}
+
+// warnUndefined opts in to undefined variable diagnostics.
+var warnUndefined = LintOptions{UndefinedVariables: UndefinedVariableWarn}
+
+func TestAnalyzeUndefinedVariable(t *testing.T) {
+	src := []byte(`a { color: var(--missing); }`)
+	ss := parseCSS(t, src)
+	diags := Analyze(ss, src, warnUndefined, Indexes{Variables: mapVariableIndex{}})
+
+	d, ok := findDiagnostic(
+		diags, UndefinedVariableMessage("--missing"),
+	)
+	if !ok {
+		t.Fatal("expected undefined variable diagnostic")
+	}
+	if d.Severity != SeverityWarning {
+		t.Errorf("expected warning severity, got %d", d.Severity)
+	}
+	if start := indexOf(src, "--missing"); d.StartChar != start ||
+		d.EndChar != start+len("--missing") {
+		t.Errorf("unexpected range %d-%d", d.StartChar, d.EndChar)
+	}
+}
+
+func TestAnalyzeUndefinedVariable_Defined(t *testing.T) {
+	src := []byte(`:root { --local: red; }
+a { color: var(--local); background: var(--shared); }`)
+	ss := parseCSS(t, src)
+	diags := Analyze(
+		ss, src, warnUndefined, Indexes{Variables: mapVariableIndex{"--shared": true}},
+	)
+
+	for _, name := range []string{"--local", "--shared"} {
+		if _, ok := findDiagnostic(
+			diags, UndefinedVariableMessage(name),
+		); ok {
+			t.Errorf("%s should not be flagged as undefined", name)
+		}
+	}
+}
+
+func TestAnalyzeUndefinedVariable_Fallback(t *testing.T) {
+	src := []byte(`a { color: var(--missing , var(--also-missing)); }`)
+	ss := parseCSS(t, src)
+	diags := Analyze(ss, src, warnUndefined, Indexes{Variables: mapVariableIndex{}})
+
+	if _, ok := findDiagnostic(
+		diags, UndefinedVariableMessage("--missing"),
+	); ok {
+		t.Error("var() with a fallback should not be flagged")
+	}
+	if _, ok := findDiagnostic(
+		diags, UndefinedVariableMessage("--also-missing"),
+	); !ok {
+		t.Error("expected nested var() without fallback to be flagged")
+	}
+}
+
+func TestAnalyzeUndefinedVariable_InCustomProperty(t *testing.T) {
+	src := []byte(`:root { --alias: var(--missing); }`)
+	ss := parseCSS(t, src)
+	diags := Analyze(ss, src, warnUndefined, Indexes{Variables: mapVariableIndex{}})
+
+	if _, ok := findDiagnostic(
+		diags, UndefinedVariableMessage("--missing"),
+	); !ok {
+		t.Error("expected undefined variable in custom property value")
+	}
+}
+
+func TestAnalyzeUndefinedVariable_Modes(t *testing.T) {
+	src := []byte(`a { color: var(--missing); }`)
+	ss := parseCSS(t, src)
+
+	diags := Analyze(ss, src, LintOptions{}, Indexes{Variables: mapVariableIndex{}})
+	if _, ok := findDiagnostic(
+		diags, UndefinedVariableMessage("--missing"),
+	); ok {
+		t.Error("undefined variables should be ignored by default")
+	}
+
+	diags = Analyze(ss, src, LintOptions{
+		UndefinedVariables: UndefinedVariableError,
+	}, Indexes{Variables: mapVariableIndex{}})
+	d, ok := findDiagnostic(
+		diags, UndefinedVariableMessage("--missing"),
+	)
+	if !ok || d.Severity != SeverityError {
+		t.Error("expected undefined variable error")
+	}
+}
+
+func TestAnalyzeUndefinedVariable_NoIndex(t *testing.T) {
+	src := []byte(`a { color: var(--missing); }`)
+	ss := parseCSS(t, src)
+	diags := Analyze(ss, src, warnUndefined, Indexes{})
+
+	if _, ok := findDiagnostic(
+		diags, UndefinedVariableMessage("--missing"),
+	); ok {
+		t.Error("undefined variables need a workspace index")
+	}
+}
+
+// markupIndex is a MarkupIndex backed by sets of names.
+type markupIndex struct {
+	classes, ids []string
+}
+
+func (m markupIndex) HasMarkup() bool {
+	return len(m.classes)+len(m.ids) > 0
+}
+
+func (m markupIndex) HasClass(name string) bool {
+	return slices.Contains(m.classes, name)
+}
+
+func (m markupIndex) HasID(name string) bool {
+	return slices.Contains(m.ids, name)
+}
+
+func (m markupIndex) Classes() []string { return m.classes }
+
+func (m markupIndex) IDs() []string { return m.ids }
+
+func TestAnalyzeUnusedSelectors(t *testing.T) {
+	src := []byte(`.card, #main > .stale { color: red; }
+a.card:not(.gone) { color: blue; }
+#gone { color: green; }
+@media (min-width: 1.5em) { .card { color: red; } }`)
+	ss := parseCSS(t, src)
+	idx := markupIndex{classes: []string{"card"}, ids: []string{"main"}}
+
+	diags := Analyze(ss, src, LintOptions{}, Indexes{Markup: idx})
+	if _, ok := findDiagnostic(diags, UnusedClassMessage("stale")); ok {
+		t.Error("unused selectors are opt-in")
+	}
+
+	diags = Analyze(ss, src, LintOptions{
+		UnusedSelectors: UnusedSelectorWarn,
+	}, Indexes{Markup: idx})
+	var got []string
+	for _, d := range diags {
+		if strings.Contains(d.Message, "not used in any markup") {
+			got = append(got, d.Message)
+		}
+	}
+	want := []string{
+		UnusedClassMessage("stale"),
+		UnusedClassMessage("gone"),
+		UnusedIDMessage("gone"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diagnostics = %q, want %q", got, want)
+	}
+	d, _ := findDiagnostic(diags, UnusedClassMessage("stale"))
+	if start := indexOf(src, ".stale"); d.StartChar != start ||
+		d.EndChar != start+len(".stale") || d.Severity != SeverityWarning {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+
+	// Without any markup, nothing can be called unused.
+	diags = Analyze(ss, src, LintOptions{
+		UnusedSelectors: UnusedSelectorError,
+	}, Indexes{Markup: markupIndex{}})
+	if _, ok := findDiagnostic(diags, UnusedClassMessage("stale")); ok {
+		t.Error("no markup is indexed")
+	}
+}
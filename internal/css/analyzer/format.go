@@ -33,6 +33,43 @@ type FormatOptions struct {
 	InsertSpaces bool
 	Mode         FormatMode
 	PrintWidth   int
+	// Order sorts the declarations of each block.
+	Order DeclarationOrder
+	// Values normalizes how declaration values are written.
+	Values ValueOptions
+	// Selectors lays out and normalizes selectors.
+	Selectors SelectorOptions
+	// EndOfLine selects the line breaks written.
+	EndOfLine EndOfLine
+	// OmitFinalNewline ends a formatted document without a line
+	// break.
+	OmitFinalNewline bool
+	// TrimTrailingWhitespace removes the spaces and tabs ending
+	// lines of comments and values copied as written.
+	TrimTrailingWhitespace bool
+}
+
+// EndOfLine selects the line break the formatter writes.
+type EndOfLine int
+
+const (
+	// EndOfLineLF writes "\n" (default).
+	EndOfLineLF EndOfLine = iota
+	// EndOfLineCRLF writes "\r\n".
+	EndOfLineCRLF
+	// EndOfLineCR writes "\r".
+	EndOfLineCR
+)
+
+// Break returns the line break.
+func (e EndOfLine) Break() string {
+	switch e {
+	case EndOfLineCRLF:
+		return "\r\n"
+	case EndOfLineCR:
+		return "\r"
+	}
+	return "\n"
 }
 
 // Format formats the CSS document and returns the formatted
@@ -60,7 +97,37 @@ func Format(
 	}
 
 	f.formatStylesheet(ss)
-	return f.buf.String()
+	text := f.buf.String()
+	if opts.OmitFinalNewline {
+		text = strings.TrimRight(text, "\r\n")
+	}
+	return finishLines(text, opts)
+}
+
+// finishLines applies the line options to formatted text, written
+// with "\n" line breaks. Line breaks copied from the source in
+// comments are converted as well when another break is asked for.
+func finishLines(text string, opts FormatOptions) string {
+	if opts.TrimTrailingWhitespace {
+		var b strings.Builder
+		for line := range strings.Lines(text) {
+			body, nl := strings.CutSuffix(line, "\n")
+			body, cr := strings.CutSuffix(body, "\r")
+			b.WriteString(strings.TrimRight(body, " \t"))
+			if cr {
+				b.WriteByte('\r')
+			}
+			if nl {
+				b.WriteByte('\n')
+			}
+		}
+		text = b.String()
+	}
+	if opts.EndOfLine != EndOfLineLF {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		text = strings.ReplaceAll(text, "\n", opts.EndOfLine.Break())
+	}
+	return text
 }
 
 type formatter struct {
@@ -68,6 +135,10 @@ type formatter struct {
 	buf    strings.Builder
 	indent int
 	opts   FormatOptions
+	// values caches the normalized declaration values, and texts
+	// holds the text the tokens of each of them index.
+	values map[*parser.Value]*parser.Value
+	texts  map[*parser.Value][]byte
 }
 
 func (f *formatter) writeIndent() {
@@ -83,14 +154,28 @@ func (f *formatter) writeIndent() {
 }
 
 func (f *formatter) formatStylesheet(ss *parser.Stylesheet) {
-	for i, child := range ss.Children {
+	f.formatTopLevel(ss.Children)
+
+	// Ensure trailing newline
+	if f.buf.Len() > 0 {
+		s := f.buf.String()
+		if s[len(s)-1] != '\n' {
+			f.buf.WriteByte('\n')
+		}
+	}
+}
+
+// formatTopLevel writes the rules of a stylesheet, separated as
+// the mode asks.
+func (f *formatter) formatTopLevel(children []parser.Node) {
+	for i, child := range children {
 		if i > 0 {
 			switch f.opts.Mode {
 			case FormatCompact:
 				// No blank lines between rules.
 			case FormatPreserve, FormatDetect:
 				f.writePreservedBlankLines(
-					ss.Children[i-1].End(),
+					children[i-1].End(),
 					child.Offset(),
 				)
 			default:
@@ -105,7 +190,7 @@ func (f *formatter) formatStylesheet(ss *parser.Stylesheet) {
 			f.formatAtRule(n)
 		case *parser.Comment:
 			if i > 0 && f.isInlineComment(
-				ss.Children[i-1].End(), n.StartPos,
+				children[i-1].End(), n.StartPos,
 			) {
 				f.formatInlineComment(n)
 				continue
@@ -113,14 +198,6 @@ func (f *formatter) formatStylesheet(ss *parser.Stylesheet) {
 			f.formatComment(n)
 		}
 	}
-
-	// Ensure trailing newline
-	if f.buf.Len() > 0 {
-		s := f.buf.String()
-		if s[len(s)-1] != '\n' {
-			f.buf.WriteByte('\n')
-		}
-	}
 }
 
 // dispatchRuleset formats a ruleset using the configured mode.
@@ -152,41 +229,67 @@ func (f *formatter) formatRuleset(rs *parser.Ruleset) {
 func (f *formatter) formatRulesetBody(rs *parser.Ruleset) {
 	f.buf.WriteString(" {\n")
 	f.indent++
+	f.formatRulesetChildren(rs.Children)
+	f.indent--
+	f.writeIndent()
+	f.buf.WriteString("}\n")
+}
 
-	for i, child := range rs.Children {
+// formatRulesetChildren writes the declarations, nested rules and
+// comments of a ruleset's block. Where blank lines are kept,
+// declarations are sorted within the groups they separate and a
+// blank line is written before each group; otherwise blank lines
+// among sorted declarations are dropped.
+func (f *formatter) formatRulesetChildren(children []parser.Node) {
+	keep := f.opts.Mode == FormatPreserve || f.opts.Mode == FormatDetect
+	children, breaks, sorted := orderChildren(
+		f.src, children, f.opts.Order, keep,
+	)
+	for i, child := range children {
 		switch n := child.(type) {
 		case *parser.Declaration:
-			if i > 0 && (f.opts.Mode == FormatPreserve || f.opts.Mode == FormatDetect) {
+			switch {
+			case i == 0 || !keep:
+				// No blank lines.
+			case sorted:
+				if breaks[n] {
+					f.buf.WriteByte('\n')
+				}
+			default:
 				f.writePreservedBlankLines(
-					rs.Children[i-1].End(),
+					children[i-1].End(),
 					n.Offset(),
 				)
 			}
 			f.formatDeclaration(n)
 		case *parser.Ruleset:
 			if i > 0 {
-				f.writeNestedBlankLine(rs.Children, i)
+				f.writeNestedBlankLine(children, i)
 			}
 			f.dispatchRuleset(n)
 		case *parser.AtRule:
 			if i > 0 {
-				f.writeNestedBlankLine(rs.Children, i)
+				f.writeNestedBlankLine(children, i)
 			}
 			f.formatAtRule(n)
 		case *parser.Comment:
 			if i > 0 && f.isInlineComment(
-				rs.Children[i-1].End(), n.StartPos,
+				children[i-1].End(), n.StartPos,
 			) {
 				f.formatInlineComment(n)
 				continue
 			}
 			if i > 0 {
-				switch f.opts.Mode {
-				case FormatCompact:
+				switch {
+				case f.opts.Mode == FormatCompact:
 					// No blank lines.
+				case sorted:
+					if breaks[n] {
+						f.buf.WriteByte('\n')
+					}
 				default:
 					f.writePreservedBlankLines(
-						rs.Children[i-1].End(),
+						children[i-1].End(),
 						n.Offset(),
 					)
 				}
@@ -194,10 +297,6 @@ func (f *formatter) formatRulesetBody(rs *parser.Ruleset) {
 			f.formatComment(n)
 		}
 	}
-
-	f.indent--
-	f.writeIndent()
-	f.buf.WriteString("}\n")
 }
 
 // hasNestedRules reports whether a ruleset contains any nested
@@ -212,27 +311,14 @@ func hasNestedRules(rs *parser.Ruleset) bool {
 	return false
 }
 
-func (f *formatter) writeSelector(sl *parser.SelectorList) {
-	for i, sel := range sl.Selectors {
-		if i > 0 {
-			f.buf.WriteString(",\n")
-			f.writeIndent()
-		}
-		f.writeSingleSelector(sel)
-	}
-}
-
-func (f *formatter) writeSingleSelector(sel *parser.Selector) {
-	f.writeSingleSelectorTo(&f.buf, sel)
-}
-
 func (f *formatter) formatDeclaration(
 	decl *parser.Declaration,
 ) {
-	if decl.Value != nil {
+	value := f.value(decl)
+	if value != nil {
 		// Measure the single-line length.
 		var tmp strings.Builder
-		f.writeValueTo(&tmp, decl.Value)
+		f.writeValueTo(&tmp, value)
 		valStr := tmp.String()
 
 		lineLen := f.indentWidth() + len(decl.Property.Value) + 2 + len(valStr) + 1
@@ -240,17 +326,17 @@ func (f *formatter) formatDeclaration(
 			lineLen += len(" !important")
 		}
 
-		commaIndices, commaDepth := f.shallowestCommaIndices(decl.Value)
+		commaIndices, commaDepth := f.shallowestCommaIndices(value)
 
 		if lineLen > f.opts.PrintWidth && len(commaIndices) > 0 {
 			f.writeIndent()
 			f.buf.WriteString(decl.Property.Value)
 			if commaDepth > 0 {
 				f.buf.WriteString(": ")
-				f.writeValueMultiLine(decl.Value, commaIndices, commaDepth)
+				f.writeValueMultiLine(value, commaIndices, commaDepth)
 			} else {
 				f.buf.WriteString(":\n")
-				f.writeValueMultiLine(decl.Value, commaIndices, 0)
+				f.writeValueMultiLine(value, commaIndices, 0)
 			}
 			if decl.Important {
 				f.buf.WriteString(" !important")
@@ -264,8 +350,8 @@ func (f *formatter) formatDeclaration(
 	f.buf.WriteString(decl.Property.Value)
 	f.buf.WriteString(": ")
 
-	if decl.Value != nil {
-		f.writeValue(decl.Value)
+	if value != nil {
+		f.writeValue(value)
 	}
 
 	if decl.Important {
@@ -324,6 +410,7 @@ func (f *formatter) writeValueMultiLine(
 	commaIndices []int,
 	commaDepth int,
 ) {
+	src := f.valueSrc(v)
 	commaSet := make(map[int]bool, len(commaIndices))
 	for _, idx := range commaIndices {
 		commaSet[idx] = true
@@ -343,7 +430,7 @@ func (f *formatter) writeValueMultiLine(
 			if tok.Kind == scanner.Whitespace {
 				continue
 			}
-			slash := f.isSlashDelim(tok)
+			slash := isSlashDelim(src, tok)
 			needSpace := false
 			if prevEnd >= 0 {
 				if tok.Offset > prevEnd {
@@ -361,7 +448,7 @@ func (f *formatter) writeValueMultiLine(
 				f.buf.WriteByte(' ')
 			}
 			f.buf.WriteString(
-				string(f.src[tok.Offset:tok.End]),
+				string(src[tok.Offset:tok.End]),
 			)
 			prevEnd = tok.End
 			prevKind = tok.Kind
@@ -400,7 +487,7 @@ func (f *formatter) writeValueMultiLine(
 				afterBreak = true
 				continue
 			}
-			slash := f.isSlashDelim(tok)
+			slash := isSlashDelim(src, tok)
 			needSpace := false
 			if !afterBreak && prevEnd >= 0 {
 				if tok.Offset > prevEnd {
@@ -419,7 +506,7 @@ func (f *formatter) writeValueMultiLine(
 			}
 			afterBreak = false
 			f.buf.WriteString(
-				string(f.src[tok.Offset:tok.End]),
+				string(src[tok.Offset:tok.End]),
 			)
 			prevEnd = tok.End
 			prevKind = tok.Kind
@@ -446,7 +533,7 @@ func (f *formatter) writeValueMultiLine(
 				afterBreak = true
 				continue
 			}
-			slash := f.isSlashDelim(tok)
+			slash := isSlashDelim(src, tok)
 			needSpace := false
 			if !afterBreak && prevEnd >= 0 {
 				if tok.Offset > prevEnd {
@@ -465,7 +552,7 @@ func (f *formatter) writeValueMultiLine(
 			}
 			afterBreak = false
 			f.buf.WriteString(
-				string(f.src[tok.Offset:tok.End]),
+				string(src[tok.Offset:tok.End]),
 			)
 			prevEnd = tok.End
 			prevKind = tok.Kind
@@ -525,22 +612,62 @@ func (f *formatter) writeAtRulePreludeTo(
 	sb.WriteString(ar.Name)
 	if len(ar.Prelude) > 0 {
 		sb.WriteByte(' ')
+		scope := strings.EqualFold(ar.Name, "scope")
 		prevEnd := -1
-		for _, tok := range ar.Prelude {
+		for i := 0; i < len(ar.Prelude); i++ {
+			tok := ar.Prelude[i]
 			if tok.Kind == scanner.Whitespace {
 				continue
 			}
 			if prevEnd >= 0 && tok.Offset > prevEnd {
 				sb.WriteByte(' ')
 			}
-			sb.WriteString(
-				string(f.src[tok.Offset:tok.End]),
-			)
+			j := -1
+			if scope {
+				j = closingParen(ar.Prelude, i)
+			}
+			if j > i {
+				// The scope root and limit are selector lists.
+				sb.WriteByte('(')
+				for k, sel := range f.selectorsIn(tok.End, ar.Prelude[j].Offset) {
+					if k > 0 {
+						sb.WriteString(", ")
+					}
+					sb.WriteString(strings.Join(sel, " "))
+				}
+				sb.WriteByte(')')
+				i, tok = j, ar.Prelude[j]
+			} else {
+				sb.WriteString(
+					string(f.src[tok.Offset:tok.End]),
+				)
+			}
 			prevEnd = tok.End
 		}
 	}
 }
 
+// closingParen returns the index of the parenthesis closing the
+// one at toks[i], or -1 if toks[i] isn't one or isn't closed.
+func closingParen(toks []scanner.Token, i int) int {
+	if toks[i].Kind != scanner.ParenOpen {
+		return -1
+	}
+	depth := 0
+	for j := i; j < len(toks); j++ {
+		switch toks[j].Kind {
+		case scanner.ParenOpen, scanner.Function:
+			depth++
+		case scanner.ParenClose:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
 // buildAtRuleSingleLine renders an at-rule as a single-line
 // string: "@media (...) { decl; decl; }".
 func (f *formatter) buildAtRuleSingleLine(
@@ -549,20 +676,14 @@ func (f *formatter) buildAtRuleSingleLine(
 	var sb strings.Builder
 	f.writeAtRulePreludeTo(&sb, ar)
 	sb.WriteString(" { ")
-	first := true
-	for _, child := range ar.Block.Children {
-		decl, ok := child.(*parser.Declaration)
-		if !ok {
-			continue
-		}
-		if !first {
+	for i, decl := range f.orderedDeclarations(ar.Block.Children) {
+		if i > 0 {
 			sb.WriteByte(' ')
 		}
-		first = false
 		sb.WriteString(decl.Property.Value)
 		sb.WriteString(": ")
-		if decl.Value != nil {
-			f.writeValueTo(&sb, decl.Value)
+		if v := f.value(decl); v != nil {
+			f.writeValueTo(&sb, v)
 		}
 		if decl.Important {
 			sb.WriteString(" !important")
@@ -651,56 +772,12 @@ func (f *formatter) isAtRuleFirstChildInline(
 
 func (f *formatter) formatAtRuleExpanded(ar *parser.AtRule) {
 	f.writeIndent()
-	f.buf.WriteByte('@')
-	f.buf.WriteString(ar.Name)
-
-	if len(ar.Prelude) > 0 {
-		f.buf.WriteByte(' ')
-		prevEnd := -1
-		for _, tok := range ar.Prelude {
-			if tok.Kind == scanner.Whitespace {
-				continue
-			}
-			// If there was whitespace between tokens, add a
-			// space
-			if prevEnd >= 0 && tok.Offset > prevEnd {
-				f.buf.WriteByte(' ')
-			}
-			f.buf.WriteString(
-				string(f.src[tok.Offset:tok.End]),
-			)
-			prevEnd = tok.End
-		}
-	}
+	f.writeAtRulePreludeTo(&f.buf, ar)
 
 	if ar.Block != nil {
 		f.buf.WriteString(" {\n")
 		f.indent++
-		for i, child := range ar.Block.Children {
-			if i > 0 {
-				if _, isDecl := child.(*parser.Declaration); !isDecl {
-					f.writeNestedBlankLine(
-						ar.Block.Children, i,
-					)
-				}
-			}
-			switch n := child.(type) {
-			case *parser.Declaration:
-				f.formatDeclaration(n)
-			case *parser.Ruleset:
-				f.dispatchRuleset(n)
-			case *parser.AtRule:
-				f.formatAtRule(n)
-			case *parser.Comment:
-				if i > 0 && f.isInlineComment(
-					ar.Block.Children[i-1].End(), n.StartPos,
-				) {
-					f.formatInlineComment(n)
-					continue
-				}
-				f.formatComment(n)
-			}
-		}
+		f.formatAtRuleChildren(ar.Block.Children)
 		f.indent--
 		f.writeIndent()
 		f.buf.WriteString("}\n")
@@ -709,6 +786,34 @@ func (f *formatter) formatAtRuleExpanded(ar *parser.AtRule) {
 	}
 }
 
+// formatAtRuleChildren writes the children of an at-rule's block.
+func (f *formatter) formatAtRuleChildren(children []parser.Node) {
+	children, _, _ = orderChildren(f.src, children, f.opts.Order, false)
+	for i, child := range children {
+		if i > 0 {
+			if _, isDecl := child.(*parser.Declaration); !isDecl {
+				f.writeNestedBlankLine(children, i)
+			}
+		}
+		switch n := child.(type) {
+		case *parser.Declaration:
+			f.formatDeclaration(n)
+		case *parser.Ruleset:
+			f.dispatchRuleset(n)
+		case *parser.AtRule:
+			f.formatAtRule(n)
+		case *parser.Comment:
+			if i > 0 && f.isInlineComment(
+				children[i-1].End(), n.StartPos,
+			) {
+				f.formatInlineComment(n)
+				continue
+			}
+			f.formatComment(n)
+		}
+	}
+}
+
 func (f *formatter) formatComment(c *parser.Comment) {
 	f.writeIndent()
 	f.buf.WriteString(
@@ -770,7 +875,7 @@ func (f *formatter) buildSingleLine(
 		f.writeSelectorTo(&sb, rs.Selectors)
 	}
 
-	decls := rs.Declarations()
+	decls := f.orderedDeclarations(rs.Children)
 
 	if len(decls) == 0 && !hasNestedRules(rs) {
 		sb.WriteString(" {}")
@@ -784,8 +889,8 @@ func (f *formatter) buildSingleLine(
 		}
 		sb.WriteString(decl.Property.Value)
 		sb.WriteString(": ")
-		if decl.Value != nil {
-			f.writeValueTo(&sb, decl.Value)
+		if v := f.value(decl); v != nil {
+			f.writeValueTo(&sb, v)
 		}
 		if decl.Important {
 			sb.WriteString(" !important")
@@ -796,48 +901,25 @@ func (f *formatter) buildSingleLine(
 	return sb.String()
 }
 
-// writeSelectorTo writes the selector list to a builder,
-// separating multiple selectors with ", ".
-func (f *formatter) writeSelectorTo(
-	sb *strings.Builder,
-	sl *parser.SelectorList,
-) {
-	for i, sel := range sl.Selectors {
-		if i > 0 {
-			sb.WriteString(", ")
+// orderedDeclarations returns the declarations among children,
+// sorted if the options ask for it.
+func (f *formatter) orderedDeclarations(
+	children []parser.Node,
+) []*parser.Declaration {
+	children, _, _ = orderChildren(f.src, children, f.opts.Order, false)
+	var decls []*parser.Declaration
+	for _, child := range children {
+		if decl, ok := child.(*parser.Declaration); ok {
+			decls = append(decls, decl)
 		}
-		f.writeSingleSelectorTo(sb, sel)
 	}
+	return decls
 }
 
-// writeSingleSelectorTo writes a single selector to a builder.
-func (f *formatter) writeSingleSelectorTo(
-	sb *strings.Builder,
-	sel *parser.Selector,
-) {
-	for i, part := range sel.Parts {
-		if part.Combinator != "" && part.Combinator != " " {
-			if i > 0 {
-				sb.WriteByte(' ')
-			}
-			sb.WriteString(part.Combinator)
-			sb.WriteByte(' ')
-		} else if i > 0 && part.Combinator == " " {
-			sb.WriteByte(' ')
-		}
-
-		if part.Token.Kind != scanner.EOF {
-			sb.WriteString(
-				string(f.src[part.Token.Offset:part.Token.End]),
-			)
-		}
-	}
-}
-
-// isSlashDelim reports whether tok is a "/" delimiter.
-func (f *formatter) isSlashDelim(tok scanner.Token) bool {
-	return tok.Kind == scanner.Delim && tok.Offset < len(f.src) &&
-		f.src[tok.Offset] == '/'
+// isSlashDelim reports whether tok, of src, is a "/" delimiter.
+func isSlashDelim(src []byte, tok scanner.Token) bool {
+	return tok.Kind == scanner.Delim && tok.Offset < len(src) &&
+		src[tok.Offset] == '/'
 }
 
 // writeValueTo writes a value to a string builder.
@@ -845,6 +927,7 @@ func (f *formatter) writeValueTo(
 	sb *strings.Builder,
 	v *parser.Value,
 ) {
+	src := f.valueSrc(v)
 	prevEnd := -1
 	prevKind := scanner.EOF
 	prevSlash := false
@@ -852,7 +935,7 @@ func (f *formatter) writeValueTo(
 		if tok.Kind == scanner.Whitespace {
 			continue
 		}
-		slash := f.isSlashDelim(tok)
+		slash := isSlashDelim(src, tok)
 		needSpace := false
 		if prevEnd >= 0 {
 			if tok.Offset > prevEnd {
@@ -870,7 +953,7 @@ func (f *formatter) writeValueTo(
 			sb.WriteByte(' ')
 		}
 		sb.WriteString(
-			string(f.src[tok.Offset:tok.End]),
+			string(src[tok.Offset:tok.End]),
 		)
 		prevEnd = tok.End
 		prevKind = tok.Kind
@@ -900,19 +983,30 @@ func (f *formatter) formatRulesetCompact(
 		return
 	}
 
-	line := f.buildSingleLine(rs)
-	totalWidth := f.indentWidth() + len(line)
-
-	if totalWidth <= f.opts.PrintWidth {
-		f.writeIndent()
-		f.buf.WriteString(line)
-		f.buf.WriteByte('\n')
+	if f.writeIfFits(f.buildSingleLine(rs)) {
 		return
 	}
 
 	f.formatRuleset(rs)
 }
 
+// writeIfFits writes a ruleset rendered by buildSingleLine if
+// each of its lines fits within PrintWidth, and reports whether
+// it did. Only selectors put on lines of their own make more than
+// one line.
+func (f *formatter) writeIfFits(line string) bool {
+	for _, l := range strings.Split(line, "\n") {
+		l = strings.TrimLeft(l, " \t")
+		if f.indentWidth()+len(l) > f.opts.PrintWidth {
+			return false
+		}
+	}
+	f.writeIndent()
+	f.buf.WriteString(line)
+	f.buf.WriteByte('\n')
+	return true
+}
+
 // isOriginalSingleLine checks whether the source between
 // startPos and endPos contains no newline characters.
 func (f *formatter) isOriginalSingleLine(
@@ -924,6 +1018,9 @@ func (f *formatter) isOriginalSingleLine(
 	if endPos > len(f.src) {
 		endPos = len(f.src)
 	}
+	if startPos > endPos {
+		return false
+	}
 	return !bytes.ContainsRune(
 		f.src[startPos:endPos], '\n',
 	)
@@ -931,18 +1028,19 @@ func (f *formatter) isOriginalSingleLine(
 
 // formatRulesetPreserve keeps the original single-line vs
 // multi-line structure, normalizing whitespace only. Adds a
-// print-width gate so overly long single-line rules expand.
+// print-width gate so overly long single-line rules expand. With
+// one selector per line, only the block decides, since the line
+// breaks between selectors are the formatter's own.
 func (f *formatter) formatRulesetPreserve(
 	rs *parser.Ruleset,
 ) {
-	if !hasNestedRules(rs) &&
-		f.isOriginalSingleLine(rs.StartPos, rs.EndPos) {
-		line := f.buildSingleLine(rs)
-		totalWidth := f.indentWidth() + len(line)
-		if totalWidth <= f.opts.PrintWidth {
-			f.writeIndent()
-			f.buf.WriteString(line)
-			f.buf.WriteByte('\n')
+	start := rs.StartPos
+	if f.opts.Selectors.OnePerLine && rs.Selectors != nil &&
+		rs.Selectors.EndPos > start {
+		start = rs.Selectors.EndPos
+	}
+	if !hasNestedRules(rs) && f.isOriginalSingleLine(start, rs.EndPos) {
+		if f.writeIfFits(f.buildSingleLine(rs)) {
 			return
 		}
 	}
@@ -1004,12 +1102,7 @@ func (f *formatter) formatRulesetDetect(
 	rs *parser.Ruleset,
 ) {
 	if !hasNestedRules(rs) && f.isFirstChildInline(rs) {
-		line := f.buildSingleLine(rs)
-		totalWidth := f.indentWidth() + len(line)
-		if totalWidth <= f.opts.PrintWidth {
-			f.writeIndent()
-			f.buf.WriteString(line)
-			f.buf.WriteByte('\n')
+		if f.writeIfFits(f.buildSingleLine(rs)) {
 			return
 		}
 	}
@@ -1017,7 +1110,7 @@ func (f *formatter) formatRulesetDetect(
 	// Detect selector layout: if the second selector is on the
 	// same line as the first, keep selectors inline when they
 	// fit within PrintWidth.
-	if rs.Selectors != nil &&
+	if rs.Selectors != nil && !f.opts.Selectors.OnePerLine &&
 		len(rs.Selectors.Selectors) > 1 &&
 		f.isSecondSelectorInline(rs.Selectors) {
 		var sb strings.Builder
@@ -1041,7 +1134,7 @@ func (f *formatter) formatRulesetDetect(
 func (f *formatter) writePreservedBlankLines(
 	prevEnd, nextStart int,
 ) {
-	if prevEnd < 0 || nextStart > len(f.src) {
+	if prevEnd < 0 || prevEnd > nextStart || nextStart > len(f.src) {
 		return
 	}
 	gap := f.src[prevEnd:nextStart]
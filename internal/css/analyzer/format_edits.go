@@ -0,0 +1,179 @@
+package analyzer
+
+import (
+	"unicode/utf8"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+// Limits of the search for where source and formatted text agree
+// again after differing in more than whitespace.
+const (
+	// resyncWindow is how far, in bytes, either side may be
+	// skipped.
+	resyncWindow = 256
+	// resyncRun is how many non-space bytes agreeing after a skip
+	// of any size make it right.
+	resyncRun = 16
+)
+
+// FormatEdits formats the CSS document and returns the edits that
+// turn src into the formatted text, rather than one edit replacing
+// it all, so that editors keep cursors, folds and undo history.
+func FormatEdits(
+	ss *parser.Stylesheet,
+	src []byte,
+	opts FormatOptions,
+) []FormatEdit {
+	return DiffEdits(src, Format(ss, src, opts))
+}
+
+// DiffEdits returns small, ordered, non-overlapping edits that
+// turn src into text. Formatting mostly changes whitespace, so the
+// texts are walked side by side, and whitespace that differs is
+// replaced where it differs. Where other text differs, such as an
+// added semicolon, the nearest point where both sides agree again
+// is found and the text in between replaced; failing that, the
+// rest of src is.
+func DiffEdits(src []byte, text string) []FormatEdit {
+	var spans []diffSpan
+	add := func(start, end, from, to int) {
+		spans = appendDiffSpan(spans, src, text, diffSpan{start, end, from, to})
+	}
+
+	i, j := 0, 0
+	for i < len(src) || j < len(text) {
+		i2, j2 := i, j
+		for i2 < len(src) && isFormatSpace(src[i2]) {
+			i2++
+		}
+		for j2 < len(text) && isFormatSpace(text[j2]) {
+			j2++
+		}
+		add(i, i2, j, j2)
+		i, j = i2, j2
+
+		for i < len(src) && j < len(text) && src[i] == text[j] &&
+			!isFormatSpace(src[i]) {
+			i++
+			j++
+		}
+		srcText := i < len(src) && !isFormatSpace(src[i])
+		textText := j < len(text) && !isFormatSpace(text[j])
+		if !srcText && !textText {
+			continue
+		}
+		a, b, ok := resync(src[i:], text[j:])
+		if !ok {
+			add(i, len(src), j, len(text))
+			break
+		}
+		add(i, i+a, j, j+b)
+		i, j = i+a, j+b
+	}
+
+	edits := make([]FormatEdit, 0, len(spans))
+	for _, sp := range spans {
+		edits = append(edits, FormatEdit{
+			StartPos: sp.start,
+			EndPos:   sp.end,
+			NewText:  text[sp.from:sp.to],
+		})
+	}
+	return edits
+}
+
+// resync returns the fewest bytes a of src and b of text to skip
+// so that more non-space bytes agree after them than were skipped,
+// or resyncRun of them do, or the rest of both does.
+func resync(src []byte, text string) (a, b int, ok bool) {
+	maxA, maxB := min(len(src), resyncWindow), min(len(text), resyncWindow)
+	for s := 1; s <= maxA+maxB; s++ {
+		for a := max(0, s-maxB); a <= min(s, maxA); a++ {
+			b := s - a
+			if n, all := agreement(src[a:], text[b:]); all || n > s {
+				return a, b, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// agreement returns how many of the first resyncRun non-space
+// bytes of src and text agree, and whether that is all of them or
+// all there are. Sides that start one with space and one without
+// don't agree at all.
+func agreement(src []byte, text string) (int, bool) {
+	if len(src) > 0 && len(text) > 0 &&
+		(isFormatSpace(src[0]) != isFormatSpace(text[0])) {
+		return 0, false
+	}
+	i, j := 0, 0
+	for n := 0; n < resyncRun; n++ {
+		for i < len(src) && isFormatSpace(src[i]) {
+			i++
+		}
+		for j < len(text) && isFormatSpace(text[j]) {
+			j++
+		}
+		switch {
+		case i == len(src) || j == len(text):
+			return n, i == len(src) && j == len(text)
+		case src[i] != text[j]:
+			return n, false
+		}
+		i++
+		j++
+	}
+	return resyncRun, true
+}
+
+// diffSpan is a difference: src[start:end] becomes text[from:to].
+type diffSpan struct {
+	start, end int
+	from, to   int
+}
+
+// appendDiffSpan appends d to spans, less the bytes its two sides
+// have in common at either end but widened to whole characters,
+// merging it into the spans it then touches. Nothing is appended
+// if the two sides are equal.
+func appendDiffSpan(
+	spans []diffSpan,
+	src []byte,
+	text string,
+	d diffSpan,
+) []diffSpan {
+	for d.start < d.end && d.from < d.to && src[d.start] == text[d.from] {
+		d.start++
+		d.from++
+	}
+	for d.start < d.end && d.from < d.to && src[d.end-1] == text[d.to-1] {
+		d.end--
+		d.to--
+	}
+	if d.start == d.end && d.from == d.to {
+		return spans
+	}
+	// What lies outside a span is the same on both sides, so a
+	// span can grow over it on both at once.
+	for d.start > 0 && d.start < len(src) && !utf8.RuneStart(src[d.start]) {
+		d.start--
+		d.from--
+	}
+	for d.end < len(src) && !utf8.RuneStart(src[d.end]) {
+		d.end++
+		d.to++
+	}
+	for n := len(spans); n > 0 && spans[n-1].end >= d.start; n-- {
+		d.start, d.from = spans[n-1].start, spans[n-1].from
+		spans = spans[:n-1]
+	}
+	return append(spans, d)
+}
+
+// isFormatSpace reports whether b is whitespace the formatter may
+// add, remove or change.
+func isFormatSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
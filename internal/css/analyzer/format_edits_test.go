@@ -0,0 +1,145 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+// applyFormatEdits applies ordered, non-overlapping edits to src.
+func applyFormatEdits(t *testing.T, src []byte, edits []FormatEdit) string {
+	t.Helper()
+	var b strings.Builder
+	pos := 0
+	for _, e := range edits {
+		if e.StartPos < pos || e.EndPos < e.StartPos || e.EndPos > len(src) {
+			t.Fatalf("edit %+v out of order or range after %d", e, pos)
+		}
+		b.Write(src[pos:e.StartPos])
+		b.WriteString(e.NewText)
+		pos = e.EndPos
+	}
+	b.Write(src[pos:])
+	return b.String()
+}
+
+func TestFormatEdits(t *testing.T) {
+	sources := []string{
+		``,
+		`.a { color: red; }`,
+		".a {\n  color: red;\n}\n",
+		`.foo{color:red;background:blue;}`,
+		".a{color:red}\n.b{color:blue;margin:0}\n",
+		"@media (max-width: 768px){.foo{color:red;}}",
+		"/* head */\n.a  >  .b ,.c{ color : red !important }\n\n\n.d{}",
+		".a {\n\tcolor: red;\n\t&:hover { color: blue }\n}\n",
+		"@import 'a.css';\n@font-face{font-family:x;src:url(a.woff)}",
+		".a{grid-template-areas:\"a b\" \"c d\";font:12px/1.5 'Open Sans',serif}",
+		".a {\r\n  color: red;\r\n}\r\n",
+		".a { color: red; }\n.b{\n  color: blue}\n  .c { margin: 0 }",
+		".a{transition:opacity 1s ease-in-out,transform 1s ease-in-out," +
+			"visibility 0s linear 1s,color 2s}",
+		strings.Repeat(".x{color:red}", 200),
+	}
+	modes := []FormatMode{
+		FormatExpanded, FormatCompact, FormatPreserve, FormatDetect,
+	}
+	for _, src := range sources {
+		for _, mode := range modes {
+			for _, spaces := range []bool{true, false} {
+				opts := FormatOptions{TabSize: 2, InsertSpaces: spaces, Mode: mode}
+				ss, _ := parser.Parse([]byte(src))
+				want := Format(ss, []byte(src), opts)
+				edits := FormatEdits(ss, []byte(src), opts)
+				if got := applyFormatEdits(t, []byte(src), edits); got != want {
+					t.Errorf("mode %d, spaces %t, %q:\ngot:\n%s\nwant:\n%s",
+						mode, spaces, src, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFormatEdits_Minimal(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		edits []FormatEdit
+	}{
+		{
+			name: "formatted already",
+			src:  ".a {\n  color: red;\n}\n",
+		},
+		{
+			name: "indentation",
+			src:  ".a {\n    color: red;\n}\n",
+			edits: []FormatEdit{
+				{StartPos: 7, EndPos: 9, NewText: ""},
+			},
+		},
+		{
+			name: "spacing and semicolon",
+			src:  ".a {\n  color:red\n}\n",
+			edits: []FormatEdit{
+				{StartPos: 13, EndPos: 13, NewText: " "},
+				{StartPos: 16, EndPos: 16, NewText: ";"},
+			},
+		},
+		{
+			name: "one rule of many",
+			src: ".a {\n  color: red;\n}\n\n.b {\n  color:blue;\n}\n\n" +
+				".c {\n  color: green;\n}\n",
+			edits: []FormatEdit{
+				{StartPos: 35, EndPos: 35, NewText: " "},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss, _ := parser.Parse([]byte(tt.src))
+			edits := FormatEdits(ss, []byte(tt.src), FormatOptions{
+				TabSize:      2,
+				InsertSpaces: true,
+			})
+			if len(edits) != len(tt.edits) {
+				t.Fatalf("edits = %+v, want %+v", edits, tt.edits)
+			}
+			for i := range edits {
+				if edits[i] != tt.edits[i] {
+					t.Errorf("edit %d = %+v, want %+v", i, edits[i], tt.edits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDiffEdits(t *testing.T) {
+	tests := []struct{ src, text string }{
+		{"", ""},
+		{"", "a {}"},
+		{"a {}", ""},
+		{"abc", "xyz"},
+		{"a b c", "a  b  c"},
+		{"a;b;c", "a;\nb;\nc;\n"},
+		{"color: red", "color: blue"},
+		{"content: 'é'", "content: 'è'"},
+		{"a { content: '→' }", "a {\n  content: '↑';\n}"},
+		{strings.Repeat("x", 600), strings.Repeat("y", 600)},
+		{strings.Repeat("ab ", 300), strings.Repeat("ab\n", 299) + "ac"},
+	}
+	for _, tt := range tests {
+		edits := DiffEdits([]byte(tt.src), tt.text)
+		if got := applyFormatEdits(t, []byte(tt.src), edits); got != tt.text {
+			t.Errorf("DiffEdits(%q, %q) applied = %q", tt.src, tt.text, got)
+		}
+		for _, e := range edits {
+			if !utf8.ValidString(e.NewText) ||
+				!utf8.Valid([]byte(tt.src[e.StartPos:e.EndPos])) {
+				t.Errorf("DiffEdits(%q, %q) splits a character: %+v",
+					tt.src, tt.text, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package analyzer
+
+import (
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+// FormatEdit replaces the source between StartPos and EndPos with
+// formatted text.
+type FormatEdit struct {
+	StartPos int
+	EndPos   int
+	NewText  string
+}
+
+// FormatRange formats the rules that the source between start and
+// end intersects, indented for how deeply they are nested. When
+// the range lies within the nested rules of a single rule, only
+// those are formatted; otherwise the outermost rules it touches
+// are, whole. It returns false if there is nothing to format or
+// the rules are formatted already.
+func FormatRange(
+	ss *parser.Stylesheet,
+	src []byte,
+	start, end int,
+	opts FormatOptions,
+) (FormatEdit, bool) {
+	block, nodes, depth := rangeNodes(ss, start, end)
+	if len(nodes) == 0 {
+		return FormatEdit{}, false
+	}
+	return formatNodes(src, block, nodes, depth, opts)
+}
+
+// FormatOnType formats the rule that the character ch, "}" or
+// ";", just typed before offset ends or is in. Rules not yet
+// closed are left alone, as formatting would close them.
+func FormatOnType(
+	ss *parser.Stylesheet,
+	src []byte,
+	offset int,
+	ch string,
+	opts FormatOptions,
+) (FormatEdit, bool) {
+	if (ch != "}" && ch != ";") || offset < 1 || offset > len(src) ||
+		string(src[offset-1]) != ch {
+		return FormatEdit{}, false
+	}
+	block, nodes, depth := rangeNodes(ss, offset-1, offset)
+	if len(nodes) == 0 {
+		return FormatEdit{}, false
+	}
+	for _, n := range nodes {
+		if !closedNode(src, n) {
+			return FormatEdit{}, false
+		}
+	}
+	return formatNodes(src, block, nodes, depth, opts)
+}
+
+// rangeNodes returns the run of rules to format for the range
+// between start and end, the stylesheet or rule whose children
+// they are, and how deeply that block is nested.
+func rangeNodes(
+	ss *parser.Stylesheet,
+	start, end int,
+) (parser.Node, []parser.Node, int) {
+	if ss == nil {
+		return nil, nil, 0
+	}
+	children, block, depth := ss.Children, parser.Node(ss), 0
+	for {
+		nodes := intersecting(children, start, end)
+		inner, ok := nestedBlock(nodes, start, end)
+		if !ok {
+			return block, nodes, depth
+		}
+		children, block, depth = inner, nodes[0], depth+1
+	}
+}
+
+// closedNode reports whether the source of n ends as written with
+// the "}" of its block or, for statements, the ";" ending it.
+func closedNode(src []byte, n parser.Node) bool {
+	end := min(n.End(), len(src))
+	if end <= n.Offset() {
+		return false
+	}
+	switch n := n.(type) {
+	case *parser.Ruleset:
+		return src[end-1] == '}'
+	case *parser.AtRule:
+		if n.Block != nil {
+			return src[end-1] == '}'
+		}
+		return src[end-1] == ';'
+	}
+	return true
+}
+
+// intersecting returns the run of nodes that the source between
+// start and end intersects. An empty range intersects the node it
+// is in.
+func intersecting(nodes []parser.Node, start, end int) []parser.Node {
+	first, last := -1, -1
+	for i, n := range nodes {
+		in := n.Offset() < end && n.End() > start
+		if start == end {
+			in = n.Offset() <= start && start < n.End()
+		}
+		if in {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	return nodes[first : last+1]
+}
+
+// nestedBlock returns the children of the single rule in nodes if
+// the range lies within nested rules of its block, which are then
+// what gets formatted.
+func nestedBlock(nodes []parser.Node, start, end int) ([]parser.Node, bool) {
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	var children []parser.Node
+	switch n := nodes[0].(type) {
+	case *parser.Ruleset:
+		children = n.Children
+	case *parser.AtRule:
+		if n.Block == nil {
+			return nil, false
+		}
+		children = n.Block.Children
+	default:
+		return nil, false
+	}
+	inner := intersecting(children, start, end)
+	if len(inner) == 0 || start < inner[0].Offset() ||
+		end > inner[len(inner)-1].End() {
+		return nil, false
+	}
+	for _, n := range inner {
+		switch n.(type) {
+		case *parser.Ruleset, *parser.AtRule:
+		default:
+			return nil, false
+		}
+	}
+	return children, true
+}
+
+// formatNodes formats nodes, a run of the children of block, at
+// the given depth. The edit starts at the beginning of the first
+// node's line, if only indentation precedes it there, and ends
+// with the line of the last node, whatever follows it there
+// moving to the next line.
+func formatNodes(
+	src []byte,
+	block parser.Node,
+	nodes []parser.Node,
+	depth int,
+	opts FormatOptions,
+) (FormatEdit, bool) {
+	if opts.TabSize == 0 {
+		opts.TabSize = 2
+	}
+	if opts.PrintWidth == 0 {
+		opts.PrintWidth = 80
+	}
+	f := &formatter{src: src, indent: depth, opts: opts}
+	switch block.(type) {
+	case *parser.Stylesheet:
+		f.formatTopLevel(nodes)
+	case *parser.Ruleset:
+		f.formatRulesetChildren(nodes)
+	default:
+		f.formatAtRuleChildren(nodes)
+	}
+	text := f.buf.String()
+
+	start := nodes[0].Offset()
+	for start > 0 && (src[start-1] == ' ' || src[start-1] == '\t') {
+		start--
+	}
+	if start > 0 && src[start-1] != '\n' {
+		start = nodes[0].Offset()
+		text = "\n" + text
+	}
+	end := min(nodes[len(nodes)-1].End(), len(src))
+	rest := end
+	for rest < len(src) && (src[rest] == ' ' || src[rest] == '\t' || src[rest] == '\r') {
+		rest++
+	}
+	switch {
+	case rest < len(src) && src[rest] == '\n':
+		end = rest + 1
+	case rest < len(src):
+		// Something follows on the same line: a sibling, or the "}"
+		// closing the block, which moves to a line of its own.
+		f.buf.Reset()
+		f.indent = depth
+		if src[rest] == '}' {
+			f.indent = max(depth-1, 0)
+		}
+		f.writeIndent()
+		text += f.buf.String()
+		end = rest
+	}
+
+	text = finishLines(text, opts)
+	if text == string(src[start:end]) {
+		return FormatEdit{}, false
+	}
+	return FormatEdit{StartPos: start, EndPos: end, NewText: text}, true
+}
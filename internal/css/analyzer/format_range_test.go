@@ -0,0 +1,145 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+// applyFormatEdit returns src with e applied.
+func applyFormatEdit(src []byte, e FormatEdit) string {
+	return string(src[:e.StartPos]) + e.NewText + string(src[e.EndPos:])
+}
+
+func TestFormatRange(t *testing.T) {
+	const src = `.a{color:red}
+.b{color:blue;margin:0}
+@media print{
+.c{color:green}
+  .d{ color : black }
+}
+`
+	opts := FormatOptions{TabSize: 2, InsertSpaces: true}
+	tests := []struct {
+		name       string
+		start, end string // the range spans from start to the end of end
+		want       string
+	}{
+		{
+			name: "one rule", start: "margin", end: "margin",
+			want: `.a{color:red}
+.b {
+  color: blue;
+  margin: 0;
+}
+@media print{
+.c{color:green}
+  .d{ color : black }
+}
+`,
+		},
+		{
+			name: "two rules", start: "red", end: ".b{",
+			want: `.a {
+  color: red;
+}
+
+.b {
+  color: blue;
+  margin: 0;
+}
+@media print{
+.c{color:green}
+  .d{ color : black }
+}
+`,
+		},
+		{
+			name: "nested rules", start: "green", end: "black",
+			want: `.a{color:red}
+.b{color:blue;margin:0}
+@media print{
+  .c {
+    color: green;
+  }
+
+  .d {
+    color: black;
+  }
+}
+`,
+		},
+		{
+			name: "at-rule prelude", start: "print", end: "print",
+			want: `.a{color:red}
+.b{color:blue;margin:0}
+@media print {
+  .c {
+    color: green;
+  }
+
+  .d {
+    color: black;
+  }
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		b := []byte(src)
+		ss, _ := parser.Parse(b)
+		start := strings.Index(src, tt.start)
+		end := strings.Index(src, tt.end) + len(tt.end)
+		e, ok := FormatRange(ss, b, start, end, opts)
+		if !ok {
+			t.Errorf("%s: no edit", tt.name)
+			continue
+		}
+		if got := applyFormatEdit(b, e); got != tt.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	formatted := []byte(".a {\n  color: red;\n}\n")
+	ss, _ := parser.Parse(formatted)
+	if e, ok := FormatRange(ss, formatted, 5, 8, opts); ok {
+		t.Errorf("formatted rule edited: %+v", e)
+	}
+}
+
+func TestFormatOnType(t *testing.T) {
+	opts := FormatOptions{TabSize: 2, InsertSpaces: true}
+	tests := []struct {
+		src  string // | marks the cursor, after the typed character
+		ch   string
+		want string // "" for no edit
+	}{
+		{".a{color:red}|\n", "}", ".a {\n  color: red;\n}\n"},
+		{".a{color:red;|}\n", ";", ".a {\n  color: red;\n}\n"},
+		{
+			"@media print{.b{color:red}|\n", "}",
+			"@media print{\n  .b {\n    color: red;\n  }\n",
+		},
+		{
+			"@media print{.b{color:red}|}\n", "}",
+			"@media print{\n  .b {\n    color: red;\n  }\n}\n",
+		},
+		{".a{color:red;|\n", ";", ""},
+		{".a{color:red}|\n", ";", ""},
+	}
+	for _, tt := range tests {
+		offset := strings.Index(tt.src, "|")
+		src := []byte(tt.src[:offset] + tt.src[offset+1:])
+		ss, _ := parser.Parse(src)
+		e, ok := FormatOnType(ss, src, offset, tt.ch, opts)
+		switch {
+		case tt.want == "" && ok:
+			t.Errorf("%q: unexpected edit %+v", tt.src, e)
+		case tt.want != "" && !ok:
+			t.Errorf("%q: no edit", tt.src)
+		case ok && applyFormatEdit(src, e) != tt.want:
+			t.Errorf("%q:\ngot:\n%s\nwant:\n%s", tt.src, applyFormatEdit(src, e), tt.want)
+		}
+	}
+}
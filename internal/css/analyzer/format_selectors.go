@@ -0,0 +1,347 @@
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/css-lsp/internal/css/scanner"
+)
+
+// PseudoElementColons selects how the legacy pseudo-elements,
+// which also take one colon, are written.
+type PseudoElementColons int
+
+const (
+	// PseudoColonsAsIs leaves pseudo-elements as written (default).
+	PseudoColonsAsIs PseudoElementColons = iota
+	// PseudoColonsDouble writes ":before" as "::before".
+	PseudoColonsDouble
+	// PseudoColonsSingle writes "::before" as ":before".
+	PseudoColonsSingle
+)
+
+// SelectorOptions selects how the formatter lays out and
+// normalizes selectors, of rules, nested rules and @scope
+// preludes. The zero value keeps selector lists on one line where
+// the mode does and puts one space around every combinator.
+type SelectorOptions struct {
+	// OnePerLine puts each selector of a list on its own line in
+	// every mode, as the expanded mode always does.
+	OnePerLine bool
+	// Wrap breaks a selector that doesn't fit within PrintWidth
+	// at its combinators, indenting the lines after the first.
+	Wrap bool
+	// Spacing removes space just inside parentheses and brackets
+	// and around attribute operators, and writes ", " between the
+	// selectors of an argument, as in ":is(a, b)".
+	Spacing bool
+	// AttributeQuotes quotes attribute values, bare or quoted
+	// otherwise, unless they hold the quote.
+	AttributeQuotes QuoteStyle
+	// LowercasePseudo lowercases pseudo-class and pseudo-element
+	// names, though not their arguments.
+	LowercasePseudo bool
+	// PseudoElements selects the colons of ::before, ::after,
+	// ::first-line and ::first-letter.
+	PseudoElements PseudoElementColons
+}
+
+// legacyPseudoElements are the pseudo-elements CSS 2 wrote with
+// one colon.
+var legacyPseudoElements = []string{
+	"after", "before", "first-letter", "first-line",
+}
+
+// writeSelector writes the selector list of an expanded rule, one
+// selector per line.
+func (f *formatter) writeSelector(sl *parser.SelectorList) {
+	for i, sel := range f.selectorList(sl) {
+		if i > 0 {
+			f.buf.WriteString(",\n")
+			f.writeIndent()
+		}
+		f.writeWrapped(sel)
+	}
+}
+
+// writeWrapped writes a selector, broken at its combinators where
+// it would pass PrintWidth if the options ask for that.
+func (f *formatter) writeWrapped(compounds []string) {
+	if !f.opts.Selectors.Wrap {
+		f.buf.WriteString(strings.Join(compounds, " "))
+		return
+	}
+	cont := f.indentText(f.indent + 1)
+	width := f.indentWidth()
+	for i, c := range compounds {
+		if i > 0 {
+			if width+1+len(c) > f.opts.PrintWidth {
+				f.buf.WriteByte('\n')
+				f.buf.WriteString(cont)
+				width = len(cont)
+			} else {
+				f.buf.WriteByte(' ')
+				width++
+			}
+		}
+		f.buf.WriteString(c)
+		width += len(c)
+	}
+}
+
+// writeSelectorTo writes the selector list to a builder,
+// separating multiple selectors with ", ", or with line breaks
+// if the options put them on lines of their own.
+func (f *formatter) writeSelectorTo(
+	sb *strings.Builder,
+	sl *parser.SelectorList,
+) {
+	sep := ", "
+	if f.opts.Selectors.OnePerLine {
+		sep = ",\n" + f.indentText(f.indent)
+	}
+	for i, sel := range f.selectorList(sl) {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		sb.WriteString(strings.Join(sel, " "))
+	}
+}
+
+// indentText returns the indentation of the given level.
+func (f *formatter) indentText(level int) string {
+	if f.opts.InsertSpaces {
+		return strings.Repeat(" ", level*f.opts.TabSize)
+	}
+	return strings.Repeat("\t", level)
+}
+
+// selectorList returns the selectors of the list, each as its
+// compound selectors: the first, then each after a combinator
+// with the combinator before it, as "> a". A descendant
+// combinator is left implicit, since the compounds are joined
+// with spaces.
+func (f *formatter) selectorList(sl *parser.SelectorList) [][]string {
+	if len(sl.Selectors) == 0 {
+		return nil
+	}
+	if sl.StartPos < 0 || sl.StartPos > sl.EndPos || sl.EndPos > len(f.src) {
+		return f.selectorParts(sl)
+	}
+	return f.selectorsIn(sl.StartPos, sl.EndPos)
+}
+
+// selectorParts returns the selectors of the list as the parser
+// split them, token by token, for a list whose range recovery
+// from a syntax error left unusable. The tokens are written as
+// they are, without the selector options.
+func (f *formatter) selectorParts(sl *parser.SelectorList) [][]string {
+	var list [][]string
+	for _, sel := range sl.Selectors {
+		var compounds []string
+		var cur strings.Builder
+		prefix := ""
+		flush := func() {
+			if cur.Len() > 0 || prefix != "" {
+				compounds = append(compounds, strings.TrimSpace(prefix+cur.String()))
+			}
+			cur.Reset()
+			prefix = ""
+		}
+		for _, part := range sel.Parts {
+			if part.Combinator != "" {
+				flush()
+				if part.Combinator != " " {
+					prefix = part.Combinator + " "
+				}
+			}
+			tok := part.Token
+			if tok.Kind != scanner.EOF && tok.Offset >= 0 &&
+				tok.Offset <= tok.End && tok.End <= len(f.src) {
+				cur.WriteString(string(f.src[tok.Offset:tok.End]))
+			}
+		}
+		flush()
+		if len(compounds) > 0 {
+			list = append(list, compounds)
+		}
+	}
+	return list
+}
+
+// selectorsIn returns the selectors of the list in src[start:end],
+// as selectorList does. The list is split at commas outside
+// parentheses, so ":is(a, b)" stays one selector.
+func (f *formatter) selectorsIn(start, end int) [][]string {
+	if start < 0 || start > end || end > len(f.src) {
+		return nil
+	}
+	opts := f.opts.Selectors
+	src := f.src[start:end]
+
+	var list [][]string
+	var sel []string
+	var cur strings.Builder
+	var stack []scanner.Kind
+	prefix := ""
+	space := false     // whitespace is pending
+	noSpace := false   // pending whitespace is dropped
+	colons := 0        // colons held until the name after them
+	attrValue := false // an attribute operator was just written
+
+	flush := func() {
+		if cur.Len() > 0 || prefix != "" {
+			sel = append(sel, prefix+cur.String())
+		}
+		cur.Reset()
+		prefix = ""
+	}
+	write := func(s string) {
+		switch {
+		case len(stack) == 0:
+			if space && cur.Len() > 0 {
+				flush()
+			}
+		case space && !noSpace && cur.Len() > 0:
+			cur.WriteByte(' ')
+		}
+		cur.WriteString(s)
+		space, noSpace = false, false
+	}
+	inBrackets := func() bool {
+		return len(stack) > 0 && stack[len(stack)-1] == scanner.BracketOpen
+	}
+
+	for _, tok := range scanner.ScanAll(src) {
+		if tok.Kind == scanner.EOF {
+			break
+		}
+		text := string(src[tok.Offset:tok.End])
+
+		if colons > 0 && tok.Kind != scanner.Colon {
+			write(pseudoColons(colons, tok, opts.PseudoElements))
+			colons = 0
+			if opts.LowercasePseudo &&
+				(tok.Kind == scanner.Ident || tok.Kind == scanner.Function) {
+				text = strings.ToLower(text)
+			}
+		}
+		if attrValue && tok.Kind != scanner.Whitespace {
+			attrValue = false
+			text = quoteAttribute(text, tok.Kind, opts.AttributeQuotes)
+		}
+
+		switch tok.Kind {
+		case scanner.Whitespace:
+			space = true
+		case scanner.Colon:
+			if colons == 0 {
+				write("")
+			}
+			colons++
+		case scanner.Comma:
+			if len(stack) == 0 {
+				flush()
+				list = append(list, sel)
+				sel = nil
+				space = false
+				continue
+			}
+			if opts.Spacing {
+				space = false
+			}
+			write(text)
+			if opts.Spacing {
+				space = true
+			}
+		case scanner.Delim:
+			switch {
+			case !inBrackets() && (text == ">" || text == "+" || text == "~"):
+				if len(stack) == 0 {
+					if cur.Len() > 0 {
+						flush()
+					}
+					prefix = text + " "
+					space = false
+					continue
+				}
+				space = !strings.HasSuffix(cur.String(), "(")
+				write(text)
+				space = true
+			case inBrackets() && text == "=":
+				if opts.Spacing {
+					space = false
+				}
+				write(text)
+				noSpace = opts.Spacing
+				attrValue = true
+			case inBrackets() && strings.Contains("~|^$*", text) && opts.Spacing:
+				space = false
+				write(text)
+				noSpace = true
+			default:
+				write(text)
+			}
+		case scanner.Function, scanner.ParenOpen, scanner.BracketOpen:
+			write(text)
+			kind := tok.Kind
+			if kind == scanner.Function {
+				kind = scanner.ParenOpen
+			}
+			stack = append(stack, kind)
+			noSpace = opts.Spacing
+		case scanner.ParenClose, scanner.BracketClose:
+			if opts.Spacing {
+				space = false
+			}
+			write(text)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			write(text)
+		}
+	}
+	if colons > 0 {
+		write(strings.Repeat(":", colons))
+	}
+	flush()
+	if len(sel) > 0 {
+		list = append(list, sel)
+	}
+	return list
+}
+
+// pseudoColons returns the colons to write before the name tok of
+// a pseudo-class or pseudo-element, written with n of them.
+func pseudoColons(n int, tok scanner.Token, style PseudoElementColons) string {
+	if tok.Kind == scanner.Ident && (n == 1 || n == 2) {
+		legacy := false
+		for _, name := range legacyPseudoElements {
+			legacy = legacy || strings.EqualFold(tok.Value, name)
+		}
+		switch {
+		case legacy && style == PseudoColonsDouble:
+			n = 2
+		case legacy && style == PseudoColonsSingle:
+			n = 1
+		}
+	}
+	return strings.Repeat(":", n)
+}
+
+// quoteAttribute returns the text of an attribute value in the
+// quotes of style. Bare values are quoted unless they hold an
+// escape.
+func quoteAttribute(text string, kind scanner.Kind, style QuoteStyle) string {
+	switch {
+	case style == QuoteAsIs:
+		return text
+	case kind == scanner.String:
+		return requote(text, style)
+	case kind == scanner.Ident && !strings.Contains(text, `\`):
+		q := string(quoteChar(style))
+		return q + text + q
+	}
+	return text
+}
@@ -0,0 +1,165 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+func TestFormatSelectors(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  SelectorOptions
+		mode  FormatMode
+		width int
+		src   string
+		want  string
+	}{
+		{
+			name: "combinators",
+			src:  "a>b+c~d  e{color:red}",
+			want: "a > b + c ~ d e {\n  color: red;\n}\n",
+		},
+		{
+			name: "commas in arguments",
+			src:  ":is(.a,.b) > p, .c{color:red}",
+			want: ":is(.a,.b) > p,\n.c {\n  color: red;\n}\n",
+		},
+		{
+			name: "one per line",
+			opts: SelectorOptions{OnePerLine: true},
+			mode: FormatCompact,
+			src:  ".a, .b { color: red; }\n.c { color: blue; }",
+			want: ".a,\n.b { color: red; }\n.c { color: blue; }\n",
+		},
+		{
+			name: "one per line preserve",
+			opts: SelectorOptions{OnePerLine: true},
+			mode: FormatPreserve,
+			src:  ".b::before,.c:after{content:'x'}",
+			want: ".b::before,\n.c:after { content: 'x'; }\n",
+		},
+		{
+			name: "one per line detect",
+			opts: SelectorOptions{OnePerLine: true},
+			mode: FormatDetect,
+			src:  ".b::before,.c:after{content:'x'}",
+			want: ".b::before,\n.c:after { content: 'x'; }\n",
+		},
+		{
+			name:  "one per line checks each line",
+			opts:  SelectorOptions{OnePerLine: true},
+			mode:  FormatCompact,
+			width: 21,
+			src:   ".alpha, .beta { color: red; }",
+			want:  ".alpha,\n.beta { color: red; }\n",
+		},
+		{
+			name:  "wrap",
+			opts:  SelectorOptions{Wrap: true},
+			width: 30,
+			src:   ".container .content > .child + .sibling ~ .x { color: red; }",
+			want: ".container .content > .child\n" +
+				"  + .sibling ~ .x {\n  color: red;\n}\n",
+		},
+		{
+			name: "spacing",
+			opts: SelectorOptions{Spacing: true},
+			src:  "[ lang |= en ], a:not( .b,.c ), a:has(>img) { color: red; }",
+			want: "[lang|=en],\na:not(.b, .c),\na:has(> img) {\n" +
+				"  color: red;\n}\n",
+		},
+		{
+			name: "attribute quotes",
+			opts: SelectorOptions{AttributeQuotes: QuoteDouble},
+			src:  `[type=text], [lang='en' i], [title='say "x"'] { color: red; }`,
+			want: `[type="text"],` + "\n" + `[lang="en" i],` + "\n" +
+				`[title='say "x"'] {` + "\n  color: red;\n}\n",
+		},
+		{
+			name: "pseudo case",
+			opts: SelectorOptions{LowercasePseudo: true},
+			src:  "A:HOVER::BEFORE, li:NTH-CHILD(2N+1) { color: red; }",
+			want: "A:hover::before,\nli:nth-child(2N+1) {\n  color: red;\n}\n",
+		},
+		{
+			name: "double colons",
+			opts: SelectorOptions{PseudoElements: PseudoColonsDouble},
+			src:  "a:before, a:first-line, a:hover, a::marker { color: red; }",
+			want: "a::before,\na::first-line,\na:hover,\na::marker {\n" +
+				"  color: red;\n}\n",
+		},
+		{
+			name: "single colons",
+			opts: SelectorOptions{PseudoElements: PseudoColonsSingle},
+			src:  "a::after, a::placeholder { color: red; }",
+			want: "a:after,\na::placeholder {\n  color: red;\n}\n",
+		},
+		{
+			name: "nested rules",
+			opts: SelectorOptions{Spacing: true, LowercasePseudo: true},
+			src:  ".a { &>.b:HOVER { color: red; } >[ x ] { color: blue; } }",
+			want: ".a {\n  & > .b:hover {\n    color: red;\n  }\n\n" +
+				"  > [x] {\n    color: blue;\n  }\n}\n",
+		},
+		{
+			name: "scope prelude",
+			opts: SelectorOptions{Spacing: true},
+			src:  "@scope (.card>.body,.x) to ( .content ) { img { color: red; } }",
+			want: "@scope (.card > .body, .x) to (.content) {\n" +
+				"  img {\n    color: red;\n  }\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := FormatOptions{
+				TabSize:      2,
+				InsertSpaces: true,
+				Mode:         tt.mode,
+				PrintWidth:   tt.width,
+				Selectors:    tt.opts,
+			}
+			src := []byte(tt.src)
+			ss, _ := parser.Parse(src)
+			got := Format(ss, src, opts)
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			ss, _ = parser.Parse([]byte(got))
+			if again := Format(ss, []byte(got), opts); again != got {
+				t.Errorf("not idempotent:\n%s\nthen:\n%s", got, again)
+			}
+		})
+	}
+}
+
+func TestFormatSelectors_Malformed(t *testing.T) {
+	// An editor holds text like this mid-keystroke; recovering
+	// from it leaves selector lists whose range is unusable.
+	const src = `.a{&:hover{color:oklch(0.5 0.2 30) .b::BEFORE,.c:after{cont:'é→'}` +
+		`a>b , c~{ background:URL( 'x.png' );font-family:"Open Sans"}`
+	sels := SelectorOptions{
+		OnePerLine:      true,
+		LowercasePseudo: true,
+		PseudoElements:  PseudoColonsDouble,
+	}
+	for _, mode := range []FormatMode{
+		FormatExpanded, FormatCompact, FormatPreserve, FormatDetect,
+	} {
+		for i := range len(src) + 1 {
+			text := []byte(src[:i])
+			ss, _ := parser.Parse(text)
+			got := Format(ss, text, FormatOptions{
+				TabSize:      2,
+				InsertSpaces: true,
+				Mode:         mode,
+				Selectors:    sels,
+			})
+			if i == len(src) && !strings.Contains(got, "a > b") {
+				t.Errorf("mode %d: got\n%s", mode, got)
+			}
+		}
+	}
+}
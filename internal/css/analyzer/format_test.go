@@ -1145,3 +1145,40 @@ func TestFormat_ScopeWithSelectorList(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatLineOptions(t *testing.T) {
+	const src = ".a{color:red}\n/* note   \n   here */\n.b{color:blue}"
+	tests := []struct {
+		name string
+		opts FormatOptions
+		want string
+	}{
+		{
+			name: "crlf",
+			opts: FormatOptions{EndOfLine: EndOfLineCRLF},
+			want: ".a {\r\n  color: red;\r\n}\r\n\r\n/* note   \r\n   here */\r\n\r\n" +
+				".b {\r\n  color: blue;\r\n}\r\n",
+		},
+		{
+			name: "cr without final newline",
+			opts: FormatOptions{EndOfLine: EndOfLineCR, OmitFinalNewline: true},
+			want: ".a {\r  color: red;\r}\r\r/* note   \r   here */\r\r" +
+				".b {\r  color: blue;\r}",
+		},
+		{
+			name: "trim trailing whitespace",
+			opts: FormatOptions{TrimTrailingWhitespace: true},
+			want: ".a {\n  color: red;\n}\n\n/* note\n   here */\n\n" +
+				".b {\n  color: blue;\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.InsertSpaces = true
+			ss, _ := parser.Parse([]byte(src))
+			if got := Format(ss, []byte(src), tt.opts); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
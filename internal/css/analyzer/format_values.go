@@ -0,0 +1,514 @@
+package analyzer
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/css-lsp/internal/css/scanner"
+)
+
+// HexCase selects the case of hex colors.
+type HexCase int
+
+const (
+	// HexCaseAsIs leaves hex colors as written (default).
+	HexCaseAsIs HexCase = iota
+	// HexLower writes hex colors in lowercase.
+	HexLower
+	// HexUpper writes hex colors in uppercase.
+	HexUpper
+)
+
+// HexLength selects the length of hex colors.
+type HexLength int
+
+const (
+	// HexLengthAsIs leaves hex colors as written (default).
+	HexLengthAsIs HexLength = iota
+	// HexShort shortens hex colors where no digits are lost, so
+	// "#aabbcc" becomes "#abc".
+	HexShort
+	// HexLong lengthens three and four digit hex colors.
+	HexLong
+)
+
+// LeadingZero selects whether decimals below one start with "0".
+type LeadingZero int
+
+const (
+	// LeadingZeroAsIs leaves decimals as written (default).
+	LeadingZeroAsIs LeadingZero = iota
+	// LeadingZeroAdd writes ".5" as "0.5".
+	LeadingZeroAdd
+	// LeadingZeroRemove writes "0.5" as ".5".
+	LeadingZeroRemove
+)
+
+// QuoteStyle selects the quotes strings are written with.
+type QuoteStyle int
+
+const (
+	// QuoteAsIs leaves strings as written (default).
+	QuoteAsIs QuoteStyle = iota
+	// QuoteDouble writes strings in double quotes.
+	QuoteDouble
+	// QuoteSingle writes strings in single quotes.
+	QuoteSingle
+)
+
+// URLQuoteStyle selects whether url() paths are quoted.
+type URLQuoteStyle int
+
+const (
+	// URLQuotesAsIs leaves url() paths as written (default).
+	URLQuotesAsIs URLQuoteStyle = iota
+	// URLQuoted quotes url() paths, with the quotes of Quotes.
+	URLQuoted
+	// URLUnquoted unquotes url() paths that need no quotes.
+	URLUnquoted
+)
+
+// ColorNotation selects the notation colors are written in.
+type ColorNotation int
+
+const (
+	// ColorAsIs leaves colors as written (default).
+	ColorAsIs ColorNotation = iota
+	// ColorHex writes colors as hex.
+	ColorHex
+	// ColorRGB writes colors with rgb().
+	ColorRGB
+	// ColorHSL writes colors with hsl().
+	ColorHSL
+	// ColorHWB writes colors with hwb().
+	ColorHWB
+	// ColorLab writes colors with lab().
+	ColorLab
+	// ColorLCH writes colors with lch().
+	ColorLCH
+	// ColorOKLab writes colors with oklab().
+	ColorOKLab
+	// ColorOKLCH writes colors with oklch().
+	ColorOKLCH
+)
+
+// colorNotations maps each notation to the one ColorPresentation
+// writes it as.
+var colorNotations = map[ColorNotation]string{
+	ColorHex: "hex", ColorRGB: "rgb", ColorHSL: "hsl", ColorHWB: "hwb",
+	ColorLab: "lab", ColorLCH: "lch", ColorOKLab: "oklab",
+	ColorOKLCH: "oklch",
+}
+
+// srgbNotations are the notations that can only write colors in
+// sRGB. A color beyond it is never converted to one, since that
+// would map it into sRGB and lose the coordinates written.
+var srgbNotations = []string{"hex", "rgb", "hsl", "hwb"}
+
+// ValueOptions selects how the formatter normalizes declaration
+// values. The zero value leaves them as written. Every
+// normalization gives the same result when applied again.
+type ValueOptions struct {
+	HexCase     HexCase
+	HexLength   HexLength
+	LeadingZero LeadingZero
+	// TrimTrailingZeros writes "1.50" as "1.5" and "1.0" as "1".
+	TrimTrailingZeros bool
+	// RemoveZeroUnits writes lengths of zero, such as "0px", as
+	// "0", except within math functions and var(), where a unit
+	// may be needed, and in flex and custom properties.
+	RemoveZeroUnits bool
+	Quotes          QuoteStyle
+	URLQuotes       URLQuoteStyle
+	// LowercaseKeywords lowercases the keywords that the property
+	// takes, global keywords and color names.
+	LowercaseKeywords bool
+	// LowercaseUnits lowercases the units of dimensions.
+	LowercaseUnits bool
+	// Colors converts colors to a notation, as ColorPresentation
+	// writes them. Named colors are left alone, as are relative
+	// colors, colors using var() and colors beyond sRGB when the
+	// notation is limited to it.
+	Colors ColorNotation
+}
+
+// enabled reports whether any normalization is selected.
+func (o ValueOptions) enabled() bool {
+	return o != ValueOptions{}
+}
+
+// lengthUnits are the units whose zero needs none.
+var lengthUnits = []string{
+	"cap", "ch", "cm", "cqb", "cqh", "cqi", "cqmax", "cqmin", "cqw",
+	"dvb", "dvh", "dvi", "dvmax", "dvmin", "dvw", "em", "ex", "ic",
+	"in", "lh", "lvb", "lvh", "lvi", "lvmax", "lvmin", "lvw", "mm",
+	"pc", "pt", "px", "q", "rem", "rlh", "svb", "svh", "svi", "svmax",
+	"svmin", "svw", "vb", "vh", "vi", "vmax", "vmin", "vw",
+}
+
+// unitFunctions are the functions within which a zero may need
+// its unit.
+var unitFunctions = []string{
+	"abs", "acos", "asin", "atan", "atan2", "calc", "clamp", "cos",
+	"env", "exp", "hypot", "log", "max", "min", "mod", "pow", "rem",
+	"round", "sign", "sin", "sqrt", "tan", "var",
+}
+
+// value returns the value of decl normalized as the options ask.
+// A normalized value is scanned from its own text, which its
+// tokens index in place of the source; see valueSrc.
+func (f *formatter) value(decl *parser.Declaration) *parser.Value {
+	if decl.Value == nil || !f.opts.Values.enabled() {
+		return decl.Value
+	}
+	if v, ok := f.values[decl.Value]; ok {
+		return v
+	}
+	text := []byte(normalizeValue(f.src, decl, f.opts.Values))
+	if f.values == nil {
+		f.values = make(map[*parser.Value]*parser.Value)
+		f.texts = make(map[*parser.Value][]byte)
+	}
+	v := &parser.Value{EndPos: len(text)}
+	for _, tok := range scanner.ScanAll(text) {
+		if tok.Kind == scanner.EOF {
+			break
+		}
+		v.Tokens = append(v.Tokens, tok)
+	}
+	f.values[decl.Value] = v
+	f.texts[v] = text
+	return v
+}
+
+// valueSrc returns the text the tokens of v index: the text of a
+// normalized value, or else the source.
+func (f *formatter) valueSrc(v *parser.Value) []byte {
+	if text, ok := f.texts[v]; ok {
+		return text
+	}
+	return f.src
+}
+
+// normalizeValue returns the source of the value of decl with the
+// normalizations of opts applied.
+func normalizeValue(
+	src []byte,
+	decl *parser.Declaration,
+	opts ValueOptions,
+) string {
+	prop := strings.ToLower(decl.Property.Value)
+	custom := IsCustomProperty(prop)
+	var keywords map[string]bool
+	if opts.LowercaseKeywords && !custom {
+		keywords = propertyValues(prop)
+	}
+	toks := decl.Value.Tokens
+	colors := colorRewrites(toks, src, opts)
+
+	var b strings.Builder
+	var fns []string
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		text := string(src[tok.Offset:tok.End])
+		if r, ok := colors[i]; ok {
+			b.WriteString(r.text)
+			i = r.last
+			continue
+		}
+		switch tok.Kind {
+		case scanner.Hash:
+			text = normalizeHex(text, opts)
+		case scanner.Number, scanner.Percentage, scanner.Dimension:
+			keepUnit := custom || prop == "flex" || prop == "flex-basis" ||
+				slices.ContainsFunc(fns, func(fn string) bool {
+					return slices.Contains(unitFunctions, fn)
+				})
+			text = normalizeNumeric(text, tok.Kind, keepUnit, opts)
+		case scanner.String:
+			text = requote(text, opts.Quotes)
+		case scanner.URL:
+			if opts.URLQuotes == URLQuoted {
+				text = quoteURL(text, opts.Quotes)
+			}
+		case scanner.Function:
+			name := strings.ToLower(tok.Value)
+			if name == "url" && opts.URLQuotes == URLUnquoted {
+				if path, last, ok := unquotableURL(toks, i, src); ok {
+					b.WriteString(text + path + ")")
+					i = last
+					continue
+				}
+			}
+			fns = append(fns, name)
+		case scanner.ParenOpen:
+			fns = append(fns, "")
+		case scanner.ParenClose:
+			if len(fns) > 0 {
+				fns = fns[:len(fns)-1]
+			}
+		case scanner.Ident:
+			lower := strings.ToLower(text)
+			if keywords[lower] || (keywords != nil && isNamedColor(lower)) {
+				text = lower
+			}
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// normalizeNumbers returns text with its numbers, percentages
+// and dimensions written as the options ask, as they are in the
+// rest of a value, keeping the unit of a zero.
+func normalizeNumbers(text string, opts ValueOptions) string {
+	var b strings.Builder
+	for _, tok := range scanner.ScanAll([]byte(text)) {
+		if tok.Kind == scanner.EOF {
+			break
+		}
+		t := text[tok.Offset:tok.End]
+		switch tok.Kind {
+		case scanner.Number, scanner.Percentage, scanner.Dimension:
+			t = normalizeNumeric(t, tok.Kind, true, opts)
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+// normalizeHex returns the hex color text as the options ask, or
+// text itself if it isn't a hex color.
+func normalizeHex(text string, opts ValueOptions) string {
+	digits := text[1:]
+	if _, ok := parseHexColor(digits); !ok {
+		return text
+	}
+	switch {
+	case opts.HexLength == HexShort && (len(digits) == 6 || len(digits) == 8):
+		short := make([]byte, 0, len(digits)/2)
+		for i := 0; i < len(digits); i += 2 {
+			if !strings.EqualFold(digits[i:i+1], digits[i+1:i+2]) {
+				short = nil
+				break
+			}
+			short = append(short, digits[i])
+		}
+		if short != nil {
+			digits = string(short)
+		}
+	case opts.HexLength == HexLong && (len(digits) == 3 || len(digits) == 4):
+		long := make([]byte, 0, len(digits)*2)
+		for i := range len(digits) {
+			long = append(long, digits[i], digits[i])
+		}
+		digits = string(long)
+	}
+	switch opts.HexCase {
+	case HexLower:
+		digits = strings.ToLower(digits)
+	case HexUpper:
+		digits = strings.ToUpper(digits)
+	}
+	return "#" + digits
+}
+
+// normalizeNumeric returns the text of a number, percentage or
+// dimension as the options ask. keepUnit keeps the unit of a zero.
+func normalizeNumeric(
+	text string,
+	kind scanner.Kind,
+	keepUnit bool,
+	opts ValueOptions,
+) string {
+	num, unit := splitNumber(text)
+	if num == "" || strings.ContainsAny(num, "eE") {
+		return text
+	}
+	sign := ""
+	if num[0] == '+' || num[0] == '-' {
+		sign, num = num[:1], num[1:]
+	}
+	whole, frac, dot := strings.Cut(num, ".")
+	if opts.TrimTrailingZeros && dot {
+		frac = strings.TrimRight(frac, "0")
+		dot = frac != ""
+		if whole == "" && !dot {
+			whole = "0"
+		}
+	}
+	switch {
+	case opts.LeadingZero == LeadingZeroAdd && dot && whole == "":
+		whole = "0"
+	case opts.LeadingZero == LeadingZeroRemove && dot && whole == "0":
+		whole = ""
+	}
+	num = whole
+	if dot {
+		num += "." + frac
+	}
+
+	if kind == scanner.Dimension {
+		zero := strings.Trim(num, "0.") == ""
+		if opts.RemoveZeroUnits && zero && !keepUnit &&
+			slices.Contains(lengthUnits, strings.ToLower(unit)) {
+			return "0"
+		}
+		if opts.LowercaseUnits {
+			unit = strings.ToLower(unit)
+		}
+	}
+	return sign + num + unit
+}
+
+// splitNumber splits numeric token text into the number, with its
+// sign and any exponent, and the unit.
+func splitNumber(text string) (string, string) {
+	i := 0
+	if i < len(text) && (text[i] == '+' || text[i] == '-') {
+		i++
+	}
+	digits := func() {
+		for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			i++
+		}
+	}
+	digits()
+	if i+1 < len(text) && text[i] == '.' && isDigit(text[i+1]) {
+		i++
+		digits()
+	}
+	if i < len(text) && (text[i] == 'e' || text[i] == 'E') {
+		j := i + 1
+		if j < len(text) && (text[j] == '+' || text[j] == '-') {
+			j++
+		}
+		if j < len(text) && isDigit(text[j]) {
+			i = j
+			digits()
+		}
+	}
+	return text[:i], text[i:]
+}
+
+// isDigit reports whether b is an ASCII digit.
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// quoteChar returns the quote of a style, double by default.
+func quoteChar(style QuoteStyle) byte {
+	if style == QuoteSingle {
+		return '\''
+	}
+	return '"'
+}
+
+// requote returns the string token text in the quotes of style,
+// unless it holds that quote.
+func requote(text string, style QuoteStyle) string {
+	if style == QuoteAsIs || len(text) < 2 || text[len(text)-1] != text[0] {
+		return text
+	}
+	q := quoteChar(style)
+	raw := text[1 : len(text)-1]
+	if text[0] == q || strings.IndexByte(raw, q) >= 0 {
+		return text
+	}
+	return string(q) + raw + string(q)
+}
+
+// quoteURL returns the text of an unquoted url() with the path
+// quoted, unless it holds the quote or an escape.
+func quoteURL(text string, style QuoteStyle) string {
+	if len(text) < 5 {
+		return text
+	}
+	q := quoteChar(style)
+	path := strings.TrimSpace(text[4 : len(text)-1])
+	if path == "" || strings.ContainsAny(path, string(q)+"\\") {
+		return text
+	}
+	return text[:4] + string(q) + path + string(q) + ")"
+}
+
+// unquotableURL returns the path of the url() function at toks[i]
+// if it is a single string that needs no quotes, with the index of
+// the closing parenthesis.
+func unquotableURL(
+	toks []scanner.Token,
+	i int,
+	src []byte,
+) (string, int, bool) {
+	next := func(j int) int {
+		for j < len(toks) && toks[j].Kind == scanner.Whitespace {
+			j++
+		}
+		return j
+	}
+	s := next(i + 1)
+	c := next(s + 1)
+	if c >= len(toks) || toks[s].Kind != scanner.String ||
+		toks[c].Kind != scanner.ParenClose {
+		return "", 0, false
+	}
+	text := string(src[toks[s].Offset:toks[s].End])
+	if len(text) < 2 || text[len(text)-1] != text[0] {
+		return "", 0, false
+	}
+	path := text[1 : len(text)-1]
+	if path == "" || strings.ContainsFunc(path, func(r rune) bool {
+		return r <= ' ' || r == 0x7f || strings.ContainsRune("\"'()\\", r)
+	}) {
+		return "", 0, false
+	}
+	return path, c, true
+}
+
+// colorRewrite replaces the tokens of a color up to last.
+type colorRewrite struct {
+	last int
+	text string
+}
+
+// colorRewrites returns, by the index of their first token, the
+// colors among toks to write in the notation of opts.
+func colorRewrites(
+	toks []scanner.Token,
+	src []byte,
+	opts ValueOptions,
+) map[int]colorRewrite {
+	if opts.Colors == ColorAsIs {
+		return nil
+	}
+	want, ok := colorNotations[opts.Colors]
+	if !ok {
+		return nil
+	}
+	rewrites := make(map[int]colorRewrite)
+	for _, dc := range findColorsInTokens(toks, src, colorContext{}) {
+		st, ok := authoredStyle(string(src[dc.StartPos:dc.EndPos]))
+		if !ok || st.notation == want || st.notation == "named" ||
+			(slices.Contains(srgbNotations, want) && !dc.Color.inSRGB()) {
+			continue
+		}
+		first := slices.IndexFunc(toks, func(t scanner.Token) bool {
+			return t.Offset == dc.StartPos
+		})
+		last := slices.IndexFunc(toks, func(t scanner.Token) bool {
+			return t.End == dc.EndPos
+		})
+		if first < 0 || last < first || isRelativeColor(toks[first:]) {
+			continue
+		}
+		text, _ := formatColor(dc.Color, defaultStyle(want))
+		if opts.Colors == ColorHex {
+			text = normalizeHex(text, opts)
+		} else {
+			text = normalizeNumbers(text, opts)
+		}
+		rewrites[first] = colorRewrite{last: last, text: text}
+	}
+	return rewrites
+}
@@ -0,0 +1,258 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+func TestFormatValues(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ValueOptions
+		src  string
+		want string
+	}{
+		{
+			name: "hex lowercase",
+			opts: ValueOptions{HexCase: HexLower},
+			src:  ".a { color: #AbCdEf; }",
+			want: ".a { color: #abcdef; }",
+		},
+		{
+			name: "hex short",
+			opts: ValueOptions{HexLength: HexShort},
+			src: ".a { color: #AABBCC; background: #aabbcd; " +
+				"border-color: #11223344; }",
+			want: ".a { color: #ABC; background: #aabbcd; border-color: #1234; }",
+		},
+		{
+			name: "hex long upper",
+			opts: ValueOptions{HexLength: HexLong, HexCase: HexUpper},
+			src:  ".a { color: #abc; background: #abcd; }",
+			want: ".a { color: #AABBCC; background: #AABBCCDD; }",
+		},
+		{
+			name: "hex ignores ids",
+			opts: ValueOptions{HexCase: HexLower},
+			src:  ".a { grid-area: #Main; }",
+			want: ".a { grid-area: #Main; }",
+		},
+		{
+			name: "add leading zero",
+			opts: ValueOptions{LeadingZero: LeadingZeroAdd},
+			src:  ".a { opacity: .5; margin: -.25em +.5px 0.5em 1.5em; }",
+			want: ".a { opacity: 0.5; margin: -0.25em +0.5px 0.5em 1.5em; }",
+		},
+		{
+			name: "remove leading zero",
+			opts: ValueOptions{LeadingZero: LeadingZeroRemove},
+			src:  ".a { opacity: 0.5; margin: -0.25em 10.5px; }",
+			want: ".a { opacity: .5; margin: -.25em 10.5px; }",
+		},
+		{
+			name: "trim trailing zeros",
+			opts: ValueOptions{TrimTrailingZeros: true},
+			src: ".a { line-height: 1.50; width: 1.0px; opacity: .0; " +
+				"top: 10%; }",
+			want: ".a { line-height: 1.5; width: 1px; opacity: 0; top: 10%; }",
+		},
+		{
+			name: "exponents kept",
+			opts: ValueOptions{
+				TrimTrailingZeros: true,
+				LeadingZero:       LeadingZeroAdd,
+			},
+			src:  ".a { width: 1.50e3px; }",
+			want: ".a { width: 1.50e3px; }",
+		},
+		{
+			name: "zero units",
+			opts: ValueOptions{RemoveZeroUnits: true},
+			src: ".a { margin: 0px -0.0em 0% 0s; width: calc(0px + 1em); " +
+				"flex: 1 1 0px; --x: 0px; }",
+			want: ".a { margin: 0 0 0% 0s; width: calc(0px + 1em); " +
+				"flex: 1 1 0px; --x: 0px; }",
+		},
+		{
+			name: "lowercase units",
+			opts: ValueOptions{LowercaseUnits: true},
+			src:  ".a { width: 10PX; height: 2Em; transition: 1S; }",
+			want: ".a { width: 10px; height: 2em; transition: 1s; }",
+		},
+		{
+			name: "double quotes",
+			opts: ValueOptions{Quotes: QuoteDouble},
+			src:  `.a { content: 'a'; quotes: 'say "x"'; }`,
+			want: `.a { content: "a"; quotes: 'say "x"'; }`,
+		},
+		{
+			name: "single quotes",
+			opts: ValueOptions{Quotes: QuoteSingle},
+			src:  `.a { content: "a"; font-family: "It's"; }`,
+			want: `.a { content: 'a'; font-family: "It's"; }`,
+		},
+		{
+			name: "quote urls",
+			opts: ValueOptions{URLQuotes: URLQuoted, Quotes: QuoteSingle},
+			src: ".a { background: url(a.png), url( b.png ), " +
+				"url(\"c.png\"); }",
+			want: ".a { background: url('a.png'), url('b.png'), " +
+				"url('c.png'); }",
+		},
+		{
+			name: "unquote urls",
+			opts: ValueOptions{URLQuotes: URLUnquoted},
+			src: `.a { background: url("a.png"), url( 'b.png' ), ` +
+				`url("c d.png"); }`,
+			want: `.a { background: url(a.png), url(b.png), url("c d.png"); }`,
+		},
+		{
+			name: "lowercase keywords",
+			opts: ValueOptions{LowercaseKeywords: true},
+			src: ".a { display: BLOCK; color: RED; width: INHERIT; " +
+				"font-family: Arial; animation-name: Spin; --x: BLOCK; }",
+			want: ".a { display: block; color: red; width: inherit; " +
+				"font-family: Arial; animation-name: Spin; --x: BLOCK; }",
+		},
+		{
+			name: "colors to hex",
+			opts: ValueOptions{Colors: ColorHex, HexLength: HexShort},
+			src: ".a { color: rgb(255, 0, 0); background: hsl(0 0% 100% / 50%); " +
+				"border-color: red; outline-color: rgb(from red r g b); }",
+			want: ".a { color: #f00; background: #ffffff80; " +
+				"border-color: red; outline-color: rgb(from red r g b); }",
+		},
+		{
+			name: "colors to rgb",
+			opts: ValueOptions{Colors: ColorRGB},
+			src:  ".a { color: #f00; background: hsl(120, 100%, 50%); }",
+			want: ".a { color: rgb(255 0 0); background: rgb(0 255 0); }",
+		},
+		{
+			name: "colors to hsl",
+			opts: ValueOptions{Colors: ColorHSL},
+			src:  ".a { color: #ff0000; border: 1px solid rgba(0, 0, 255, .5); }",
+			want: ".a { color: hsl(0 100% 50%); " +
+				"border: 1px solid hsl(240 100% 50% / 50%); }",
+		},
+		{
+			name: "colors to hwb",
+			opts: ValueOptions{Colors: ColorHWB},
+			src:  ".a { color: #f00; background: rgb(0 0 255 / 50%); }",
+			want: ".a { color: hwb(0 0% 0%); background: hwb(240 0% 0% / 50%); }",
+		},
+		{
+			name: "colors to oklch",
+			opts: ValueOptions{Colors: ColorOKLCH},
+			src: ".a { color: hwb(0 0% 0%); " +
+				"background: color(display-p3 0 1 0); border-color: red; }",
+			want: ".a { color: oklch(0.628 0.2577 29.23); " +
+				"background: oklch(0.8488 0.3685 145.64); border-color: red; }",
+		},
+		{
+			name: "converted colors take number options",
+			opts: ValueOptions{
+				Colors:            ColorOKLab,
+				LeadingZero:       LeadingZeroRemove,
+				TrimTrailingZeros: true,
+			},
+			src:  ".a { color: color(display-p3 1 0 0); background: #fff; }",
+			want: ".a { color: oklab(.6486 .262 .145); background: oklab(1 0 0); }",
+		},
+		{
+			name: "colors beyond srgb stay",
+			opts: ValueOptions{Colors: ColorRGB},
+			src: ".a { color: lab(50 20 0); " +
+				"background: color(display-p3 0 1 0); border-color: lab(50 90 0); }",
+			want: ".a { color: rgb(151 106 120); " +
+				"background: color(display-p3 0 1 0); border-color: lab(50 90 0); }",
+		},
+		{
+			name: "none",
+			src:  ".a { color: #AABBCC; opacity: .50; margin: 0PX; }",
+			want: ".a { color: #AABBCC; opacity: .50; margin: 0PX; }",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := FormatOptions{
+				TabSize:      2,
+				InsertSpaces: true,
+				Mode:         FormatCompact,
+				PrintWidth:   200,
+				Values:       tt.opts,
+			}
+			src := []byte(tt.src)
+			ss, _ := parser.Parse(src)
+			got := Format(ss, src, opts)
+			if got != tt.want+"\n" {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if string(src) != tt.src {
+				t.Errorf("source changed to %q", src)
+			}
+
+			ss, _ = parser.Parse([]byte(got))
+			if again := Format(ss, []byte(got), opts); again != got {
+				t.Errorf("not idempotent:\n%s\nthen:\n%s", got, again)
+			}
+		})
+	}
+}
+
+func TestFormatValues_SourceOffsets(t *testing.T) {
+	const src = ".a { color: #AABBCC; margin: 0PX; }"
+	f := &formatter{
+		src:  []byte(src),
+		opts: FormatOptions{Values: ValueOptions{HexCase: HexLower}},
+	}
+	ss, _ := parser.Parse(f.src)
+	decl := ss.Children[0].(*parser.Ruleset).Children[0].(*parser.Declaration)
+	v := f.value(decl)
+	if v == decl.Value {
+		t.Fatal("value not normalized")
+	}
+	if string(f.src) != src {
+		t.Errorf("formatter source changed to %q", f.src)
+	}
+	var sb strings.Builder
+	f.writeValueTo(&sb, v)
+	if sb.String() != "#aabbcc" {
+		t.Errorf("normalized value = %q", sb.String())
+	}
+	for _, tok := range decl.Value.Tokens {
+		if got := src[tok.Offset:tok.End]; got != "#AABBCC" {
+			t.Errorf("source token = %q", got)
+		}
+	}
+}
+
+func TestFormatValues_MultiLine(t *testing.T) {
+	const src = ".a{transition:ALL .50S,transform .50S ease-in," +
+		"visibility 0S linear .50S,color 2S}"
+	opts := FormatOptions{
+		TabSize:      2,
+		InsertSpaces: true,
+		Values: ValueOptions{
+			LeadingZero:       LeadingZeroAdd,
+			TrimTrailingZeros: true,
+			LowercaseKeywords: true,
+			LowercaseUnits:    true,
+		},
+	}
+	ss, _ := parser.Parse([]byte(src))
+	got := Format(ss, []byte(src), opts)
+	const want = `.a {
+  transition:
+    all 0.5s,
+    transform 0.5s ease-in,
+    visibility 0s linear 0.5s,
+    color 2s;
+}
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
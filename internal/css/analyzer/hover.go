@@ -1,6 +1,7 @@
 package analyzer
 
 import (
+	"slices"
 	"strings"
 
 	"github.com/toba/css-lsp/internal/css/data"
@@ -19,25 +20,63 @@ type HoverResult struct {
 	Found      bool
 }
 
+// HoverOptions configures Hover.
+type HoverOptions struct {
+	// Resolver, if set, looks up custom properties defined in
+	// other files.
+	Resolver VariableResolver
+	// Scheme is the color scheme light-dark() colors are shown,
+	// and their contrast given, in.
+	Scheme ColorScheme
+	// ContrastPairs are the selectors whose contrast with each
+	// other hovers show, besides that within a ruleset.
+	ContrastPairs []ContrastPair
+}
+
 // Hover returns markdown hover content for the given byte
-// offset. An optional VariableResolver enables cross-file
-// custom property value lookup.
+// offset. Hovers of color and background declarations show
+// their contrast with each other.
 func Hover(
 	ss *parser.Stylesheet,
 	src []byte,
 	offset int,
-	resolvers ...VariableResolver,
+	opts HoverOptions,
 ) HoverResult {
 	if ss == nil {
 		return HoverResult{}
 	}
 
-	var resolver VariableResolver
-	if len(resolvers) > 0 {
-		resolver = resolvers[0]
+	result := hoverToken(ss, src, offset, opts)
+	decl := declarationAtOffset(ss, offset)
+	if decl == nil {
+		return result
+	}
+	contrast := hoverContrast(ss, src, decl, opts)
+	if contrast == "" {
+		return result
+	}
+	if !result.Found {
+		return HoverResult{
+			Content:    strings.TrimPrefix(contrast, "\n\n"),
+			RangeStart: decl.Offset(),
+			RangeEnd:   decl.End(),
+			Found:      true,
+		}
 	}
+	result.Content += contrast
+	return result
+}
 
+// hoverToken returns the hover for the token at offset, or for the
+// selector there.
+func hoverToken(
+	ss *parser.Stylesheet,
+	src []byte,
+	offset int,
+	opts HoverOptions,
+) HoverResult {
 	tok := tokenAtOffset(ss, offset)
+	resolver := opts.Resolver
 	if tok == nil {
 		// Check selectors for pseudo-classes/elements
 		content, found := hoverSelector(ss, offset)
@@ -58,6 +97,10 @@ func Hover(
 			)
 		}
 		content, found := hoverFunction(tok)
+		if found {
+			cc := colorContext{resolver: resolver, scheme: opts.Scheme}
+			content += hoverColorValue(ss, src, tok, offset, cc)
+		}
 		return HoverResult{Content: content, Found: found}
 	}
 
@@ -360,6 +403,51 @@ func hoverFunction(
 	return b.String(), true
 }
 
+// hoverColorValue returns the colors a color-mix() or
+// light-dark() call at tok computes to, as a paragraph to append
+// to its hover, or "" for other functions and calls whose colors
+// can't be resolved.
+func hoverColorValue(
+	ss *parser.Stylesheet,
+	src []byte,
+	tok *scanner.Token,
+	offset int,
+	cc colorContext,
+) string {
+	name := strings.ToLower(tok.Value)
+	if name != "color-mix" && name != "light-dark" {
+		return ""
+	}
+	decl := declarationAtOffset(ss, offset)
+	if decl == nil || decl.Value == nil {
+		return ""
+	}
+	tokens := decl.Value.Tokens
+	i := slices.IndexFunc(tokens, func(t scanner.Token) bool {
+		return t.Offset == tok.Offset
+	})
+	if i < 0 {
+		return ""
+	}
+
+	if name == "color-mix" {
+		dc, ok := parseColorMix(tokens[i:], src, cc)
+		if !ok {
+			return ""
+		}
+		return "\n\nComputes to `" + ColorPresentation(dc.Color)[1] + "`"
+	}
+	var b strings.Builder
+	colors, found := parseLightDark(tokens[i:], src, cc)
+	for k, label := range []string{"Light", "Dark"} {
+		if found[k] {
+			b.WriteString("\n\n" + label + ": `" +
+				ColorPresentation(colors[k].Color)[1] + "`")
+		}
+	}
+	return b.String()
+}
+
 func hoverSelector(
 	ss *parser.Stylesheet,
 	offset int,
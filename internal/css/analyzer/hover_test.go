@@ -15,7 +15,7 @@ func TestHoverProperty(t *testing.T) {
 	}
 
 	// "color" is at bytes 7-12
-	hr := Hover(ss, src, 8)
+	hr := Hover(ss, src, 8, HoverOptions{})
 	if !hr.Found {
 		t.Fatal("expected hover to find content")
 	}
@@ -30,7 +30,7 @@ func TestHoverUnknownProperty(t *testing.T) {
 	src := []byte(`body { foobar: red; }`)
 	ss, _ := parser.Parse(src)
 
-	hr := Hover(ss, src, 8)
+	hr := Hover(ss, src, 8, HoverOptions{})
 	if hr.Found {
 		t.Error(
 			"expected hover not to find unknown property",
@@ -46,7 +46,7 @@ func TestHoverFunctionSignatures(t *testing.T) {
 	}
 
 	// "rgb" function token starts at byte 14
-	hr := Hover(ss, src, 14)
+	hr := Hover(ss, src, 14, HoverOptions{})
 	if !hr.Found {
 		t.Fatal("expected hover to find rgb function")
 	}
@@ -80,7 +80,7 @@ func TestHoverFunctionCalc(t *testing.T) {
 	}
 
 	// "calc" function token starts at byte 14
-	hr := Hover(ss, src, 14)
+	hr := Hover(ss, src, 14, HoverOptions{})
 	if !hr.Found {
 		t.Fatal("expected hover to find calc function")
 	}
@@ -102,7 +102,7 @@ func TestHoverFunctionUnknown(t *testing.T) {
 	)
 	ss, _ := parser.Parse(src)
 
-	hr := Hover(ss, src, 14)
+	hr := Hover(ss, src, 14, HoverOptions{})
 	if hr.Found {
 		t.Error(
 			"expected hover not to find unknown function",
@@ -116,7 +116,7 @@ func TestHoverNoPanic(t *testing.T) {
 
 	// Just ensure no panic at various offsets
 	for i := range src {
-		Hover(ss, src, i)
+		Hover(ss, src, i, HoverOptions{})
 	}
 }
 
@@ -128,7 +128,7 @@ func TestHoverCustomPropertyDeclaration(t *testing.T) {
 	}
 
 	// "--color-link-icon" starts at byte 8
-	hr := Hover(ss, src, 10)
+	hr := Hover(ss, src, 10, HoverOptions{})
 	if !hr.Found {
 		t.Fatal(
 			"expected hover for custom property declaration",
@@ -168,7 +168,7 @@ func TestHoverVarReference(t *testing.T) {
 		string(src[varIdx:]), "--brand",
 	) + varIdx
 
-	hr := Hover(ss, src, identIdx+1)
+	hr := Hover(ss, src, identIdx+1, HoverOptions{})
 	if !hr.Found {
 		t.Fatal("expected hover for var() reference")
 	}
@@ -204,7 +204,7 @@ func TestHoverVarReferenceWithResolver(t *testing.T) {
 		string(src[varIdx:]), "--brand",
 	) + varIdx
 
-	hr := Hover(ss, src, identIdx+1, resolver)
+	hr := Hover(ss, src, identIdx+1, HoverOptions{Resolver: resolver})
 	if !hr.Found {
 		t.Fatal("expected hover for cross-file var()")
 	}
@@ -246,7 +246,7 @@ func TestHoverPropertyBaseline(t *testing.T) {
 		t.Fatalf("parse errors: %v", errs)
 	}
 
-	hr := Hover(ss, src, 8)
+	hr := Hover(ss, src, 8, HoverOptions{})
 	if !hr.Found {
 		t.Fatal("expected hover to find content")
 	}
@@ -265,7 +265,7 @@ func TestHoverPropertyNoBaseline(t *testing.T) {
 	src := []byte(`:root { --my-var: red; }`)
 	ss, _ := parser.Parse(src)
 
-	hr := Hover(ss, src, 10)
+	hr := Hover(ss, src, 10, HoverOptions{})
 	if hr.Found && strings.Contains(
 		hr.Content, "available",
 	) {
@@ -282,7 +282,7 @@ func TestHoverExperimentalNoBaseline(t *testing.T) {
 	ss, _ := parser.Parse(src)
 
 	// "initial-letter-align" starts at byte 7
-	hr := Hover(ss, src, 8)
+	hr := Hover(ss, src, 8, HoverOptions{})
 	if !hr.Found {
 		t.Skip(
 			"initial-letter-align not in data, skipping",
@@ -316,7 +316,7 @@ func TestHoverVarFunctionToken(t *testing.T) {
 	varExpr := "var(--brand)"
 	varIdx := strings.Index(string(src), varExpr)
 
-	hr := Hover(ss, src, varIdx+1)
+	hr := Hover(ss, src, varIdx+1, HoverOptions{})
 	if !hr.Found {
 		t.Fatal(
 			"expected hover for var() function token",
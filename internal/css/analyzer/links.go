@@ -12,6 +12,13 @@ type DocumentLink struct {
 	StartPos int
 	EndPos   int
 	Target   string
+	// TargetStart and TargetEnd span the target as written, within
+	// the link's quotes or url(); they are -1 when escapes make the
+	// written form differ from Target.
+	TargetStart, TargetEnd int
+	// Import is set for the stylesheet an @import rule loads,
+	// and unset for url() references.
+	Import bool
 }
 
 // FindDocumentLinks returns links found in the CSS document,
@@ -45,30 +52,40 @@ func FindDocumentLinks(
 		return true
 	})
 
+	for i := range links {
+		links[i].TargetStart, links[i].TargetEnd = -1, -1
+		l := &links[i]
+		if l.Target == "" || l.EndPos > len(src) {
+			continue
+		}
+		if j := strings.Index(string(src[l.StartPos:l.EndPos]), l.Target); j >= 0 {
+			l.TargetStart = l.StartPos + j
+			l.TargetEnd = l.TargetStart + len(l.Target)
+		}
+	}
 	return links
 }
 
 func extractImportLink(rule *parser.AtRule) *DocumentLink {
-	for _, tok := range rule.Prelude {
+	for i, tok := range rule.Prelude {
 		switch tok.Kind {
-		case scanner.String:
-			target := tok.Value
-			return &DocumentLink{
-				StartPos: tok.Offset,
-				EndPos:   tok.End,
-				Target:   target,
-			}
-		case scanner.URL:
-			target := tok.Value
+		case scanner.String, scanner.URL:
 			return &DocumentLink{
 				StartPos: tok.Offset,
 				EndPos:   tok.End,
-				Target:   target,
+				Target:   tok.Value,
+				Import:   true,
 			}
 		case scanner.Function:
-			if strings.ToLower(tok.Value) == "url" {
-				// Next token should be the URL string
-				return nil // handled by URL token type
+			arg, ok := quotedURL(rule.Prelude, i)
+			if !ok {
+				return nil
+			}
+			return &DocumentLink{
+				StartPos: arg.Offset,
+				EndPos:   arg.End,
+				Target:   arg.Value,
+				Import:   true,
 			}
 		}
 	}
@@ -80,8 +97,18 @@ func extractURLLinks(
 ) []DocumentLink {
 	var links []DocumentLink
 
-	for _, tok := range tokens {
-		if tok.Kind == scanner.URL && tok.Value != "" {
+	for i, tok := range tokens {
+		switch tok.Kind {
+		case scanner.URL:
+		case scanner.Function:
+			var ok bool
+			if tok, ok = quotedURL(tokens, i); !ok {
+				continue
+			}
+		default:
+			continue
+		}
+		if tok.Value != "" {
 			links = append(links, DocumentLink{
 				StartPos: tok.Offset,
 				EndPos:   tok.End,
@@ -92,3 +119,122 @@ func extractURLLinks(
 
 	return links
 }
+
+// quotedURL returns the string argument of the url( function
+// token at tokens[i]. url("...") quotes its argument, which unlike
+// a bare url(...) is scanned as a string.
+func quotedURL(tokens []scanner.Token, i int) (scanner.Token, bool) {
+	if !strings.EqualFold(tokens[i].Value, "url") {
+		return scanner.Token{}, false
+	}
+	for _, arg := range tokens[i+1:] {
+		switch arg.Kind {
+		case scanner.Whitespace:
+			continue
+		case scanner.String:
+			return arg, true
+		}
+		break
+	}
+	return scanner.Token{}, false
+}
+
+// PathCompletion is the path being typed in a url() or @import.
+type PathCompletion struct {
+	// Dir is the path up to and including its last "/", as
+	// written; Prefix is the rest of it up to the cursor.
+	Dir, Prefix string
+	// Start and End span the path segment the cursor is in, the
+	// part a completion replaces.
+	Start, End int
+	// Import is set in @import rules, which load stylesheets.
+	Import bool
+}
+
+// PathCompletionAt returns the path being typed at offset, if the
+// offset is inside the quoted or bare argument of a url() or the
+// string of an @import. It reads the text rather than tokens so
+// that unterminated strings and url( arguments are found.
+func PathCompletionAt(src []byte, offset int) (PathCompletion, bool) {
+	if offset > len(src) || isInsideComment(src, offset) {
+		return PathCompletion{}, false
+	}
+	start := offset
+	for start > 0 && isPathChar(src[start-1]) {
+		start--
+	}
+	i := start
+	quoted := i > 0 && (src[i-1] == '"' || src[i-1] == '\'')
+	if quoted {
+		i--
+	}
+	before := strings.TrimRight(string(src[:i]), " \t")
+
+	var pc PathCompletion
+	switch {
+	case hasSuffixFold(before, "url("):
+		before = strings.TrimRight(before[:len(before)-len("url(")], " \t")
+		pc.Import = hasSuffixFold(before, "@import")
+	case quoted && hasSuffixFold(before, "@import"):
+		pc.Import = true
+	default:
+		return PathCompletion{}, false
+	}
+
+	typed := string(src[start:offset])
+	slash := strings.LastIndexByte(typed, '/') + 1
+	pc.Dir, pc.Prefix = typed[:slash], typed[slash:]
+	pc.Start, pc.End = start+slash, offset
+	for pc.End < len(src) && isPathChar(src[pc.End]) && src[pc.End] != '/' {
+		pc.End++
+	}
+	return pc, true
+}
+
+// isPathChar reports whether ch can be part of a path written in
+// a url() or @import.
+func isPathChar(ch byte) bool {
+	switch ch {
+	case '"', '\'', '(', ')', ';', '{', '}', ' ', '\t', '\n', '\r', '\f':
+		return false
+	}
+	return true
+}
+
+// hasSuffixFold reports whether s ends with suffix, ignoring
+// ASCII case.
+func hasSuffixFold(s, suffix string) bool {
+	return len(s) >= len(suffix) &&
+		strings.EqualFold(s[len(s)-len(suffix):], suffix)
+}
+
+// ImportIndex resolves the @import rules of the stylesheet being
+// analyzed against the workspace.
+type ImportIndex interface {
+	// ResolveImport returns the path of the stylesheet an @import
+	// of target loads, or false if there is none.
+	ResolveImport(target string) (string, bool)
+	// ImportCycle returns the paths of the shortest chain of
+	// imports from the stylesheet at path back to the one being
+	// analyzed, both ends included, or nil if there is none.
+	ImportCycle(path string) []string
+}
+
+// URLIndex resolves the url() references of the stylesheet being
+// analyzed against the workspace.
+type URLIndex interface {
+	// ResolveURL returns the path of the file a url() of target
+	// names, or false if there is none.
+	ResolveURL(target string) (string, bool)
+}
+
+// IsRemoteURL reports whether a link target names a resource by
+// scheme, such as https: or data:, or by a protocol-relative
+// //host URL, rather than a path.
+func IsRemoteURL(target string) bool {
+	if strings.HasPrefix(target, "//") {
+		return true
+	}
+	i := strings.IndexAny(target, ":/?#")
+	return i > 1 && target[i] == ':'
+}
@@ -1,6 +1,9 @@
 package analyzer
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/toba/css-lsp/internal/css/parser"
@@ -39,3 +42,164 @@ func TestFindDocumentLinks_Nil(t *testing.T) {
 		t.Fatal("expected nil for nil stylesheet")
 	}
 }
+
+func TestIsRemoteURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/a.css": true,
+		"//cdn.example.com/a.css":   true,
+		"data:text/css,a{}":         true,
+		"./a.css":                   false,
+		"a.css?v=1:2":               false,
+		"C:/styles/a.css":           false,
+		"pkg/a.css":                 false,
+	}
+	for target, want := range tests {
+		if got := IsRemoteURL(target); got != want {
+			t.Errorf("IsRemoteURL(%q) = %v, want %v", target, got, want)
+		}
+	}
+}
+
+// importIndex is an ImportIndex backed by fixed paths.
+type importIndex struct {
+	paths  map[string]string
+	cycles map[string][]string
+}
+
+func (m importIndex) ResolveImport(target string) (string, bool) {
+	p, ok := m.paths[target]
+	return p, ok
+}
+
+func (m importIndex) ImportCycle(path string) []string { return m.cycles[path] }
+
+func (m importIndex) ResolveURL(target string) (string, bool) {
+	return m.ResolveImport(target)
+}
+
+func TestAnalyzeImports(t *testing.T) {
+	src := []byte(`@import "./base.css";
+@import url(missing.css);
+@import "https://example.com/remote.css";
+@import "./loop.css";
+`)
+	idx := importIndex{
+		paths: map[string]string{
+			"./base.css": "/a/base.css",
+			"./loop.css": "/a/loop.css",
+		},
+		cycles: map[string][]string{
+			"/a/loop.css": {"/a/main.css", "/a/loop.css", "/a/main.css"},
+		},
+	}
+	ix := Indexes{Imports: idx, URLs: idx}
+	diags := Analyze(parseCSS(t, src), src, LintOptions{}, ix)
+	if len(diags) != 2 {
+		t.Fatalf("diagnostics = %+v", diags)
+	}
+	if d, ok := findDiagnostic(diags, UnresolvedImportMessage("missing.css")); !ok ||
+		d.StartLine != 1 {
+		t.Errorf("unresolved import = %+v, %v", d, ok)
+	}
+	msg := ImportCycleMessage([]string{"main.css", "loop.css", "main.css"})
+	if _, ok := findDiagnostic(diags, msg); !ok {
+		t.Errorf("expected %q in %+v", msg, diags)
+	}
+
+	opts := LintOptions{
+		UnresolvedImports: UnresolvedImportError,
+		ImportCycles:      ImportCycleIgnore,
+	}
+	diags = Analyze(parseCSS(t, src), src, opts, ix)
+	if len(diags) != 1 || diags[0].Severity != SeverityError {
+		t.Errorf("with cycles ignored, diagnostics = %+v", diags)
+	}
+	opts = LintOptions{
+		UnresolvedImports: UnresolvedImportIgnore,
+		ImportCycles:      ImportCycleError,
+	}
+	diags = Analyze(parseCSS(t, src), src, opts, ix)
+	if d, ok := findDiagnostic(diags, msg); len(diags) != 1 || !ok ||
+		d.Severity != SeverityError {
+		t.Errorf("with unresolved imports ignored, diagnostics = %+v", diags)
+	}
+}
+
+func TestAnalyzeURLs(t *testing.T) {
+	src := []byte(`.a { background: url(logo.png) url("gone.png"); }
+.b { mask: url(data:image/png;base64,AAAA) url(#clip); }
+.c { background: url(//cdn.example.com/x.png); }
+@import "gone.css";
+`)
+	idx := importIndex{
+		paths: map[string]string{"logo.png": "/a/logo.png"},
+	}
+	ix := Indexes{Imports: idx, URLs: idx}
+	opts := LintOptions{MissingFiles: MissingFileError}
+	diags := Analyze(parseCSS(t, src), src, opts, ix)
+	if len(diags) != 2 {
+		t.Fatalf("diagnostics = %+v", diags)
+	}
+	d, ok := findDiagnostic(diags, MissingFileMessage("gone.png"))
+	if !ok || d.Severity != SeverityError || d.StartChar != indexOf(src, `"gone`) {
+		t.Errorf("missing file = %+v, %v", d, ok)
+	}
+
+	if diags := Analyze(parseCSS(t, src), src, LintOptions{}, ix); len(diags) != 1 {
+		t.Errorf("missing files are only reported when asked for: %+v", diags)
+	}
+}
+
+func TestFindDocumentLinks_ImportForms(t *testing.T) {
+	src := []byte(`@import url(a.css); @import url("b.css") screen; @import 'c.css';
+.x { background: url(d.png), url( 'e.png' ); content: "f.png"; }`)
+	ss, _ := parser.Parse(src)
+	var got []string
+	for _, link := range FindDocumentLinks(ss, src) {
+		written := string(src[link.TargetStart:link.TargetEnd])
+		got = append(got, fmt.Sprint(written, " ", link.Import))
+	}
+	want := []string{
+		"a.css true", "b.css true", "c.css true", "d.png false", "e.png false",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+}
+
+func TestPathCompletionAt(t *testing.T) {
+	tests := []struct {
+		src    string // | marks the cursor
+		ok     bool
+		dir    string
+		prefix string
+		seg    string // the segment replaced
+		imp    bool
+	}{
+		{`.a { mask: url("./img/lo|go.png"); }`, true, "./img/", "lo", "logo.png", false},
+		{`a { background: url(|`, true, "", "", "", false},
+		{`a { background: URL( img/|`, true, "img/", "", "", false},
+		{`@import "|`, true, "", "", "", true},
+		{`@import 'theme/da|`, true, "theme/", "da", "da", true},
+		{`@import url("../b|.css") screen;`, true, "../", "b", "b.css", true},
+		{`a { content: "img/|"; }`, false, "", "", "", false},
+		{`@import "a.css" scr|een;`, false, "", "", "", false},
+		{`/* url(img/| */`, false, "", "", "", false},
+	}
+	for _, tt := range tests {
+		offset := strings.Index(tt.src, "|")
+		src := []byte(tt.src[:offset] + tt.src[offset+1:])
+		pc, ok := PathCompletionAt(src, offset)
+		if ok != tt.ok {
+			t.Errorf("PathCompletionAt(%s) ok = %v", tt.src, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if pc.Dir != tt.dir || pc.Prefix != tt.prefix || pc.Import != tt.imp ||
+			string(src[pc.Start:pc.End]) != tt.seg {
+			t.Errorf("PathCompletionAt(%s) = %+v", tt.src, pc)
+		}
+	}
+}
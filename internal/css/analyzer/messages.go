@@ -1,5 +1,7 @@
 package analyzer
 
+import "strings"
+
 // Diagnostic message prefixes and builders.
 const (
 	UnknownPropertyPrefix = "unknown property '"
@@ -8,6 +10,10 @@ const (
 	AvoidImportantMsg     = "avoid using !important"
 	VendorPrefixPrefix    = "vendor prefix '"
 	UnknownAtRulePrefix   = "unknown at-rule '@"
+
+	// Links that name nothing, which quick fixes point elsewhere.
+	UnresolvedImportPrefix = "cannot resolve import '"
+	MissingFilePrefix      = "cannot find file '"
 )
 
 // UnknownPropertyMessage returns a diagnostic message for an
@@ -52,3 +58,88 @@ func UnknownValueMessage(value, property string) string {
 	return "unknown value '" + value +
 		"' for property '" + property + "'"
 }
+
+// OutOfOrderMessage returns a diagnostic message for a declaration
+// that belongs before another.
+func OutOfOrderMessage(property, before string) string {
+	return "property '" + property + "' should come before '" + before + "'"
+}
+
+// UnusedClassMessage returns a diagnostic message for a class
+// selector that no markup in the workspace uses.
+func UnusedClassMessage(name string) string {
+	return "class '" + name + "' is not used in any markup"
+}
+
+// UnusedIDMessage returns a diagnostic message for an id selector
+// that no markup in the workspace uses.
+func UnusedIDMessage(name string) string {
+	return "id '" + name + "' is not used in any markup"
+}
+
+// ComposesSelectorMsg is reported for composes declarations in
+// rules whose selector isn't a single class.
+const ComposesSelectorMsg = "composes is only allowed in a rule " +
+	"whose selector is a single class"
+
+// UndefinedModuleClassMessage returns a diagnostic message for a
+// composed class that the module it comes from doesn't define.
+// from is "" for the module itself.
+func UndefinedModuleClassMessage(name, from string) string {
+	if from == "" {
+		return "class '" + name + "' is not defined in this module"
+	}
+	return "class '" + name + "' is not defined in '" + from + "'"
+}
+
+// UndefinedModuleValueMessage returns a diagnostic message for an
+// @value import that the module it comes from doesn't define.
+func UndefinedModuleValueMessage(name, from string) string {
+	return "value '" + name + "' is not defined in '" + from + "'"
+}
+
+// UnresolvedModuleMessage returns a diagnostic message for a
+// composes or @value import from a module that can't be read.
+func UnresolvedModuleMessage(from string) string {
+	return "cannot find module '" + from + "'"
+}
+
+// UnresolvedImportMessage returns a diagnostic message for an
+// @import whose stylesheet can't be found.
+func UnresolvedImportMessage(target string) string {
+	return UnresolvedImportPrefix + target + "'"
+}
+
+// MissingFileMessage returns a diagnostic message for a url()
+// whose file doesn't exist.
+func MissingFileMessage(target string) string {
+	return MissingFilePrefix + target + "'"
+}
+
+// ImportCycleMessage returns a diagnostic message for an @import
+// that leads back to the importing stylesheet through chain, the
+// names of the stylesheets from the importer back to it.
+func ImportCycleMessage(chain []string) string {
+	return "import cycle: " + strings.Join(chain, " -> ")
+}
+
+// UnusedModuleClassMessage returns a diagnostic message for a
+// class of a CSS module that no importer uses.
+func UnusedModuleClassMessage(name string) string {
+	return "class '" + name + "' is not used by any importer of this module"
+}
+
+// UndefinedVariableMessage returns a diagnostic message for a
+// var() reference to a custom property defined nowhere in the
+// workspace.
+func UndefinedVariableMessage(name string) string {
+	return "undefined custom property '" + name + "'"
+}
+
+// LowContrastMessage returns a diagnostic message for a color
+// whose contrast with the background it is shown over falls
+// short of what the conformance level requires.
+func LowContrastMessage(contrast, background, level, required string) string {
+	return "low contrast " + contrast + " with " + background +
+		"; " + level + " needs " + required
+}
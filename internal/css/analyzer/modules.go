@@ -0,0 +1,462 @@
+package analyzer
+
+import (
+	"path"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/css-lsp/internal/css/scanner"
+)
+
+// composesProperty is the CSS Modules property that makes a class
+// include the classes it names.
+const composesProperty = "composes"
+
+// valueAtRule is the CSS Modules at-rule defining and importing
+// constants.
+const valueAtRule = "value"
+
+// composesGlobal is the "from" of composes declarations naming
+// global classes.
+const composesGlobal = "global"
+
+// IsModule reports whether the file at uri or path is a CSS module
+// by the usual naming convention, such as card.module.css.
+func IsModule(uri string) bool {
+	base := path.Base(uri)
+	return strings.HasSuffix(strings.TrimSuffix(base, path.Ext(base)), ".module")
+}
+
+// Module is what a CSS module defines and imports.
+type Module struct {
+	// Classes maps each local class name to the spans of its names
+	// in selectors, in document order. Classes inside :global()
+	// or after :global are not local.
+	Classes map[string][]Location
+	// Values are the @value definitions and imports.
+	Values []ModuleValue
+	// Composes are the composes declarations.
+	Composes []Composition
+}
+
+// ModuleValue is a name defined or imported by an @value rule.
+type ModuleValue struct {
+	// Name and its span.
+	Name       string
+	Start, End int
+	// Value is the raw value of a definition.
+	Value string
+	// From is the path an import reads from, and "" for a
+	// definition. Imported is the name there, which differs from
+	// Name for "x as y".
+	From     string
+	Imported string
+	// FromStart and FromEnd span the path string, quotes included.
+	FromStart, FromEnd int
+}
+
+// Composition is a composes declaration.
+type Composition struct {
+	// Names are the ident tokens of the composed classes.
+	Names []scanner.Token
+	// From is the path of the module the classes come from,
+	// composesGlobal for global classes, and "" for classes of
+	// this module.
+	From string
+	// FromStart and FromEnd span the path string, quotes included.
+	FromStart, FromEnd int
+	// Class is the class of the rule holding the declaration, or
+	// "" if its selector isn't a single local class.
+	Class string
+	Decl  *parser.Declaration
+}
+
+// ParseModule collects the local classes, values and compositions
+// of the CSS module ss.
+func ParseModule(ss *parser.Stylesheet, src []byte) *Module {
+	m := &Module{Classes: make(map[string][]Location)}
+	if ss == nil {
+		return m
+	}
+	parser.Walk(ss, func(n parser.Node) bool {
+		switch n := n.(type) {
+		case *parser.AtRule:
+			if strings.EqualFold(n.Name, valueAtRule) {
+				m.Values = append(m.Values, parseValueRule(n, src)...)
+			}
+		case *parser.Ruleset:
+			if n.Selectors == nil {
+				return true
+			}
+			for _, sel := range n.Selectors.Selectors {
+				walkSelectorNames(sel, true, func(name selectorName) {
+					if name.class && name.local {
+						m.Classes[name.tok.Value] = append(m.Classes[name.tok.Value],
+							Location{StartPos: name.tok.Offset, EndPos: name.tok.End})
+					}
+				})
+			}
+			class := singleClass(n)
+			for _, decl := range n.Declarations() {
+				if c, ok := parseComposes(decl); ok {
+					c.Class = class
+					m.Composes = append(m.Composes, c)
+				}
+			}
+		}
+		return true
+	})
+	return m
+}
+
+// Definition returns the span of the first local class or @value
+// definition or import named name.
+func (m *Module) Definition(name string, value bool) (Location, bool) {
+	if !value {
+		locs := m.Classes[name]
+		if len(locs) == 0 {
+			return Location{}, false
+		}
+		return locs[0], true
+	}
+	v, ok := m.Value(name)
+	return Location{StartPos: v.Start, EndPos: v.End}, ok
+}
+
+// Value returns the @value named name.
+func (m *Module) Value(name string) (ModuleValue, bool) {
+	for _, v := range m.Values {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return ModuleValue{}, false
+}
+
+// HasClass reports whether the module defines the local class.
+func (m *Module) HasClass(name string) bool {
+	return len(m.Classes[name]) > 0
+}
+
+// composedLocally reports whether a composes declaration of the
+// module names its own class name.
+func (m *Module) composedLocally(name string) bool {
+	for _, c := range m.Composes {
+		if c.From != "" {
+			continue
+		}
+		for _, tok := range c.Names {
+			if tok.Value == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectorName is a class or id in a selector.
+type selectorName struct {
+	// tok is the name's ident or hash token; start includes the
+	// "." of a class.
+	tok   scanner.Token
+	start int
+	class bool
+	// local is set in CSS modules for names not made global by
+	// :global.
+	local bool
+}
+
+// walkSelectorNames calls fn for each class and id in sel. With
+// modules, :global(...) and :local(...) scope the names inside
+// them, and a bare :global or :local the rest of the selector.
+func walkSelectorNames(
+	sel *parser.Selector,
+	modules bool,
+	fn func(selectorName),
+) {
+	local := modules
+	// scopes holds the enclosing modes, one for every open
+	// parenthesis; only :global( and :local( change the mode.
+	var scopes []bool
+	parts := sel.Parts
+	for i := 0; i < len(parts); i++ {
+		tok := parts[i].Token
+		switch {
+		case tok.Kind == scanner.Colon && i+1 < len(parts) && modules:
+			next := parts[i+1].Token
+			name := strings.ToLower(next.Value)
+			if name != "global" && name != "local" {
+				continue
+			}
+			switch next.Kind {
+			case scanner.Function:
+				scopes = append(scopes, local)
+				local = name == "local"
+				i++
+			case scanner.Ident:
+				local = name == "local"
+				i++
+			}
+		case tok.Kind == scanner.Function || tok.Kind == scanner.ParenOpen:
+			scopes = append(scopes, local)
+		case tok.Kind == scanner.ParenClose:
+			if len(scopes) > 0 {
+				local = scopes[len(scopes)-1]
+				scopes = scopes[:len(scopes)-1]
+			}
+		case tok.Kind == scanner.Hash:
+			fn(selectorName{tok: tok, start: tok.Offset, local: local})
+		case isClassDot(parts, i):
+			fn(selectorName{
+				tok:   parts[i+1].Token,
+				start: tok.Offset,
+				class: true,
+				local: local,
+			})
+			i++
+		}
+	}
+}
+
+// singleClass returns the class of a ruleset whose selector is a
+// single local class, such as .card, and "" otherwise.
+func singleClass(rs *parser.Ruleset) string {
+	if rs.Selectors == nil || len(rs.Selectors.Selectors) != 1 {
+		return ""
+	}
+	parts := rs.Selectors.Selectors[0].Parts
+	if len(parts) != 2 || !isClassDot(parts, 0) {
+		return ""
+	}
+	return parts[1].Token.Value
+}
+
+// parseComposes reads a composes declaration: class names,
+// optionally followed by "from" and a path string or global.
+func parseComposes(decl *parser.Declaration) (Composition, bool) {
+	if !strings.EqualFold(decl.Property.Value, composesProperty) {
+		return Composition{}, false
+	}
+	c := Composition{Decl: decl}
+	if decl.Value == nil {
+		return c, true
+	}
+	tokens := significantTokens(decl.Value.Tokens)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Kind == scanner.Ident && tok.Value == "from" && i+1 < len(tokens) {
+			from := tokens[i+1]
+			switch {
+			case from.Kind == scanner.String:
+				c.From = from.Value
+			case from.Kind == scanner.Ident && from.Value == composesGlobal:
+				c.From = composesGlobal
+			default:
+				continue
+			}
+			c.FromStart, c.FromEnd = from.Offset, from.End
+			break
+		}
+		if tok.Kind == scanner.Ident {
+			c.Names = append(c.Names, tok)
+		}
+	}
+	return c, true
+}
+
+// parseValueRule reads an @value rule: "name: value" defines a
+// value, and "a, b as c from "path"" imports some.
+func parseValueRule(rule *parser.AtRule, src []byte) []ModuleValue {
+	tokens := significantTokens(rule.Prelude)
+	if len(tokens) >= 2 && tokens[0].Kind == scanner.Ident &&
+		tokens[1].Kind == scanner.Colon {
+		name := tokens[0]
+		v := ModuleValue{Name: name.Value, Start: name.Offset, End: name.End}
+		if len(tokens) > 2 {
+			start, end := tokens[2].Offset, tokens[len(tokens)-1].End
+			if end <= len(src) {
+				v.Value = strings.TrimSpace(string(src[start:end]))
+			}
+		}
+		return []ModuleValue{v}
+	}
+
+	n := len(tokens)
+	if n < 3 || tokens[n-2].Kind != scanner.Ident || tokens[n-2].Value != "from" ||
+		tokens[n-1].Kind != scanner.String {
+		return nil
+	}
+	from := tokens[n-1]
+	var values []ModuleValue
+	for i := 0; i < n-2; i++ {
+		tok := tokens[i]
+		if tok.Kind != scanner.Ident {
+			continue
+		}
+		v := ModuleValue{
+			Name:      tok.Value,
+			Start:     tok.Offset,
+			End:       tok.End,
+			From:      from.Value,
+			Imported:  tok.Value,
+			FromStart: from.Offset,
+			FromEnd:   from.End,
+		}
+		if i+2 < n-2 && tokens[i+1].Value == "as" && tokens[i+2].Kind == scanner.Ident {
+			alias := tokens[i+2]
+			v.Name, v.Start, v.End = alias.Value, alias.Offset, alias.End
+			i += 2
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// significantTokens returns tokens without whitespace and
+// comments.
+func significantTokens(tokens []scanner.Token) []scanner.Token {
+	out := make([]scanner.Token, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.Kind != scanner.Whitespace && tok.Kind != scanner.Comment {
+			out = append(out, tok)
+		}
+	}
+	return out
+}
+
+// ModuleReference is a class or @value name at a position in a
+// CSS module.
+type ModuleReference struct {
+	// Name and the span of the occurrence.
+	Name       string
+	Start, End int
+	// Value is set for @value names, and unset for classes.
+	Value bool
+	// From is the path of the module the name is defined in, as
+	// written in the file, and "" for names of this module.
+	// Imported is the name there.
+	From     string
+	Imported string
+}
+
+// ModuleReferenceAt returns the class or @value name at offset in
+// the CSS module ss: a local class in a selector or composes
+// declaration, or a value that is defined, imported or used in a
+// declaration.
+func ModuleReferenceAt(
+	ss *parser.Stylesheet,
+	src []byte,
+	offset int,
+) (ModuleReference, bool) {
+	m := ParseModule(ss, src)
+	within := func(start, end int) bool {
+		return offset >= start && offset <= end
+	}
+
+	for name, locs := range m.Classes {
+		for _, loc := range locs {
+			if within(loc.StartPos, loc.EndPos) {
+				return ModuleReference{
+					Name: name, Start: loc.StartPos, End: loc.EndPos,
+				}, true
+			}
+		}
+	}
+	for _, c := range m.Composes {
+		for _, tok := range c.Names {
+			if within(tok.Offset, tok.End) {
+				ref := ModuleReference{
+					Name: tok.Value, Start: tok.Offset, End: tok.End,
+				}
+				switch c.From {
+				case composesGlobal:
+					return ModuleReference{}, false
+				case "":
+				default:
+					ref.From, ref.Imported = c.From, tok.Value
+				}
+				return ref, true
+			}
+		}
+	}
+	for _, v := range m.Values {
+		if within(v.Start, v.End) {
+			return ModuleReference{
+				Name: v.Name, Start: v.Start, End: v.End, Value: true,
+				From: v.From, Imported: v.Imported,
+			}, true
+		}
+	}
+
+	// Values used in declarations resolve like the @value naming
+	// them.
+	var ref ModuleReference
+	found := false
+	parser.Walk(ss, func(n parser.Node) bool {
+		if found || n.Offset() > offset || n.End() < offset {
+			return false
+		}
+		decl, ok := n.(*parser.Declaration)
+		if !ok || decl.Value == nil {
+			return true
+		}
+		for _, tok := range decl.Value.Tokens {
+			if tok.Kind != scanner.Ident || !within(tok.Offset, tok.End) {
+				continue
+			}
+			if v, ok := m.Value(tok.Value); ok {
+				ref = ModuleReference{
+					Name: v.Name, Start: tok.Offset, End: tok.End, Value: true,
+					From: v.From, Imported: v.Imported,
+				}
+				found = true
+			}
+		}
+		return !found
+	})
+	return ref, found
+}
+
+// ModuleClassEdits returns the edits renaming the local class name
+// to newName throughout the CSS module ss: in selectors and in the
+// module's own composes declarations.
+func ModuleClassEdits(
+	ss *parser.Stylesheet,
+	src []byte,
+	name, newName string,
+) []RenameEdit {
+	m := ParseModule(ss, src)
+	var edits []RenameEdit
+	for _, loc := range m.Classes[name] {
+		edits = append(edits, RenameEdit{
+			StartPos: loc.StartPos, EndPos: loc.EndPos, NewText: newName,
+		})
+	}
+	for _, c := range m.Composes {
+		if c.From != "" {
+			continue
+		}
+		for _, tok := range c.Names {
+			if tok.Value == name {
+				edits = append(edits, RenameEdit{
+					StartPos: tok.Offset, EndPos: tok.End, NewText: newName,
+				})
+			}
+		}
+	}
+	return edits
+}
+
+// ModuleIndex resolves the imports of the CSS module being
+// analyzed and reports how the workspace uses it.
+type ModuleIndex interface {
+	// ModuleImported reports whether any indexed file imports the
+	// module. Until one does, nothing is known about its usage.
+	ModuleImported() bool
+	// ModuleClassUsed reports whether an indexed file uses the
+	// module's class name, from script or by composing it.
+	ModuleClassUsed(name string) bool
+	// ResolveModule returns the module at path, relative to the
+	// one being analyzed, or false if it can't be read.
+	ResolveModule(path string) (*Module, bool)
+}
@@ -0,0 +1,222 @@
+package analyzer
+
+import (
+	"slices"
+	"testing"
+)
+
+const moduleSrc = `@value primary: #f00;
+@value accent, small as narrow from "./theme.module.css";
+.base { color: primary; }
+.card { composes: base; composes: shadow from "./other.module.css"; }
+.title:global(.dark) :local(.inner), :global .legacy .x { color: red; }
+#main .card { composes: base from global; }
+`
+
+func TestParseModule(t *testing.T) {
+	src := []byte(moduleSrc)
+	m := ParseModule(parseCSS(t, src), src)
+
+	var classes []string
+	for name := range m.Classes {
+		classes = append(classes, name)
+	}
+	slices.Sort(classes)
+	if want := []string{"base", "card", "inner", "title"}; !slices.Equal(classes, want) {
+		t.Errorf("classes = %q, want %q", classes, want)
+	}
+	if len(m.Classes["card"]) != 2 {
+		t.Errorf("card occurrences = %v", m.Classes["card"])
+	}
+
+	if len(m.Values) != 3 {
+		t.Fatalf("values = %+v", m.Values)
+	}
+	if v := m.Values[0]; v.Name != "primary" || v.Value != "#f00" || v.From != "" {
+		t.Errorf("definition = %+v", v)
+	}
+	if v := m.Values[2]; v.Name != "narrow" || v.Imported != "small" ||
+		v.From != "./theme.module.css" ||
+		string(src[v.FromStart:v.FromEnd]) != `"./theme.module.css"` {
+		t.Errorf("aliased import = %+v", v)
+	}
+
+	if len(m.Composes) != 3 {
+		t.Fatalf("composes = %+v", m.Composes)
+	}
+	if c := m.Composes[0]; c.Class != "card" || c.From != "" ||
+		len(c.Names) != 1 || c.Names[0].Value != "base" {
+		t.Errorf("local composes = %+v", c)
+	}
+	if c := m.Composes[1]; c.From != "./other.module.css" ||
+		c.Names[0].Value != "shadow" {
+		t.Errorf("imported composes = %+v", c)
+	}
+	if c := m.Composes[2]; c.Class != "" || c.From != composesGlobal {
+		t.Errorf("global composes = %+v", c)
+	}
+}
+
+func TestIsModule(t *testing.T) {
+	tests := map[string]bool{
+		"file:///a/card.module.css": true,
+		"/a/card.module.pcss":       true,
+		"/a/card.css":               false,
+		"/a/module.css":             false,
+	}
+	for uri, want := range tests {
+		if got := IsModule(uri); got != want {
+			t.Errorf("IsModule(%q) = %v, want %v", uri, got, want)
+		}
+	}
+}
+
+// moduleIndex is a ModuleIndex backed by fixed modules.
+type moduleIndex struct {
+	imported bool
+	used     []string
+	modules  map[string]*Module
+}
+
+func (m moduleIndex) ModuleImported() bool { return m.imported }
+
+func (m moduleIndex) ModuleClassUsed(name string) bool {
+	return slices.Contains(m.used, name)
+}
+
+func (m moduleIndex) ResolveModule(path string) (*Module, bool) {
+	mod, ok := m.modules[path]
+	return mod, ok
+}
+
+func TestAnalyzeModule(t *testing.T) {
+	src := []byte(moduleSrc + `.orphan { composes: missing; }`)
+	ss := parseCSS(t, src)
+	other := []byte(`.shadow { }`)
+	theme := []byte(`@value accent: blue;`)
+	idx := moduleIndex{
+		imported: true,
+		used:     []string{"card", "title"},
+		modules: map[string]*Module{
+			"./other.module.css": ParseModule(parseCSS(t, other), other),
+			"./theme.module.css": ParseModule(parseCSS(t, theme), theme),
+		},
+	}
+	diags := Analyze(ss, src, LintOptions{
+		CSSModules:      true,
+		UnusedSelectors: UnusedSelectorWarn,
+	}, Indexes{Modules: idx})
+
+	for _, msg := range []string{
+		UnknownPropertyMessage("composes"),
+		UnknownAtRuleMessage("value"),
+		UnknownValueMessage("primary", "color"),
+		UndefinedModuleClassMessage("shadow", "./other.module.css"),
+		UndefinedModuleValueMessage("accent", "./theme.module.css"),
+		UnusedModuleClassMessage("card"),
+		UnusedModuleClassMessage("base"), // composed by .card
+		UnusedClassMessage("legacy"),     // global, and no markup
+	} {
+		if _, ok := findDiagnostic(diags, msg); ok {
+			t.Errorf("unexpected %q", msg)
+		}
+	}
+	for _, msg := range []string{
+		ComposesSelectorMsg,
+		UndefinedModuleClassMessage("missing", ""),
+		UndefinedModuleValueMessage("small", "./theme.module.css"),
+		UnusedModuleClassMessage("inner"),
+		UnusedModuleClassMessage("orphan"),
+	} {
+		if _, ok := findDiagnostic(diags, msg); !ok {
+			t.Errorf("expected %q in %+v", msg, diags)
+		}
+	}
+
+	delete(idx.modules, "./other.module.css")
+	diags = Analyze(ss, src, LintOptions{CSSModules: true}, Indexes{Modules: idx})
+	d, ok := findDiagnostic(diags, UnresolvedModuleMessage("./other.module.css"))
+	if !ok || d.StartChar != indexOf(src, `"./other`)-indexOf(src, ".card {") {
+		t.Errorf("unresolved module diagnostic = %+v, %v", d, ok)
+	}
+	opts := LintOptions{CSSModules: true, ModuleReferences: ModuleReferenceIgnore}
+	diags = Analyze(ss, src, opts, Indexes{Modules: idx})
+	for _, msg := range []string{
+		UnresolvedModuleMessage("./other.module.css"),
+		UndefinedModuleClassMessage("missing", ""),
+	} {
+		if _, ok := findDiagnostic(diags, msg); ok {
+			t.Errorf("unexpected %q with module references ignored", msg)
+		}
+	}
+	if _, ok := findDiagnostic(diags, ComposesSelectorMsg); !ok {
+		t.Error("composes misuse should be reported regardless")
+	}
+
+	// Packages are left to the bundler.
+	pkg := []byte(`.a { composes: b from "pkg/b.module.css"; }`)
+	diags = Analyze(
+		parseCSS(t, pkg), pkg, LintOptions{CSSModules: true}, Indexes{Modules: idx},
+	)
+	if _, ok := findDiagnostic(diags, UnresolvedModuleMessage("pkg/b.module.css")); ok {
+		t.Error("package imports should not be resolved")
+	}
+
+	// Outside module mode, composes is an unknown property.
+	diags = Analyze(ss, src, LintOptions{}, Indexes{})
+	if _, ok := findDiagnostic(diags, UnknownPropertyMessage("composes")); !ok {
+		t.Error("composes should be unknown outside CSS modules")
+	}
+}
+
+func TestModuleReferenceAt(t *testing.T) {
+	src := []byte(moduleSrc)
+	ss := parseCSS(t, src)
+	tests := []struct {
+		at   string
+		want ModuleReference
+	}{
+		{".card {", ModuleReference{Name: "card"}},
+		{"base; composes", ModuleReference{Name: "base"}},
+		{"shadow", ModuleReference{
+			Name: "shadow", From: "./other.module.css", Imported: "shadow",
+		}},
+		{"primary; }", ModuleReference{Name: "primary", Value: true}},
+		{"narrow", ModuleReference{
+			Name: "narrow", Value: true,
+			From: "./theme.module.css", Imported: "small",
+		}},
+	}
+	for _, tt := range tests {
+		offset := indexOf(src, tt.at)
+		if tt.at[0] == '.' {
+			offset++
+		}
+		got, ok := ModuleReferenceAt(ss, src, offset+1)
+		got.Start, got.End = 0, 0
+		if !ok || got != tt.want {
+			t.Errorf("at %q: %+v, %v; want %+v", tt.at, got, ok, tt.want)
+		}
+	}
+	if _, ok := ModuleReferenceAt(ss, src, indexOf(src, "legacy")+1); ok {
+		t.Error("global classes are not module references")
+	}
+}
+
+func TestModuleClassEdits(t *testing.T) {
+	src := []byte(moduleSrc)
+	edits := ModuleClassEdits(parseCSS(t, src), src, "base", "root")
+	out := string(src)
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		out = out[:e.StartPos] + e.NewText + out[e.EndPos:]
+	}
+	want := []byte(moduleSrc)
+	want = slices.Concat(want[:indexOf(want, ".base")+1], []byte("root"),
+		want[indexOf(want, ".base")+5:])
+	want = slices.Concat(want[:indexOf(want, "composes: base;")+10], []byte("root"),
+		want[indexOf(want, "composes: base;")+14:])
+	if out != string(want) {
+		t.Errorf("renamed:\n%s\nwant:\n%s", out, want)
+	}
+}
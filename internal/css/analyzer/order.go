@@ -0,0 +1,424 @@
+package analyzer
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+// OrderStrategy selects how the declarations of a block are
+// sorted.
+type OrderStrategy int
+
+const (
+	// OrderNone leaves declarations as written (default).
+	OrderNone OrderStrategy = iota
+	// OrderAlphabetical sorts declarations by property name.
+	OrderAlphabetical
+	// OrderConcentric sorts declarations from the outside of the
+	// box in: positioning, box model, typography, visual, then
+	// everything else.
+	OrderConcentric
+	// OrderCustom sorts declarations by a list of properties.
+	OrderCustom
+)
+
+// DeclarationOrder configures how declarations are sorted. Custom
+// properties always come first, and vendor-prefixed properties
+// just before the standard property. Declarations that compare
+// equal keep their order, and nested rules never move. A blank
+// line starts a new group of declarations, sorted on its own,
+// wherever blank lines are kept: by the out-of-order check and its
+// fix, and by the formatter in preserve and detect modes.
+type DeclarationOrder struct {
+	Strategy OrderStrategy
+	// Properties is the order of OrderCustom. A property not
+	// listed is placed by its shorthand, so "margin" places
+	// "margin-top"; the rest follow every listed property.
+	Properties []string
+}
+
+// concentricGroups are the properties of each group of
+// OrderConcentric, in order. Longhands not listed are placed by
+// their shorthand.
+var concentricGroups = [][]string{
+	// Positioning.
+	{
+		"position", "inset", "inset-block", "inset-inline", "top",
+		"right", "bottom", "left", "z-index", "float", "clear",
+	},
+	// Box model.
+	{
+		"display", "flex", "flex-direction", "flex-wrap", "flex-flow",
+		"flex-grow", "flex-shrink", "flex-basis", "grid", "grid-area",
+		"grid-template", "grid-template-areas", "grid-template-rows",
+		"grid-template-columns", "grid-row", "grid-column",
+		"grid-auto-flow", "grid-auto-rows", "grid-auto-columns", "gap",
+		"row-gap", "column-gap", "place-content", "place-items",
+		"place-self", "align-content", "align-items", "align-self",
+		"justify-content", "justify-items", "justify-self", "order",
+		"columns", "box-sizing", "margin", "margin-block",
+		"margin-inline", "outline", "border", "border-block",
+		"border-inline", "border-width", "border-style", "border-color",
+		"border-radius", "padding", "padding-block", "padding-inline",
+		"width", "min-width", "max-width", "inline-size",
+		"min-inline-size", "max-inline-size", "height", "min-height",
+		"max-height", "block-size", "min-block-size", "max-block-size",
+		"aspect-ratio", "overflow", "overflow-x", "overflow-y",
+		"contain", "container",
+	},
+	// Typography.
+	{
+		"color", "font", "font-family", "font-size", "font-style",
+		"font-weight", "font-variant", "font-stretch", "line-height",
+		"letter-spacing", "word-spacing", "text-align", "text-indent",
+		"text-transform", "text-decoration", "text-shadow",
+		"text-overflow", "text-wrap", "white-space", "word-break",
+		"overflow-wrap", "hyphens", "tab-size", "vertical-align",
+		"direction", "writing-mode", "list-style", "quotes", "content",
+	},
+	// Visual.
+	{
+		"background", "background-color", "background-image",
+		"background-position", "background-size", "background-repeat",
+		"box-shadow", "opacity", "visibility", "filter",
+		"backdrop-filter", "mix-blend-mode", "clip-path", "mask",
+		"object-fit", "object-position", "transform",
+		"transform-origin", "transition", "animation", "cursor",
+		"pointer-events", "user-select", "appearance", "resize",
+		"will-change",
+	},
+}
+
+// concentricRanks maps each property of concentricGroups to its
+// position.
+var concentricRanks = func() map[string]int {
+	ranks := make(map[string]int)
+	for _, group := range concentricGroups {
+		for _, p := range group {
+			ranks[p] = len(ranks)
+		}
+	}
+	return ranks
+}()
+
+// Enabled reports whether declarations are sorted at all.
+func (o DeclarationOrder) Enabled() bool {
+	return o.Strategy != OrderNone &&
+		(o.Strategy != OrderCustom || len(o.Properties) > 0)
+}
+
+// comparer returns the function that orders two properties.
+func (o DeclarationOrder) comparer() func(a, b string) int {
+	ranks := concentricRanks
+	if o.Strategy == OrderCustom {
+		ranks = make(map[string]int, len(o.Properties))
+		for i, p := range o.Properties {
+			p = strings.ToLower(p)
+			if _, ok := ranks[p]; !ok {
+				ranks[p] = i
+			}
+		}
+	}
+	return func(a, b string) int {
+		a, b = strings.ToLower(a), strings.ToLower(b)
+		if c := cmp.Compare(
+			boolRank(!IsCustomProperty(a)),
+			boolRank(!IsCustomProperty(b)),
+		); c != 0 || IsCustomProperty(a) {
+			return c
+		}
+		baseA, baseB := unprefixed(a), unprefixed(b)
+		var c int
+		if o.Strategy == OrderAlphabetical {
+			c = strings.Compare(baseA, baseB)
+		} else {
+			c = cmp.Compare(shorthandRank(ranks, baseA), shorthandRank(ranks, baseB))
+		}
+		if c != 0 || baseA != baseB {
+			return c
+		}
+		return cmp.Compare(boolRank(baseA == a), boolRank(baseB == b))
+	}
+}
+
+// boolRank returns 1 for true and 0 for false.
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// unprefixed returns name without its vendor prefix.
+func unprefixed(name string) string {
+	for _, p := range vendorPrefixes {
+		if rest, ok := strings.CutPrefix(name, p); ok {
+			return rest
+		}
+	}
+	return name
+}
+
+// shorthandRank returns the rank of name, or of the nearest
+// shorthand of it that has one, or else one past every rank.
+func shorthandRank(ranks map[string]int, name string) int {
+	for {
+		if r, ok := ranks[name]; ok {
+			return r
+		}
+		i := strings.LastIndexByte(name, '-')
+		if i <= 0 {
+			return len(ranks)
+		}
+		name = name[:i]
+	}
+}
+
+// orderItem is a declaration with the comments that belong to it:
+// those on the lines just above it and the one following it on its
+// line.
+type orderItem struct {
+	decl  *parser.Declaration
+	nodes []parser.Node
+}
+
+// orderRun is a run of declarations between nested rules, split
+// into items, with the comments that follow the last of them, and
+// whether a blank line comes before it.
+type orderRun struct {
+	items []orderItem
+	tail  []parser.Node
+	blank bool
+}
+
+// orderRuns splits the children of a block into runs of
+// declarations, returning them with the nested rules each run
+// precedes. With groups set, a blank line also ends a run, and
+// the rule it precedes is nil.
+func orderRuns(
+	src []byte,
+	children []parser.Node,
+	groups bool,
+) ([]orderRun, []parser.Node) {
+	var runs []orderRun
+	var rules []parser.Node
+	var run orderRun
+	var prev parser.Node
+	for _, child := range children {
+		gap := prev != nil && blankLine(src, prev.End(), child.Offset())
+		prev = child
+		switch child.(type) {
+		case *parser.Declaration, *parser.Comment:
+			switch {
+			case len(run.items) == 0 && len(run.tail) == 0:
+				run.blank = gap
+			case groups && gap:
+				runs = append(runs, run)
+				rules = append(rules, nil)
+				run = orderRun{blank: true}
+			}
+		}
+		switch n := child.(type) {
+		case *parser.Declaration:
+			run.items = append(run.items, orderItem{
+				decl:  n,
+				nodes: append(run.tail, n),
+			})
+			run.tail = nil
+		case *parser.Comment:
+			if k := len(run.items) - 1; k >= 0 && run.tail == nil &&
+				sameLine(src, run.items[k].decl.End(), n.StartPos) {
+				run.items[k].nodes = append(run.items[k].nodes, n)
+				continue
+			}
+			run.tail = append(run.tail, n)
+		default:
+			runs = append(runs, run)
+			rules = append(rules, child)
+			run = orderRun{}
+		}
+	}
+	return append(runs, run), rules
+}
+
+// sameLine reports whether no line break lies between start and
+// end.
+func sameLine(src []byte, start, end int) bool {
+	return start <= end && end <= len(src) &&
+		!bytes.ContainsRune(src[start:end], '\n')
+}
+
+// blankLine reports whether a blank line lies between start and
+// end.
+func blankLine(src []byte, start, end int) bool {
+	return start <= end && end <= len(src) &&
+		bytes.Count(src[start:end], []byte{'\n'}) >= 2
+}
+
+// sorted returns the items of the run in order, and whether that
+// differs from the order they are in.
+func (r orderRun) sorted(order DeclarationOrder) ([]orderItem, bool) {
+	compare := order.comparer()
+	items := slices.Clone(r.items)
+	slices.SortStableFunc(items, func(a, b orderItem) int {
+		return compare(a.decl.Property.Value, b.decl.Property.Value)
+	})
+	moved := !slices.EqualFunc(items, r.items, func(a, b orderItem) bool {
+		return a.decl == b.decl
+	})
+	return items, moved
+}
+
+// orderChildren returns the children of a block with the
+// declarations sorted, and whether any moved. With groups set,
+// declarations are sorted within the groups blank lines separate,
+// and breaks holds the first node of each group that a blank line
+// comes before.
+func orderChildren(
+	src []byte,
+	children []parser.Node,
+	order DeclarationOrder,
+	groups bool,
+) ([]parser.Node, map[parser.Node]bool, bool) {
+	if !order.Enabled() {
+		return children, nil, false
+	}
+	runs, rules := orderRuns(src, children, groups)
+	out := make([]parser.Node, 0, len(children))
+	var breaks map[parser.Node]bool
+	moved := false
+	for i, run := range runs {
+		items, m := run.sorted(order)
+		moved = moved || m
+		start := len(out)
+		for _, it := range items {
+			out = append(out, it.nodes...)
+		}
+		out = append(out, run.tail...)
+		if groups && run.blank && len(out) > start {
+			if breaks == nil {
+				breaks = make(map[parser.Node]bool)
+			}
+			breaks[out[start]] = true
+		}
+		if i < len(rules) && rules[i] != nil {
+			out = append(out, rules[i])
+		}
+	}
+	if !moved {
+		return children, nil, false
+	}
+	return out, breaks, true
+}
+
+// OrderFix is an edit that puts the declarations of a run in
+// order, and the declaration that most needs to move, which the
+// out-of-order diagnostic is reported at.
+type OrderFix struct {
+	FormatEdit
+	Decl   *parser.Declaration
+	Before *parser.Declaration
+}
+
+// DeclarationOrderFixes returns a fix for every run of
+// declarations in the stylesheet that is out of order. A fix
+// moves the source of declarations, with their comments, leaving
+// the space between them and all else alone; a semicolon is added
+// to a declaration that lacks one.
+func DeclarationOrderFixes(
+	ss *parser.Stylesheet,
+	src []byte,
+	order DeclarationOrder,
+) []OrderFix {
+	if ss == nil || !order.Enabled() {
+		return nil
+	}
+	var fixes []OrderFix
+	parser.Walk(ss, func(n parser.Node) bool {
+		var children []parser.Node
+		switch n := n.(type) {
+		case *parser.Stylesheet:
+			children = n.Children
+		case *parser.Ruleset:
+			children = n.Children
+		case *parser.AtRule:
+			return true
+		default:
+			return false
+		}
+		runs, _ := orderRuns(src, children, true)
+		for _, run := range runs {
+			if fix, ok := run.fix(src, order); ok {
+				fixes = append(fixes, fix)
+			}
+		}
+		return true
+	})
+	return fixes
+}
+
+// fix returns the fix that puts the run in order, if it isn't.
+func (r orderRun) fix(src []byte, order DeclarationOrder) (OrderFix, bool) {
+	items, moved := r.sorted(order)
+	if !moved {
+		return OrderFix{}, false
+	}
+	k := 0
+	for items[k].decl == r.items[k].decl {
+		k++
+	}
+
+	var b strings.Builder
+	for i, it := range items {
+		if i > 0 {
+			b.Write(src[r.items[i-1].end(src):r.items[i].start()])
+		}
+		start, end := it.start(), it.end(src)
+		if it.decl.Semicolon {
+			b.Write(src[start:end])
+		} else {
+			b.Write(src[start:it.decl.End()])
+			b.WriteByte(';')
+			b.Write(src[it.decl.End():end])
+		}
+	}
+	return OrderFix{
+		FormatEdit: FormatEdit{
+			StartPos: r.items[0].start(),
+			EndPos:   r.items[len(r.items)-1].end(src),
+			NewText:  b.String(),
+		},
+		Decl:   items[k].decl,
+		Before: r.items[k].decl,
+	}, true
+}
+
+// start returns where the source of the item starts.
+func (it orderItem) start() int {
+	return it.nodes[0].Offset()
+}
+
+// end returns where the source of the item ends: after its
+// trailing comment or else the semicolon ending the declaration.
+func (it orderItem) end(src []byte) int {
+	last := it.nodes[len(it.nodes)-1]
+	if last != parser.Node(it.decl) {
+		return last.End()
+	}
+	end := it.decl.End()
+	if !it.decl.Semicolon {
+		return end
+	}
+	i := end
+	for i < len(src) && isFormatSpace(src[i]) {
+		i++
+	}
+	if i < len(src) && src[i] == ';' {
+		return i + 1
+	}
+	return end
+}
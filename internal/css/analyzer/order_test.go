@@ -0,0 +1,246 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+func TestFormatDeclarationOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order DeclarationOrder
+		mode  FormatMode
+		src   string
+		want  string
+	}{
+		{
+			name:  "alphabetical",
+			order: DeclarationOrder{Strategy: OrderAlphabetical},
+			src:   ".a { z-index: 1; color: red; --b: 2; --a: 1; background: blue; }",
+			want: `.a {
+  --b: 2;
+  --a: 1;
+  background: blue;
+  color: red;
+  z-index: 1;
+}
+`,
+		},
+		{
+			name:  "vendor prefixes first",
+			order: DeclarationOrder{Strategy: OrderAlphabetical},
+			src: ".a { transition: none; -webkit-transition: none; " +
+				"appearance: none; -moz-appearance: none; }",
+			want: `.a {
+  -moz-appearance: none;
+  appearance: none;
+  -webkit-transition: none;
+  transition: none;
+}
+`,
+		},
+		{
+			name:  "concentric",
+			order: DeclarationOrder{Strategy: OrderConcentric},
+			src: ".a { color: red; cursor: pointer; padding: 0; " +
+				"margin-top: 1px; position: absolute; display: flex; " +
+				"unknown-thing: 1; top: 0; }",
+			want: `.a {
+  position: absolute;
+  top: 0;
+  display: flex;
+  margin-top: 1px;
+  padding: 0;
+  color: red;
+  cursor: pointer;
+  unknown-thing: 1;
+}
+`,
+		},
+		{
+			name: "custom",
+			order: DeclarationOrder{
+				Strategy:   OrderCustom,
+				Properties: []string{"width", "margin", "color"},
+			},
+			src: ".a { color: red; float: left; margin-left: 0; width: 1px; }",
+			want: `.a {
+  width: 1px;
+  margin-left: 0;
+  color: red;
+  float: left;
+}
+`,
+		},
+		{
+			name:  "comments stay attached",
+			order: DeclarationOrder{Strategy: OrderAlphabetical},
+			src: `.a {
+  /* the text */
+  color: red; /* brand */
+  background: blue;
+  /* trailing */
+}`,
+			want: `.a {
+  background: blue;
+  /* the text */
+  color: red; /* brand */
+  /* trailing */
+}
+`,
+		},
+		{
+			name:  "nested rules stay",
+			order: DeclarationOrder{Strategy: OrderAlphabetical},
+			src: ".a { color: red; & .b { z-index: 1; margin: 0; } " +
+				"background: blue; border: 0; }",
+			want: `.a {
+  color: red;
+
+  & .b {
+    margin: 0;
+    z-index: 1;
+  }
+  background: blue;
+  border: 0;
+}
+`,
+		},
+		{
+			name:  "compact",
+			order: DeclarationOrder{Strategy: OrderAlphabetical},
+			mode:  FormatCompact,
+			src: ".a { color: red; background: blue; }\n" +
+				"@font-face { src: url(a.woff); font-family: A; }",
+			want: ".a { background: blue; color: red; }\n" +
+				"@font-face { font-family: A; src: url(a.woff); }\n",
+		},
+		{
+			name:  "preserve sorts blank-line groups",
+			order: DeclarationOrder{Strategy: OrderAlphabetical},
+			mode:  FormatPreserve,
+			src: `.a {
+  z-index: 1;
+  position: absolute;
+
+  /* text */
+  font-size: 1em;
+  color: red;
+
+  border: 0;
+  & .b { margin: 0; }
+
+  width: 1px;
+  height: 1px;
+}`,
+			want: `.a {
+  position: absolute;
+  z-index: 1;
+
+  color: red;
+  /* text */
+  font-size: 1em;
+
+  border: 0;
+  & .b { margin: 0; }
+
+  height: 1px;
+  width: 1px;
+}
+`,
+		},
+		{
+			name:  "expanded drops blank lines it sorted",
+			order: DeclarationOrder{Strategy: OrderAlphabetical},
+			src:   ".a {\n  color: red;\n\n  background: blue;\n}",
+			want:  ".a {\n  background: blue;\n  color: red;\n}\n",
+		},
+		{
+			name: "no order",
+			src:  ".a { color: red; background: blue; }",
+			want: ".a {\n  color: red;\n  background: blue;\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := []byte(tt.src)
+			ss, _ := parser.Parse(src)
+			got := Format(ss, src, FormatOptions{
+				TabSize:      2,
+				InsertSpaces: true,
+				Mode:         tt.mode,
+				Order:        tt.order,
+			})
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeclarationOrderDiagnostics(t *testing.T) {
+	const src = `.a {
+  color: red;
+  background: blue;
+  & .b { margin: 0; }
+  border: 0;
+  align-items: center
+}
+.c { background: blue; color: red; }
+`
+	ss := parseCSS(t, []byte(src))
+	order := DeclarationOrder{Strategy: OrderAlphabetical}
+	diags := Analyze(ss, []byte(src), LintOptions{
+		OutOfOrder: OutOfOrderWarn,
+		Order:      order,
+	}, Indexes{})
+	var got []string
+	for _, d := range diags {
+		if d.Severity == SeverityWarning {
+			got = append(got, d.Message)
+		}
+	}
+	want := []string{
+		OutOfOrderMessage("background", "color"),
+		OutOfOrderMessage("align-items", "border"),
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("diagnostics = %q, want %q", got, want)
+	}
+
+	if d := Analyze(ss, []byte(src), LintOptions{Order: order}, Indexes{}); len(d) != 0 {
+		t.Errorf("ignored diagnostics = %v", d)
+	}
+
+	fixes := DeclarationOrderFixes(ss, []byte(src), order)
+	if len(fixes) != 2 {
+		t.Fatalf("fixes = %+v", fixes)
+	}
+	out := []byte(src)
+	for i := len(fixes) - 1; i >= 0; i-- {
+		out = []byte(applyFormatEdit(out, fixes[i].FormatEdit))
+	}
+	const fixed = `.a {
+  background: blue;
+  color: red;
+  & .b { margin: 0; }
+  align-items: center;
+  border: 0;
+}
+.c { background: blue; color: red; }
+`
+	if string(out) != fixed {
+		t.Errorf("fixed:\n%s\nwant:\n%s", out, fixed)
+	}
+	ss = parseCSS(t, []byte(fixed))
+	if f := DeclarationOrderFixes(ss, []byte(fixed), order); len(f) != 0 {
+		t.Errorf("fixes after fixing = %+v", f)
+	}
+
+	const grouped = ".a {\n  color: red;\n\n  background: blue;\n}\n"
+	ss = parseCSS(t, []byte(grouped))
+	if f := DeclarationOrderFixes(ss, []byte(grouped), order); len(f) != 0 {
+		t.Errorf("fixes across a blank line = %+v", f)
+	}
+}
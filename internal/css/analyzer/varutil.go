@@ -18,6 +18,23 @@ func IsCustomProperty(name string) bool {
 	return strings.HasPrefix(name, CustomPropertyPrefix)
 }
 
+// VariableIndex reports whether a custom property is defined
+// anywhere in the workspace.
+type VariableIndex interface {
+	HasVariable(name string) bool
+}
+
+// MarkupIndex reports the class names and ids used by the
+// workspace's markup. Until HasMarkup is true, nothing is known
+// about them.
+type MarkupIndex interface {
+	HasMarkup() bool
+	HasClass(name string) bool
+	HasID(name string) bool
+	Classes() []string
+	IDs() []string
+}
+
 // varRef describes a var(--name) reference found in tokens.
 type varRef struct {
 	// identIdx is the index of the --custom-property ident
@@ -69,6 +86,21 @@ func findVarRefs(tokens []scanner.Token) []varRef {
 	return refs
 }
 
+// hasVarFallback reports whether the var() call described by ref
+// has a fallback value after the custom property name.
+func hasVarFallback(tokens []scanner.Token, ref varRef) bool {
+	for _, tok := range tokens[ref.identIdx+1:] {
+		switch tok.Kind {
+		case scanner.Whitespace, scanner.Comment:
+			continue
+		case scanner.Comma:
+			return true
+		}
+		return false
+	}
+	return false
+}
+
 // FindCustomPropertyAt determines the custom property name at
 // the cursor position. Works on both declarations and var()
 // usages.
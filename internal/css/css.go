@@ -3,6 +3,8 @@
 package css
 
 import (
+	"slices"
+
 	"github.com/toba/css-lsp/internal/css/analyzer"
 	"github.com/toba/css-lsp/internal/css/parser"
 )
@@ -19,13 +21,42 @@ func Parse(src []byte) *ParseResult {
 	return &ParseResult{Stylesheet: ss, Errors: errs}
 }
 
-// Diagnostics returns diagnostic messages for the given CSS.
+// Reparse updates a previous parse result after an edit that
+// replaced prev's bytes [edit.Start, edit.End) with
+// edit.NewLen bytes; src is the complete new source. Only the
+// top-level rules touching the edit are parsed again.
+func Reparse(
+	prev *ParseResult,
+	src []byte,
+	edit parser.Edit,
+) *ParseResult {
+	if prev == nil {
+		return Parse(src)
+	}
+	ss, errs := parser.Reparse(prev.Stylesheet, prev.Errors, src, edit)
+	return &ParseResult{Stylesheet: ss, Errors: errs}
+}
+
+// Diagnostics returns diagnostic messages for the given CSS,
+// checked against the workspace through ix.
 func Diagnostics(
 	src []byte,
 	opts analyzer.LintOptions,
+	ix analyzer.Indexes,
 ) ([]analyzer.Diagnostic, *parser.Stylesheet) {
 	result := Parse(src)
-	diags := analyzer.Analyze(result.Stylesheet, src, opts)
+	return ParsedDiagnostics(result, src, opts, ix), result.Stylesheet
+}
+
+// ParsedDiagnostics returns diagnostic messages for CSS that has
+// already been parsed, including the parse errors.
+func ParsedDiagnostics(
+	result *ParseResult,
+	src []byte,
+	opts analyzer.LintOptions,
+	ix analyzer.Indexes,
+) []analyzer.Diagnostic {
+	diags := analyzer.Analyze(result.Stylesheet, src, opts, ix)
 
 	// Add parse errors as diagnostics
 	for _, e := range result.Errors {
@@ -41,20 +72,18 @@ func Diagnostics(
 		})
 	}
 
-	return diags, result.Stylesheet
+	return diags
 }
 
 // Hover returns hover information for the given position.
-// An optional VariableResolver enables cross-file custom
-// property value lookup.
 func Hover(
 	ss *parser.Stylesheet,
 	src []byte,
 	line, char int,
-	resolvers ...analyzer.VariableResolver,
+	opts analyzer.HoverOptions,
 ) analyzer.HoverResult {
 	offset := LineCharToOffset(src, line, char)
-	return analyzer.Hover(ss, src, offset, resolvers...)
+	return analyzer.Hover(ss, src, offset, opts)
 }
 
 // DocumentColors returns all colors found in the CSS document.
@@ -66,14 +95,16 @@ func DocumentColors(
 }
 
 // DocumentColorsResolved returns all colors found in the CSS
-// document, resolving var() references through the resolver.
+// document, resolving var() references through the resolver and
+// taking the colors of light-dark() that scheme asks for.
 func DocumentColorsResolved(
 	ss *parser.Stylesheet,
 	src []byte,
 	resolver analyzer.VariableResolver,
+	scheme analyzer.ColorScheme,
 ) []analyzer.DocumentColor {
 	return analyzer.FindDocumentColorsResolved(
-		ss, src, resolver,
+		ss, src, resolver, scheme,
 	)
 }
 
@@ -85,6 +116,20 @@ func ColorPresentations(
 	return analyzer.ColorPresentation(c)
 }
 
+// ColorPresentationsAt returns the ways to write a color in place
+// of the color between the given positions, with the edits that
+// write each.
+func ColorPresentationsAt(
+	ss *parser.Stylesheet,
+	src []byte,
+	startLine, startChar, endLine, endChar int,
+	c analyzer.Color,
+) []analyzer.ColorPresentationItem {
+	start := LineCharToOffset(src, startLine, startChar)
+	end := LineCharToOffset(src, endLine, endChar)
+	return analyzer.ColorPresentationsAt(ss, src, start, end, c)
+}
+
 // SelectionRange returns the selection range at the given
 // position.
 func SelectionRange(
@@ -117,13 +162,40 @@ func Rename(
 	return analyzer.Rename(ss, src, offset, newName)
 }
 
-// FormatDocument formats the CSS document.
+// FormatDocument formats the CSS document, returning the edits
+// that turn src into the formatted text.
 func FormatDocument(
 	ss *parser.Stylesheet,
 	src []byte,
 	opts analyzer.FormatOptions,
-) string {
-	return analyzer.Format(ss, src, opts)
+) []analyzer.FormatEdit {
+	return analyzer.FormatEdits(ss, src, opts)
+}
+
+// FormatRange formats the rules intersecting the range between
+// the given positions.
+func FormatRange(
+	ss *parser.Stylesheet,
+	src []byte,
+	startLine, startChar, endLine, endChar int,
+	opts analyzer.FormatOptions,
+) (analyzer.FormatEdit, bool) {
+	start := LineCharToOffset(src, startLine, startChar)
+	end := LineCharToOffset(src, endLine, endChar)
+	return analyzer.FormatRange(ss, src, start, end, opts)
+}
+
+// FormatOnType formats the rule that the character ch, typed just
+// before the given position, closes or ends a declaration of.
+func FormatOnType(
+	ss *parser.Stylesheet,
+	src []byte,
+	line, char int,
+	ch string,
+	opts analyzer.FormatOptions,
+) (analyzer.FormatEdit, bool) {
+	offset := LineCharToOffset(src, line, char)
+	return analyzer.FormatOnType(ss, src, offset, ch, opts)
 }
 
 // FoldingRanges returns foldable ranges in the CSS document.
@@ -154,13 +226,29 @@ func DocumentHighlights(
 }
 
 // FixAllActions returns code actions for all auto-fixable
-// diagnostics in the document.
+// diagnostics in the document, including declarations out of
+// order when those are reported.
 func FixAllActions(
 	src []byte,
 	opts analyzer.LintOptions,
 ) []analyzer.CodeAction {
-	diags, _ := Diagnostics(src, opts)
-	return analyzer.FindFixAllActions(diags)
+	diags, ss := Diagnostics(src, opts, analyzer.Indexes{})
+	actions := analyzer.FindFixAllActions(diags)
+	if opts.OutOfOrder == analyzer.OutOfOrderIgnore {
+		return actions
+	}
+	sorts := analyzer.FindDeclarationOrderActions(ss, src, opts.Order)
+	// Sorting moves whole declarations, so fixes within them are
+	// left for the next run.
+	actions = slices.DeleteFunc(actions, func(a analyzer.CodeAction) bool {
+		start := LineCharToOffset(src, a.StartLine, a.StartChar)
+		end := LineCharToOffset(src, a.EndLine, a.EndChar)
+		return slices.ContainsFunc(sorts, func(s analyzer.CodeAction) bool {
+			return start < LineCharToOffset(src, s.EndLine, s.EndChar) &&
+				end > LineCharToOffset(src, s.StartLine, s.StartChar)
+		})
+	})
+	return append(actions, sorts...)
 }
 
 // CodeActions returns code actions for the given diagnostics
@@ -202,6 +290,17 @@ func Definition(
 	return analyzer.FindDefinition(ss, src, offset)
 }
 
+// ModuleReference returns the class or @value name at the given
+// position in a CSS module.
+func ModuleReference(
+	ss *parser.Stylesheet,
+	src []byte,
+	line, char int,
+) (analyzer.ModuleReference, bool) {
+	offset := LineCharToOffset(src, line, char)
+	return analyzer.ModuleReferenceAt(ss, src, offset)
+}
+
 // DocumentSymbols returns a hierarchical list of symbols in
 // the CSS document.
 func DocumentSymbols(
@@ -211,15 +310,22 @@ func DocumentSymbols(
 	return analyzer.FindDocumentSymbols(ss, src)
 }
 
+// PathCompletionAt returns the url() or @import path being typed
+// at the given position.
+func PathCompletionAt(src []byte, line, char int) (analyzer.PathCompletion, bool) {
+	return analyzer.PathCompletionAt(src, LineCharToOffset(src, line, char))
+}
+
 // Completions returns completion items for the given position.
 func Completions(
 	ss *parser.Stylesheet,
 	src []byte,
 	line, char int,
 	opts analyzer.LintOptions,
+	markup ...analyzer.MarkupIndex,
 ) []analyzer.CompletionItem {
 	offset := LineCharToOffset(src, line, char)
-	return analyzer.Complete(ss, src, offset, opts)
+	return analyzer.Complete(ss, src, offset, opts, markup...)
 }
 
 // VarReferenceAt returns the CSS variable name at the given
@@ -0,0 +1,22 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// Fingerprint returns a hash of every data table. It changes
+// whenever the tables are regenerated or edited, so anything
+// derived from them and stored across runs can be invalidated.
+var Fingerprint = sync.OnceValue(func() string {
+	// The tables are plain literals, which always marshal.
+	b, _ := json.Marshal([]any{
+		Properties, AtRules, PseudoClasses, PseudoElements,
+		MediaFeatures, Functions, GlobalValues, NamedColors,
+		ShorthandLonghands, Units, ColorFunctions, CommonFunctions,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+})
@@ -0,0 +1,281 @@
+// Package embedded extracts the CSS embedded in HTML documents, in
+// <style> elements and style attributes, so that it can be
+// analyzed like a stylesheet.
+package embedded
+
+import (
+	"bytes"
+	"path"
+	"slices"
+	"strings"
+)
+
+// htmlExtensions lists the file extensions of HTML documents.
+var htmlExtensions = []string{".html", ".htm"}
+
+// rawTextElements holds the elements whose content is not markup,
+// so that tags inside them are not taken for real ones.
+var rawTextElements = []string{"script", "style", "textarea", "title"}
+
+// IsHTML reports whether the file at uri or path is an HTML
+// document, judging by its extension.
+func IsHTML(uri string) bool {
+	return slices.Contains(htmlExtensions, strings.ToLower(path.Ext(uri)))
+}
+
+// Region is a span of CSS within an HTML document.
+type Region struct {
+	// Start and End are byte offsets into the document.
+	Start, End int
+	// Attribute is set for the value of a style attribute, which
+	// holds a declaration list rather than a stylesheet.
+	Attribute bool
+}
+
+// Document is the CSS extracted from an HTML document.
+type Document struct {
+	// Host is the HTML source.
+	Host []byte
+	// CSS has the same length and line breaks as Host. The bytes of
+	// each region are copied unchanged and everything else is
+	// blanked, so that offsets, lines and byte columns in CSS are
+	// those of Host. Style attribute values are wrapped in a
+	// universal rule written over the bytes around them, which
+	// makes them parse as declaration lists.
+	CSS []byte
+	// Regions are the CSS spans in document order.
+	Regions []Region
+	// Classes and IDs are the names in the class and id attributes
+	// of the document's elements, in document order.
+	Classes, IDs []string
+}
+
+// Extract finds the <style> elements and style attributes in the
+// HTML document src, and collects the class names and ids its
+// elements use. <style> elements with a type other than text/css
+// are skipped.
+func Extract(src []byte) *Document {
+	d := &Document{Host: src, CSS: make([]byte, len(src))}
+	for i, b := range src {
+		if b == '\n' || b == '\r' {
+			d.CSS[i] = b
+		} else {
+			d.CSS[i] = ' '
+		}
+	}
+
+	s := scanner{src: src}
+	for s.pos < len(src) {
+		lt := bytes.IndexByte(src[s.pos:], '<')
+		if lt < 0 {
+			break
+		}
+		s.pos += lt
+		switch {
+		case s.hasPrefix("<!--"):
+			s.skipPast("-->")
+		case s.hasPrefix("</"), s.hasPrefix("<!"), s.hasPrefix("<?"):
+			s.skipPast(">")
+		default:
+			d.element(&s)
+		}
+	}
+
+	for _, r := range d.Regions {
+		copy(d.CSS[r.Start:r.End], src[r.Start:r.End])
+		if r.Attribute {
+			d.CSS[r.Start-2], d.CSS[r.Start-1] = '*', '{'
+			d.CSS[r.End] = '}'
+		}
+	}
+	return d
+}
+
+// Contains reports whether offset lies within a region, including
+// its end.
+func (d *Document) Contains(offset int) bool {
+	_, ok := d.RegionAt(offset)
+	return ok
+}
+
+// RegionAt returns the region containing offset, including its
+// end.
+func (d *Document) RegionAt(offset int) (Region, bool) {
+	for _, r := range d.Regions {
+		if offset >= r.Start && offset <= r.End {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// element reads the start tag at s.pos, recording style attribute
+// values, and the content of <style> elements, as regions, along
+// with its class names and id. The content of other raw text
+// elements is skipped.
+func (d *Document) element(s *scanner) {
+	s.pos++ // <
+	name := strings.ToLower(s.until("\t\n\f\r />"))
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		return
+	}
+
+	cssType := true
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.src) {
+			return
+		}
+		if s.src[s.pos] == '>' {
+			s.pos++
+			break
+		}
+		if s.src[s.pos] == '/' {
+			s.pos++
+			continue
+		}
+
+		attr := strings.ToLower(s.until("\t\n\f\r />="))
+		if attr == "" {
+			s.pos++
+			continue
+		}
+		start, end, ok := s.value()
+		if !ok {
+			continue
+		}
+		switch {
+		case attr == "style" && s.canWrap(start, end):
+			d.Regions = append(d.Regions, Region{
+				Start: start, End: end, Attribute: true,
+			})
+		case attr == "class":
+			d.Classes = append(d.Classes, strings.Fields(string(s.src[start:end]))...)
+		case attr == "id":
+			if id := strings.TrimSpace(string(s.src[start:end])); id != "" {
+				d.IDs = append(d.IDs, id)
+			}
+		case attr == "type" && name == "style":
+			t := strings.TrimSpace(string(s.src[start:end]))
+			cssType = t == "" || strings.EqualFold(t, "text/css")
+		}
+	}
+
+	if !slices.Contains(rawTextElements, name) {
+		return
+	}
+	start := s.pos
+	end := indexFold(s.src[start:], "</"+name)
+	if end < 0 {
+		end = len(s.src)
+	} else {
+		end += start
+	}
+	if name == "style" && cssType {
+		d.Regions = append(d.Regions, Region{Start: start, End: end})
+	}
+	s.pos = end
+}
+
+// scanner reads HTML markup.
+type scanner struct {
+	src []byte
+	pos int
+}
+
+// hasPrefix reports whether the input at the current position
+// starts with prefix.
+func (s *scanner) hasPrefix(prefix string) bool {
+	return bytes.HasPrefix(s.src[s.pos:], []byte(prefix))
+}
+
+// skipPast advances past the next occurrence of delim, or to the
+// end of input if there is none.
+func (s *scanner) skipPast(delim string) {
+	i := bytes.Index(s.src[s.pos:], []byte(delim))
+	if i < 0 {
+		s.pos = len(s.src)
+		return
+	}
+	s.pos += i + len(delim)
+}
+
+// until returns the input up to the next byte in stop and
+// advances past it.
+func (s *scanner) until(stop string) string {
+	start := s.pos
+	for s.pos < len(s.src) && !strings.ContainsRune(stop, rune(s.src[s.pos])) {
+		s.pos++
+	}
+	return string(s.src[start:s.pos])
+}
+
+// skipSpace advances past HTML whitespace.
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.src) && isSpace(s.src[s.pos]) {
+		s.pos++
+	}
+}
+
+// value reads an attribute's "=" and value, if there is one, and
+// returns the value's span. For quoted values the span excludes
+// the quotes, and end is the offset of the closing one.
+func (s *scanner) value() (start, end int, ok bool) {
+	save := s.pos
+	s.skipSpace()
+	if s.pos >= len(s.src) || s.src[s.pos] != '=' {
+		s.pos = save
+		return 0, 0, false
+	}
+	s.pos++
+	s.skipSpace()
+	if s.pos >= len(s.src) {
+		return 0, 0, false
+	}
+
+	if q := s.src[s.pos]; q == '"' || q == '\'' {
+		start = s.pos + 1
+		i := bytes.IndexByte(s.src[start:], q)
+		if i < 0 {
+			s.pos = len(s.src)
+			return 0, 0, false
+		}
+		end = start + i
+		s.pos = end + 1
+		return start, end, true
+	}
+	start = s.pos
+	s.until("\t\n\f\r >")
+	return start, s.pos, true
+}
+
+// canWrap reports whether the attribute value at [start, end) can
+// be wrapped in a rule: the two bytes before it and the one after
+// it are overwritten, so they must exist and not be line breaks.
+func (s *scanner) canWrap(start, end int) bool {
+	if start < 2 || end >= len(s.src) {
+		return false
+	}
+	for _, i := range []int{start - 2, start - 1, end} {
+		if s.src[i] == '\n' || s.src[i] == '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// isSpace reports whether b is HTML whitespace.
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
+
+// indexFold returns the offset of the first case-insensitive
+// occurrence of the ASCII string sub in b, or -1.
+func indexFold(b []byte, sub string) int {
+	for i := 0; i+len(sub) <= len(b); i++ {
+		if bytes.EqualFold(b[i:i+len(sub)], []byte(sub)) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,150 @@
+package embedded
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+func TestIsHTML(t *testing.T) {
+	tests := map[string]bool{
+		"file:///a/index.html": true,
+		"/a/page.HTM":          true,
+		"file:///a/site.css":   false,
+		"/a/html":              false,
+	}
+	for uri, want := range tests {
+		if got := IsHTML(uri); got != want {
+			t.Errorf("IsHTML(%q) = %v, want %v", uri, got, want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	const src = `<!doctype html>
+<html>
+<head>
+  <!-- <style>.commented { }</style> -->
+  <style>
+    a { color: red; }
+  </style>
+  <STYLE type="text/less">.less { }</STYLE>
+  <script>const s = "<style>.js { }</style>";</script>
+</head>
+<body style="margin: 0" class="page  dark">
+  <p style='color: blue' title="x > y" id=intro>é</p>
+  <input style=color:red disabled>
+</body>
+</html>
+`
+	d := Extract([]byte(src))
+	if len(d.CSS) != len(src) {
+		t.Fatalf("len(CSS) = %d, want %d", len(d.CSS), len(src))
+	}
+	if strings.Count(string(d.CSS), "\n") != strings.Count(src, "\n") {
+		t.Error("line breaks must be kept")
+	}
+
+	var got []string
+	for _, r := range d.Regions {
+		text := strings.TrimSpace(src[r.Start:r.End])
+		if r.Attribute {
+			text = "attr:" + text
+		}
+		got = append(got, text)
+	}
+	want := []string{
+		"a { color: red; }",
+		"attr:margin: 0",
+		"attr:color: blue",
+		"attr:color:red",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("regions = %q, want %q", got, want)
+	}
+
+	// The extracted CSS parses cleanly, with every declaration at
+	// its offset in the host document.
+	ss, errs := parser.Parse(d.CSS)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	var props []string
+	parser.Walk(ss, func(n parser.Node) bool {
+		if decl, ok := n.(*parser.Declaration); ok {
+			p := decl.Property
+			if src[p.Offset:p.End] != p.Value {
+				t.Errorf("%s at %d is %q in the host", p.Value, p.Offset,
+					src[p.Offset:p.End])
+			}
+			props = append(props, p.Value)
+		}
+		return true
+	})
+	if strings.Join(props, ",") != "color,margin,color,color" {
+		t.Errorf("declarations = %v", props)
+	}
+
+	if strings.Join(d.Classes, ",") != "page,dark" ||
+		strings.Join(d.IDs, ",") != "intro" {
+		t.Errorf("classes = %q, ids = %q", d.Classes, d.IDs)
+	}
+
+	if !d.Contains(strings.Index(src, "margin")) ||
+		d.Contains(strings.Index(src, "title")) {
+		t.Error("Contains disagrees with the regions")
+	}
+}
+
+func TestExtractUnterminated(t *testing.T) {
+	for _, src := range []string{
+		"<style>a { color: red; }",
+		"<p style=\"color: red",
+		"<p style=\ncolor:red>",
+		"<p style=color:red",
+		"<",
+		"<3 style=\"a\">",
+	} {
+		d := Extract([]byte(src))
+		for _, r := range d.Regions {
+			if r.Attribute && (r.Start < 2 || r.End >= len(src)) {
+				t.Errorf("%q: region %+v can't be wrapped", src, r)
+			}
+		}
+		if len(d.CSS) != len(src) {
+			t.Errorf("%q: len(CSS) = %d", src, len(d.CSS))
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	const src = "<html>\n  <head>\n    <style>a{color:red}b{margin:0}</style>\n" +
+		"  </head>\n  <body style=\"color:red;margin:0 auto;\">" +
+		"<i style='content:\"x\"'></i><b style=\"\"></b></body>\n</html>\n"
+	d := Extract([]byte(src))
+	edits := Format(d, analyzer.FormatOptions{TabSize: 2, InsertSpaces: true})
+
+	out := src
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		out = out[:e.Start] + e.NewText + out[e.End:]
+	}
+	const want = "<html>\n  <head>\n    <style>\n" +
+		"      a {\n        color: red;\n      }\n\n" +
+		"      b {\n        margin: 0;\n      }\n" +
+		"    </style>\n" +
+		"  </head>\n  <body style=\"color: red; margin: 0 auto\">" +
+		"<i style='content: \"x\"'></i><b style=\"\"></b></body>\n</html>\n"
+	if out != want {
+		t.Errorf("formatted:\n%s\nwant:\n%s", out, want)
+	}
+
+	// Formatting is stable.
+	if edits := Format(Extract([]byte(out)), analyzer.FormatOptions{
+		TabSize: 2, InsertSpaces: true,
+	}); len(edits) != 0 {
+		t.Errorf("second pass edits = %+v", edits)
+	}
+}
@@ -0,0 +1,133 @@
+package embedded
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/parser"
+)
+
+// Edit replaces the bytes [Start, End) of a document with NewText.
+type Edit struct {
+	Start, End int
+	NewText    string
+}
+
+// Format formats the CSS in each region of d and returns an edit
+// for every region that changes. The rules of a <style> element
+// are indented one level deeper than its start tag, and the end
+// tag is put on its own line. Style attributes are formatted onto
+// a single line; ones whose formatted value wouldn't fit in their
+// quotes are left alone.
+func Format(d *Document, opts analyzer.FormatOptions) []Edit {
+	var edits []Edit
+	for _, r := range d.Regions {
+		text := d.Host[r.Start:r.End]
+		var formatted string
+		if r.Attribute {
+			formatted = formatAttribute(d.Host, r, opts)
+		} else {
+			formatted = formatBlock(d.Host, r, opts)
+		}
+		if formatted != "" && formatted != string(text) {
+			edits = append(edits, Edit{
+				Start:   r.Start,
+				End:     r.End,
+				NewText: formatted,
+			})
+		}
+	}
+	return edits
+}
+
+// formatBlock returns the formatted content of the <style>
+// element at r, or "" if it is blank.
+func formatBlock(host []byte, r Region, opts analyzer.FormatOptions) string {
+	src := host[r.Start:r.End]
+	if len(bytes.TrimSpace(src)) == 0 {
+		return ""
+	}
+	ss, _ := parser.Parse(src)
+	// The block is indented and ended here, in the line breaks
+	// of the options.
+	nl := opts.EndOfLine.Break()
+	opts.EndOfLine, opts.OmitFinalNewline = analyzer.EndOfLineLF, false
+	formatted := analyzer.Format(ss, src, opts)
+
+	base := lineIndent(host, r.Start)
+	unit := "\t"
+	if opts.InsertSpaces {
+		tabSize := opts.TabSize
+		if tabSize == 0 {
+			tabSize = 2
+		}
+		unit = strings.Repeat(" ", tabSize)
+	}
+
+	var b strings.Builder
+	b.WriteString(nl)
+	for line := range strings.Lines(formatted) {
+		if strings.TrimSpace(line) != "" {
+			b.WriteString(base + unit)
+		}
+		b.WriteString(strings.TrimRight(line, " \t\r\n"))
+		b.WriteString(nl)
+	}
+	b.WriteString(base)
+	return b.String()
+}
+
+// formatAttribute returns the formatted value of the style
+// attribute at r, or "" if it can't be formatted in place.
+func formatAttribute(host []byte, r Region, opts analyzer.FormatOptions) string {
+	src := make([]byte, 0, r.End-r.Start+3)
+	src = append(src, "*{"...)
+	src = append(src, host[r.Start:r.End]...)
+	src = append(src, '}')
+
+	ss, errs := parser.Parse(src)
+	if len(errs) > 0 {
+		return ""
+	}
+	opts.Mode = analyzer.FormatCompact
+	opts.PrintWidth = len(src) * 2
+	opts.EndOfLine = analyzer.EndOfLineLF
+	formatted := strings.TrimSpace(analyzer.Format(ss, src, opts))
+
+	value, ok := strings.CutPrefix(formatted, "* {")
+	if !ok {
+		return ""
+	}
+	value, ok = strings.CutSuffix(value, "}")
+	if !ok {
+		return ""
+	}
+	value = strings.TrimSuffix(strings.TrimSpace(value), ";")
+
+	// The value must not close its quotes early or, unquoted,
+	// contain anything that ends it.
+	quote := host[r.End]
+	switch {
+	case strings.ContainsAny(value, "\r\n"):
+		return ""
+	case quote == '"' || quote == '\'':
+		if strings.IndexByte(value, quote) >= 0 {
+			return ""
+		}
+	case strings.ContainsAny(value, " \t\f>\"'="):
+		return ""
+	}
+	return value
+}
+
+// lineIndent returns the whitespace that starts the line holding
+// offset.
+func lineIndent(src []byte, offset int) string {
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	end := start
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	return string(src[start:end])
+}
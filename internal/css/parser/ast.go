@@ -33,6 +33,12 @@ type Node interface {
 type Stylesheet struct {
 	Children []Node
 	EndPos   int
+
+	// extents records, for each top-level child, the end of the
+	// source its parse depended on. Lookahead can read past a
+	// rule's own end, so this may exceed the child's End. Only set
+	// on top-level stylesheets; used by Reparse.
+	extents []int
 }
 
 func (n *Stylesheet) Kind() NodeKind { return NodeStylesheet }
@@ -0,0 +1,314 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/scanner"
+)
+
+// Edit describes a text replacement: the bytes in [Start, End)
+// of the previous source were replaced by NewLen bytes.
+type Edit struct {
+	Start  int
+	End    int
+	NewLen int
+}
+
+// Delta returns the change in source length caused by the edit.
+func (e Edit) Delta() int {
+	return e.NewLen - (e.End - e.Start)
+}
+
+// Reparse updates a previously parsed stylesheet after an edit.
+// Only the top-level rules touching the edit are reparsed: rules
+// before it are reused as-is and rules after it are copied with
+// their offsets shifted. src is the complete new source.
+//
+// The result is identical to Parse(src). When the edit cannot be
+// confined to whole top-level rules (an unclosed block, comment
+// or at-rule that would swallow what follows), Reparse falls back
+// to a full parse.
+func Reparse(
+	prev *Stylesheet,
+	prevErrs []*Error,
+	src []byte,
+	edit Edit,
+) (*Stylesheet, []*Error) {
+	if prev == nil || len(prev.extents) != len(prev.Children) ||
+		edit.Start < 0 || edit.End < edit.Start ||
+		edit.End > prev.EndPos || edit.NewLen < 0 ||
+		prev.EndPos+edit.Delta() != len(src) {
+		return Parse(src)
+	}
+
+	children := prev.Children
+	delta := edit.Delta()
+
+	// Children whose parse never looked at the edited bytes are
+	// kept, provided parsing can resume cleanly after them.
+	first := 0
+	for first < len(children) && prev.extents[first] < edit.Start {
+		first++
+	}
+	for first > 0 && !endsCleanly(src, children[first-1]) {
+		first--
+	}
+
+	next := first
+	for next < len(children) && children[next].Offset() <= edit.End {
+		next++
+	}
+
+	regionStart := 0
+	if first > 0 {
+		regionStart = children[first-1].End()
+	}
+	regionEnd := prev.EndPos
+	if next < len(children) {
+		regionEnd = children[next].Offset()
+	}
+
+	// An error sitting exactly on a region boundary can't be
+	// attributed to one side or the other.
+	for _, e := range prevErrs {
+		if (first > 0 && e.StartPos == regionStart) ||
+			(next < len(children) && e.StartPos == regionEnd) {
+			return Parse(src)
+		}
+	}
+
+	region := src[regionStart : regionEnd+delta]
+	tokens := scanner.ScanAll(region)
+	if next < len(children) && !endsAtBoundary(region, tokens) {
+		return Parse(src)
+	}
+
+	p := &Parser{tokens: tokens, far: -1}
+	sub := p.parseStylesheet()
+	if next < len(children) {
+		// Anything that ran into the end of the region would
+		// have continued into the following rules.
+		for _, e := range p.Errors {
+			if e.EndPos >= len(region) {
+				return Parse(src)
+			}
+		}
+		for _, ext := range sub.extents {
+			if ext >= len(region) {
+				return Parse(src)
+			}
+		}
+	}
+
+	ss := &Stylesheet{EndPos: len(src)}
+	if n := first + len(sub.Children) + len(children) - next; n > 0 {
+		ss.Children = make([]Node, 0, n)
+		ss.extents = make([]int, 0, n)
+	}
+	ss.Children = append(ss.Children, children[:first]...)
+	ss.extents = append(ss.extents, prev.extents[:first]...)
+	for i, child := range sub.Children {
+		ss.Children = append(ss.Children, shiftNode(child, regionStart))
+		ss.extents = append(ss.extents, sub.extents[i]+regionStart)
+	}
+	for i, child := range children[next:] {
+		ss.Children = append(ss.Children, shiftNode(child, delta))
+		ss.extents = append(ss.extents, prev.extents[next+i]+delta)
+	}
+
+	var errs []*Error
+	for _, e := range prevErrs {
+		if e.StartPos < regionStart {
+			errs = append(errs, e)
+		}
+	}
+	for _, e := range p.Errors {
+		errs = append(errs, &Error{
+			Message:  e.Message,
+			StartPos: e.StartPos + regionStart,
+			EndPos:   e.EndPos + regionStart,
+		})
+	}
+	for _, e := range prevErrs {
+		if e.StartPos > regionEnd {
+			errs = append(errs, &Error{
+				Message:  e.Message,
+				StartPos: e.StartPos + delta,
+				EndPos:   e.EndPos + delta,
+			})
+		}
+	}
+
+	return ss, errs
+}
+
+// endsCleanly reports whether a top-level node ends on a token
+// after which parsing starts afresh: a closing brace, a semicolon
+// or a terminated comment. The node must lie before the edit so
+// its bytes are unchanged in src.
+func endsCleanly(src []byte, n Node) bool {
+	end := n.End()
+	if end <= n.Offset() || end > len(src) {
+		return false
+	}
+	if c, ok := n.(*Comment); ok {
+		return end-c.StartPos >= 4 &&
+			strings.HasSuffix(string(src[c.StartPos:end]), "*/")
+	}
+	return src[end-1] == '}' || src[end-1] == ';'
+}
+
+// endsAtBoundary reports whether a reparsed region ends in a
+// state where the following, unchanged rules tokenize and parse
+// exactly as they did before.
+func endsAtBoundary(region []byte, tokens []scanner.Token) bool {
+	// The final token is always EOF.
+	if len(tokens) < 2 {
+		return true
+	}
+	last := tokens[len(tokens)-2]
+	switch last.Kind {
+	case scanner.Whitespace, scanner.BraceClose, scanner.Semicolon:
+		return true
+	case scanner.Comment:
+		return last.Len() >= 4 &&
+			strings.HasSuffix(string(region[last.Offset:last.End]), "*/")
+	}
+	return false
+}
+
+// shiftNode returns a deep copy of n with every offset moved by
+// delta. Reused nodes may be read concurrently, so they are never
+// modified in place.
+func shiftNode(n Node, delta int) Node {
+	switch node := n.(type) {
+	case *Ruleset:
+		return shiftRuleset(node, delta)
+	case *AtRule:
+		return shiftAtRule(node, delta)
+	case *Declaration:
+		return shiftDeclaration(node, delta)
+	case *Comment:
+		c := *node
+		c.StartPos += delta
+		c.EndPos += delta
+		return &c
+	case *Stylesheet:
+		return shiftStylesheet(node, delta)
+	}
+	return n
+}
+
+func shiftStylesheet(ss *Stylesheet, delta int) *Stylesheet {
+	if ss == nil {
+		return nil
+	}
+	out := &Stylesheet{EndPos: ss.EndPos + delta}
+	out.Children = shiftNodes(ss.Children, delta)
+	return out
+}
+
+func shiftRuleset(rs *Ruleset, delta int) *Ruleset {
+	out := &Ruleset{
+		StartPos: rs.StartPos + delta,
+		EndPos:   rs.EndPos + delta,
+		Children: shiftNodes(rs.Children, delta),
+	}
+	if rs.Selectors != nil {
+		out.Selectors = shiftSelectorList(rs.Selectors, delta)
+	}
+	return out
+}
+
+func shiftSelectorList(sl *SelectorList, delta int) *SelectorList {
+	out := &SelectorList{
+		StartPos: sl.StartPos + delta,
+		EndPos:   sl.EndPos,
+	}
+	// The parser leaves EndPos unset for an empty list.
+	if len(sl.Selectors) > 0 {
+		out.EndPos += delta
+	}
+	if sl.Selectors != nil {
+		out.Selectors = make([]*Selector, len(sl.Selectors))
+		for i, sel := range sl.Selectors {
+			out.Selectors[i] = shiftSelector(sel, delta)
+		}
+	}
+	return out
+}
+
+func shiftSelector(sel *Selector, delta int) *Selector {
+	out := &Selector{
+		StartPos: sel.StartPos + delta,
+		EndPos:   sel.EndPos,
+	}
+	// The parser leaves EndPos unset when the selector ends in a
+	// combinator.
+	if n := len(sel.Parts); n > 0 && sel.Parts[n-1].Combinator == "" {
+		out.EndPos += delta
+	}
+	if sel.Parts != nil {
+		out.Parts = make([]SelectorPart, len(sel.Parts))
+		for i, part := range sel.Parts {
+			if part.Combinator == "" {
+				part.Token = shiftToken(part.Token, delta)
+			}
+			out.Parts[i] = part
+		}
+	}
+	return out
+}
+
+func shiftDeclaration(decl *Declaration, delta int) *Declaration {
+	out := *decl
+	out.Property = shiftToken(decl.Property, delta)
+	out.StartPos += delta
+	out.EndPos += delta
+	if decl.Value != nil {
+		out.Value = &Value{
+			Tokens:   shiftTokens(decl.Value.Tokens, delta),
+			StartPos: decl.Value.StartPos + delta,
+			EndPos:   decl.Value.EndPos + delta,
+		}
+	}
+	return &out
+}
+
+func shiftAtRule(rule *AtRule, delta int) *AtRule {
+	return &AtRule{
+		Name:     rule.Name,
+		Prelude:  shiftTokens(rule.Prelude, delta),
+		Block:    shiftStylesheet(rule.Block, delta),
+		StartPos: rule.StartPos + delta,
+		EndPos:   rule.EndPos + delta,
+	}
+}
+
+func shiftNodes(nodes []Node, delta int) []Node {
+	if nodes == nil {
+		return nil
+	}
+	out := make([]Node, len(nodes))
+	for i, n := range nodes {
+		out[i] = shiftNode(n, delta)
+	}
+	return out
+}
+
+func shiftTokens(tokens []scanner.Token, delta int) []scanner.Token {
+	if tokens == nil {
+		return nil
+	}
+	out := make([]scanner.Token, len(tokens))
+	for i, t := range tokens {
+		out[i] = shiftToken(t, delta)
+	}
+	return out
+}
+
+func shiftToken(t scanner.Token, delta int) scanner.Token {
+	t.Offset += delta
+	t.End += delta
+	return t
+}
@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const incrementalSrc = `/* theme */
+@import "base.css";
+
+:root {
+  --brand: #336699;
+}
+
+.card {
+  color: var(--brand);
+  &:hover { color: red; }
+}
+
+@media (min-width: 600px) {
+  .card { padding: 0 1rem; }
+}
+
+a:hover, a:focus { text-decoration: underline; }
+`
+
+// applyEdit replaces src[start:end] with text and returns the new
+// source together with the matching Edit.
+func applyEdit(src string, start, end int, text string) (string, Edit) {
+	return src[:start] + text + src[end:],
+		Edit{Start: start, End: end, NewLen: len(text)}
+}
+
+func assertSameAsFullParse(
+	t *testing.T,
+	label, src string,
+	ss *Stylesheet,
+	errs []*Error,
+) {
+	t.Helper()
+	want, wantErrs := Parse([]byte(src))
+	if !reflect.DeepEqual(ss, want) {
+		t.Fatalf("%s: reparsed tree differs from full parse of %q", label, src)
+	}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Fatalf(
+			"%s: reparsed errors %v differ from full parse %v of %q",
+			label, errs, wantErrs, src,
+		)
+	}
+}
+
+func TestReparse_MatchesFullParse(t *testing.T) {
+	at := func(s string) int {
+		i := strings.Index(incrementalSrc, s)
+		if i < 0 {
+			t.Fatalf("fixture missing %q", s)
+		}
+		return i
+	}
+
+	tests := []struct {
+		name  string
+		start int
+		end   int
+		text  string
+	}{
+		{"insert into declaration", at("#336699"), at("#336699"), "0"},
+		{"replace value", at("red"), at("red") + 3, "blue"},
+		{"delete declaration", at("--brand: #"), at("--brand: #") + 17, ""},
+		{"insert rule between rules", at(".card {"), at(".card {"), "p { margin: 0; }\n"},
+		{"insert at start", 0, 0, "html { color: black; }\n"},
+		{"append at end", len(incrementalSrc), len(incrementalSrc), "b{}"},
+		{"open unclosed comment", at(".card {"), at(".card {"), "/* "},
+		{"remove closing brace", at("}\n\n.card"), at("}\n\n.card") + 1, ""},
+		{"unterminated at-rule", at(";\n\n:root"), at(";\n\n:root") + 1, ""},
+		{"edit inside media block", at("0 1rem"), at("0 1rem") + 1, "2"},
+		{"type partial property", at("color: red"), at("color: red"), "col"},
+		{"replace everything", 0, len(incrementalSrc), "x { y: z }"},
+		{"delete everything", 0, len(incrementalSrc), ""},
+		{"edit in whitespace gap", at("\n\n@media"), at("\n\n@media") + 1, "\n\n"},
+		{"stray selector without block", at("a:hover"), at("a:hover"), "div "},
+		{"edit comment", at("theme"), at("theme") + 5, "colors"},
+	}
+
+	prev, prevErrs := Parse([]byte(incrementalSrc))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, edit := applyEdit(incrementalSrc, tt.start, tt.end, tt.text)
+			ss, errs := Reparse(prev, prevErrs, []byte(src), edit)
+			assertSameAsFullParse(t, tt.name, src, ss, errs)
+		})
+	}
+}
+
+func TestReparse_ReusesUnchangedRules(t *testing.T) {
+	prev, prevErrs := Parse([]byte(incrementalSrc))
+	i := strings.Index(incrementalSrc, "0 1rem")
+	src, edit := applyEdit(incrementalSrc, i, i+1, "2")
+
+	ss, _ := Reparse(prev, prevErrs, []byte(src), edit)
+
+	// Everything before the @media rule is shared with the
+	// previous tree rather than reparsed.
+	for j, child := range prev.Children {
+		if rule, ok := child.(*AtRule); ok && rule.Name == "media" {
+			break
+		}
+		if ss.Children[j] != child {
+			t.Errorf("child %d (%T) was not reused", j, child)
+		}
+	}
+}
+
+func TestReparse_DoesNotModifyPrevious(t *testing.T) {
+	prev, prevErrs := Parse([]byte(incrementalSrc))
+	snapshot, _ := Parse([]byte(incrementalSrc))
+
+	src, edit := applyEdit(incrementalSrc, 0, 0, "p{}\n")
+	_, _ = Reparse(prev, prevErrs, []byte(src), edit)
+
+	if !reflect.DeepEqual(prev, snapshot) {
+		t.Fatal("Reparse modified the previous stylesheet")
+	}
+}
+
+func TestReparse_Sequence(t *testing.T) {
+	fragments := []string{
+		"a", "{", "}", ";", ":", " ", "\n", "/*", "*/", "\"", "(", ")",
+		"color", "red", "@media", "@import", "--x", "&", ".b", "#c",
+		"var(--x)", "1px", ",", "!important",
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	src := incrementalSrc
+	ss, errs := Parse([]byte(src))
+
+	for step := range 2000 {
+		start := rng.IntN(len(src) + 1)
+		end := min(start+rng.IntN(6), len(src))
+		var text string
+		for range rng.IntN(3) {
+			text += fragments[rng.IntN(len(fragments))]
+		}
+
+		var edit Edit
+		src, edit = applyEdit(src, start, end, text)
+		ss, errs = Reparse(ss, errs, []byte(src), edit)
+		assertSameAsFullParse(t, fmt.Sprintf("step %d", step), src, ss, errs)
+
+		// Keep the document from drifting into one huge comment.
+		if step%200 == 199 {
+			src = incrementalSrc
+			ss, errs = Parse([]byte(src))
+		}
+	}
+}
+
+func TestReparse_InvalidEditFallsBack(t *testing.T) {
+	prev, prevErrs := Parse([]byte(incrementalSrc))
+	src := "p { color: red; }"
+
+	ss, errs := Reparse(prev, prevErrs, []byte(src), Edit{Start: 5, End: 2})
+	assertSameAsFullParse(t, "invalid edit", src, ss, errs)
+
+	ss, errs = Reparse(nil, nil, []byte(src), Edit{})
+	assertSameAsFullParse(t, "nil previous", src, ss, errs)
+}
+
+// largeStylesheet builds a stylesheet of roughly the given number
+// of lines, similar in shape to a generated theme file.
+func largeStylesheet(lines int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < lines*24; i++ {
+		fmt.Fprintf(&b, ".component-%d {\n", i)
+		fmt.Fprintf(&b, "  color: var(--color-%d);\n", i%50)
+		fmt.Fprintf(&b, "  padding: %dpx %dpx;\n", i%16, i%8)
+		b.WriteString("  border: 1px solid rgb(0 0 0 / 10%);\n")
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func BenchmarkParse_Large(b *testing.B) {
+	src := []byte(largeStylesheet(8000))
+	b.SetBytes(int64(len(src)))
+	for b.Loop() {
+		Parse(src)
+	}
+}
+
+func BenchmarkReparse_Large(b *testing.B) {
+	orig := largeStylesheet(8000)
+	i := strings.Index(orig, "padding: 7px") + len("padding: ")
+	prev, prevErrs := Parse([]byte(orig))
+	src, edit := applyEdit(orig, i, i+1, "9")
+	buf := []byte(src)
+	b.SetBytes(int64(len(buf)))
+	for b.Loop() {
+		Reparse(prev, prevErrs, buf, edit)
+	}
+}
@@ -9,12 +9,16 @@ type Parser struct {
 	tokens []scanner.Token
 	pos    int
 	Errors []*Error
+
+	// far is the furthest token index examined by lookahead
+	// since it was last reset, or -1.
+	far int
 }
 
 // Parse parses CSS source into a Stylesheet AST.
 func Parse(src []byte) (*Stylesheet, []*Error) {
 	tokens := scanner.ScanAll(src)
-	p := &Parser{tokens: tokens}
+	p := &Parser{tokens: tokens, far: -1}
 	ss := p.parseStylesheet()
 	return ss, p.Errors
 }
@@ -75,7 +79,10 @@ func (p *Parser) parseStylesheet() *Stylesheet {
 
 	for {
 		comments := p.skipWhitespaceAndComments()
-		ss.Children = append(ss.Children, comments...)
+		for _, c := range comments {
+			ss.Children = append(ss.Children, c)
+			ss.extents = append(ss.extents, c.End())
+		}
 
 		t := p.peek()
 		if t.Kind == scanner.EOF {
@@ -83,15 +90,28 @@ func (p *Parser) parseStylesheet() *Stylesheet {
 			break
 		}
 
+		p.far = -1
 		node := p.parseRule()
 		if node != nil {
 			ss.Children = append(ss.Children, node)
+			ss.extents = append(ss.extents, p.extent(node))
 		}
 	}
 
 	return ss
 }
 
+// extent returns the end of the source the parse of a top-level
+// node depended on: its own end, or further if lookahead read
+// past it.
+func (p *Parser) extent(n Node) int {
+	end := n.End()
+	if p.far >= 0 && p.far < len(p.tokens) {
+		end = max(end, p.tokens[p.far].End)
+	}
+	return end
+}
+
 func (p *Parser) parseRule() Node {
 	t := p.peek()
 
@@ -207,7 +227,10 @@ func (p *Parser) parseBlock() *Stylesheet {
 // appears first, it's a declaration.
 func (p *Parser) looksLikeDeclaration() bool {
 	saved := p.pos
-	defer func() { p.pos = saved }()
+	defer func() {
+		p.far = max(p.far, min(p.pos, len(p.tokens)-1))
+		p.pos = saved
+	}()
 
 	// Skip whitespace
 	for p.pos < len(p.tokens) &&
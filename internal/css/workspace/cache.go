@@ -0,0 +1,312 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/toba/css-lsp/internal/css/data"
+	"github.com/toba/lsp/pathutil"
+)
+
+// cacheFormatVersion is bumped whenever the layout of cache files
+// or the meaning of what they store changes. Files written with
+// another version are ignored.
+const cacheFormatVersion = 4
+
+// Cache persists what was indexed from each file of a workspace
+// root, so that a restart only reparses files that changed. A
+// file is reused while its size and modification time match; if
+// only the modification time differs, its content hash decides.
+//
+// Cache files are replaced atomically, so servers sharing a
+// cache directory never see partial writes; the last one to save
+// wins. A cache that can't be read, was written by another format
+// version or against other data tables starts out empty. A Cache
+// is safe for concurrent use.
+type Cache struct {
+	path string
+	root string
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry // file path -> entry
+	dirty   bool
+}
+
+// cacheFile is the on-disk layout of a cache.
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Data    string                `json:"data"`
+	Root    string                `json:"root"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+// cacheEntry records what was indexed from one file. CSS and
+// Markup tell how the file was read, so that entries aren't
+// reused once settings make it read differently.
+type cacheEntry struct {
+	Size      int64            `json:"size"`
+	ModTime   int64            `json:"mtime"`
+	Hash      string           `json:"hash"`
+	CSS       bool             `json:"css,omitempty"`
+	Variables []cacheVariable  `json:"variables,omitempty"`
+	Composes  []cacheModuleUse `json:"composes,omitempty"`
+	Imports   []string         `json:"imports,omitempty"`
+	Markup    bool             `json:"markup,omitempty"`
+	Classes   []string         `json:"classes,omitempty"`
+	IDs       []string         `json:"ids,omitempty"`
+	Uses      []cacheModuleUse `json:"uses,omitempty"`
+}
+
+// cacheVariable is a VariableDefinition without its URI, which is
+// implied by the entry.
+type cacheVariable struct {
+	Name  string `json:"name"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Value string `json:"value,omitempty"`
+}
+
+// cacheModuleUse is a ModuleUse without its URI, which is implied
+// by the entry.
+type cacheModuleUse struct {
+	Module string `json:"module"`
+	Name   string `json:"name,omitempty"`
+	Start  int    `json:"start,omitempty"`
+	End    int    `json:"end,omitempty"`
+	Member bool   `json:"member,omitempty"`
+}
+
+// OpenCache loads the cache for the workspace root from dir.
+// Problems reading it are not errors: the cache then starts out
+// empty and is rewritten on Save.
+func OpenCache(dir, root string) *Cache {
+	key := sha256.Sum256([]byte(root))
+	c := &Cache{
+		path:    filepath.Join(dir, hex.EncodeToString(key[:8])+".json"),
+		root:    root,
+		entries: make(map[string]cacheEntry),
+	}
+
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		return c
+	}
+	var f cacheFile
+	if json.Unmarshal(b, &f) != nil || f.Version != cacheFormatVersion ||
+		f.Data != data.Fingerprint() || f.Root != root {
+		return c
+	}
+	for path, e := range f.Entries {
+		if e.valid() {
+			c.entries[path] = e
+		}
+	}
+	return c
+}
+
+// valid reports whether an entry read from disk is usable.
+func (e cacheEntry) valid() bool {
+	if e.Size < 0 || len(e.Hash) != 2*sha256.Size {
+		return false
+	}
+	for _, v := range e.Variables {
+		if v.Start < 0 || v.End < v.Start || int64(v.End) > e.Size {
+			return false
+		}
+	}
+	for _, u := range slices.Concat(e.Composes, e.Uses) {
+		if u.Module == "" || u.Start < 0 || u.End < u.Start || int64(u.End) > e.Size {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns what was cached for the file at path when read
+// as CSS and as markup, depending on css and markup. With src nil,
+// the entry is only used if the file's size and modification time
+// are unchanged. Otherwise src is the file's content and must
+// hash to the cached value.
+func (c *Cache) lookup(
+	path string,
+	info fs.FileInfo,
+	src []byte,
+	css, markup bool,
+) (fileData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[path]
+	if !ok || e.Size != info.Size() || e.CSS != css || e.Markup != markup {
+		return fileData{}, false
+	}
+	if src == nil {
+		if e.ModTime != info.ModTime().UnixNano() {
+			return fileData{}, false
+		}
+	} else {
+		if e.Hash != contentHash(src) {
+			return fileData{}, false
+		}
+		// The file was touched without changing; remember the new
+		// time so that the next start needn't read it.
+		e.ModTime = info.ModTime().UnixNano()
+		c.entries[path] = e
+		c.dirty = true
+	}
+
+	uri := pathutil.FilePathToURI(path)
+	d := fileData{css: css, markup: markup}
+	d.vars = make([]VariableDefinition, len(e.Variables))
+	for i, v := range e.Variables {
+		d.vars[i] = VariableDefinition{
+			Name:     v.Name,
+			URI:      uri,
+			StartPos: v.Start,
+			EndPos:   v.End,
+			RawValue: v.Value,
+		}
+	}
+	d.classes, d.ids = e.Classes, e.IDs
+	d.composes = fromCacheUses(uri, e.Composes)
+	d.imports = e.Imports
+	d.uses = fromCacheUses(uri, e.Uses)
+	return d, true
+}
+
+// toCacheUses converts module uses for storing.
+func toCacheUses(uses []ModuleUse) []cacheModuleUse {
+	var out []cacheModuleUse
+	for _, u := range uses {
+		out = append(out, cacheModuleUse{
+			Module: u.Module, Name: u.Name,
+			Start: u.Start, End: u.End, Member: u.Member,
+		})
+	}
+	return out
+}
+
+// fromCacheUses converts stored module uses of the file at uri.
+func fromCacheUses(uri string, uses []cacheModuleUse) []ModuleUse {
+	var out []ModuleUse
+	for _, u := range uses {
+		out = append(out, ModuleUse{
+			URI: uri, Module: u.Module, Name: u.Name,
+			Start: u.Start, End: u.End, Member: u.Member,
+		})
+	}
+	return out
+}
+
+// store records what was indexed from the file at path.
+func (c *Cache) store(
+	path string,
+	info fs.FileInfo,
+	src []byte,
+	fd fileData,
+) {
+	e := cacheEntry{
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UnixNano(),
+		Hash:     contentHash(src),
+		CSS:      fd.css,
+		Markup:   fd.markup,
+		Classes:  fd.classes,
+		IDs:      fd.ids,
+		Composes: toCacheUses(fd.composes),
+		Imports:  fd.imports,
+		Uses:     toCacheUses(fd.uses),
+	}
+	for _, d := range fd.vars {
+		e.Variables = append(e.Variables, cacheVariable{
+			Name:  d.Name,
+			Start: d.StartPos,
+			End:   d.EndPos,
+			Value: d.RawValue,
+		})
+	}
+
+	c.mu.Lock()
+	c.entries[path] = e
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+// retain drops the entries of files other than paths.
+func (c *Cache) retain(paths []string) {
+	keep := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keep[p] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path := range c.entries {
+		if !keep[path] {
+			delete(c.entries, path)
+			c.dirty = true
+		}
+	}
+}
+
+// Save writes the cache to disk if it changed since it was opened
+// or last saved.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	b, err := json.Marshal(cacheFile{
+		Version: cacheFormatVersion,
+		Data:    data.Fingerprint(),
+		Root:    c.root,
+		Entries: c.entries,
+	})
+	c.dirty = false
+	c.mu.Unlock()
+	if err == nil {
+		err = c.write(b)
+	}
+	if err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// write replaces the cache file with b.
+func (c *Cache) write(b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o750); err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it into place, so that
+	// readers see either the old or the new cache.
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".cache-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// contentHash returns the hex-encoded SHA-256 of src.
+func contentHash(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
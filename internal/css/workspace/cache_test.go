@@ -0,0 +1,197 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/toba/lsp/pathutil"
+)
+
+// scanCached scans dir into a fresh index through the cache kept
+// in cacheDir and saves the cache.
+func scanCached(t *testing.T, cacheDir, dir string) *Index {
+	t.Helper()
+	c := OpenCache(cacheDir, dir)
+	idx := NewIndex()
+	err := idx.Scan(context.Background(), NewFilter(dir, ScanOptions{}), ScanConfig{
+		Cache: c,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func TestCacheReusesUnchangedFiles(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.css": ":root { --aa: 1; }",
+		"b.css": ":root { --bb: 2; }",
+	})
+	if idx := scanCached(t, cacheDir, dir); !idx.HasVariable("--aa") {
+		t.Fatalf("Files() = %v", idx.Files())
+	}
+
+	// Rewrite a.css with content of the same size and restore its
+	// modification time: the cached definitions are used without
+	// reading the file.
+	a := filepath.Join(dir, "a.css")
+	info, err := os.Stat(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, dir, map[string]string{"a.css": ":root { --zz: 1; }"})
+	if err := os.Chtimes(a, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	idx := scanCached(t, cacheDir, dir)
+	if !idx.HasVariable("--aa") || idx.HasVariable("--zz") {
+		t.Errorf("expected the cached definitions, got %v", idx.AllVariableNames())
+	}
+
+	// Once the modification time changes, the content decides.
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(a, later, later); err != nil {
+		t.Fatal(err)
+	}
+	idx = scanCached(t, cacheDir, dir)
+	if idx.HasVariable("--aa") || !idx.HasVariable("--zz") {
+		t.Errorf("expected the new definitions, got %v", idx.AllVariableNames())
+	}
+	defs := idx.LookupDefinitions("--zz")
+	if len(defs) != 1 || defs[0].RawValue != "1" ||
+		defs[0].URI != pathutil.FilePathToURI(a) {
+		t.Errorf("LookupDefinitions(--zz) = %+v", defs)
+	}
+}
+
+func TestCacheTouchedFile(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	writeTree(t, dir, map[string]string{"a.css": ":root { --a: 1; }"})
+	scanCached(t, cacheDir, dir)
+
+	a := filepath.Join(dir, "a.css")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(a, later, later); err != nil {
+		t.Fatal(err)
+	}
+	idx := scanCached(t, cacheDir, dir)
+	if !idx.HasVariable("--a") {
+		t.Fatalf("Files() = %v", idx.Files())
+	}
+
+	// The entry now carries the new modification time.
+	c := OpenCache(cacheDir, dir)
+	info, _ := os.Stat(a)
+	if _, ok := c.lookup(a, info, nil, true, false); !ok {
+		t.Error("touched file should be reused by size and time")
+	}
+}
+
+func TestCacheEscapedPath(t *testing.T) {
+	dir, cacheDir := filepath.Join(t.TempDir(), "my dir"), t.TempDir()
+	writeTree(t, dir, map[string]string{"a.css": ":root { --a: 1; }"})
+	scanCached(t, cacheDir, dir)
+
+	idx := scanCached(t, cacheDir, dir)
+	uri := pathutil.FilePathToURI(filepath.Join(dir, "a.css"))
+	if files := idx.Files(); len(files) != 1 || files[0] != uri {
+		t.Errorf("files = %q, want %q", files, uri)
+	}
+	if defs := idx.LookupDefinitions("--a"); len(defs) != 1 || defs[0].URI != uri {
+		t.Errorf("LookupDefinitions(--a) = %+v", defs)
+	}
+}
+
+func TestCacheForgetsRemovedFiles(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.css": ":root { --a: 1; }",
+		"b.css": ":root { --b: 2; }",
+	})
+	scanCached(t, cacheDir, dir)
+	if err := os.Remove(filepath.Join(dir, "b.css")); err != nil {
+		t.Fatal(err)
+	}
+	scanCached(t, cacheDir, dir)
+
+	c := OpenCache(cacheDir, dir)
+	if len(c.entries) != 1 {
+		t.Errorf("entries = %v", c.entries)
+	}
+}
+
+func TestCacheRejectsStaleFiles(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	writeTree(t, dir, map[string]string{"a.css": ":root { --a: 1; }"})
+	scanCached(t, cacheDir, dir)
+	path := OpenCache(cacheDir, dir).path
+
+	rewrite := func(edit func(f *cacheFile)) {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f cacheFile
+		if err := json.Unmarshal(b, &f); err != nil {
+			t.Fatal(err)
+		}
+		edit(&f)
+		if b, err = json.Marshal(f); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		edit func(f *cacheFile)
+	}{
+		{"version", func(f *cacheFile) { f.Version++ }},
+		{"data tables", func(f *cacheFile) { f.Data = "stale" }},
+		{"root", func(f *cacheFile) { f.Root = "/elsewhere" }},
+		{"bad entry", func(f *cacheFile) {
+			for p, e := range f.Entries {
+				e.Variables[0].End = int(e.Size) + 1
+				f.Entries[p] = e
+			}
+		}},
+	}
+	for _, tt := range tests {
+		scanCached(t, cacheDir, dir)
+		rewrite(tt.edit)
+		if c := OpenCache(cacheDir, dir); len(c.entries) != 0 {
+			t.Errorf("%s: entries = %v", tt.name, c.entries)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if c := OpenCache(cacheDir, dir); len(c.entries) != 0 {
+		t.Errorf("corrupt file: entries = %v", c.entries)
+	}
+	// A scan through the rejected cache rewrites it.
+	scanCached(t, cacheDir, dir)
+	if c := OpenCache(cacheDir, dir); len(c.entries) != 1 {
+		t.Errorf("after rescan: entries = %v", c.entries)
+	}
+
+	names, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("cache directory holds %d files, want 1", len(names))
+	}
+}
@@ -0,0 +1,375 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+)
+
+// EditorConfigFile is the name of the files that set formatting
+// properties for the files below them.
+const EditorConfigFile = ".editorconfig"
+
+// EditorConfig holds the properties that .editorconfig files set
+// for a file, by name. Names and values are lowercase, as every
+// property the formatter takes is case-insensitive.
+type EditorConfig map[string]string
+
+// editorConfigSection is a section of an .editorconfig file: a
+// glob and the properties it sets for the files it matches.
+type editorConfigSection struct {
+	pattern *regexp.Regexp
+	// ranges are the bounds of the numeric ranges of the glob,
+	// one per submatch of pattern.
+	ranges [][2]int
+	props  [][2]string
+}
+
+// editorConfigDir is the parsed .editorconfig file of a
+// directory, if it has one.
+type editorConfigDir struct {
+	found    bool
+	root     bool
+	sections []editorConfigSection
+}
+
+// EditorConfigs caches the .editorconfig files of directories,
+// parsed, and whether a directory has none. The zero value is
+// ready to use. Forget drops a file that changed on disk.
+type EditorConfigs struct {
+	mu   sync.Mutex
+	dirs map[string]editorConfigDir
+}
+
+// ResolveEditorConfig returns the properties that the
+// .editorconfig files in the directories above the file at p set
+// for it, reading every file. See EditorConfigs.Resolve.
+func ResolveEditorConfig(p string) EditorConfig {
+	return new(EditorConfigs).Resolve(p)
+}
+
+// Forget drops the cached .editorconfig file at path, so that it
+// is read again when next needed.
+func (c *EditorConfigs) Forget(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.dirs, filepath.Dir(abs))
+	c.mu.Unlock()
+}
+
+// dir returns the .editorconfig file of dir, reading it unless it
+// is cached.
+func (c *EditorConfigs) dir(dir string) editorConfigDir {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.dirs[dir]; ok {
+		return d
+	}
+	var d editorConfigDir
+	data, err := os.ReadFile(filepath.Join(dir, EditorConfigFile)) //nolint:gosec
+	if err == nil {
+		d.found = true
+		d.sections, d.root = parseEditorConfig(data)
+	}
+	if c.dirs == nil {
+		c.dirs = make(map[string]editorConfigDir)
+	}
+	c.dirs[dir] = d
+	return d
+}
+
+// Resolve returns the properties that the .editorconfig files in
+// the directories above the file at p set for it. Files are read
+// from its directory up to the first one marked root; nearer
+// files and later sections take precedence. Properties set to
+// "unset" are left out.
+func (c *EditorConfigs) Resolve(p string) EditorConfig {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return nil
+	}
+	type config struct {
+		dir      string
+		sections []editorConfigSection
+	}
+	var configs []config
+	for dir := filepath.Dir(abs); ; {
+		if d := c.dir(dir); d.found {
+			configs = append(configs, config{dir, d.sections})
+			if d.root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := EditorConfig{}
+	for i := len(configs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(configs[i].dir, abs)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, sec := range configs[i].sections {
+			if !sec.matches(rel) {
+				continue
+			}
+			for _, kv := range sec.props {
+				props[kv[0]] = kv[1]
+			}
+		}
+	}
+	for k, v := range props {
+		if v == "unset" {
+			delete(props, k)
+		}
+	}
+	return props
+}
+
+// FormatOptions returns opts with the properties of the config
+// that the formatter takes applied over them: indent_style,
+// indent_size, tab_width, max_line_length, end_of_line,
+// insert_final_newline and trim_trailing_whitespace.
+func (c EditorConfig) FormatOptions(opts analyzer.FormatOptions) analyzer.FormatOptions {
+	switch c["indent_style"] {
+	case "space":
+		opts.InsertSpaces = true
+	case "tab":
+		opts.InsertSpaces = false
+	}
+	size := c["indent_size"]
+	if size == "tab" || size == "" {
+		size = c["tab_width"]
+	}
+	if n, err := strconv.Atoi(size); err == nil && n > 0 {
+		opts.TabSize = n
+	}
+	if n, err := strconv.Atoi(c["max_line_length"]); err == nil && n > 0 {
+		opts.PrintWidth = n
+	}
+	switch c["end_of_line"] {
+	case "lf":
+		opts.EndOfLine = analyzer.EndOfLineLF
+	case "crlf":
+		opts.EndOfLine = analyzer.EndOfLineCRLF
+	case "cr":
+		opts.EndOfLine = analyzer.EndOfLineCR
+	}
+	switch c["insert_final_newline"] {
+	case "true":
+		opts.OmitFinalNewline = false
+	case "false":
+		opts.OmitFinalNewline = true
+	}
+	switch c["trim_trailing_whitespace"] {
+	case "true":
+		opts.TrimTrailingWhitespace = true
+	case "false":
+		opts.TrimTrailingWhitespace = false
+	}
+	return opts
+}
+
+// parseEditorConfig parses an .editorconfig file, returning its
+// sections and whether its preamble marks it root. Blank lines and
+// comments, starting with '#' or ';', are skipped, as are
+// sections whose glob is malformed.
+func parseEditorConfig(src []byte) ([]editorConfigSection, bool) {
+	var sections []editorConfigSection
+	var sec *editorConfigSection
+	root := false
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			sec = nil
+			end := strings.LastIndexByte(line, ']')
+			if end < 1 {
+				continue
+			}
+			if re, ranges, ok := editorConfigGlob(line[1:end]); ok {
+				sections = append(sections, editorConfigSection{
+					pattern: re,
+					ranges:  ranges,
+				})
+				sec = &sections[len(sections)-1]
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		switch {
+		case sec != nil:
+			sec.props = append(sec.props, [2]string{key, value})
+		case key == "root":
+			root = value == "true"
+		}
+	}
+	return sections, root
+}
+
+// matches reports whether the section applies to rel, a
+// slash-separated path relative to the directory of its file.
+func (s editorConfigSection) matches(rel string) bool {
+	m := s.pattern.FindStringSubmatch(rel)
+	if m == nil {
+		return false
+	}
+	for i, r := range s.ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// numericRange matches the body of a "{n1..n2}" glob.
+var numericRange = regexp.MustCompile(`^\{([+-]?\d+)\.\.([+-]?\d+)\}$`)
+
+// editorConfigGlob converts an .editorconfig section glob to a
+// regular expression, with the bounds of its numeric ranges. A
+// glob without a '/' matches files at any depth. In globs, '*'
+// matches within a path segment, "**" across them, '?' one
+// character, "[...]" and "[!...]" a character class, "{a,b}" any
+// of its alternatives and "{n1..n2}" an integer between the two.
+func editorConfigGlob(glob string) (*regexp.Regexp, [][2]int, bool) {
+	var b strings.Builder
+	var ranges [][2]int
+	b.WriteByte('^')
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimPrefix(glob, "/")
+	} else {
+		b.WriteString("(?:.*/)?")
+	}
+	var braces []bool // whether each open brace is a group
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case '*':
+			switch {
+			case strings.HasPrefix(glob[i:], "**/"):
+				b.WriteString("(?:.*/)?")
+				i += 2
+			case strings.HasPrefix(glob[i:], "**"):
+				b.WriteString(".*")
+				i++
+			default:
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			class := ""
+			if end >= 0 {
+				class = glob[i+1 : i+1+end]
+			}
+			if class == "" || strings.Contains(class, "/") {
+				b.WriteString(`\[`)
+				continue
+			}
+			if rest, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + rest
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '{':
+			end, group := braceEnd(glob, i)
+			if m := numericRange.FindStringSubmatch(glob[i : end+1]); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				ranges = append(ranges, [2]int{min(lo, hi), max(lo, hi)})
+				b.WriteString(`([+-]?\d+)`)
+				i = end
+				continue
+			}
+			braces = append(braces, group)
+			if group {
+				b.WriteString("(?:")
+			} else {
+				b.WriteString(`\{`)
+			}
+		case ',':
+			if len(braces) > 0 && braces[len(braces)-1] {
+				b.WriteByte('|')
+			} else {
+				b.WriteByte(',')
+			}
+		case '}':
+			switch {
+			case len(braces) == 0:
+				b.WriteString(`\}`)
+			case braces[len(braces)-1]:
+				b.WriteByte(')')
+			default:
+				b.WriteString(`\}`)
+			}
+			if len(braces) > 0 {
+				braces = braces[:len(braces)-1]
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if len(braces) > 0 {
+		return nil, nil, false
+	}
+	b.WriteByte('$')
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, nil, false
+	}
+	return re, ranges, true
+}
+
+// braceEnd returns the index of the brace closing the one at
+// glob[i], or the end of glob, and whether a comma lies directly
+// within them, making them a group of alternatives.
+func braceEnd(glob string, i int) (int, bool) {
+	depth := 0
+	comma := false
+	for j := i; j < len(glob); j++ {
+		switch glob[j] {
+		case '\\':
+			j++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j, comma
+			}
+		case ',':
+			comma = comma || depth == 1
+		}
+	}
+	return len(glob) - 1, comma
+}
@@ -0,0 +1,137 @@
+package workspace
+
+import (
+	"maps"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+)
+
+func TestResolveEditorConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".editorconfig": `# top
+root = true
+
+[*]
+indent_style = space
+indent_size = 4
+end_of_line = LF
+insert_final_newline = true
+
+[*.{css,scss}]
+max_line_length = 100
+
+[lib/**.css]
+indent_style = tab
+
+[{package.json,*.yml}]
+indent_size = 2
+
+[v{1..3}.css]
+end_of_line = crlf
+`,
+		"src/.editorconfig": `[*.css]
+indent_size = 2
+insert_final_newline = unset
+`,
+	})
+	tests := []struct {
+		file string
+		want EditorConfig
+	}{
+		{"app.css", EditorConfig{
+			"indent_style": "space", "indent_size": "4", "end_of_line": "lf",
+			"insert_final_newline": "true", "max_line_length": "100",
+		}},
+		{"lib/deep/a.css", EditorConfig{
+			"indent_style": "tab", "indent_size": "4", "end_of_line": "lf",
+			"insert_final_newline": "true", "max_line_length": "100",
+		}},
+		{"src/a.css", EditorConfig{
+			"indent_style": "space", "indent_size": "2", "end_of_line": "lf",
+			"max_line_length": "100",
+		}},
+		{"ci.yml", EditorConfig{
+			"indent_style": "space", "indent_size": "2", "end_of_line": "lf",
+			"insert_final_newline": "true",
+		}},
+		{"v2.css", EditorConfig{
+			"indent_style": "space", "indent_size": "4", "end_of_line": "crlf",
+			"insert_final_newline": "true", "max_line_length": "100",
+		}},
+		{"v4.css", EditorConfig{
+			"indent_style": "space", "indent_size": "4", "end_of_line": "lf",
+			"insert_final_newline": "true", "max_line_length": "100",
+		}},
+	}
+	for _, tt := range tests {
+		got := ResolveEditorConfig(filepath.Join(dir, filepath.FromSlash(tt.file)))
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestEditorConfigGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		match []string
+		miss  []string
+	}{
+		{"*.css", []string{"a.css", "x/y/a.css"}, []string{"a.scss"}},
+		{"/a/*.css", []string{"a/b.css"}, []string{"a/b/c.css", "x/a/b.css"}},
+		{"a/**/*.css", []string{"a/b.css", "a/b/c.css"}, []string{"b/c.css"}},
+		{"?.css", []string{"a.css"}, []string{"ab.css"}},
+		{"[ab].css", []string{"a.css", "b.css"}, []string{"c.css"}},
+		{"[!ab].css", []string{"c.css"}, []string{"a.css"}},
+		{"*.{css,{s,p}css}", []string{"a.css", "a.scss", "a.pcss"}, []string{"a.less"}},
+		{"{single}.css", []string{"{single}.css"}, []string{"single.css"}},
+		{"f{-1..10}.css", []string{"f-1.css", "f10.css"}, []string{"f11.css", "fa.css"}},
+	}
+	for _, tt := range tests {
+		sections, _ := parseEditorConfig([]byte("[" + tt.glob + "]\nk = v\n"))
+		if len(sections) != 1 {
+			t.Fatalf("%s: sections = %v", tt.glob, sections)
+		}
+		for _, p := range tt.match {
+			if !sections[0].matches(p) {
+				t.Errorf("%s should match %s", tt.glob, p)
+			}
+		}
+		for _, p := range tt.miss {
+			if sections[0].matches(p) {
+				t.Errorf("%s should not match %s", tt.glob, p)
+			}
+		}
+	}
+}
+
+func TestEditorConfigFormatOptions(t *testing.T) {
+	base := analyzer.FormatOptions{TabSize: 2, InsertSpaces: true, PrintWidth: 80}
+	got := EditorConfig{
+		"indent_style":             "tab",
+		"indent_size":              "tab",
+		"tab_width":                "8",
+		"max_line_length":          "120",
+		"end_of_line":              "crlf",
+		"insert_final_newline":     "false",
+		"trim_trailing_whitespace": "true",
+	}.FormatOptions(base)
+	want := analyzer.FormatOptions{
+		TabSize:                8,
+		PrintWidth:             120,
+		EndOfLine:              analyzer.EndOfLineCRLF,
+		OmitFinalNewline:       true,
+		TrimTrailingWhitespace: true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	got = EditorConfig{"max_line_length": "off"}.FormatOptions(base)
+	if !reflect.DeepEqual(got, base) {
+		t.Errorf("max_line_length off: got %+v", got)
+	}
+}
@@ -0,0 +1,409 @@
+package workspace
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxFileSize is the size above which files are not
+// indexed unless ScanOptions sets another limit. Larger files are
+// almost always generated bundles.
+const DefaultMaxFileSize = 2 << 20
+
+// defaultExtensions are the extensions of the files that are
+// always indexed: stylesheets and HTML documents, whose embedded
+// CSS is indexed.
+var defaultExtensions = []string{".css", ".html", ".htm"}
+
+// GitignoreFile is the name of the files whose patterns exclude
+// paths from scanning.
+const GitignoreFile = ".gitignore"
+
+// defaultExclude holds gitignore-style patterns for the
+// directories of dependencies, build output and version control.
+// They apply before any .gitignore file, so that a negation there
+// or in ScanOptions.Exclude indexes such a directory again, as
+// does an include glob that names it.
+var defaultExclude = []string{
+	"node_modules/",
+	".git/",
+	"dist/",
+	"vendor/",
+	".next/",
+	"bower_components/",
+}
+
+// defaultMarkup are the extensions of the markup files whose
+// class names and ids are indexed unless ScanOptions sets others.
+var defaultMarkup = []string{".html", ".htm", ".jsx", ".tsx"}
+
+// ScanOptions configures which files workspace scanning indexes.
+// The zero value indexes every stylesheet, HTML document and JSX
+// file below the root that no .gitignore file excludes, outside
+// dependency and build output directories such as node_modules
+// and dist.
+type ScanOptions struct {
+	// Include restricts scanning to files matching at least one
+	// of these globs, relative to the root. "**" matches any
+	// number of directories. A glob that names a directory
+	// excluded by default, such as "dist/**", indexes it.
+	Include []string
+	// Exclude holds additional gitignore-style patterns, applied
+	// as if they were in a .gitignore file at the root.
+	Exclude []string
+	// Extensions lists file extensions indexed besides
+	// defaultExtensions.
+	Extensions []string
+	// Markup lists the extensions of the markup files whose class
+	// names and ids are indexed. Nil means defaultMarkup.
+	Markup []string
+	// IgnoreGitignore disables .gitignore handling.
+	IgnoreGitignore bool
+	// FollowSymlinks follows symbolic links to files and
+	// directories. Otherwise they are skipped.
+	FollowSymlinks bool
+	// MaxFileSize is the largest file, in bytes, that is indexed.
+	// Zero means DefaultMaxFileSize.
+	MaxFileSize int64
+}
+
+// Filter decides which files below a workspace root are indexed.
+// It reads .gitignore files lazily and caches them until Reload
+// is called. A Filter is safe for concurrent use.
+type Filter struct {
+	root       string
+	opts       ScanOptions
+	extensions []string
+	markup     []string
+	defaults   []ignoreRule
+	exclude    []ignoreRule
+
+	mu      sync.Mutex
+	ignores map[string][]ignoreRule // slash-separated dir -> rules
+}
+
+// NewFilter returns a filter for files below root.
+func NewFilter(root string, opts ScanOptions) *Filter {
+	f := &Filter{
+		root:       filepath.Clean(root),
+		opts:       opts,
+		extensions: slices.Clone(defaultExtensions),
+		ignores:    make(map[string][]ignoreRule),
+	}
+	if f.opts.MaxFileSize <= 0 {
+		f.opts.MaxFileSize = DefaultMaxFileSize
+	}
+	f.extensions = appendExtensions(f.extensions, opts.Extensions)
+	markup := opts.Markup
+	if markup == nil {
+		markup = defaultMarkup
+	}
+	f.markup = appendExtensions(nil, markup)
+	for _, p := range defaultExclude {
+		if r, ok := parseIgnoreLine(p); ok {
+			f.defaults = append(f.defaults, r)
+		}
+	}
+	for _, p := range opts.Exclude {
+		if r, ok := parseIgnoreLine(p); ok {
+			f.exclude = append(f.exclude, r)
+		}
+	}
+	return f
+}
+
+// appendExtensions appends the extensions in exts not yet in
+// list, normalized to lower case with a leading dot.
+func appendExtensions(list, exts []string) []string {
+	for _, ext := range exts {
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if ext = strings.ToLower(ext); !slices.Contains(list, ext) {
+			list = append(list, ext)
+		}
+	}
+	return list
+}
+
+// Reload discards the cached .gitignore files so that they are
+// read again on next use.
+func (f *Filter) Reload() {
+	f.mu.Lock()
+	clear(f.ignores)
+	f.mu.Unlock()
+}
+
+// Root returns the directory the filter applies to.
+func (f *Filter) Root() string {
+	return f.root
+}
+
+// Extensions returns the file extensions the filter indexes,
+// stylesheets' and then markup's.
+func (f *Filter) Extensions() []string {
+	return appendExtensions(slices.Clone(f.extensions), f.markup)
+}
+
+// IsStylesheet reports whether the file at path is indexed for its
+// custom properties, judging by its extension.
+func (f *Filter) IsStylesheet(p string) bool {
+	return slices.Contains(f.extensions, strings.ToLower(path.Ext(p)))
+}
+
+// IsMarkup reports whether the file at path is indexed for its
+// class names and ids, judging by its extension.
+func (f *Filter) IsMarkup(p string) bool {
+	return slices.Contains(f.markup, strings.ToLower(path.Ext(p)))
+}
+
+// Includes reports whether a scan would index the file at path,
+// which must be absolute. It consults the file system, so files
+// that no longer exist are not included.
+func (f *Filter) Includes(p string) bool {
+	rel, ok := f.rel(p)
+	if !ok || rel == "." {
+		return false
+	}
+	info, err := os.Lstat(p)
+	if err != nil {
+		return false
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if !f.opts.FollowSymlinks {
+			return false
+		}
+		if info, err = os.Stat(p); err != nil {
+			return false
+		}
+	}
+	if !info.Mode().IsRegular() || info.Size() > f.opts.MaxFileSize {
+		return false
+	}
+
+	// A file inside an excluded directory can't be included again.
+	dirs := strings.Split(rel, "/")
+	for i := 1; i < len(dirs); i++ {
+		if f.skipDir(strings.Join(dirs[:i], "/")) {
+			return false
+		}
+	}
+	return f.includeFile(rel)
+}
+
+// Walk calls fn with the absolute path of every file below the
+// root that the filter includes. Excluded directories are not
+// descended into, and with FollowSymlinks each directory is
+// visited once even if links lead to it repeatedly. Unreadable
+// entries below the root are skipped; Walk returns an error only
+// when the root can't be read or ctx is cancelled.
+func (f *Filter) Walk(ctx context.Context, fn func(path string)) error {
+	if _, err := os.ReadDir(f.root); err != nil {
+		return err
+	}
+	visited := make(map[string]bool)
+	f.walk(ctx, f.root, ".", visited, false, fn)
+	return ctx.Err()
+}
+
+// WalkAll is like Walk, but calls fn for every file that isn't
+// excluded, whatever its extension or size: the default excludes,
+// .gitignore files and the exclude patterns apply, but the include
+// globs only reopen the directories they name. It finds files, such as images,
+// that aren't indexed.
+func (f *Filter) WalkAll(ctx context.Context, fn func(path string)) error {
+	if _, err := os.ReadDir(f.root); err != nil {
+		return err
+	}
+	visited := make(map[string]bool)
+	f.walk(ctx, f.root, ".", visited, true, fn)
+	return ctx.Err()
+}
+
+func (f *Filter) walk(
+	ctx context.Context,
+	dir, rel string,
+	visited map[string]bool,
+	all bool,
+	fn func(string),
+) {
+	if ctx.Err() != nil {
+		return
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		if visited[real] {
+			return
+		}
+		visited[real] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		r := path.Join(rel, e.Name())
+
+		typ := e.Type()
+		var size int64 = -1
+		if typ&fs.ModeSymlink != 0 {
+			if !f.opts.FollowSymlinks {
+				continue
+			}
+			info, err := os.Stat(p)
+			if err != nil {
+				continue
+			}
+			typ = info.Mode().Type()
+			size = info.Size()
+		}
+
+		switch {
+		case typ.IsDir():
+			if !f.skipDir(r) {
+				f.walk(ctx, p, r, visited, all, fn)
+			}
+		case typ.IsRegular() && all:
+			if !f.ignored(r, false) {
+				fn(p)
+			}
+		case typ.IsRegular():
+			if !f.includeFile(r) {
+				continue
+			}
+			if size < 0 {
+				info, err := e.Info()
+				if err != nil {
+					continue
+				}
+				size = info.Size()
+			}
+			if size <= f.opts.MaxFileSize {
+				fn(p)
+			}
+		}
+	}
+}
+
+// rel returns p relative to the root with forward slashes, or
+// false if p is outside the root.
+func (f *Filter) rel(p string) (string, bool) {
+	rel, err := filepath.Rel(f.root, p)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// skipDir reports whether the directory at rel is excluded.
+func (f *Filter) skipDir(rel string) bool {
+	return f.ignored(rel, true)
+}
+
+// namedByInclude reports whether an include glob names the
+// directory at rel, or one inside it, without a "**" standing in
+// for any of its segments.
+func (f *Filter) namedByInclude(rel string) bool {
+	segs := strings.Split(rel, "/")
+	return slices.ContainsFunc(f.opts.Include, func(g string) bool {
+		pattern := strings.Split(strings.Trim(g, "/"), "/")
+		if len(pattern) < len(segs) {
+			return false
+		}
+		for i, seg := range segs {
+			if pattern[i] == "**" {
+				return false
+			}
+			if ok, _ := path.Match(pattern[i], seg); !ok {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// includeFile reports whether the file at rel has an indexed
+// extension, is not ignored and matches the include globs. The
+// directories containing it are assumed to be included.
+func (f *Filter) includeFile(rel string) bool {
+	if !f.IsStylesheet(rel) && !f.IsMarkup(rel) || f.ignored(rel, false) {
+		return false
+	}
+	if len(f.opts.Include) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(f.opts.Include, func(g string) bool {
+		return matchGlob(g, rel)
+	})
+}
+
+// ignored reports whether rel is excluded by default, by the
+// exclude setting or by a .gitignore file in one of its parent
+// directories. As in git, the last matching pattern wins and
+// patterns from deeper .gitignore files take precedence; the
+// default excludes come first, and don't apply to directories an
+// include glob names.
+func (f *Filter) ignored(rel string, isDir bool) bool {
+	ignored := false
+	apply := func(rules []ignoreRule, base string) {
+		sub := rel
+		if base != "." {
+			sub = strings.TrimPrefix(rel, base+"/")
+		}
+		for _, r := range rules {
+			if r.match(sub, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	if isDir && !f.namedByInclude(rel) {
+		apply(f.defaults, ".")
+	}
+	if !f.opts.IgnoreGitignore {
+		dir := "."
+		for {
+			apply(f.gitignore(dir), dir)
+			next, _, ok := strings.Cut(strings.TrimPrefix(rel, dir+"/"), "/")
+			if !ok {
+				break
+			}
+			if dir == "." {
+				dir = next
+			} else {
+				dir += "/" + next
+			}
+		}
+	}
+	apply(f.exclude, ".")
+	return ignored
+}
+
+// gitignore returns the rules of the .gitignore file in dir,
+// reading it on first use.
+func (f *Filter) gitignore(dir string) []ignoreRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rules, ok := f.ignores[dir]
+	if !ok {
+		src, err := os.ReadFile( //nolint:gosec
+			filepath.Join(f.root, filepath.FromSlash(dir), GitignoreFile),
+		)
+		if err == nil {
+			rules = parseIgnore(src)
+		}
+		f.ignores[dir] = rules
+	}
+	return rules
+}
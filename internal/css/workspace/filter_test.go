@@ -0,0 +1,340 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files under dir. Keys are slash-separated
+// paths relative to dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// walked returns the files f includes, relative to its root.
+func walked(t *testing.T, f *Filter) []string {
+	t.Helper()
+	var got []string
+	err := f.Walk(context.Background(), func(p string) {
+		rel, _ := filepath.Rel(f.Root(), p)
+		got = append(got, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestFilterGitignore(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":             "build/\n*.min.css\n/coverage\n",
+		"app.css":                "",
+		"app.min.css":            "",
+		"build/out.css":          "",
+		"coverage/report.css":    "",
+		"src/coverage/cov.css":   "",
+		"src/.gitignore":         "generated-*.css\n!generated-keep.css\n",
+		"src/generated-a.css":    "",
+		"src/generated-keep.css": "",
+		"src/theme/.gitignore":   "!*.min.css\n",
+		"src/theme/dark.min.css": "",
+		"node_modules/pkg/a.css": "",
+		"notes.txt":              "",
+	})
+
+	want := []string{
+		"app.css",
+		"src/coverage/cov.css",
+		"src/generated-keep.css",
+		"src/theme/dark.min.css",
+	}
+	f := NewFilter(dir, ScanOptions{})
+	got := walked(t, f)
+	if !slices.Equal(got, want) {
+		t.Errorf("Walk = %v, want %v", got, want)
+	}
+
+	// Includes agrees with Walk for every file in the tree.
+	_ = filepath.WalkDir(dir, func(p string, d os.DirEntry, _ error) error {
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		if f.Includes(p) != slices.Contains(want, rel) {
+			t.Errorf("Includes(%s) = %v", rel, f.Includes(p))
+		}
+		return nil
+	})
+
+	all := walked(t, NewFilter(dir, ScanOptions{IgnoreGitignore: true}))
+	if !slices.Contains(all, "build/out.css") ||
+		slices.Contains(all, "node_modules/pkg/a.css") {
+		t.Errorf("without .gitignore: %v", all)
+	}
+}
+
+func TestFilterParentDirectoryCannotBeReincluded(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":     "out/\n!out/keep.css\n",
+		"out/keep.css":   "",
+		"gen/.gitignore": "",
+	})
+	if got := walked(t, NewFilter(dir, ScanOptions{})); len(got) != 0 {
+		t.Errorf("Walk = %v, want nothing", got)
+	}
+}
+
+func TestFilterDefaultExcludes(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"app.css":                       "",
+		"dist/out.css":                  "",
+		"vendor/lib.css":                "",
+		"node_modules/pkg/a.css":        "",
+		"packages/ui/dist/ui.css":       "",
+		"packages/ui/dist/vendor/x.css": "",
+	})
+	tests := []struct {
+		name string
+		opts ScanOptions
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"app.css"},
+		},
+		{
+			name: "exclude negation",
+			opts: ScanOptions{Exclude: []string{"!dist/"}},
+			want: []string{"app.css", "dist/out.css", "packages/ui/dist/ui.css"},
+		},
+		{
+			name: "include naming the directory",
+			opts: ScanOptions{Include: []string{"packages/*/dist/**", "vendor/*.css"}},
+			want: []string{"packages/ui/dist/ui.css", "vendor/lib.css"},
+		},
+		{
+			name: "include through **",
+			opts: ScanOptions{Include: []string{"**/*.css"}},
+			want: []string{"app.css"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFilter(dir, tt.opts)
+			if got := walked(t, f); !slices.Equal(got, tt.want) {
+				t.Errorf("Walk = %v, want %v", got, tt.want)
+			}
+			for _, rel := range tt.want {
+				if !f.Includes(filepath.Join(dir, filepath.FromSlash(rel))) {
+					t.Errorf("Includes(%s) = false", rel)
+				}
+			}
+		})
+	}
+
+	// A .gitignore negation reopens a directory too.
+	writeTree(t, dir, map[string]string{".gitignore": "!vendor/\n"})
+	got := walked(t, NewFilter(dir, ScanOptions{}))
+	if want := []string{"app.css", "vendor/lib.css"}; !slices.Equal(got, want) {
+		t.Errorf("with .gitignore negation: Walk = %v, want %v", got, want)
+	}
+}
+
+func TestFilterIncludeExcludeExtensions(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/a.css":          "",
+		"src/b.pcss":         "",
+		"src/c.SCSS":         "",
+		"src/legacy/d.css":   "",
+		"docs/e.css":         "",
+		"src/vendor.min.css": "",
+	})
+
+	f := NewFilter(dir, ScanOptions{
+		Include:    []string{"src/**"},
+		Exclude:    []string{"legacy/", "*.min.css"},
+		Extensions: []string{".pcss", "scss"},
+	})
+	want := []string{"src/a.css", "src/b.pcss", "src/c.SCSS"}
+	if got := walked(t, f); !slices.Equal(got, want) {
+		t.Errorf("Walk = %v, want %v", got, want)
+	}
+	exts := f.Extensions()
+	want = []string{".css", ".html", ".htm", ".pcss", ".scss", ".jsx", ".tsx"}
+	if !slices.Equal(exts, want) {
+		t.Errorf("Extensions = %v", exts)
+	}
+}
+
+func TestFilterMarkup(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.css":      "",
+		"page.html":  "",
+		"app.tsx":    "",
+		"view.vue":   "",
+		"util.ts":    "",
+		"legacy.jsx": "",
+	})
+
+	f := NewFilter(dir, ScanOptions{})
+	want := []string{"a.css", "app.tsx", "legacy.jsx", "page.html"}
+	if got := walked(t, f); !slices.Equal(got, want) {
+		t.Errorf("Walk = %v, want %v", got, want)
+	}
+	if !f.IsStylesheet("page.html") || !f.IsMarkup("page.html") ||
+		f.IsStylesheet("app.tsx") || f.IsMarkup("a.css") {
+		t.Error("page.html is both, app.tsx only markup, a.css only CSS")
+	}
+
+	f = NewFilter(dir, ScanOptions{Markup: []string{"vue"}})
+	want = []string{"a.css", "page.html", "view.vue"}
+	if got := walked(t, f); !slices.Equal(got, want) {
+		t.Errorf("Walk with Markup = %v, want %v", got, want)
+	}
+	if f.IsMarkup("page.html") {
+		t.Error("Markup replaces the default markup extensions")
+	}
+}
+
+func TestFilterMaxFileSize(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"small.css": "a{}",
+		"large.css": strings.Repeat("a{}", 100),
+	})
+	f := NewFilter(dir, ScanOptions{MaxFileSize: 64})
+	if got := walked(t, f); !slices.Equal(got, []string{"small.css"}) {
+		t.Errorf("Walk = %v", got)
+	}
+	if f.Includes(filepath.Join(dir, "large.css")) {
+		t.Error("Includes should reject files over the size limit")
+	}
+}
+
+func TestFilterSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeTree(t, dir, map[string]string{"src/a.css": ""})
+	writeTree(t, outside, map[string]string{"shared.css": ""})
+
+	links := map[string]string{
+		"linked.css": filepath.Join(outside, "shared.css"),
+		"shared":     outside,
+		"src/loop":   dir,
+	}
+	for name, target := range links {
+		link := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+
+	got := walked(t, NewFilter(dir, ScanOptions{}))
+	if !slices.Equal(got, []string{"src/a.css"}) {
+		t.Errorf("without following: %v", got)
+	}
+	if NewFilter(dir, ScanOptions{}).Includes(filepath.Join(dir, "linked.css")) {
+		t.Error("Includes should reject symlinks unless following them")
+	}
+
+	// Following links visits each directory once, so the link
+	// back to the root doesn't loop.
+	f := NewFilter(dir, ScanOptions{FollowSymlinks: true})
+	want := []string{"linked.css", "shared/shared.css", "src/a.css"}
+	if got := walked(t, f); !slices.Equal(got, want) {
+		t.Errorf("following: %v, want %v", got, want)
+	}
+	if !f.Includes(filepath.Join(dir, "linked.css")) {
+		t.Error("Includes should accept followed symlinks")
+	}
+}
+
+func TestScanWithFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":    "dist-*/\n",
+		"tokens.css":    ":root { --brand: red; }",
+		"dist-v1/a.css": ":root { --old: red; }",
+	})
+	idx := NewIndex()
+	if err := idx.ScanWorkspace(dir); err != nil {
+		t.Fatal(err)
+	}
+	if !idx.HasVariable("--brand") || idx.HasVariable("--old") {
+		t.Errorf("Files() = %v", idx.Files())
+	}
+	if err := idx.ScanWorkspace(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing root")
+	}
+}
+
+func TestScanParallel(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := range 40 {
+		files[fmt.Sprintf("f%02d.css", i)] = fmt.Sprintf(":root { --v%d: %d; }", i, i)
+	}
+	writeTree(t, dir, files)
+
+	var calls []int
+	total := 0
+	idx := NewIndex()
+	err := idx.Scan(context.Background(), NewFilter(dir, ScanOptions{}), ScanConfig{
+		Workers: 4,
+		Skip: func(uri string) bool {
+			return strings.HasSuffix(uri, "/f07.css")
+		},
+		Progress: func(done, n int) {
+			calls = append(calls, done)
+			total = n
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 40 || len(calls) != 40 || calls[39] != 40 {
+		t.Errorf("progress calls = %v, total %d", calls, total)
+	}
+	if len(idx.Files()) != 39 || idx.HasVariable("--v7") ||
+		!idx.HasVariable("--v39") {
+		t.Errorf("Files() = %v", idx.Files())
+	}
+}
+
+func TestScanCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.css": ":root { --a: 1; }"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	idx := NewIndex()
+	err := idx.Scan(ctx, NewFilter(dir, ScanOptions{}), ScanConfig{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Scan = %v, want context.Canceled", err)
+	}
+	if len(idx.Files()) != 0 {
+		t.Errorf("cancelled scan indexed %v", idx.Files())
+	}
+}
@@ -0,0 +1,125 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strings"
+)
+
+// ignoreRule is one pattern from a .gitignore file or from the
+// exclude setting.
+type ignoreRule struct {
+	// segments is the pattern split on '/'. A "**" segment
+	// matches any number of path segments.
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// parseIgnore parses gitignore-format patterns. Blank lines and
+// comments are skipped; a leading '!' negates a pattern, a
+// trailing '/' restricts it to directories, and patterns without
+// a '/' elsewhere match at any depth.
+func parseIgnore(src []byte) []ignoreRule {
+	var rules []ignoreRule
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		if r, ok := parseIgnoreLine(sc.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine parses a single gitignore pattern.
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+
+	var r ignoreRule
+	if line[0] == '!' {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to the
+	// directory of the .gitignore file.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if !anchored {
+		line = "**/" + line
+	}
+
+	for _, seg := range strings.Split(line, "/") {
+		if seg == "" {
+			continue
+		}
+		// gitignore negates character classes with '!', while
+		// path.Match expects '^'.
+		r.segments = append(r.segments, strings.ReplaceAll(seg, "[!", "[^"))
+	}
+	return r, len(r.segments) > 0
+}
+
+// match reports whether the rule matches rel, a slash-separated
+// path relative to the directory the rule was read from.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments,
+// where a "**" pattern segment matches zero or more path
+// segments.
+func matchSegments(pattern, segs []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				// A trailing "**" matches everything inside a
+				// directory but not the directory itself.
+				return len(segs) > 0
+			}
+			for i := range segs {
+				if matchSegments(pattern, segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+			return false
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// matchGlob reports whether rel matches the slash-separated glob
+// pattern. Unlike gitignore patterns, globs always match from the
+// start of rel.
+func matchGlob(pattern, rel string) bool {
+	segs := strings.Split(strings.Trim(pattern, "/"), "/")
+	return matchSegments(segs, strings.Split(rel, "/"))
+}
@@ -0,0 +1,58 @@
+package workspace
+
+import "testing"
+
+func TestIgnoreRuleMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"build", "build", true, true},
+		{"build", "src/build", true, true},
+		{"build", "src/build", false, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/build", "src/build", true, false},
+		{"/build", "build", true, true},
+		{"*.min.css", "a/b/site.min.css", false, true},
+		{"*.min.css", "site.css", false, false},
+		{"src/*.css", "src/a.css", false, true},
+		{"src/*.css", "src/sub/a.css", false, false},
+		{"src/*.css", "lib/src/a.css", false, false},
+		{"src/**/*.css", "src/a.css", false, true},
+		{"src/**/*.css", "src/x/y/a.css", false, true},
+		{"gen/**", "gen", true, false},
+		{"gen/**", "gen/a.css", false, true},
+		{"**/out", "a/b/out", true, true},
+		{"theme-[!a]*.css", "theme-b.css", false, true},
+		{"theme-[!a]*.css", "theme-a.css", false, false},
+		{"file?.css", "file1.css", false, true},
+		{`\#hash.css`, "#hash.css", false, true},
+		{"trailing.css   ", "trailing.css", false, true},
+	}
+	for _, tt := range tests {
+		r, ok := parseIgnoreLine(tt.pattern)
+		if !ok {
+			t.Fatalf("parseIgnoreLine(%q) returned no rule", tt.pattern)
+		}
+		if got := r.match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q match %q (dir=%v) = %v, want %v",
+				tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestParseIgnore(t *testing.T) {
+	rules := parseIgnore([]byte("# comment\n\n*.css\r\n!keep.css\n\\!bang\n/\n"))
+	if len(rules) != 3 {
+		t.Fatalf("rules = %+v, want 3", rules)
+	}
+	if rules[0].negate || !rules[1].negate || rules[2].negate {
+		t.Errorf("rules = %+v", rules)
+	}
+	if !rules[2].match("!bang", false) {
+		t.Error(`escaped "!" should match literally`)
+	}
+}
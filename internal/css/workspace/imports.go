@@ -0,0 +1,354 @@
+package workspace
+
+import (
+	"cmp"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/lsp/pathutil"
+)
+
+// packageDir is the directory packages are installed in.
+const packageDir = "node_modules"
+
+// importTargets returns the targets of the @import rules of ss, in
+// document order.
+func importTargets(ss *parser.Stylesheet, src []byte) []string {
+	var targets []string
+	for _, link := range analyzer.FindDocumentLinks(ss, src) {
+		if link.Import {
+			targets = append(targets, link.Target)
+		}
+	}
+	return targets
+}
+
+// Resolver finds the stylesheets that @import rules load:
+// relative to the importing file, through path aliases, or from
+// packages in node_modules. It also finds the files url()
+// references name, which may be in asset directories such as a
+// public folder.
+type Resolver struct {
+	root    string
+	aliases []importAlias
+	assets  []string
+	filter  *Filter
+
+	// listed holds the files ClosestFile found below each
+	// directory it searched, until Refresh.
+	mu     sync.Mutex
+	listed map[string][]string
+}
+
+// importAlias maps imports starting with prefix to a directory.
+type importAlias struct {
+	prefix string
+	dir    string
+}
+
+// NewResolver returns a resolver for the workspace folder at root.
+// Each alias maps an import prefix, such as "@/" or "~styles", to
+// a directory relative to root. Longer prefixes win. assets lists
+// the directories, relative to root, that url() references are
+// also looked up in. f, if not nil, is the folder's filter, whose
+// exclusions ClosestFile honors below root.
+func NewResolver(
+	root string,
+	aliases map[string]string,
+	assets []string,
+	f *Filter,
+) *Resolver {
+	r := &Resolver{root: root, filter: f, listed: make(map[string][]string)}
+	for _, dir := range assets {
+		if dir != "" {
+			r.assets = append(r.assets, rootedDir(root, dir))
+		}
+	}
+	for prefix, dir := range aliases {
+		if prefix == "" {
+			continue
+		}
+		r.aliases = append(r.aliases, importAlias{
+			prefix: prefix,
+			dir:    rootedDir(root, dir),
+		})
+	}
+	slices.SortFunc(r.aliases, func(a, b importAlias) int {
+		return cmp.Or(
+			cmp.Compare(len(b.prefix), len(a.prefix)),
+			strings.Compare(a.prefix, b.prefix),
+		)
+	})
+	return r
+}
+
+// rootedDir returns dir, a slash-separated path relative to root
+// unless it is absolute, as an absolute path.
+func rootedDir(root, dir string) string {
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(root, filepath.FromSlash(dir))
+}
+
+// Resolve returns the path of the stylesheet that the file at
+// from loads with an @import of target, or false if it can't be
+// found. A target that isn't relative, an alias or a root-relative
+// path is looked up next to the importer first, as CSS does, and
+// then as a package, as bundlers do; "~" forces the package
+// lookup.
+func (r *Resolver) Resolve(from, target string) (string, bool) {
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		target = target[:i]
+	}
+	if target == "" || analyzer.IsRemoteURL(target) {
+		return "", false
+	}
+	dir := filepath.Dir(from)
+
+	for _, a := range r.aliases {
+		if rest, ok := strings.CutPrefix(target, a.prefix); ok {
+			return stylesheetFile(filepath.Join(a.dir, filepath.FromSlash(rest)))
+		}
+	}
+	if spec, ok := strings.CutPrefix(target, "~"); ok {
+		return resolvePackage(dir, spec)
+	}
+	if strings.HasPrefix(target, "/") {
+		if r.root == "" {
+			return "", false
+		}
+		return stylesheetFile(filepath.Join(r.root, filepath.FromSlash(target)))
+	}
+	if p, ok := stylesheetFile(filepath.Join(dir, filepath.FromSlash(target))); ok {
+		return p, true
+	}
+	if strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	return resolvePackage(dir, target)
+}
+
+// stylesheetFile returns p, or p with a .css extension added, if
+// it names a regular file.
+func stylesheetFile(p string) (string, bool) {
+	for _, c := range []string{p, p + ".css"} {
+		if info, err := os.Stat(c); err == nil && info.Mode().IsRegular() {
+			return c, true
+		}
+		if filepath.Ext(p) != "" {
+			break
+		}
+	}
+	return "", false
+}
+
+// resolvePackage finds the stylesheet a package import names,
+// looking for the package in the node_modules directories of dir
+// and its parents. A bare package name loads the package's entry
+// stylesheet; a path within the package loads that file.
+func resolvePackage(dir, spec string) (string, bool) {
+	name, sub := spec, ""
+	parts := strings.SplitN(spec, "/", 3)
+	switch {
+	case strings.HasPrefix(spec, "@") && len(parts) >= 2:
+		name = parts[0] + "/" + parts[1]
+		if len(parts) == 3 {
+			sub = parts[2]
+		}
+	case len(parts) >= 2:
+		name, sub = parts[0], strings.Join(parts[1:], "/")
+	}
+	if name == "" || strings.HasPrefix(name, ".") {
+		return "", false
+	}
+
+	for {
+		pkg := filepath.Join(dir, packageDir, filepath.FromSlash(name))
+		if info, err := os.Stat(pkg); err == nil && info.IsDir() {
+			if sub != "" {
+				return stylesheetFile(filepath.Join(pkg, filepath.FromSlash(sub)))
+			}
+			return packageEntry(pkg)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// packageManifest is what is read from a package.json file.
+type packageManifest struct {
+	Style   string `json:"style"`
+	Main    string `json:"main"`
+	Exports any    `json:"exports"`
+}
+
+// packageEntry returns the stylesheet a package directory exports:
+// its "style" export condition, its "style" field, a stylesheet
+// its exports or "main" field name, or index.css.
+func packageEntry(pkg string) (string, bool) {
+	var m packageManifest
+	manifest := filepath.Join(pkg, "package.json")
+	if b, err := os.ReadFile(manifest); err == nil { //nolint:gosec
+		_ = json.Unmarshal(b, &m)
+	}
+	var candidates []string
+	if entry, ok := exportsEntry(m.Exports, "style"); ok {
+		candidates = append(candidates, entry)
+	}
+	candidates = append(candidates, m.Style)
+	if entry, ok := exportsEntry(m.Exports, "default"); ok && isCSSFile(entry) {
+		candidates = append(candidates, entry)
+	}
+	if isCSSFile(m.Main) {
+		candidates = append(candidates, m.Main)
+	}
+	candidates = append(candidates, "index.css")
+
+	for _, c := range candidates {
+		if c == "" {
+			continue
+		}
+		if p, ok := stylesheetFile(filepath.Join(pkg, filepath.FromSlash(c))); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// exportsEntry returns the path a package.json "exports" value
+// gives its main entry under condition: the value itself if it is
+// a path, the "." subpath of a subpath map, or the entry for
+// condition, or failing that "default", of a condition map.
+func exportsEntry(exports any, condition string) (string, bool) {
+	switch v := exports.(type) {
+	case string:
+		return v, condition == "default"
+	case map[string]any:
+		if main, ok := v["."]; ok {
+			return exportsEntry(main, condition)
+		}
+		for _, c := range []string{condition, "default"} {
+			if entry, ok := v[c]; ok {
+				if p, ok := exportsEntry(entry, "default"); ok {
+					return p, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// isCSSFile reports whether p names a .css file.
+func isCSSFile(p string) bool {
+	return strings.EqualFold(filepath.Ext(p), ".css")
+}
+
+// ImportGraph is the graph of the @import rules of indexed
+// stylesheets, resolved to paths.
+type ImportGraph struct {
+	imports   map[string][]string // path -> imported paths
+	importers map[string][]string // path -> importing paths
+}
+
+// ImportGraph resolves the imports of every indexed stylesheet
+// with r.
+func (idx *Index) ImportGraph(r *Resolver) *ImportGraph {
+	idx.mu.RLock()
+	files := make(map[string][]string, len(idx.fileImports))
+	for uri, targets := range idx.fileImports {
+		files[uri] = targets
+	}
+	idx.mu.RUnlock()
+
+	g := &ImportGraph{
+		imports:   make(map[string][]string),
+		importers: make(map[string][]string),
+	}
+	for uri, targets := range files {
+		from := pathutil.URIToFilePath(uri)
+		if from == "" {
+			continue
+		}
+		for _, target := range targets {
+			to, ok := r.Resolve(from, target)
+			if !ok || slices.Contains(g.imports[from], to) {
+				continue
+			}
+			g.imports[from] = append(g.imports[from], to)
+			g.importers[to] = append(g.importers[to], from)
+		}
+	}
+	return g
+}
+
+// Imports returns the paths of the stylesheets the one at path
+// imports.
+func (g *ImportGraph) Imports(path string) []string {
+	return g.imports[path]
+}
+
+// Chain returns the paths of the shortest chain of imports from
+// the stylesheet at from to the one at to, both included, or nil
+// if from doesn't lead to to.
+func (g *ImportGraph) Chain(from, to string) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p == to {
+			var chain []string
+			for ; p != ""; p = prev[p] {
+				chain = append(chain, p)
+			}
+			slices.Reverse(chain)
+			return chain
+		}
+		for _, next := range g.imports[p] {
+			if _, seen := prev[next]; !seen {
+				prev[next] = p
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// Bundle returns the paths of the stylesheets loaded together with
+// the one at path: those importing it, directly or not, and
+// everything they import, path included.
+func (g *ImportGraph) Bundle(path string) map[string]bool {
+	roots := reachable([]string{path}, g.importers)
+	entries := make([]string, 0, len(roots))
+	for p := range roots {
+		entries = append(entries, p)
+	}
+	return reachable(entries, g.imports)
+}
+
+// reachable returns the paths reachable from start through edges,
+// start included.
+func reachable(start []string, edges map[string][]string) map[string]bool {
+	seen := make(map[string]bool)
+	stack := slices.Clone(start)
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		stack = append(stack, edges[p]...)
+	}
+	return seen
+}
@@ -0,0 +1,113 @@
+package workspace
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/app.css":        "",
+		"src/base.css":       "",
+		"src/theme/dark.css": "",
+		"lib/tokens.css":     "",
+		"public/reset.css":   "",
+		"node_modules/styled/package.json": `{"style": "dist/styled.css",
+			"main": "index.js"}`,
+		"node_modules/styled/dist/styled.css": "",
+		"node_modules/@ui/kit/package.json": `{"exports": {
+			".": {"style": "./css/kit.css", "default": "./index.js"}}}`,
+		"node_modules/@ui/kit/css/kit.css":     "",
+		"node_modules/@ui/kit/css/buttons.css": "",
+		"node_modules/plain/index.css":         "",
+		"node_modules/script/package.json":     `{"main": "index.js"}`,
+	})
+	r := NewResolver(dir, map[string]string{
+		"@/":      "src/",
+		"@tokens": "lib/tokens.css",
+	}, nil, nil)
+	from := filepath.Join(dir, "src", "app.css")
+	tests := map[string]string{
+		"./base.css":                "src/base.css",
+		"base.css":                  "src/base.css",
+		"./theme/dark":              "src/theme/dark.css",
+		"base.css?inline":           "src/base.css",
+		"@/theme/dark.css":          "src/theme/dark.css",
+		"@tokens":                   "lib/tokens.css",
+		"/public/reset.css":         "public/reset.css",
+		"styled":                    "node_modules/styled/dist/styled.css",
+		"~styled":                   "node_modules/styled/dist/styled.css",
+		"@ui/kit":                   "node_modules/@ui/kit/css/kit.css",
+		"@ui/kit/css/buttons.css":   "node_modules/@ui/kit/css/buttons.css",
+		"plain":                     "node_modules/plain/index.css",
+		"script":                    "",
+		"./styled":                  "",
+		"missing.css":               "",
+		"https://example.com/a.css": "",
+	}
+	for target, want := range tests {
+		got, ok := r.Resolve(from, target)
+		if want == "" {
+			if ok {
+				t.Errorf("Resolve(%q) = %q, want none", target, got)
+			}
+			continue
+		}
+		if want = filepath.Join(dir, filepath.FromSlash(want)); !ok || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", target, got, ok, want)
+		}
+	}
+}
+
+func TestImportGraph(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"main.css":  `@import "./a.css"; @import url("b.css");`,
+		"a.css":     `@import "./c.css";`,
+		"b.css":     `@import "./c.css"; @import "./missing.css";`,
+		"c.css":     `@import "./a.css";`,
+		"other.css": `:root { --x: 1; }`,
+		"page.html": `<style>@import "./main.css";</style>`,
+	})
+	path := func(name string) string { return filepath.Join(dir, name) }
+	r := NewResolver(dir, nil, nil, nil)
+	for range 2 { // the second scan comes from the cache
+		g := scanCached(t, cacheDir, dir).ImportGraph(r)
+		if got := g.Imports(path("main.css")); !slices.Equal(got,
+			[]string{path("a.css"), path("b.css")}) {
+			t.Errorf("Imports(main.css) = %q", got)
+		}
+		if got := g.Chain(path("c.css"), path("c.css")); len(got) != 1 {
+			t.Errorf("Chain(c, c) = %q", got)
+		}
+		want := []string{path("c.css"), path("a.css")}
+		if got := g.Chain(path("c.css"), path("a.css")); !slices.Equal(got, want) {
+			t.Errorf("Chain(c, a) = %q", got)
+		}
+		if got := g.Chain(path("a.css"), path("main.css")); got != nil {
+			t.Errorf("Chain(a, main) = %q, want none", got)
+		}
+
+		bundle := g.Bundle(path("b.css"))
+		for _, name := range []string{
+			"page.html", "main.css", "a.css", "b.css", "c.css",
+		} {
+			if !bundle[path(name)] {
+				t.Errorf("bundle lacks %s", name)
+			}
+		}
+		if bundle[path("other.css")] {
+			t.Error("bundle includes an unrelated stylesheet")
+		}
+	}
+
+	idx := NewIndex()
+	idx.IndexFile("file:///x/a.css", []byte(`@import "b.css";`))
+	v := idx.Version()
+	idx.IndexFile("file:///x/a.css", []byte(`@import "c.css";`))
+	if idx.Version() == v {
+		t.Error("changing an import should bump the version")
+	}
+}
@@ -3,14 +3,18 @@
 package workspace
 
 import (
+	"context"
 	"io/fs"
 	"os"
-	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
 
+	"github.com/toba/css-lsp/internal/css/embedded"
 	"github.com/toba/css-lsp/internal/css/parser"
 	"github.com/toba/css-lsp/internal/css/scanner"
+	"github.com/toba/lsp/pathutil"
 )
 
 const (
@@ -18,17 +22,6 @@ const (
 	customPropertyPrefix = "--"
 )
 
-// skipDirs contains directory names to skip during workspace
-// scanning.
-var skipDirs = map[string]bool{
-	"node_modules":     true,
-	".git":             true,
-	"dist":             true,
-	"vendor":           true,
-	".next":            true,
-	"bower_components": true,
-}
-
 // VariableDefinition represents a CSS custom property
 // definition.
 type VariableDefinition struct {
@@ -40,58 +33,354 @@ type VariableDefinition struct {
 }
 
 // Index maintains a workspace-wide index of CSS custom
-// properties.
+// properties, of the class names and ids used in markup, and of
+// how files use CSS modules.
 type Index struct {
-	mu          sync.RWMutex
-	definitions map[string][]VariableDefinition // name -> defs
-	fileVars    map[string][]string             // uri -> var names
+	mu           sync.RWMutex
+	definitions  map[string][]VariableDefinition // name -> defs
+	fileVars     map[string][]string             // uri -> var names
+	fileComposes map[string][]ModuleUse          // uri -> CSS uses
+	fileImports  map[string][]string             // uri -> @imports
+	fileMarkup   map[string]markupEntry          // uri -> names used
+	classes      map[string]int                  // name -> file count
+	ids          map[string]int                  // name -> file count
+	// moduleUses holds the uses of each module, by module path.
+	moduleUses map[string][]ModuleUse
+	version    uint64
+}
+
+// markupEntry holds the class names and ids one markup file uses,
+// each sorted and distinct, and its uses of CSS modules.
+type markupEntry struct {
+	classes, ids []string
+	uses         []ModuleUse
+}
+
+// styleEntry holds the custom properties one stylesheet defines,
+// the classes it composes from CSS modules, and the targets of
+// its @import rules.
+type styleEntry struct {
+	vars     []VariableDefinition
+	composes []ModuleUse
+	imports  []string
+}
+
+// fileData is what is indexed from one file: its style entry if
+// it holds CSS, and its class names, ids and module uses if it is
+// markup.
+type fileData struct {
+	css bool
+	styleEntry
+	markup bool
+	markupEntry
+}
+
+// FileURI returns uri as the index keys files: for file URIs, the
+// form pathutil.FilePathToURI writes, whichever characters the
+// client chose to escape. Other URIs are returned unchanged.
+func FileURI(uri string) string {
+	if !strings.HasPrefix(uri, "file:") {
+		return uri
+	}
+	if p := pathutil.URIToFilePath(uri); p != "" {
+		return pathutil.FilePathToURI(p)
+	}
+	return uri
 }
 
 // NewIndex creates a new workspace index.
 func NewIndex() *Index {
 	return &Index{
-		definitions: make(map[string][]VariableDefinition),
-		fileVars:    make(map[string][]string),
+		definitions:  make(map[string][]VariableDefinition),
+		fileVars:     make(map[string][]string),
+		fileComposes: make(map[string][]ModuleUse),
+		fileImports:  make(map[string][]string),
+		fileMarkup:   make(map[string]markupEntry),
+		classes:      make(map[string]int),
+		ids:          make(map[string]int),
+		moduleUses:   make(map[string][]ModuleUse),
 	}
 }
 
-// ScanWorkspace scans all CSS files in the root directory and
-// indexes their custom properties.
+// ScanWorkspace scans all CSS and markup files in the root
+// directory that no .gitignore file excludes and indexes their
+// custom properties, class names and ids.
 func (idx *Index) ScanWorkspace(rootPath string) error {
-	return filepath.WalkDir(rootPath, func(
-		path string,
-		d fs.DirEntry,
-		err error,
-	) error {
-		if err != nil {
-			return err
-		}
+	return idx.Scan(
+		context.Background(),
+		NewFilter(rootPath, ScanOptions{}),
+		ScanConfig{},
+	)
+}
+
+// ScanConfig controls how Scan runs.
+type ScanConfig struct {
+	// Workers bounds the number of files read and parsed at once.
+	// Zero means GOMAXPROCS.
+	Workers int
+	// Skip, if set, is consulted before each file is indexed.
+	// Files it returns true for are left as they are in the
+	// index, for instance because the editor holds newer content.
+	Skip func(uri string) bool
+	// Progress, if set, is called after each file with the number
+	// of files done so far and the total found. Calls don't
+	// overlap, and done increases by one each time.
+	Progress func(done, total int)
+	// Cache, if set, supplies what was indexed from files
+	// unchanged since they were cached and records the others. A
+	// scan that runs to completion also forgets files it no
+	// longer finds.
+	Cache *Cache
+}
 
-		// Skip common non-source directories
-		if d.IsDir() {
-			if skipDirs[d.Name()] {
-				return filepath.SkipDir
+// Scan indexes the custom properties of every stylesheet and the
+// class names and ids of every markup file the filter includes,
+// reading and parsing files on a bounded pool of
+// goroutines. Files that can't be read are skipped. The index is
+// usable while the scan runs and then holds the files indexed so
+// far. When ctx is cancelled, Scan stops early and returns its
+// error.
+func (idx *Index) Scan(ctx context.Context, f *Filter, cfg ScanConfig) error {
+	var paths []string
+	if err := f.Walk(ctx, func(path string) {
+		paths = append(paths, path)
+	}); err != nil {
+		return err
+	}
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, max(len(paths), 1))
+
+	var (
+		mu   sync.Mutex
+		done int
+		wg   sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for range workers {
+		wg.Go(func() {
+			for path := range jobs {
+				idx.scanFile(path, f, cfg)
+				if cfg.Progress != nil {
+					mu.Lock()
+					done++
+					cfg.Progress(done, len(paths))
+					mu.Unlock()
+				}
 			}
-			return nil
+		})
+	}
+
+feed:
+	for _, path := range paths {
+		select {
+		case jobs <- path:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if cfg.Cache != nil {
+		cfg.Cache.retain(paths)
+	}
+	return nil
+}
+
+// scanFile indexes the file at path unless cfg.Skip excludes it,
+// going through cfg.Cache when there is one.
+func (idx *Index) scanFile(path string, f *Filter, cfg ScanConfig) {
+	uri := pathutil.FilePathToURI(path)
+	skip := func() bool {
+		return cfg.Skip != nil && cfg.Skip(uri)
+	}
+	if skip() {
+		return
+	}
+	css, markup := f.IsStylesheet(path), f.IsMarkup(path)
+
+	var info fs.FileInfo
+	if cfg.Cache != nil {
+		var err error
+		if info, err = os.Stat(path); err != nil {
+			return
+		}
+		if d, ok := cfg.Cache.lookup(path, info, nil, css, markup); ok {
+			idx.setFileData(uri, d)
+			return
 		}
+	}
 
-		if !strings.HasSuffix(path, ".css") {
-			return nil
+	src, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return
+	}
+	var d fileData
+	cached := false
+	if cfg.Cache != nil {
+		d, cached = cfg.Cache.lookup(path, info, src, css, markup)
+	}
+	if !cached {
+		d = readFileData(uri, src, css, markup)
+		if cfg.Cache != nil {
+			cfg.Cache.store(path, info, src, d)
 		}
+	}
+	if !skip() {
+		idx.setFileData(uri, d)
+	}
+}
 
-		src, err := os.ReadFile(path) //nolint:gosec
-		if err != nil {
-			return err
+// readFileData returns what is indexed from the file at uri whose
+// content is src, as CSS and as markup, depending on css and
+// markup.
+func readFileData(uri string, src []byte, css, markup bool) fileData {
+	d := fileData{css: css, markup: markup}
+	if css {
+		css := stylesheetSource(uri, src)
+		if ss, _ := parser.Parse(css); ss != nil {
+			d.styleEntry = readStyle(uri, ss, css)
 		}
+	}
+	if markup {
+		d.markupEntry = readMarkup(uri, src)
+	}
+	return d
+}
 
-		uri := "file://" + path
-		idx.IndexFile(uri, src)
-		return nil
-	})
+// readMarkup returns what is indexed from the markup file at uri
+// whose content is src.
+func readMarkup(uri string, src []byte) markupEntry {
+	var m markupEntry
+	m.classes, m.ids = markupNames(uri, src)
+	if !embedded.IsHTML(uri) {
+		m.uses = scriptModuleUses(uri, src)
+	}
+	return m
+}
+
+// setFileData replaces what is indexed for uri with d.
+func (idx *Index) setFileData(uri string, d fileData) {
+	if d.css {
+		idx.setFile(uri, d.styleEntry)
+	}
+	if d.markup {
+		idx.setMarkup(uri, d.markupEntry)
+	}
+}
+
+// IndexMarkup indexes the class names and ids used by a markup
+// file: an HTML document, or JavaScript with JSX. For scripts, the
+// classes they use from the CSS modules they import are indexed
+// too.
+func (idx *Index) IndexMarkup(uri string, src []byte) {
+	uri = FileURI(uri)
+	idx.setMarkup(uri, readMarkup(uri, src))
+}
+
+// setMarkup replaces the class names and ids indexed for uri.
+func (idx *Index) setMarkup(uri string, m markupEntry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	prev, ok := idx.fileMarkup[uri]
+	if !ok || !slices.Equal(prev.classes, m.classes) ||
+		!slices.Equal(prev.ids, m.ids) || !sameModuleUses(prev.uses, m.uses) {
+		idx.version++
+	}
+	idx.removeFileMarkupLocked(uri)
+	for _, name := range m.classes {
+		idx.classes[name]++
+	}
+	for _, name := range m.ids {
+		idx.ids[name]++
+	}
+	idx.addModuleUsesLocked(m.uses)
+	idx.fileMarkup[uri] = m
+}
+
+// removeFileMarkupLocked drops the markup indexed for uri.
+func (idx *Index) removeFileMarkupLocked(uri string) {
+	m, ok := idx.fileMarkup[uri]
+	if !ok {
+		return
+	}
+	release := func(counts map[string]int, names []string) {
+		for _, name := range names {
+			if counts[name]--; counts[name] <= 0 {
+				delete(counts, name)
+			}
+		}
+	}
+	release(idx.classes, m.classes)
+	release(idx.ids, m.ids)
+	idx.removeModuleUsesLocked(uri, m.uses)
+	delete(idx.fileMarkup, uri)
+}
+
+// HasMarkup reports whether any markup file is indexed. Until one
+// is, no class or id can be told to be unused.
+func (idx *Index) HasMarkup() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.fileMarkup) > 0
+}
+
+// HasClass reports whether any indexed markup uses the class.
+func (idx *Index) HasClass(name string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.classes[name] > 0
+}
+
+// HasID reports whether any indexed markup uses the id.
+func (idx *Index) HasID(name string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.ids[name] > 0
+}
+
+// Classes returns the class names used in indexed markup, sorted.
+func (idx *Index) Classes() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return sortedKeys(idx.classes)
+}
+
+// IDs returns the ids used in indexed markup, sorted.
+func (idx *Index) IDs() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return sortedKeys(idx.ids)
+}
+
+// MarkupFiles returns the URIs of the indexed markup files,
+// sorted.
+func (idx *Index) MarkupFiles() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return sortedKeys(idx.fileMarkup)
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
 }
 
-// IndexFile indexes a single file's custom properties.
+// IndexFile indexes a single file's custom properties. For HTML
+// documents, those in the CSS embedded in them are indexed.
 func (idx *Index) IndexFile(uri string, src []byte) {
+	src = stylesheetSource(uri, src)
 	ss, _ := parser.Parse(src)
 	if ss == nil {
 		return
@@ -99,6 +388,16 @@ func (idx *Index) IndexFile(uri string, src []byte) {
 	idx.IndexFileWithStylesheet(uri, ss, src)
 }
 
+// stylesheetSource returns the CSS of the file at uri or path
+// whose content is src: src itself, or for HTML documents the
+// CSS extracted from it, at the same offsets.
+func stylesheetSource(uri string, src []byte) []byte {
+	if embedded.IsHTML(uri) {
+		return embedded.Extract(src).CSS
+	}
+	return src
+}
+
 // IndexFileWithStylesheet indexes a file's custom properties
 // using a pre-parsed stylesheet, avoiding a redundant parse.
 // The src parameter is used to extract raw values for custom
@@ -111,10 +410,27 @@ func (idx *Index) IndexFileWithStylesheet(
 	if ss == nil {
 		return
 	}
+	uri = FileURI(uri)
+	idx.setFile(uri, readStyle(uri, ss, src))
+}
 
-	var defs []VariableDefinition
-	var names []string
+// readStyle returns what is indexed from the stylesheet ss of the
+// file at uri.
+func readStyle(uri string, ss *parser.Stylesheet, src []byte) styleEntry {
+	return styleEntry{
+		vars:     definitions(uri, ss, src),
+		composes: styleModuleUses(uri, ss, src),
+		imports:  importTargets(ss, src),
+	}
+}
 
+// definitions returns the custom properties a stylesheet defines.
+func definitions(
+	uri string,
+	ss *parser.Stylesheet,
+	src []byte,
+) []VariableDefinition {
+	var defs []VariableDefinition
 	parser.Walk(ss, func(n parser.Node) bool {
 		decl, ok := n.(*parser.Declaration)
 		if !ok {
@@ -140,19 +456,41 @@ func (idx *Index) IndexFileWithStylesheet(
 			EndPos:   decl.Property.End,
 			RawValue: rawValue,
 		})
-		names = append(names, name)
 
 		return true
 	})
+	return defs
+}
+
+// setFile replaces the definitions, compositions and imports
+// indexed for uri.
+func (idx *Index) setFile(uri string, s styleEntry) {
+	names := make([]string, len(s.vars))
+	for i, def := range s.vars {
+		names[i] = def.Name
+	}
 
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
+	if !slices.Equal(idx.fileVars[uri], names) ||
+		!sameModuleUses(idx.fileComposes[uri], s.composes) ||
+		!slices.Equal(idx.fileImports[uri], s.imports) {
+		idx.version++
+	}
+
 	// Remove old definitions for this file
 	idx.removeFileVarsLocked(uri)
+	idx.addModuleUsesLocked(s.composes)
+	if len(s.composes) > 0 {
+		idx.fileComposes[uri] = s.composes
+	}
+	if len(s.imports) > 0 {
+		idx.fileImports[uri] = s.imports
+	}
 
 	// Add new definitions
-	for _, def := range defs {
+	for _, def := range s.vars {
 		idx.definitions[def.Name] = append(
 			idx.definitions[def.Name], def,
 		)
@@ -162,12 +500,40 @@ func (idx *Index) IndexFileWithStylesheet(
 
 // RemoveFile removes a file's entries from the index.
 func (idx *Index) RemoveFile(uri string) {
+	uri = FileURI(uri)
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
+	if _, ok := idx.fileMarkup[uri]; ok || len(idx.fileVars[uri]) > 0 ||
+		len(idx.fileComposes[uri]) > 0 || len(idx.fileImports[uri]) > 0 {
+		idx.version++
+	}
 	idx.removeFileVarsLocked(uri)
+	idx.removeFileMarkupLocked(uri)
+}
+
+// Version returns a counter that changes whenever the set of
+// custom property names defined in the workspace, the class names
+// and ids used in its markup, or its imports may have changed.
+// Edits that only change values leave it unchanged.
+func (idx *Index) Version() uint64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.version
+}
+
+// Files returns the URIs of all files indexed for their custom
+// properties, sorted.
+func (idx *Index) Files() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return sortedKeys(idx.fileVars)
 }
 
 func (idx *Index) removeFileVarsLocked(uri string) {
+	idx.removeModuleUsesLocked(uri, idx.fileComposes[uri])
+	delete(idx.fileComposes, uri)
+	delete(idx.fileImports, uri)
+
 	names, ok := idx.fileVars[uri]
 	if !ok {
 		return
@@ -203,6 +569,14 @@ func (idx *Index) LookupDefinitions(
 	return result
 }
 
+// HasVariable reports whether any indexed file defines the
+// custom property.
+func (idx *Index) HasVariable(name string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.definitions[name]) > 0
+}
+
 // ResolveVariable returns the raw value of a custom property.
 // It uses the first definition found (no cascade resolution).
 func (idx *Index) ResolveVariable(
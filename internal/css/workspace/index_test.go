@@ -35,6 +35,25 @@ func TestIndex_IndexFile(t *testing.T) {
 	}
 }
 
+func TestIndex_IndexFileHTML(t *testing.T) {
+	idx := NewIndex()
+
+	src := []byte(`<style>:root { --primary: red; }</style>
+<p style="--local: 1px">`)
+	idx.IndexFile("file:///page.html", src)
+
+	defs := idx.LookupDefinitions("--primary")
+	if len(defs) != 1 || defs[0].RawValue != "red" {
+		t.Fatalf("definitions for --primary = %+v", defs)
+	}
+	if got := string(src[defs[0].StartPos:defs[0].EndPos]); got != "--primary" {
+		t.Errorf("definition spans %q in the document", got)
+	}
+	if !idx.HasVariable("--local") {
+		t.Error("expected custom properties in style attributes")
+	}
+}
+
 func TestIndex_MultipleFiles(t *testing.T) {
 	idx := NewIndex()
 
@@ -78,6 +97,28 @@ func TestIndex_RemoveFile(t *testing.T) {
 	}
 }
 
+func TestIndex_EscapedURIs(t *testing.T) {
+	idx := NewIndex()
+	idx.IndexFile("file:///my dir/a.css", []byte(":root { --a: 1; }"))
+	idx.IndexFile("file:///my%20dir/a.css", []byte(":root { --b: 1; }"))
+
+	files := idx.Files()
+	if len(files) != 1 || files[0] != "file:///my%20dir/a.css" {
+		t.Fatalf("files = %q, want one escaped URI", files)
+	}
+	if idx.HasVariable("--a") {
+		t.Error("the file was indexed twice")
+	}
+
+	idx.RemoveFile("file:///my%20dir/a.css")
+	if idx.HasVariable("--b") {
+		t.Error("the file is still indexed")
+	}
+	if got := FileURI("untitled:Untitled-1"); got != "untitled:Untitled-1" {
+		t.Errorf("FileURI changed a non-file URI to %q", got)
+	}
+}
+
 func TestIndex_ReindexFile(t *testing.T) {
 	idx := NewIndex()
 
@@ -298,3 +339,64 @@ func TestIndexFile_VarsInMediaRule(t *testing.T) {
 		)
 	}
 }
+
+func TestIndex_HasVariable(t *testing.T) {
+	idx := NewIndex()
+	idx.IndexFile("file:///a.css", []byte(`:root { --color: red; }`))
+
+	if !idx.HasVariable("--color") {
+		t.Error("expected --color to be defined")
+	}
+	if idx.HasVariable("--missing") {
+		t.Error("expected --missing to be undefined")
+	}
+
+	idx.RemoveFile("file:///a.css")
+	if idx.HasVariable("--color") {
+		t.Error("expected --color to be gone after removal")
+	}
+}
+
+func TestIndex_Version(t *testing.T) {
+	idx := NewIndex()
+	v := idx.Version()
+
+	idx.IndexFile("file:///plain.css", []byte(`a { color: red; }`))
+	if idx.Version() != v {
+		t.Error("indexing a file without variables changed the version")
+	}
+
+	idx.IndexFile("file:///a.css", []byte(`:root { --color: red; }`))
+	if idx.Version() == v {
+		t.Fatal("adding a variable did not change the version")
+	}
+	v = idx.Version()
+
+	idx.IndexFile("file:///a.css", []byte(`:root { --color: blue; }`))
+	if idx.Version() != v {
+		t.Error("changing only a value changed the version")
+	}
+
+	idx.IndexFile("file:///a.css", []byte(`:root { --accent: blue; }`))
+	if idx.Version() == v {
+		t.Fatal("renaming a variable did not change the version")
+	}
+	v = idx.Version()
+
+	idx.RemoveFile("file:///a.css")
+	if idx.Version() == v {
+		t.Error("removing a file with variables did not change the version")
+	}
+}
+
+func TestIndex_Files(t *testing.T) {
+	idx := NewIndex()
+	idx.IndexFile("file:///b.css", []byte(`a { color: red; }`))
+	idx.IndexFile("file:///a.css", []byte(`:root { --x: 1; }`))
+
+	files := idx.Files()
+	if len(files) != 2 || files[0] != "file:///a.css" ||
+		files[1] != "file:///b.css" {
+		t.Errorf("Files() = %v", files)
+	}
+}
@@ -0,0 +1,154 @@
+package workspace
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/embedded"
+)
+
+// markupNames returns the distinct class names and ids used by
+// the markup file at uri or path whose content is src, each
+// sorted. HTML documents are read as such; other files as
+// JavaScript with JSX, taking the string literals assigned to
+// className, class and id attributes.
+func markupNames(uri string, src []byte) (classes, ids []string) {
+	if embedded.IsHTML(uri) {
+		d := embedded.Extract(src)
+		classes, ids = d.Classes, d.IDs
+	} else {
+		classes, ids = jsxNames(src)
+	}
+	slices.Sort(classes)
+	slices.Sort(ids)
+	return slices.Compact(classes), slices.Compact(ids)
+}
+
+// jsxNames scans JSX for className, class and id attributes whose
+// values are string literals, bare or in braces. Only the static
+// parts of template literals are used: names next to an
+// interpolation are incomplete.
+func jsxNames(src []byte) (classes, ids []string) {
+	for i := 0; i < len(src); {
+		if !isJSXNameStart(src[i]) || (i > 0 && isJSXNameChar(src[i-1])) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(src) && isJSXNameChar(src[i]) {
+			i++
+		}
+		name := string(src[start:i])
+		if name != "className" && name != "class" && name != "id" {
+			continue
+		}
+		value, next, ok := jsxLiteral(src, i)
+		if !ok {
+			continue
+		}
+		i = next
+		names := strings.Fields(value)
+		names = slices.DeleteFunc(names, func(n string) bool {
+			return strings.ContainsRune(n, interpolation)
+		})
+		if name == "id" {
+			if len(names) == 1 {
+				ids = append(ids, names[0])
+			}
+		} else {
+			classes = append(classes, names...)
+		}
+	}
+	return classes, ids
+}
+
+// interpolation stands in for the ${...} parts of template
+// literals.
+const interpolation = '\x00'
+
+// jsxLiteral reads ="...", ='...', ={"..."}, ={'...'} or
+// ={`...`} at i and returns the literal's content and the offset
+// after it. Unlike JSX, no space is allowed around "=", which
+// keeps out assignments such as id = "x" in plain code.
+func jsxLiteral(src []byte, i int) (string, int, bool) {
+	if i >= len(src) || src[i] != '=' {
+		return "", i, false
+	}
+	i++
+	braced := i < len(src) && src[i] == '{'
+	if braced {
+		i = skipJSXSpace(src, i+1)
+	}
+	if i >= len(src) {
+		return "", i, false
+	}
+
+	quote := src[i]
+	switch {
+	case quote == '"' || quote == '\'':
+		end := bytes.IndexByte(src[i+1:], quote)
+		if end < 0 {
+			return "", len(src), false
+		}
+		return string(src[i+1 : i+1+end]), i + 2 + end, true
+	case quote == '`' && braced:
+		return templateLiteral(src, i+1)
+	}
+	return "", i, false
+}
+
+// templateLiteral reads a template literal whose content starts at
+// i, replacing interpolations with the interpolation rune.
+func templateLiteral(src []byte, i int) (string, int, bool) {
+	var b strings.Builder
+	for i < len(src) {
+		switch {
+		case src[i] == '\\' && i+1 < len(src):
+			b.WriteByte(src[i+1])
+			i += 2
+		case src[i] == '`':
+			return b.String(), i + 1, true
+		case src[i] == '$' && i+1 < len(src) && src[i+1] == '{':
+			depth := 0
+			for i < len(src) {
+				if src[i] == '{' {
+					depth++
+				} else if src[i] == '}' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+				i++
+			}
+			b.WriteRune(interpolation)
+			i++
+		default:
+			b.WriteByte(src[i])
+			i++
+		}
+	}
+	return "", i, false
+}
+
+// skipJSXSpace returns the offset of the first byte at or after i
+// that is not whitespace.
+func skipJSXSpace(src []byte, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t' ||
+		src[i] == '\n' || src[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// isJSXNameStart reports whether b can start a JSX attribute name.
+func isJSXNameStart(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == '_' || b == '$'
+}
+
+// isJSXNameChar reports whether b can continue a JSX attribute
+// name, or an identifier the name would be part of.
+func isJSXNameChar(b byte) bool {
+	return isJSXNameStart(b) || b >= '0' && b <= '9' || b == '-' || b == '.'
+}
@@ -0,0 +1,128 @@
+package workspace
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestJSXNames(t *testing.T) {
+	const src = `
+export function Card({ active, id }) {
+  const cls = "not-an-attribute";
+  id = "assigned";
+  return (
+    <div className="card  shadow" id='main'>
+      <p className={"lead"} data-id="skip">x</p>
+      <span className={ 'badge' } />
+      <li className={` + "`item ${active ? \"on\" : \"\"} row`" + `} />
+      <a class="nav" id={"two words"} styles.className="no" />
+      <b className={cls} />
+    </div>
+  );
+}
+`
+	classes, ids := jsxNames([]byte(src))
+	want := []string{"card", "shadow", "lead", "badge", "item", "row", "nav"}
+	if !slices.Equal(classes, want) {
+		t.Errorf("classes = %q, want %q", classes, want)
+	}
+	if !slices.Equal(ids, []string{"main"}) {
+		t.Errorf("ids = %q", ids)
+	}
+}
+
+func TestMarkupNames(t *testing.T) {
+	classes, ids := markupNames("file:///a/page.html", []byte(
+		`<p class="b a b" id="x"></p><p class='a' id=x></p>`,
+	))
+	if strings.Join(classes, ",") != "a,b" || strings.Join(ids, ",") != "x" {
+		t.Errorf("classes = %q, ids = %q", classes, ids)
+	}
+
+	// Everything else is read as JSX; in HTML, className means
+	// nothing.
+	classes, _ = markupNames("file:///a/page.html", []byte(
+		`<p className="jsx"></p>`,
+	))
+	if len(classes) != 0 {
+		t.Errorf("HTML className = %q", classes)
+	}
+	classes, _ = markupNames("file:///a/App.tsx", []byte(
+		`<p className="jsx"></p>`,
+	))
+	if !slices.Equal(classes, []string{"jsx"}) {
+		t.Errorf("TSX className = %q", classes)
+	}
+}
+
+func TestIndex_IndexMarkup(t *testing.T) {
+	idx := NewIndex()
+	if idx.HasMarkup() {
+		t.Error("empty index has no markup")
+	}
+
+	idx.IndexMarkup("file:///a.html", []byte(`<p class="card" id="top">`))
+	idx.IndexMarkup("file:///b.jsx", []byte(`<p className="card lead" />`))
+	if !idx.HasMarkup() || !idx.HasClass("lead") || !idx.HasID("top") ||
+		idx.HasClass("top") {
+		t.Errorf("classes = %q, ids = %q", idx.Classes(), idx.IDs())
+	}
+	if got := idx.Classes(); !slices.Equal(got, []string{"card", "lead"}) {
+		t.Errorf("Classes() = %q", got)
+	}
+	if got := idx.MarkupFiles(); len(got) != 2 {
+		t.Errorf("MarkupFiles() = %q", got)
+	}
+	if len(idx.Files()) != 0 {
+		t.Errorf("Files() = %q, want stylesheets only", idx.Files())
+	}
+
+	// Reindexing with the same names keeps the version.
+	v := idx.Version()
+	idx.IndexMarkup("file:///b.jsx", []byte(`<p className="lead card"></p>`))
+	if idx.Version() != v {
+		t.Error("same names should not bump the version")
+	}
+
+	idx.RemoveFile("file:///a.html")
+	if idx.Version() == v {
+		t.Error("RemoveFile should bump the version")
+	}
+	if !idx.HasClass("card") || idx.HasID("top") {
+		t.Errorf("after remove: classes = %q, ids = %q", idx.Classes(), idx.IDs())
+	}
+}
+
+func TestScanMarkup(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"app.css":   ":root { --a: 1; }",
+		"page.html": `<style>:root { --b: 2; }</style><p class="intro">`,
+		"App.tsx":   `export const App = () => <main id="root" />;`,
+	})
+	for range 2 { // the second scan comes from the cache
+		idx := scanCached(t, cacheDir, dir)
+		if !idx.HasVariable("--b") || !idx.HasClass("intro") || !idx.HasID("root") {
+			t.Errorf("variables = %q, classes = %q, ids = %q",
+				idx.AllVariableNames(), idx.Classes(), idx.IDs())
+		}
+		if files := idx.Files(); len(files) != 2 {
+			t.Errorf("Files() = %q", files)
+		}
+	}
+
+	// Without markup extensions, neither markup nor its cache
+	// entries are used.
+	idx := NewIndex()
+	err := idx.Scan(context.Background(), NewFilter(dir, ScanOptions{
+		Markup: []string{},
+	}), ScanConfig{Cache: OpenCache(cacheDir, dir)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.HasMarkup() || !idx.HasVariable("--b") {
+		t.Errorf("markup files = %q", idx.MarkupFiles())
+	}
+}
@@ -0,0 +1,331 @@
+package workspace
+
+import (
+	"bytes"
+	"cmp"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+	"github.com/toba/css-lsp/internal/css/parser"
+	"github.com/toba/lsp/pathutil"
+)
+
+// ModuleUse is a use of a CSS module by another file: a class a
+// script reads from the object it imports the module as, or one
+// another module composes.
+type ModuleUse struct {
+	// URI is the file using the module, and Module the module's
+	// path.
+	URI    string
+	Module string
+	// Name is the class used, spanned by Start and End in the
+	// using file. It is "" for imports that name no class, which
+	// still make the module used.
+	Name       string
+	Start, End int
+	// Member is set for script uses written as a property access,
+	// styles.name, rather than an index, styles["name"].
+	Member bool
+}
+
+// styleModuleUses returns the classes the stylesheet at uri
+// composes from other modules, and an entry for each module it
+// imports values from.
+func styleModuleUses(uri string, ss *parser.Stylesheet, src []byte) []ModuleUse {
+	m := analyzer.ParseModule(ss, src)
+	var uses []ModuleUse
+	for _, c := range m.Composes {
+		module := ModulePath(uri, c.From)
+		if module == "" {
+			continue
+		}
+		for _, tok := range c.Names {
+			uses = append(uses, ModuleUse{
+				URI: uri, Module: module,
+				Name: tok.Value, Start: tok.Offset, End: tok.End,
+			})
+		}
+	}
+	for _, v := range m.Values {
+		if module := ModulePath(uri, v.From); module != "" {
+			uses = append(uses, ModuleUse{URI: uri, Module: module})
+		}
+	}
+	return uses
+}
+
+// scriptModuleUses returns the stylesheets the script at uri
+// imports into a binding, as in import styles from "./a.module.css"
+// or import * as styles from ..., and the classes it reads from
+// them as styles.name or styles["name"].
+func scriptModuleUses(uri string, src []byte) []ModuleUse {
+	var uses []ModuleUse
+	for _, imp := range scriptImports(src) {
+		module := ModulePath(uri, imp.spec)
+		if module == "" || !isStylesheetPath(imp.spec) {
+			continue
+		}
+		uses = append(uses, ModuleUse{URI: uri, Module: module})
+		for _, use := range memberUses(src, imp.binding) {
+			use.URI, use.Module = uri, module
+			uses = append(uses, use)
+		}
+	}
+	return uses
+}
+
+// ModulePath returns the path of the module that the file at
+// uri imports as spec, or "" unless spec is a relative path.
+func ModulePath(uri, spec string) string {
+	if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+		return ""
+	}
+	p := pathutil.URIToFilePath(uri)
+	if p == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(p), filepath.FromSlash(spec))
+}
+
+// isStylesheetPath reports whether an import specifier names a
+// stylesheet.
+func isStylesheetPath(spec string) bool {
+	switch strings.ToLower(path.Ext(spec)) {
+	case ".css", ".pcss", ".scss", ".sass", ".less":
+		return true
+	}
+	return false
+}
+
+// scriptImport is an import of a module's default export or
+// namespace into a binding.
+type scriptImport struct {
+	binding string
+	spec    string
+}
+
+// scriptImports finds the default and namespace imports of src.
+func scriptImports(src []byte) []scriptImport {
+	var imports []scriptImport
+	for i := 0; i < len(src); i++ {
+		if !hasWord(src, i, "import") {
+			continue
+		}
+		i = skipJSXSpace(src, i+len("import"))
+		if i < len(src) && src[i] == '*' {
+			i = skipJSXSpace(src, i+1)
+			if !hasWord(src, i, "as") {
+				continue
+			}
+			i = skipJSXSpace(src, i+len("as"))
+		}
+		binding := jsIdent(src, i)
+		if binding == "" {
+			continue
+		}
+		i += len(binding)
+
+		// The specifier follows the next "from" in the statement.
+		rest := src[i:]
+		if end := bytes.IndexByte(rest, ';'); end >= 0 {
+			rest = rest[:end]
+		}
+		from := bytes.Index(rest, []byte("from"))
+		if from < 0 || !hasWord(src, i+from, "from") {
+			continue
+		}
+		j := skipJSXSpace(src, i+from+len("from"))
+		if j >= len(src) || (src[j] != '"' && src[j] != '\'') {
+			continue
+		}
+		end := bytes.IndexByte(src[j+1:], src[j])
+		if end < 0 {
+			continue
+		}
+		imports = append(imports, scriptImport{
+			binding: binding,
+			spec:    string(src[j+1 : j+1+end]),
+		})
+		i = j + end + 1
+	}
+	return imports
+}
+
+// memberUses returns the property names read from binding in src,
+// as binding.name or binding["name"]. Quoted strings and comments
+// are skipped; template literals are not, as their interpolations
+// often read classes.
+func memberUses(src []byte, binding string) []ModuleUse {
+	var uses []ModuleUse
+	for i := 0; i < len(src); i++ {
+		if next, ok := skipScriptText(src, i); ok {
+			i = next - 1
+			continue
+		}
+		if !hasWord(src, i, binding) {
+			continue
+		}
+		j := i + len(binding)
+		switch {
+		case j < len(src) && src[j] == '.':
+			if name := jsIdent(src, j+1); name != "" {
+				uses = append(uses, ModuleUse{
+					Name: name, Start: j + 1, End: j + 1 + len(name), Member: true,
+				})
+			}
+		case j+1 < len(src) && src[j] == '[' && (src[j+1] == '"' || src[j+1] == '\''):
+			end := bytes.IndexByte(src[j+2:], src[j+1])
+			if end >= 0 && j+3+end < len(src) && src[j+3+end] == ']' {
+				uses = append(uses, ModuleUse{
+					Name: string(src[j+2 : j+2+end]), Start: j + 2, End: j + 2 + end,
+				})
+			}
+		}
+		i = j - 1
+	}
+	return uses
+}
+
+// skipScriptText returns the offset after the quoted string or
+// comment starting at i, if there is one.
+func skipScriptText(src []byte, i int) (int, bool) {
+	switch {
+	case src[i] == '"' || src[i] == '\'':
+		for j := i + 1; j < len(src); j++ {
+			switch src[j] {
+			case '\\':
+				j++
+			case src[i], '\n':
+				return j + 1, true
+			}
+		}
+		return len(src), true
+	case bytes.HasPrefix(src[i:], []byte("//")):
+		end := bytes.IndexByte(src[i:], '\n')
+		if end < 0 {
+			return len(src), true
+		}
+		return i + end + 1, true
+	case bytes.HasPrefix(src[i:], []byte("/*")):
+		end := bytes.Index(src[i+2:], []byte("*/"))
+		if end < 0 {
+			return len(src), true
+		}
+		return i + 2 + end + 2, true
+	}
+	return i, false
+}
+
+// hasWord reports whether word starts at i in src as a whole
+// identifier.
+func hasWord(src []byte, i int, word string) bool {
+	if !bytes.HasPrefix(src[i:], []byte(word)) {
+		return false
+	}
+	if i > 0 && isJSXNameChar(src[i-1]) {
+		return false
+	}
+	end := i + len(word)
+	return end >= len(src) || !isJSIdentChar(src[end])
+}
+
+// jsIdent returns the JavaScript identifier starting at i, or "".
+func jsIdent(src []byte, i int) string {
+	if i >= len(src) || !isJSXNameStart(src[i]) {
+		return ""
+	}
+	end := i + 1
+	for end < len(src) && isJSIdentChar(src[end]) {
+		end++
+	}
+	return string(src[i:end])
+}
+
+// isJSIdentChar reports whether b can continue a JavaScript
+// identifier.
+func isJSIdentChar(b byte) bool {
+	return isJSXNameStart(b) || b >= '0' && b <= '9'
+}
+
+// ModuleImported reports whether any indexed file imports the
+// module at path, or composes or imports values from it.
+func (idx *Index) ModuleImported(module string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.moduleUses[module]) > 0
+}
+
+// ModuleClassUsed reports whether any indexed file uses the class
+// of the module at path.
+func (idx *Index) ModuleClassUsed(module, name string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	for _, use := range idx.moduleUses[module] {
+		if use.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ModuleUses returns the uses of the class of the module at path,
+// ordered by file and position.
+func (idx *Index) ModuleUses(module, name string) []ModuleUse {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var uses []ModuleUse
+	for _, use := range idx.moduleUses[module] {
+		if use.Name == name {
+			uses = append(uses, use)
+		}
+	}
+	slices.SortFunc(uses, func(a, b ModuleUse) int {
+		return cmp.Or(strings.Compare(a.URI, b.URI), cmp.Compare(a.Start, b.Start))
+	})
+	return uses
+}
+
+// addModuleUsesLocked records uses of modules.
+func (idx *Index) addModuleUsesLocked(uses []ModuleUse) {
+	for _, use := range uses {
+		idx.moduleUses[use.Module] = append(idx.moduleUses[use.Module], use)
+	}
+}
+
+// removeModuleUsesLocked drops the uses of modules by uri that
+// were recorded from uses.
+func (idx *Index) removeModuleUsesLocked(uri string, uses []ModuleUse) {
+	for _, use := range uses {
+		kept := slices.DeleteFunc(idx.moduleUses[use.Module], func(u ModuleUse) bool {
+			return u.URI == uri
+		})
+		if len(kept) == 0 {
+			delete(idx.moduleUses, use.Module)
+		} else {
+			idx.moduleUses[use.Module] = kept
+		}
+	}
+}
+
+// sameModuleUses reports whether a and b use the same classes of
+// the same modules, wherever they are written.
+func sameModuleUses(a, b []ModuleUse) bool {
+	return slices.EqualFunc(a, b, func(x, y ModuleUse) bool {
+		return x.Module == y.Module && x.Name == y.Name
+	})
+}
+
+// RenameEdit returns the edit renaming the class the use names to
+// newName. A property access becomes an index when newName isn't an
+// identifier, as styles.old becomes styles["new-name"].
+func (u ModuleUse) RenameEdit(newName string) analyzer.RenameEdit {
+	if u.Member && jsIdent([]byte(newName), 0) != newName {
+		return analyzer.RenameEdit{
+			StartPos: u.Start - 1, EndPos: u.End, NewText: `["` + newName + `"]`,
+		}
+	}
+	return analyzer.RenameEdit{StartPos: u.Start, EndPos: u.End, NewText: newName}
+}
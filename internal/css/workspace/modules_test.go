@@ -0,0 +1,107 @@
+package workspace
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestScriptModuleUses(t *testing.T) {
+	const src = `import styles from "./Card.module.css";
+import * as theme from '../theme.module.css';
+import "./global.css";
+import React from "react";
+import data from "./data.json";
+
+export const Card = () => (
+  <div className={styles.card + " " + styles['is-active']}>
+    <p className={theme.lead}>{other.styles.x}</p>
+  </div>
+);
+`
+	uses := scriptModuleUses("file:///app/src/Card.tsx", []byte(src))
+	var got []string
+	for _, u := range uses {
+		name := u.Name
+		if name != "" {
+			if src[u.Start:u.End] != name {
+				t.Errorf("%s spans %q", name, src[u.Start:u.End])
+			}
+			if u.Member {
+				name = "." + name
+			}
+		}
+		got = append(got, filepath.ToSlash(u.Module)+" "+name)
+	}
+	want := []string{
+		"/app/src/Card.module.css ",
+		"/app/src/Card.module.css .card",
+		"/app/src/Card.module.css is-active",
+		"/app/theme.module.css ",
+		"/app/theme.module.css .lead",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("uses = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("uses[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestIndexModuleUses(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"base.module.css": ".base { } .unused { }",
+		"card.module.css": `.card { composes: base from "./base.module.css"; }`,
+		"Card.jsx":        `import s from "./card.module.css"; <p className={s.card} />`,
+	})
+	base := filepath.Join(dir, "base.module.css")
+	card := filepath.Join(dir, "card.module.css")
+	for range 2 { // the second scan comes from the cache
+		idx := scanCached(t, cacheDir, dir)
+		if !idx.ModuleImported(base) || !idx.ModuleClassUsed(base, "base") ||
+			idx.ModuleClassUsed(base, "unused") || !idx.ModuleClassUsed(card, "card") {
+			t.Errorf("module uses = %+v", idx.moduleUses)
+		}
+		uses := idx.ModuleUses(card, "card")
+		if len(uses) != 1 || !uses[0].Member ||
+			uses[0].URI != "file://"+filepath.Join(dir, "Card.jsx") {
+			t.Errorf("ModuleUses(card) = %+v", uses)
+		}
+	}
+
+	idx := NewIndex()
+	idx.IndexFile("file://"+card, []byte(".card { }"))
+	if idx.ModuleImported(base) {
+		t.Error("removing the composition should drop the use")
+	}
+	idx.IndexMarkup("file:///x/App.tsx", []byte(`import a from "./card.module.css"`))
+	v := idx.Version()
+	idx.RemoveFile("file:///x/App.tsx")
+	if idx.ModuleImported("/x/card.module.css") || idx.Version() == v {
+		t.Error("RemoveFile should drop the file's module uses")
+	}
+}
+
+func TestModuleUseRenameEdit(t *testing.T) {
+	const src = `s.card + s["card"]`
+	tests := []struct {
+		use     ModuleUse
+		newName string
+		want    string
+	}{
+		{ModuleUse{Start: 2, End: 6, Member: true}, "tile", `s.tile + s["card"]`},
+		{
+			ModuleUse{Start: 2, End: 6, Member: true},
+			"big-card", `s["big-card"] + s["card"]`,
+		},
+		{ModuleUse{Start: 12, End: 16}, "big-card", `s.card + s["big-card"]`},
+	}
+	for _, tt := range tests {
+		e := tt.use.RenameEdit(tt.newName)
+		if got := src[:e.StartPos] + e.NewText + src[e.EndPos:]; got != tt.want {
+			t.Errorf("rename to %q = %q, want %q", tt.newName, got, tt.want)
+		}
+	}
+}
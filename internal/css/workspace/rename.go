@@ -0,0 +1,104 @@
+package workspace
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// FileMove is a file or directory moving from Old to New.
+type FileMove struct {
+	Old, New string
+}
+
+// MovedPath returns where the file at p ends up after moves, and
+// whether any of them moves it, itself or with a directory.
+func MovedPath(moves []FileMove, p string) (string, bool) {
+	for _, m := range moves {
+		if p == m.Old {
+			return m.New, true
+		}
+		if rest, ok := strings.CutPrefix(p, m.Old+string(filepath.Separator)); ok {
+			return filepath.Join(m.New, rest), true
+		}
+	}
+	return p, false
+}
+
+// RewriteLink returns the target a link must be given so that,
+// from the file's new path newFrom, it still names the file it
+// names now, path, which moves to newPath. The target keeps its
+// form: alias and root-relative targets stay so where they can,
+// the latter relative to an asset directory if one holds the
+// file; relative ones stay relative, with a leading "./" if they
+// had one; and a query or fragment is kept. Links into packages
+// are left alone. It returns false if the target needn't change.
+func (r *Resolver) RewriteLink(
+	newFrom, target, path, newPath string,
+) (string, bool) {
+	name, suffix := target, ""
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		name, suffix = target[:i], target[i:]
+	}
+	if strings.HasPrefix(name, "~") || isPackagePath(path) {
+		return "", false
+	}
+	// Targets that leave out the extension keep leaving it out.
+	if filepath.Ext(name) == "" && filepath.Ext(newPath) == ".css" {
+		newPath = strings.TrimSuffix(newPath, ".css")
+	}
+
+	var rewritten string
+	for _, a := range r.aliases {
+		if !strings.HasPrefix(name, a.prefix) {
+			continue
+		}
+		if rel, ok := relativeSlash(a.dir, newPath); ok {
+			rewritten = a.prefix + rel
+		}
+		break
+	}
+	switch {
+	case rewritten != "":
+	case strings.HasPrefix(name, "/"):
+		for _, dir := range append(slices.Clone(r.assets), r.root) {
+			if rel, ok := relativeSlash(dir, newPath); ok && dir != "" {
+				rewritten = "/" + rel
+				break
+			}
+		}
+		if rewritten == "" {
+			return "", false
+		}
+	default:
+		rel, err := filepath.Rel(filepath.Dir(newFrom), newPath)
+		if err != nil {
+			return "", false
+		}
+		rewritten = filepath.ToSlash(rel)
+		if strings.HasPrefix(name, "./") && !strings.HasPrefix(rewritten, "../") {
+			rewritten = "./" + rewritten
+		}
+	}
+	if rewritten == name {
+		return "", false
+	}
+	return rewritten + suffix, true
+}
+
+// relativeSlash returns the slash-separated path of p relative to
+// dir, if p is inside dir.
+func relativeSlash(dir, p string) (string, bool) {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// isPackagePath reports whether p is inside an installed package.
+func isPackagePath(p string) bool {
+	sep := string(filepath.Separator)
+	return strings.Contains(p, sep+packageDir+sep)
+}
@@ -0,0 +1,97 @@
+package workspace
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMovedPath(t *testing.T) {
+	moves := []FileMove{
+		{Old: "/p/a.css", New: "/p/b.css"},
+		{Old: "/p/img", New: "/p/assets/img"},
+	}
+	tests := map[string]string{
+		"/p/a.css":       "/p/b.css",
+		"/p/img/x/y.png": "/p/assets/img/x/y.png",
+		"/p/imgs/z.png":  "",
+		"/p/c.css":       "",
+	}
+	for p, want := range tests {
+		got, ok := MovedPath(moves, filepath.FromSlash(p))
+		if want == "" {
+			if ok {
+				t.Errorf("MovedPath(%q) = %q, want unmoved", p, got)
+			}
+		} else if !ok || got != filepath.FromSlash(want) {
+			t.Errorf("MovedPath(%q) = %q, %v; want %q", p, got, ok, want)
+		}
+	}
+}
+
+func TestRewriteLink(t *testing.T) {
+	r := NewResolver(
+		"/p", map[string]string{"@/": "src/"}, []string{"public"}, nil,
+	)
+	tests := []struct {
+		from, newFrom, target, path, newPath string
+		want                                 string
+	}{
+		// The target moves.
+		{
+			"/p/src/a.css", "/p/src/a.css", "./b.css",
+			"/p/src/b.css", "/p/src/ui/b.css", "./ui/b.css",
+		},
+		{
+			"/p/src/a.css", "/p/src/a.css", "b.css",
+			"/p/src/b.css", "/p/lib/b.css", "../lib/b.css",
+		},
+		{
+			"/p/src/a.css", "/p/src/a.css", "./b",
+			"/p/src/b.css", "/p/src/c.css", "./c",
+		},
+		{
+			"/p/src/a.css", "/p/src/a.css", "../img/x.png?v=2#i",
+			"/p/img/x.png", "/p/assets/x.png", "../assets/x.png?v=2#i",
+		},
+		{
+			"/p/src/a.css", "/p/src/a.css", "@/b.css",
+			"/p/src/b.css", "/p/src/ui/b.css", "@/ui/b.css",
+		},
+		{
+			"/p/src/a.css", "/p/src/a.css", "@/b.css",
+			"/p/src/b.css", "/p/lib/b.css", "../lib/b.css",
+		},
+		{
+			"/p/src/a.css", "/p/src/a.css", "/img/x.png",
+			"/p/img/x.png", "/p/i/x.png", "/i/x.png",
+		},
+		{
+			"/p/src/a.css", "/p/src/a.css", "/logo.png",
+			"/p/public/logo.png", "/p/public/brand/logo.png", "/brand/logo.png",
+		},
+		// The importer moves.
+		{
+			"/p/src/a.css", "/p/src/ui/a.css", "./b.css",
+			"/p/src/b.css", "/p/src/b.css", "../b.css",
+		},
+		{
+			"/p/src/a.css", "/p/lib/a.css", "@/b.css",
+			"/p/src/b.css", "/p/src/b.css", "",
+		},
+		// Packages are left alone.
+		{
+			"/p/src/a.css", "/p/lib/a.css", "kit",
+			"/p/node_modules/kit/kit.css", "/p/node_modules/kit/kit.css", "",
+		},
+	}
+	for _, tt := range tests {
+		p := filepath.FromSlash
+		got, ok := r.RewriteLink(
+			p(tt.newFrom), tt.target, p(tt.path), p(tt.newPath),
+		)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("RewriteLink(%q from %s to %s) = %q, %v; want %q",
+				tt.target, tt.from, tt.newFrom, got, ok, tt.want)
+		}
+	}
+}
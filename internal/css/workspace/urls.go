@@ -0,0 +1,297 @@
+package workspace
+
+import (
+	"cmp"
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/toba/css-lsp/internal/css/analyzer"
+)
+
+// assetExtensions are the extensions of the images and fonts
+// suggested in url() paths.
+var assetExtensions = []string{
+	".apng", ".avif", ".bmp", ".cur", ".gif", ".ico", ".jpeg", ".jpg",
+	".png", ".svg", ".webp",
+	".eot", ".otf", ".ttf", ".woff", ".woff2",
+}
+
+// AssetExtensions returns the extensions of the images and fonts
+// that url() references are looked up and suggested among.
+func AssetExtensions() []string {
+	return slices.Clone(assetExtensions)
+}
+
+// PathEntry is a directory entry suggested while a path is typed.
+type PathEntry struct {
+	Name string
+	Dir  bool
+}
+
+// PathEntries returns the entries of the directory that dir, the
+// directory part of a path being typed in the file at from, names:
+// subdirectories and, for imports, stylesheets or, for url(),
+// images and fonts. Paths are read as ResolveURL and Resolve read
+// them, so url() paths list the asset directories too, and import
+// paths may start with an alias. Hidden entries, node_modules and
+// the file itself are left out, and "~" package paths list
+// nothing.
+func (r *Resolver) PathEntries(from, dir string, imports bool) []PathEntry {
+	var dirs []string
+	name := filepath.FromSlash(dir)
+	relative := strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../")
+	switch {
+	case analyzer.IsRemoteURL(dir) || strings.HasPrefix(dir, "~"):
+	case strings.HasPrefix(dir, "/"):
+		if !imports {
+			dirs = append(dirs, r.assets...)
+		}
+		if r.root != "" {
+			dirs = append(dirs, r.root)
+		}
+	default:
+		if imports {
+			for _, a := range r.aliases {
+				if rest, ok := strings.CutPrefix(dir, a.prefix); ok {
+					dirs, name = []string{a.dir}, filepath.FromSlash(rest)
+					break
+				}
+			}
+		}
+		if dirs == nil {
+			dirs = []string{filepath.Dir(from)}
+			if !imports && !relative {
+				dirs = append(dirs, r.assets...)
+			}
+		}
+	}
+
+	var entries []PathEntry
+	for _, d := range dirs {
+		if d == "" {
+			continue
+		}
+		list, err := os.ReadDir(filepath.Join(d, name))
+		if err != nil {
+			continue
+		}
+		for _, e := range list {
+			n := e.Name()
+			p := filepath.Join(d, name, n)
+			if strings.HasPrefix(n, ".") || n == packageDir || p == from {
+				continue
+			}
+			info, err := os.Stat(p)
+			if err != nil {
+				continue
+			}
+			entry := PathEntry{Name: n, Dir: info.IsDir()}
+			switch {
+			case entry.Dir:
+			case imports && isCSSFile(n):
+			case !imports && slices.Contains(assetExtensions,
+				strings.ToLower(filepath.Ext(n))):
+			default:
+				continue
+			}
+			if !slices.Contains(entries, entry) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	slices.SortFunc(entries, func(a, b PathEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries
+}
+
+// maxClosestFiles bounds how many files ClosestFile lists in each
+// directory it searches, so that huge folders don't stall it.
+const maxClosestFiles = 20000
+
+// urlPath returns the path a url() of target names: without its
+// query or fragment, and with percent-escapes decoded.
+func urlPath(target string) string {
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		target = target[:i]
+	}
+	if p, err := url.PathUnescape(target); err == nil {
+		return p
+	}
+	return target
+}
+
+// ResolveURL returns the path of the file that the url() of target
+// in the file at from names, or false if it doesn't exist. A path
+// starting with "/" is looked up in the asset directories and then
+// the root; other paths are relative to from and, unless they
+// start with "./" or "../", are looked up in the asset directories
+// too.
+func (r *Resolver) ResolveURL(from, target string) (string, bool) {
+	target = urlPath(target)
+	if target == "" || analyzer.IsRemoteURL(target) {
+		return "", false
+	}
+	name := filepath.FromSlash(target)
+
+	var dirs []string
+	if strings.HasPrefix(target, "/") {
+		dirs = append(slices.Clone(r.assets), r.root)
+	} else {
+		dirs = []string{filepath.Dir(from)}
+		if !strings.HasPrefix(target, "./") && !strings.HasPrefix(target, "../") {
+			dirs = append(dirs, r.assets...)
+		}
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// HasURLRoot reports whether url() paths starting with "/" have
+// a directory to be looked up in: the root or an asset directory.
+func (r *Resolver) HasURLRoot() bool {
+	return r.root != "" || len(r.assets) > 0
+}
+
+// ClosestFile returns the existing file whose name is most like
+// that of target, a link in the file at from that names nothing,
+// for a quick fix. The root and the asset directories are
+// searched, or without a root the directory of from, skipping
+// hidden files and those a scan would exclude. Only stylesheets
+// are considered when stylesheet is set. Of equally close names,
+// the file nearest the directory target points into wins. The
+// files found are kept until Refresh.
+func (r *Resolver) ClosestFile(
+	from, target string,
+	stylesheet bool,
+) (string, bool) {
+	target = urlPath(target)
+	want := strings.ToLower(filepath.Base(filepath.FromSlash(target)))
+	if target == "" || want == "." || want == string(filepath.Separator) {
+		return "", false
+	}
+	trimExt := filepath.Ext(want) == ""
+	base := filepath.Dir(from)
+	if strings.HasPrefix(target, "/") {
+		base = r.root
+	}
+	near := filepath.Dir(filepath.Join(base, filepath.FromSlash(target)))
+
+	dirs := []string{r.root}
+	if r.root == "" {
+		dirs = []string{filepath.Dir(from)}
+	}
+	for _, dir := range r.assets {
+		if _, ok := relativeSlash(dirs[0], dir); !ok {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	type candidate struct {
+		path       string
+		dist, hops int
+	}
+	var best *candidate
+	limit := max(len(want)/3, 2)
+	for _, dir := range dirs {
+		for _, p := range r.files(dir) {
+			name := filepath.Base(p)
+			if stylesheet && !isCSSFile(name) {
+				continue
+			}
+			name = strings.ToLower(name)
+			if trimExt {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			c := candidate{path: p, dist: analyzer.EditDistance(want, name)}
+			if c.dist > limit {
+				continue
+			}
+			c.hops = hops(near, filepath.Dir(p))
+			if best == nil || cmp.Or(
+				cmp.Compare(c.dist, best.dist),
+				cmp.Compare(c.hops, best.hops),
+			) < 0 {
+				best = &c
+			}
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.path, true
+}
+
+// files returns the files below dir that ClosestFile considers,
+// listing them on first use. Below the root, the resolver's filter
+// decides which are excluded; elsewhere the default scan settings
+// do.
+func (r *Resolver) files(dir string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if files, ok := r.listed[dir]; ok {
+		return files
+	}
+
+	f := r.filter
+	if f == nil || f.Root() != filepath.Clean(dir) {
+		f = NewFilter(dir, ScanOptions{})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var files []string
+	_ = f.WalkAll(ctx, func(p string) {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || hidden(rel) || len(files) == maxClosestFiles {
+			return
+		}
+		if files = append(files, p); len(files) == maxClosestFiles {
+			cancel()
+		}
+	})
+	r.listed[dir] = files
+	return files
+}
+
+// hidden reports whether the relative path rel is of a hidden file
+// or inside a hidden directory.
+func hidden(rel string) bool {
+	for part := range strings.SplitSeq(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh forgets the files ClosestFile found, so that it looks
+// for them again after files are created, deleted or excluded.
+func (r *Resolver) Refresh() {
+	r.mu.Lock()
+	clear(r.listed)
+	r.mu.Unlock()
+}
+
+// hops returns how many directories separate dir from to.
+func hops(dir, to string) int {
+	rel, err := filepath.Rel(dir, to)
+	if err != nil {
+		return len(to)
+	}
+	if rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
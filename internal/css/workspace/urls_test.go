@@ -0,0 +1,166 @@
+package workspace
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestResolveURL(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/app.css":        "",
+		"src/img/icon.svg":   "",
+		"public/logo.png":    "",
+		"public/fonts/a.ttf": "",
+		"docs/guide.png":     "",
+		"src/my img.png":     "",
+	})
+	r := NewResolver(dir, nil, []string{"public"}, nil)
+	from := filepath.Join(dir, "src", "app.css")
+	tests := map[string]string{
+		"img/icon.svg":              "src/img/icon.svg",
+		"./img/icon.svg#shape":      "src/img/icon.svg",
+		"/logo.png":                 "public/logo.png",
+		"logo.png?v=1":              "public/logo.png",
+		"/docs/guide.png":           "docs/guide.png",
+		"my%20img.png":              "src/my img.png",
+		"./logo.png":                "",
+		"../img/icon.svg":           "",
+		"fonts/b.ttf":               "",
+		"data:image/png;base64,AA":  "",
+		"https://example.com/a.png": "",
+	}
+	for target, want := range tests {
+		got, ok := r.ResolveURL(from, target)
+		if want == "" {
+			if ok {
+				t.Errorf("ResolveURL(%q) = %q, want none", target, got)
+			}
+			continue
+		}
+		if want = filepath.Join(dir, filepath.FromSlash(want)); !ok || got != want {
+			t.Errorf("ResolveURL(%q) = %q, %v; want %q", target, got, ok, want)
+		}
+	}
+}
+
+func TestClosestFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/app.css":                 "",
+		"src/buttons.css":             "",
+		"src/img/logo.png":            "",
+		"lib/logo.png":                "",
+		"lib/buttons.png":             "",
+		"public/icon.svg":             "",
+		"node_modules/kit/button.css": "",
+		".cache/icons.svg":            "",
+	})
+	r := NewResolver(dir, nil, []string{"public"}, nil)
+	from := filepath.Join(dir, "src", "app.css")
+	tests := []struct {
+		target     string
+		stylesheet bool
+		want       string
+	}{
+		{"img/lgoo.png", false, "src/img/logo.png"},
+		{"../lib/logo.pgn", false, "lib/logo.png"},
+		{"/icon.sgv", false, "public/icon.svg"},
+		{"./button.css", true, "src/buttons.css"},
+		{"./button", true, "src/buttons.css"},
+		{"./buttons.png", true, "src/buttons.css"},
+		{"./unrelated.css", true, ""},
+	}
+	for _, tt := range tests {
+		got, ok := r.ClosestFile(from, tt.target, tt.stylesheet)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("ClosestFile(%q) = %q, want none", tt.target, got)
+			}
+			continue
+		}
+		if want := filepath.Join(dir, filepath.FromSlash(tt.want)); !ok || got != want {
+			t.Errorf("ClosestFile(%q) = %q, %v; want %q", tt.target, got, ok, want)
+		}
+	}
+}
+
+func TestClosestFileFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":      "build/\n",
+		"src/app.css":     "",
+		"build/logo.png":  "",
+		"generated/a.png": "",
+	})
+	f := NewFilter(dir, ScanOptions{Exclude: []string{"generated/"}})
+	r := NewResolver(dir, nil, nil, f)
+	from := filepath.Join(dir, "src", "app.css")
+	for _, target := range []string{"../build/logo.png", "../generated/b.png"} {
+		if got, ok := r.ClosestFile(from, target, false); ok {
+			t.Errorf("ClosestFile(%q) = %q, want none", target, got)
+		}
+	}
+
+	// Files are listed once, until Refresh.
+	writeTree(t, dir, map[string]string{"src/logo.png": ""})
+	if got, ok := r.ClosestFile(from, "lgoo.png", false); ok {
+		t.Errorf("ClosestFile before Refresh = %q", got)
+	}
+	r.Refresh()
+	want := filepath.Join(dir, "src", "logo.png")
+	if got, ok := r.ClosestFile(from, "lgoo.png", false); !ok || got != want {
+		t.Errorf("ClosestFile after Refresh = %q, %v; want %q", got, ok, want)
+	}
+}
+
+func TestPathEntries(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/app.css":          "",
+		"src/base.css":         "",
+		"src/theme/dark.css":   "",
+		"src/img/logo.png":     "",
+		"src/img/notes.txt":    "",
+		"src/.hidden/a.css":    "",
+		"public/fonts/a.woff2": "",
+		"public/favicon.ico":   "",
+		"node_modules/kit.css": "",
+	})
+	r := NewResolver(
+		dir, map[string]string{"@/": "src/"}, []string{"public"}, nil,
+	)
+	from := filepath.Join(dir, "src", "app.css")
+	names := func(entries []PathEntry) []string {
+		var out []string
+		for _, e := range entries {
+			if e.Dir {
+				e.Name += "/"
+			}
+			out = append(out, e.Name)
+		}
+		return out
+	}
+	tests := []struct {
+		dir     string
+		imports bool
+		want    []string
+	}{
+		{"", false, []string{"favicon.ico", "fonts/", "img/", "theme/"}},
+		{"./", false, []string{"img/", "theme/"}},
+		{"img/", false, []string{"logo.png"}},
+		{"/", false, []string{"favicon.ico", "fonts/", "public/", "src/"}},
+		{"", true, []string{"base.css", "img/", "theme/"}},
+		{"@/theme/", true, []string{"dark.css"}},
+		{"../", true, []string{"public/", "src/"}},
+		{"~kit/", true, nil},
+	}
+	for _, tt := range tests {
+		got := names(r.PathEntries(from, tt.dir, tt.imports))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("PathEntries(%q, %v) = %q, want %q",
+				tt.dir, tt.imports, got, tt.want)
+		}
+	}
+}
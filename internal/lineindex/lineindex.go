@@ -0,0 +1,188 @@
+// Package lineindex converts between byte offsets and LSP
+// line/character positions. Characters are counted in the
+// position encoding negotiated with the client: UTF-8 bytes,
+// UTF-16 code units (the LSP default) or UTF-32 code points.
+package lineindex
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encoding is an LSP position encoding kind.
+type Encoding string
+
+// Position encodings defined by LSP 3.17.
+const (
+	UTF8  Encoding = "utf-8"
+	UTF16 Encoding = "utf-16"
+	UTF32 Encoding = "utf-32"
+)
+
+// Negotiate picks the position encoding to use given the
+// encodings a client offers, in the client's order of
+// preference. Without an offer, or when nothing offered is
+// supported, the LSP default of UTF-16 is used.
+func Negotiate(offered []string) Encoding {
+	for _, o := range offered {
+		switch enc := Encoding(o); enc {
+		case UTF8, UTF16, UTF32:
+			return enc
+		}
+	}
+	return UTF16
+}
+
+// Index records where each line of a document starts so that
+// conversions only scan a single line. Lines end at '\n'; a
+// preceding '\r' is treated as part of the line's content.
+type Index struct {
+	src   []byte
+	lines []int
+}
+
+// New builds an index for src. The index keeps a reference to
+// src, which must not be modified afterwards.
+func New(src []byte) *Index {
+	lines := make([]int, 1, bytes.Count(src, []byte{'\n'})+1)
+	for i, b := range src {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &Index{src: src, lines: lines}
+}
+
+// Source returns the indexed document.
+func (ix *Index) Source() []byte {
+	return ix.src
+}
+
+// LineCount returns the number of lines in the document.
+func (ix *Index) LineCount() int {
+	return len(ix.lines)
+}
+
+// Position converts a byte offset to a zero-based line and a
+// character counted in enc. Offsets are clamped to the document,
+// and an offset inside a multi-byte character resolves to the
+// start of that character.
+func (ix *Index) Position(offset int, enc Encoding) (int, int) {
+	offset = max(0, min(offset, len(ix.src)))
+	line, found := slices.BinarySearch(ix.lines, offset)
+	if !found {
+		line--
+	}
+	start := ix.lines[line]
+	for i := 0; i < utf8.UTFMax-1 && offset > start &&
+		offset < len(ix.src) && !utf8.RuneStart(ix.src[offset]); i++ {
+		offset--
+	}
+	return line, width(ix.src[start:offset], enc)
+}
+
+// Offset converts a zero-based line and a character counted in
+// enc to a byte offset. Lines past the end resolve to the end of
+// the document and characters past the end of a line resolve to
+// the end of that line. A character that falls inside a UTF-16
+// surrogate pair resolves to the start of the pair.
+func (ix *Index) Offset(line, char int, enc Encoding) int {
+	if line < 0 {
+		return 0
+	}
+	if line >= len(ix.lines) {
+		return len(ix.src)
+	}
+	start := ix.lines[line]
+	end := len(ix.src)
+	if line+1 < len(ix.lines) {
+		end = ix.lines[line+1] - 1
+	}
+	if enc == UTF8 {
+		return start + max(0, min(char, end-start))
+	}
+
+	offset := start
+	for n := 0; offset < end; {
+		r, size := utf8.DecodeRune(ix.src[offset:end])
+		n += runeWidth(r, enc)
+		if n > char {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
+// OffsetIn converts a position in s to a byte offset as Offset
+// does, without building an index: only the lines up to the
+// position are scanned, which suits converting a single edit.
+func OffsetIn(s string, line, char int, enc Encoding) int {
+	if line < 0 {
+		return 0
+	}
+	start := 0
+	for ; line > 0; line-- {
+		i := strings.IndexByte(s[start:], '\n')
+		if i < 0 {
+			return len(s)
+		}
+		start += i + 1
+	}
+	end := len(s)
+	if i := strings.IndexByte(s[start:], '\n'); i >= 0 {
+		end = start + i
+	}
+	if enc == UTF8 {
+		return start + max(0, min(char, end-start))
+	}
+
+	offset := start
+	for n := 0; offset < end; {
+		r, size := utf8.DecodeRuneInString(s[offset:end])
+		n += runeWidth(r, enc)
+		if n > char {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
+// Convert re-expresses a line/character position counted in one
+// encoding in another.
+func (ix *Index) Convert(line, char int, from, to Encoding) (int, int) {
+	if from == to {
+		return line, char
+	}
+	return ix.Position(ix.Offset(line, char, from), to)
+}
+
+// width returns the length of b counted in enc.
+func width(b []byte, enc Encoding) int {
+	switch enc {
+	case UTF8:
+		return len(b)
+	case UTF32:
+		return utf8.RuneCount(b)
+	}
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		n += runeWidth(r, enc)
+		b = b[size:]
+	}
+	return n
+}
+
+// runeWidth returns the number of UTF-16 or UTF-32 code units r
+// occupies. Invalid UTF-8 bytes decode as U+FFFD and count as
+// one unit.
+func runeWidth(r rune, enc Encoding) int {
+	if enc == UTF16 && r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
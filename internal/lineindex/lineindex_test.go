@@ -0,0 +1,151 @@
+package lineindex
+
+import (
+	"strings"
+	"testing"
+)
+
+// src mixes 1-, 2-, 3- and 4-byte UTF-8 characters. "é" is one
+// UTF-16 unit, "日本" are one unit each and "😀" is a surrogate
+// pair.
+const src = "a { content: \"😀\"; }\n/* 日本 café */\r\nb {}"
+
+func TestPosition(t *testing.T) {
+	afterEmoji := strings.Index(src, "😀") + len("😀")
+	afterCafe := strings.Index(src, "café") + len("café")
+
+	tests := []struct {
+		name   string
+		offset int
+		enc    Encoding
+		line   int
+		char   int
+	}{
+		{"start", 0, UTF16, 0, 0},
+		{"emoji utf-8", afterEmoji, UTF8, 0, 18},
+		{"emoji utf-16", afterEmoji, UTF16, 0, 16},
+		{"emoji utf-32", afterEmoji, UTF32, 0, 15},
+		{"cjk and accent utf-8", afterCafe, UTF8, 1, 15},
+		{"cjk and accent utf-16", afterCafe, UTF16, 1, 10},
+		{"cjk and accent utf-32", afterCafe, UTF32, 1, 10},
+		{"last line", len(src) - 1, UTF16, 2, 3},
+		{"past end", len(src) + 10, UTF16, 2, 4},
+		{"negative", -3, UTF16, 0, 0},
+		{"inside emoji", afterEmoji - 1, UTF16, 0, 14},
+	}
+
+	ix := New([]byte(src))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, char := ix.Position(tt.offset, tt.enc)
+			if line != tt.line || char != tt.char {
+				t.Errorf(
+					"Position(%d, %s) = %d:%d, want %d:%d",
+					tt.offset, tt.enc, line, char, tt.line, tt.char,
+				)
+			}
+		})
+	}
+}
+
+func TestOffset(t *testing.T) {
+	emoji := strings.Index(src, "😀")
+	cafe := strings.Index(src, "café")
+	lineTwo := strings.Index(src, "b {}")
+
+	tests := []struct {
+		name   string
+		line   int
+		char   int
+		enc    Encoding
+		offset int
+	}{
+		{"after emoji utf-16", 0, 16, UTF16, emoji + 4},
+		{"after emoji utf-32", 0, 15, UTF32, emoji + 4},
+		{"after emoji utf-8", 0, 18, UTF8, emoji + 4},
+		{"inside surrogate pair", 0, 15, UTF16, emoji},
+		{"after cjk utf-16", 1, 5, UTF16, cafe - 1},
+		{"past line end stops before newline", 0, 99, UTF16, strings.Index(src, "\n")},
+		{"crlf keeps carriage return in line", 1, 99, UTF16, lineTwo - 1},
+		{"past last line", 9, 0, UTF16, len(src)},
+		{"negative line", -1, 4, UTF16, 0},
+		{"negative char", 2, -1, UTF8, lineTwo},
+	}
+
+	ix := New([]byte(src))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ix.Offset(tt.line, tt.char, tt.enc); got != tt.offset {
+				t.Errorf(
+					"Offset(%d, %d, %s) = %d, want %d",
+					tt.line, tt.char, tt.enc, got, tt.offset,
+				)
+			}
+			if got := OffsetIn(src, tt.line, tt.char, tt.enc); got != tt.offset {
+				t.Errorf(
+					"OffsetIn(%d, %d, %s) = %d, want %d",
+					tt.line, tt.char, tt.enc, got, tt.offset,
+				)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ix := New([]byte(src))
+	for _, enc := range []Encoding{UTF8, UTF16, UTF32} {
+		for offset := range len(src) + 1 {
+			line, char := ix.Position(offset, enc)
+			got := ix.Offset(line, char, enc)
+			if got > offset || got < offset-3 {
+				t.Errorf(
+					"%s: offset %d -> %d:%d -> %d",
+					enc, offset, line, char, got,
+				)
+			}
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	ix := New([]byte(src))
+	line, char := ix.Convert(0, 18, UTF8, UTF16)
+	if line != 0 || char != 16 {
+		t.Errorf("Convert utf-8 -> utf-16 = %d:%d, want 0:16", line, char)
+	}
+	line, char = ix.Convert(1, 10, UTF32, UTF8)
+	if line != 1 || char != 15 {
+		t.Errorf("Convert utf-32 -> utf-8 = %d:%d, want 1:15", line, char)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		offered []string
+		want    Encoding
+	}{
+		{nil, UTF16},
+		{[]string{"utf-8", "utf-16"}, UTF8},
+		{[]string{"utf-32", "utf-8"}, UTF32},
+		{[]string{"latin-1"}, UTF16},
+		{[]string{"latin-1", "utf-8"}, UTF8},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.offered); got != tt.want {
+			t.Errorf("Negotiate(%v) = %s, want %s", tt.offered, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyDocument(t *testing.T) {
+	ix := New(nil)
+	if ix.LineCount() != 1 {
+		t.Errorf("LineCount = %d, want 1", ix.LineCount())
+	}
+	if line, char := ix.Position(5, UTF16); line != 0 || char != 0 {
+		t.Errorf("Position = %d:%d, want 0:0", line, char)
+	}
+	if got := ix.Offset(0, 5, UTF16); got != 0 {
+		t.Errorf("Offset = %d, want 0", got)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+const (
+	debounceInterval = 100 * time.Millisecond
+	diagQueueSize    = 64
+)
+
+// diagRequest is a request to compute diagnostics for a document.
+type diagRequest struct {
+	uri     protocol.DocumentURI
+	content string
+}
+
+// diagnosticPublisher runs a background loop that debounces diagnostic
+// requests and publishes results via the LSP client.
+type diagnosticPublisher struct {
+	handler Handler
+	client  protocol.Client
+	ch      chan diagRequest
+}
+
+func newDiagnosticPublisher(
+	handler Handler,
+	client protocol.Client,
+) *diagnosticPublisher {
+	return &diagnosticPublisher{
+		handler: handler,
+		client:  client,
+		ch:      make(chan diagRequest, diagQueueSize),
+	}
+}
+
+// request enqueues a diagnostic computation. Non-blocking; drops if full.
+func (p *diagnosticPublisher) request(uri protocol.DocumentURI, content string) {
+	select {
+	case p.ch <- diagRequest{uri: uri, content: content}:
+	default:
+		slog.Warn("diagnostic queue full, dropping request", "uri", string(uri))
+	}
+}
+
+// run processes diagnostic requests with debouncing. It coalesces rapid
+// changes to the same URI, only computing diagnostics after a quiet period.
+func (p *diagnosticPublisher) run(ctx context.Context) {
+	pending := make(map[protocol.DocumentURI]string)
+	timer := time.NewTimer(debounceInterval)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case req := <-p.ch:
+			pending[req.uri] = req.content
+			timer.Reset(debounceInterval)
+		case <-timer.C:
+			for uri, content := range pending {
+				p.publish(ctx, uri, content)
+			}
+			clear(pending)
+		}
+	}
+}
+
+func (p *diagnosticPublisher) publish(
+	ctx context.Context,
+	uri protocol.DocumentURI,
+	content string,
+) {
+	diags, err := p.handler.Diagnostics(ctx, uri, content)
+	if err != nil {
+		slog.Error("diagnostics failed", "uri", string(uri), "error", err)
+		return
+	}
+	if diags == nil {
+		diags = []protocol.Diagnostic{}
+	}
+	err = p.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diags,
+	})
+	if err != nil {
+		slog.Error("publish diagnostics failed", "uri", string(uri), "error", err)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/toba/css-lsp/internal/lineindex"
+	"go.lsp.dev/protocol"
+)
+
+// contentChange is a textDocument/didChange content change as sent
+// on the wire. Unlike protocol.TextDocumentContentChangeEvent, a
+// missing range is distinguishable from an empty one: it means
+// the text replaces the whole document.
+type contentChange struct {
+	Range *protocol.Range `json:"range,omitempty"`
+	Text  string          `json:"text"`
+}
+
+// didChangeParams mirrors protocol.DidChangeTextDocumentParams
+// with wire-accurate content changes.
+type didChangeParams struct {
+	TextDocument   protocol.VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []contentChange                          `json:"contentChanges"`
+}
+
+// documents is a thread-safe store for open document content.
+// Change ranges are interpreted in encoding.
+type documents struct {
+	mu       sync.RWMutex
+	files    map[protocol.DocumentURI]string
+	encoding lineindex.Encoding
+}
+
+func newDocuments() *documents {
+	return &documents{
+		files:    make(map[protocol.DocumentURI]string),
+		encoding: lineindex.UTF16,
+	}
+}
+
+func (d *documents) open(uri protocol.DocumentURI, content string) {
+	d.mu.Lock()
+	d.files[uri] = content
+	d.mu.Unlock()
+}
+
+// change applies content changes in order and returns them
+// resolved to byte offsets, along with the resulting content.
+// Changes to a document that isn't open are ignored.
+func (d *documents) change(
+	uri protocol.DocumentURI,
+	changes []contentChange,
+) ([]TextChange, string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	content, ok := d.files[uri]
+	if !ok {
+		return nil, "", false
+	}
+
+	applied := make([]TextChange, 0, len(changes))
+	for _, change := range changes {
+		tc := TextChange{End: len(content), Text: change.Text}
+		if r := change.Range; r != nil {
+			tc.Start = lineindex.OffsetIn(
+				content, int(r.Start.Line), int(r.Start.Character), d.encoding,
+			)
+			tc.End = max(lineindex.OffsetIn(
+				content, int(r.End.Line), int(r.End.Character), d.encoding,
+			), tc.Start)
+		}
+		content = content[:tc.Start] + tc.Text + content[tc.End:]
+		applied = append(applied, tc)
+	}
+	d.files[uri] = content
+
+	return applied, content, true
+}
+
+func (d *documents) close(uri protocol.DocumentURI) {
+	d.mu.Lock()
+	delete(d.files, uri)
+	d.mu.Unlock()
+}
+
+func (d *documents) get(uri protocol.DocumentURI) (string, bool) {
+	d.mu.RLock()
+	content, ok := d.files[uri]
+	d.mu.RUnlock()
+	return content, ok
+}
+
+// all returns a snapshot of every open document.
+func (d *documents) all() map[protocol.DocumentURI]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return maps.Clone(d.files)
+}
@@ -0,0 +1,184 @@
+// Package server provides the LSP server harness for the CSS
+// language server. It extends github.com/toba/lsp/server, whose
+// Handler contract and optional handlers it shares, with the hooks
+// that package doesn't have yet: incremental document sync, pulled
+// diagnostics, background progress and the like. They are to move
+// upstream (lrp-4uq), after which this package goes away.
+// Language features are implemented by a Handler; the Server
+// manages lifecycle, document state, and diagnostic publishing.
+package server
+
+import (
+	"context"
+
+	"github.com/toba/css-lsp/internal/lineindex"
+	lspserver "github.com/toba/lsp/server"
+	"go.lsp.dev/protocol"
+)
+
+// Handler is the minimal interface each language-specific LSP
+// implements. It is the toba/lsp/server contract, so a handler
+// runs on either harness.
+type Handler = lspserver.Handler
+
+// TextChange is a document edit resolved to byte offsets. Start
+// and End index the content as it was immediately before the
+// change was applied.
+type TextChange struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// DocumentSyncHandler is optionally implemented by handlers that
+// keep their own per-document state. DidOpen, DidChange and
+// DidClose are called synchronously, in the order notifications
+// arrive, before diagnostics are scheduled. content is the full
+// text after the changes were applied.
+type DocumentSyncHandler interface {
+	DidOpen(ctx context.Context, uri protocol.DocumentURI, content string)
+	DidChange(
+		ctx context.Context,
+		uri protocol.DocumentURI,
+		changes []TextChange,
+		content string,
+	)
+	DidClose(ctx context.Context, uri protocol.DocumentURI)
+}
+
+// PositionEncodingHandler is optionally implemented by handlers
+// that convert positions themselves. SetPositionEncoding is
+// called with the encoding negotiated with the client before
+// Handler.Initialize; every position exchanged afterwards counts
+// characters in it.
+type PositionEncodingHandler interface {
+	SetPositionEncoding(enc lineindex.Encoding)
+}
+
+// WorkspaceDiagnosticsHandler is optionally implemented to report
+// diagnostics for every file in the workspace through
+// workspace/diagnostic, including files the client hasn't opened.
+// Open documents are reported through textDocument/diagnostic
+// instead and are dropped from the result.
+type WorkspaceDiagnosticsHandler interface {
+	WorkspaceDiagnostics(
+		ctx context.Context,
+	) (map[protocol.DocumentURI][]protocol.Diagnostic, error)
+}
+
+// WatchedFilesHandler is optionally implemented to follow changes
+// made to workspace files outside the editor. WatchPatterns
+// returns the glob patterns to watch; they are registered
+// dynamically with clients that support it.
+// DidChangeWatchedFiles receives the resulting events.
+type WatchedFilesHandler interface {
+	WatchPatterns() []string
+	DidChangeWatchedFiles(ctx context.Context, changes []protocol.FileEvent)
+}
+
+// WorkspaceFoldersHandler is optionally implemented by handlers
+// that support multi-root workspaces. Servers whose handler
+// implements it advertise workspace folder support and forward
+// workspace/didChangeWorkspaceFolders to DidChangeWorkspaceFolders.
+type WorkspaceFoldersHandler interface {
+	DidChangeWorkspaceFolders(
+		ctx context.Context,
+		added, removed []protocol.WorkspaceFolder,
+	)
+}
+
+// BackgroundHandler is optionally implemented by handlers that do
+// work outside of requests, such as indexing the workspace.
+// Initialized is called once the client has finished
+// initializing. It must not block: long-running work belongs on
+// a new goroutine, which can report progress and announce changes
+// to shared state through bg.
+type BackgroundHandler interface {
+	Initialized(bg *Background)
+}
+
+// InterFileHandler is optionally implemented by handlers whose
+// diagnostics for one document depend on other files.
+// WorkspaceVersion must change whenever that shared state does;
+// the server then re-evaluates diagnostics for every open
+// document, or asks pull clients to refresh.
+type InterFileHandler interface {
+	WorkspaceVersion() uint64
+}
+
+// HoverHandler is optionally implemented for textDocument/hover.
+type HoverHandler = lspserver.HoverHandler
+
+// CompletionHandler is optionally implemented for textDocument/completion.
+type CompletionHandler = lspserver.CompletionHandler
+
+// DefinitionHandler is optionally implemented for textDocument/definition.
+type DefinitionHandler = lspserver.DefinitionHandler
+
+// FormattingHandler is optionally implemented for textDocument/formatting.
+type FormattingHandler = lspserver.FormattingHandler
+
+// RangeFormattingHandler is optionally implemented for
+// textDocument/rangeFormatting.
+type RangeFormattingHandler interface {
+	RangeFormatting(
+		ctx context.Context,
+		params *protocol.DocumentRangeFormattingParams,
+	) ([]protocol.TextEdit, error)
+}
+
+// OnTypeFormattingHandler is optionally implemented for
+// textDocument/onTypeFormatting.
+type OnTypeFormattingHandler interface {
+	OnTypeFormatting(
+		ctx context.Context,
+		params *protocol.DocumentOnTypeFormattingParams,
+	) ([]protocol.TextEdit, error)
+}
+
+// CodeActionHandler is optionally implemented for textDocument/codeAction.
+type CodeActionHandler = lspserver.CodeActionHandler
+
+// ReferencesHandler is optionally implemented for textDocument/references.
+type ReferencesHandler = lspserver.ReferencesHandler
+
+// RenameHandler is optionally implemented for textDocument/rename.
+type RenameHandler = lspserver.RenameHandler
+
+// DocumentSymbolHandler is optionally implemented for textDocument/documentSymbol.
+type DocumentSymbolHandler = lspserver.DocumentSymbolHandler
+
+// DocumentColorHandler is optionally implemented for textDocument/documentColor.
+type DocumentColorHandler interface {
+	DocumentColor(
+		ctx context.Context,
+		params *protocol.DocumentColorParams,
+	) ([]protocol.ColorInformation, error)
+}
+
+// ColorPresentationHandler is optionally implemented for
+// textDocument/colorPresentation.
+type ColorPresentationHandler interface {
+	ColorPresentation(
+		ctx context.Context,
+		params *protocol.ColorPresentationParams,
+	) ([]protocol.ColorPresentation, error)
+}
+
+// DocumentLinkHandler is optionally implemented for textDocument/documentLink.
+type DocumentLinkHandler interface {
+	DocumentLink(
+		ctx context.Context,
+		params *protocol.DocumentLinkParams,
+	) ([]protocol.DocumentLink, error)
+}
+
+// FileRenameHandler is optionally implemented to update references
+// to files and folders the client is about to rename, through
+// workspace/willRenameFiles.
+type FileRenameHandler interface {
+	WillRenameFiles(
+		ctx context.Context,
+		params *protocol.RenameFilesParams,
+	) (*protocol.WorkspaceEdit, error)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// Background gives handlers access to server facilities from work
+// they run outside of requests and notifications.
+type Background struct {
+	s *Server
+}
+
+// Context returns a context that is cancelled when the server
+// shuts down.
+func (b *Background) Context() context.Context {
+	return b.s.bgCtx
+}
+
+// StartProgress begins reporting the progress of work titled
+// title through window/workDoneProgress. The returned context is
+// derived from ctx and is also cancelled when the user cancels the
+// work in the client. Reports are discarded when the client
+// doesn't support work done progress.
+//
+// StartProgress waits for the client to accept the progress token,
+// so it must not be called on a goroutine that handles a request
+// or notification.
+func (b *Background) StartProgress(
+	ctx context.Context,
+	title string,
+) (context.Context, *WorkDone) {
+	s := b.s
+	ctx, cancel := context.WithCancel(ctx)
+	w := &WorkDone{cancel: cancel}
+	if !s.workDone {
+		return ctx, w
+	}
+
+	token := protocol.NewProgressToken(
+		fmt.Sprintf("%s/%d", s.Name, s.progressSeq.Add(1)),
+	)
+	err := s.client.WorkDoneProgressCreate(ctx, &protocol.WorkDoneProgressCreateParams{
+		Token: *token,
+	})
+	if err != nil {
+		slog.Debug("creating work done progress failed", "error", err)
+		return ctx, w
+	}
+
+	s.progressMu.Lock()
+	s.progress[token.String()] = cancel
+	s.progressMu.Unlock()
+
+	w.s, w.token = s, token
+	w.send(ctx, &protocol.WorkDoneProgressBegin{
+		Kind:        protocol.WorkDoneProgressKindBegin,
+		Title:       title,
+		Cancellable: true,
+	})
+	return ctx, w
+}
+
+// WorkspaceChanged re-evaluates the diagnostics of open documents
+// after background work changed the state they depend on. See
+// InterFileHandler.
+func (b *Background) WorkspaceChanged() {
+	b.s.workspaceChanged(b.s.bgCtx, "")
+}
+
+// WorkDone reports the progress of one piece of background work.
+// Its methods are safe for concurrent use, and reports after End
+// are dropped.
+type WorkDone struct {
+	s      *Server
+	token  *protocol.ProgressToken
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	percentage uint32
+	ended      bool
+}
+
+// Report updates the progress message and percentage. Reports
+// that change neither are not sent.
+func (w *WorkDone) Report(message string, percentage uint32) {
+	w.mu.Lock()
+	if w.ended || (percentage == w.percentage && message == "") {
+		w.mu.Unlock()
+		return
+	}
+	w.percentage = percentage
+	w.mu.Unlock()
+
+	w.send(context.Background(), &protocol.WorkDoneProgressReport{
+		Kind:        protocol.WorkDoneProgressKindReport,
+		Cancellable: true,
+		Message:     message,
+		Percentage:  percentage,
+	})
+}
+
+// End finishes the report with an optional final message and
+// releases the work's context.
+func (w *WorkDone) End(message string) {
+	w.mu.Lock()
+	ended := w.ended
+	w.ended = true
+	w.mu.Unlock()
+	if ended {
+		return
+	}
+
+	w.send(context.Background(), &protocol.WorkDoneProgressEnd{
+		Kind:    protocol.WorkDoneProgressKindEnd,
+		Message: message,
+	})
+	if w.s != nil {
+		w.s.progressMu.Lock()
+		delete(w.s.progress, w.token.String())
+		w.s.progressMu.Unlock()
+	}
+	w.cancel()
+}
+
+// send notifies the client of a progress value.
+func (w *WorkDone) send(ctx context.Context, value any) {
+	if w.s == nil {
+		return
+	}
+	err := w.s.client.Progress(ctx, &protocol.ProgressParams{
+		Token: *w.token,
+		Value: value,
+	})
+	if err != nil {
+		slog.Debug("sending progress failed", "error", err)
+	}
+}
+
+// WorkDoneProgressCancel cancels the work whose progress the user
+// dismissed in the client.
+func (s *Server) WorkDoneProgressCancel(
+	_ context.Context,
+	params *protocol.WorkDoneProgressCancelParams,
+) error {
+	s.progressMu.Lock()
+	cancel, ok := s.progress[params.Token.String()]
+	s.progressMu.Unlock()
+	if ok {
+		cancel()
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/toba/css-lsp/internal/lineindex"
+	"go.lsp.dev/protocol"
+)
+
+// clientExtensions captures LSP 3.17 client capabilities from
+// initialize params that go.lsp.dev/protocol does not model.
+type clientExtensions struct {
+	Capabilities struct {
+		General struct {
+			PositionEncodings []string `json:"positionEncodings"`
+		} `json:"general"`
+		TextDocument struct {
+			Diagnostic *json.RawMessage `json:"diagnostic"`
+		} `json:"textDocument"`
+		Workspace struct {
+			Diagnostics struct {
+				RefreshSupport bool `json:"refreshSupport"`
+			} `json:"diagnostics"`
+		} `json:"workspace"`
+	} `json:"capabilities"`
+}
+
+// initializeResult mirrors protocol.InitializeResult with the
+// negotiated position encoding added to the capabilities.
+type initializeResult struct {
+	Capabilities serverCapabilities   `json:"capabilities"`
+	ServerInfo   *protocol.ServerInfo `json:"serverInfo,omitempty"`
+}
+
+// serverCapabilities adds LSP 3.17 capabilities to
+// protocol.ServerCapabilities.
+type serverCapabilities struct {
+	protocol.ServerCapabilities
+
+	PositionEncoding   lineindex.Encoding `json:"positionEncoding,omitempty"`
+	DiagnosticProvider *diagnosticOptions `json:"diagnosticProvider,omitempty"`
+}
+
+// diagnosticOptions advertises pull diagnostics support.
+type diagnosticOptions struct {
+	InterFileDependencies bool `json:"interFileDependencies"`
+	WorkspaceDiagnostics  bool `json:"workspaceDiagnostics"`
+}
+
+// LSP 3.17 methods go.lsp.dev/protocol does not define.
+const (
+	methodTextDocumentDiagnostic     = "textDocument/diagnostic"
+	methodWorkspaceDiagnostic        = "workspace/diagnostic"
+	methodWorkspaceDiagnosticRefresh = "workspace/diagnostic/refresh"
+)
+
+// Document diagnostic report kinds.
+const (
+	reportFull      = "full"
+	reportUnchanged = "unchanged"
+)
+
+// documentDiagnosticParams are the params of a
+// textDocument/diagnostic request.
+type documentDiagnosticParams struct {
+	TextDocument     protocol.TextDocumentIdentifier `json:"textDocument"`
+	PreviousResultID string                          `json:"previousResultId,omitempty"`
+}
+
+// documentDiagnosticReport is a full or unchanged report. Items
+// is nil, and omitted, for unchanged reports; full reports always
+// carry a non-nil slice.
+type documentDiagnosticReport struct {
+	Kind     string                `json:"kind"`
+	ResultID string                `json:"resultId"`
+	Items    []protocol.Diagnostic `json:"items,omitzero"`
+}
+
+// workspaceDiagnosticParams are the params of a
+// workspace/diagnostic request.
+type workspaceDiagnosticParams struct {
+	PreviousResultIDs []previousResultID `json:"previousResultIds"`
+}
+
+// previousResultID is the result ID a client holds for a URI.
+type previousResultID struct {
+	URI   protocol.DocumentURI `json:"uri"`
+	Value string               `json:"value"`
+}
+
+// workspaceDiagnosticReport is the result of a
+// workspace/diagnostic request.
+type workspaceDiagnosticReport struct {
+	Items []workspaceDocumentDiagnosticReport `json:"items"`
+}
+
+// workspaceDocumentDiagnosticReport is a document report within
+// a workspace report. Version is always null: only files that
+// aren't open are reported.
+type workspaceDocumentDiagnosticReport struct {
+	documentDiagnosticReport
+
+	URI     protocol.DocumentURI `json:"uri"`
+	Version *int32               `json:"version"`
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"slices"
+
+	"go.lsp.dev/protocol"
+)
+
+// resultID identifies a set of diagnostics. It is derived from
+// the diagnostics themselves, so a document whose diagnostics
+// didn't change reports the same ID even if its content or its
+// dependencies did.
+func resultID(diags []protocol.Diagnostic) string {
+	data, _ := json.Marshal(diags)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:12])
+}
+
+// report builds a full report, or an unchanged one when the
+// client already holds the same result.
+func report(
+	diags []protocol.Diagnostic,
+	previous string,
+) documentDiagnosticReport {
+	if diags == nil {
+		diags = []protocol.Diagnostic{}
+	}
+	id := resultID(diags)
+	if id == previous {
+		return documentDiagnosticReport{Kind: reportUnchanged, ResultID: id}
+	}
+	return documentDiagnosticReport{Kind: reportFull, ResultID: id, Items: diags}
+}
+
+// documentDiagnostic answers a textDocument/diagnostic request.
+// Documents that aren't open have no diagnostics.
+func (s *Server) documentDiagnostic(
+	ctx context.Context,
+	params *documentDiagnosticParams,
+) (*documentDiagnosticReport, error) {
+	uri := params.TextDocument.URI
+	var diags []protocol.Diagnostic
+	if content, ok := s.docs.get(uri); ok {
+		var err error
+		diags, err = s.Handler.Diagnostics(ctx, uri, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r := report(diags, params.PreviousResultID)
+	return &r, nil
+}
+
+// workspaceDiagnostic answers a workspace/diagnostic request with
+// a report for every workspace file that isn't open.
+func (s *Server) workspaceDiagnostic(
+	ctx context.Context,
+	params *workspaceDiagnosticParams,
+) (*workspaceDiagnosticReport, error) {
+	result := &workspaceDiagnosticReport{
+		Items: []workspaceDocumentDiagnosticReport{},
+	}
+	h, ok := s.Handler.(WorkspaceDiagnosticsHandler)
+	if !ok {
+		return result, nil
+	}
+
+	all, err := h.WorkspaceDiagnostics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[protocol.DocumentURI]string, len(params.PreviousResultIDs))
+	for _, p := range params.PreviousResultIDs {
+		previous[p.URI] = p.Value
+	}
+
+	for uri, diags := range all {
+		if _, open := s.docs.get(uri); open {
+			continue
+		}
+		result.Items = append(result.Items, workspaceDocumentDiagnosticReport{
+			documentDiagnosticReport: report(diags, previous[uri]),
+			URI:                      uri,
+		})
+	}
+	slices.SortFunc(result.Items, func(a, b workspaceDocumentDiagnosticReport) int {
+		return cmp.Compare(a.URI, b.URI)
+	})
+
+	return result, nil
+}
+
+// workspaceChanged re-evaluates diagnostics after a change that
+// may affect other documents, as reported by an InterFileHandler.
+// Push clients get fresh diagnostics for every open document
+// except changed, which was already queued; pull clients are
+// asked to pull again.
+func (s *Server) workspaceChanged(ctx context.Context, changed protocol.DocumentURI) {
+	h, ok := s.Handler.(InterFileHandler)
+	if !ok {
+		return
+	}
+	v := h.WorkspaceVersion()
+	if s.workspaceVersion.Swap(v) == v {
+		return
+	}
+
+	if s.pull {
+		if s.refresh {
+			// The reply comes back through this connection, so
+			// don't wait for it on the handler goroutine.
+			go func() {
+				_, err := s.conn.Call(ctx, methodWorkspaceDiagnosticRefresh, nil, nil)
+				if err != nil {
+					slog.Debug("diagnostic refresh failed", "error", err)
+				}
+			}()
+		}
+		return
+	}
+
+	for uri, content := range s.docs.all() {
+		if uri != changed {
+			s.diag.request(uri, content)
+		}
+	}
+}
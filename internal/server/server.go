@@ -0,0 +1,787 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/toba/css-lsp/internal/lineindex"
+	"github.com/toba/lsp/logging"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.uber.org/zap"
+)
+
+// Server manages the LSP lifecycle. Embed a Handler and configure
+// Name/Version, then call Run to start the server on stdin/stdout.
+type Server struct {
+	Name    string
+	Version string
+	Handler Handler
+
+	docs     *documents
+	diag     *diagnosticPublisher
+	encoding lineindex.Encoding
+	client   protocol.Client
+	conn     jsonrpc2.Conn
+
+	// pull is set when the client pulls diagnostics, in which
+	// case none are pushed; refresh when it also accepts
+	// workspace/diagnostic/refresh requests.
+	pull             bool
+	refresh          bool
+	workspaceVersion atomic.Uint64
+
+	// watch is set when the client accepts dynamic registration
+	// of file watchers.
+	watch bool
+
+	// bgCtx is cancelled at shutdown to stop background work.
+	// workDone is set when the client accepts progress reports;
+	// progress holds the cancel functions of reports in flight,
+	// by token.
+	bgCtx       context.Context
+	bgCancel    context.CancelFunc
+	workDone    bool
+	progressSeq atomic.Int32
+	progressMu  sync.Mutex
+	progress    map[string]context.CancelFunc
+}
+
+// Run starts the LSP server on stdin/stdout. Blocks until the
+// connection is closed or context is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	logging.Configure(s.Name)
+
+	s.serve(ctx, jsonrpc2.NewStream(stdRWC{}))
+
+	slog.Info("server started", "name", s.Name, "version", s.Version)
+
+	<-s.conn.Done()
+	return nil
+}
+
+// serve wires the server to a stream and starts the read loop and
+// diagnostic publisher.
+func (s *Server) serve(ctx context.Context, stream jsonrpc2.Stream) {
+	s.docs = newDocuments()
+	s.encoding = lineindex.UTF16
+	s.bgCtx, s.bgCancel = context.WithCancel(ctx)
+	s.progress = make(map[string]context.CancelFunc)
+
+	s.conn = jsonrpc2.NewConn(stream)
+	s.client = protocol.ClientDispatcher(s.conn, zap.NewNop())
+	ctx = protocol.WithClient(ctx, s.client)
+
+	s.diag = newDiagnosticPublisher(s.Handler, s.client)
+	go s.diag.run(ctx)
+
+	s.conn.Go(ctx, protocol.Handlers(
+		s.intercept(protocol.ServerHandler(s, jsonrpc2.MethodNotFoundHandler)),
+	))
+}
+
+// intercept handles messages whose wire format go.lsp.dev/protocol
+// decodes lossily, passing everything else to next.
+func (s *Server) intercept(next jsonrpc2.Handler) jsonrpc2.Handler {
+	return func(
+		ctx context.Context,
+		reply jsonrpc2.Replier,
+		req jsonrpc2.Request,
+	) error {
+		switch req.Method() {
+		case protocol.MethodInitialize:
+			var params protocol.InitializeParams
+			if err := json.Unmarshal(req.Params(), &params); err != nil {
+				return reply(ctx, nil, jsonrpc2.ErrParse)
+			}
+			var ext clientExtensions
+			_ = json.Unmarshal(req.Params(), &ext)
+			s.pull = ext.Capabilities.TextDocument.Diagnostic != nil
+			s.refresh = ext.Capabilities.Workspace.Diagnostics.RefreshSupport
+			enc := lineindex.Negotiate(
+				ext.Capabilities.General.PositionEncodings,
+			)
+			result, err := s.initialize(ctx, &params, enc)
+			return reply(ctx, result, err)
+
+		case methodTextDocumentDiagnostic:
+			var params documentDiagnosticParams
+			if err := json.Unmarshal(req.Params(), &params); err != nil {
+				return reply(ctx, nil, jsonrpc2.ErrParse)
+			}
+			result, err := s.documentDiagnostic(ctx, &params)
+			return reply(ctx, result, err)
+
+		case methodWorkspaceDiagnostic:
+			var params workspaceDiagnosticParams
+			if err := json.Unmarshal(req.Params(), &params); err != nil {
+				return reply(ctx, nil, jsonrpc2.ErrParse)
+			}
+			result, err := s.workspaceDiagnostic(ctx, &params)
+			return reply(ctx, result, err)
+
+		case protocol.MethodTextDocumentDidChange:
+			var params didChangeParams
+			if err := json.Unmarshal(req.Params(), &params); err != nil {
+				return reply(ctx, nil, jsonrpc2.ErrParse)
+			}
+			s.didChange(ctx, params.TextDocument.URI, params.ContentChanges)
+			return reply(ctx, nil, nil)
+		}
+		return next(ctx, reply, req)
+	}
+}
+
+// stdRWC wraps stdin/stdout as a ReadWriteCloser for jsonrpc2.
+type stdRWC struct{}
+
+func (stdRWC) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdRWC) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (stdRWC) Close() error                { return nil }
+
+// --- protocol.Server implementation ---
+
+// Initialize is only reached when the intercepting handler is
+// bypassed, so no position encoding was negotiated and the LSP
+// default applies.
+func (s *Server) Initialize(
+	ctx context.Context,
+	params *protocol.InitializeParams,
+) (*protocol.InitializeResult, error) {
+	result, err := s.initialize(ctx, params, lineindex.UTF16)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.InitializeResult{
+		Capabilities: result.Capabilities.ServerCapabilities,
+		ServerInfo:   result.ServerInfo,
+	}, nil
+}
+
+func (s *Server) initialize(
+	ctx context.Context,
+	params *protocol.InitializeParams,
+	enc lineindex.Encoding,
+) (*initializeResult, error) {
+	// Handlers learn the encoding first so that Initialize can
+	// already rely on it.
+	s.encoding = enc
+	s.docs.encoding = enc
+	if h, ok := s.Handler.(PositionEncodingHandler); ok {
+		h.SetPositionEncoding(enc)
+	}
+
+	caps, err := s.Handler.Initialize(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if h, ok := s.Handler.(InterFileHandler); ok {
+		s.workspaceVersion.Store(h.WorkspaceVersion())
+	}
+
+	// Documents are always synced incrementally; the server keeps
+	// the full text and hands handlers resolved edits.
+	caps.TextDocumentSync = protocol.TextDocumentSyncOptions{
+		OpenClose: true,
+		Change:    protocol.TextDocumentSyncKindIncremental,
+	}
+
+	if implements[WorkspaceFoldersHandler](s.Handler) {
+		if caps.Workspace == nil {
+			caps.Workspace = &protocol.ServerCapabilitiesWorkspace{}
+		}
+		caps.Workspace.WorkspaceFolders = &protocol.ServerCapabilitiesWorkspaceFolders{
+			Supported:           true,
+			ChangeNotifications: true,
+		}
+	}
+
+	if implements[FileRenameHandler](s.Handler) {
+		if caps.Workspace == nil {
+			caps.Workspace = &protocol.ServerCapabilitiesWorkspace{}
+		}
+		// Folders are included: moving one moves what it holds.
+		rename := &protocol.FileOperationRegistrationOptions{
+			Filters: []protocol.FileOperationFilter{{
+				Scheme:  "file",
+				Pattern: protocol.FileOperationPattern{Glob: "**/*"},
+			}},
+		}
+		caps.Workspace.FileOperations =
+			&protocol.ServerCapabilitiesWorkspaceFileOperations{WillRename: rename}
+	}
+
+	if ws := params.Capabilities.Workspace; ws != nil &&
+		ws.DidChangeWatchedFiles != nil {
+		s.watch = ws.DidChangeWatchedFiles.DynamicRegistration
+	}
+	if w := params.Capabilities.Window; w != nil {
+		s.workDone = w.WorkDoneProgress
+	}
+
+	clientName := ""
+	if params.ClientInfo != nil {
+		clientName = params.ClientInfo.Name
+	}
+	slog.Info("initialized", "client", clientName, "positionEncoding", enc)
+
+	return &initializeResult{
+		Capabilities: serverCapabilities{
+			ServerCapabilities: caps,
+			PositionEncoding:   enc,
+			DiagnosticProvider: &diagnosticOptions{
+				InterFileDependencies: implements[InterFileHandler](s.Handler),
+				WorkspaceDiagnostics:  implements[WorkspaceDiagnosticsHandler](s.Handler),
+			},
+		},
+		ServerInfo: &protocol.ServerInfo{
+			Name:    s.Name,
+			Version: s.Version,
+		},
+	}, nil
+}
+
+// implements reports whether h implements the optional handler
+// interface T.
+func implements[T any](h Handler) bool {
+	_, ok := h.(T)
+	return ok
+}
+
+func (s *Server) Initialized(ctx context.Context, _ *protocol.InitializedParams) error {
+	slog.Info("client initialized")
+	if h, ok := s.Handler.(WatchedFilesHandler); ok && s.watch {
+		// The reply comes back through this connection, so don't
+		// wait for it on the handler goroutine.
+		go s.registerWatchers(ctx, h.WatchPatterns())
+	}
+	if h, ok := s.Handler.(BackgroundHandler); ok {
+		h.Initialized(&Background{s: s})
+	}
+	return nil
+}
+
+// watchedFilesRegistrationID identifies the dynamic registration
+// of file watchers.
+const watchedFilesRegistrationID = "watched-files"
+
+// registerWatchers asks the client to watch files matching
+// patterns and report changes through
+// workspace/didChangeWatchedFiles.
+func (s *Server) registerWatchers(ctx context.Context, patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+	watchers := make([]protocol.FileSystemWatcher, len(patterns))
+	for i, p := range patterns {
+		watchers[i] = protocol.FileSystemWatcher{GlobPattern: p}
+	}
+	err := s.client.RegisterCapability(ctx, &protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     watchedFilesRegistrationID,
+			Method: protocol.MethodWorkspaceDidChangeWatchedFiles,
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: watchers,
+			},
+		}},
+	})
+	if err != nil {
+		slog.Warn("registering file watchers failed", "error", err)
+	}
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	slog.Info("shutdown requested")
+	s.bgCancel()
+	return s.Handler.Shutdown(ctx)
+}
+
+func (s *Server) Exit(context.Context) error {
+	slog.Info("exit")
+	return s.conn.Close()
+}
+
+func (s *Server) DidOpen(
+	ctx context.Context,
+	params *protocol.DidOpenTextDocumentParams,
+) error {
+	uri := params.TextDocument.URI
+	content := params.TextDocument.Text
+	s.docs.open(uri, content)
+	if h, ok := s.Handler.(DocumentSyncHandler); ok {
+		h.DidOpen(ctx, uri, content)
+	}
+	s.documentChanged(ctx, uri, content)
+	return nil
+}
+
+// DidChange is only reached when the intercepting handler is
+// bypassed. protocol.TextDocumentContentChangeEvent can't tell a
+// full-text change from an edit at the start of the document, so
+// a change with an empty range and no text length is treated as
+// full text.
+func (s *Server) DidChange(
+	ctx context.Context,
+	params *protocol.DidChangeTextDocumentParams,
+) error {
+	changes := make([]contentChange, len(params.ContentChanges))
+	for i, c := range params.ContentChanges {
+		changes[i].Text = c.Text
+		if c.RangeLength != 0 || c.Range != (protocol.Range{}) {
+			changes[i].Range = &c.Range
+		}
+	}
+	s.didChange(ctx, params.TextDocument.URI, changes)
+	return nil
+}
+
+func (s *Server) didChange(
+	ctx context.Context,
+	uri protocol.DocumentURI,
+	changes []contentChange,
+) {
+	applied, content, ok := s.docs.change(uri, changes)
+	if !ok {
+		return
+	}
+	if h, ok := s.Handler.(DocumentSyncHandler); ok {
+		h.DidChange(ctx, uri, applied, content)
+	}
+	s.documentChanged(ctx, uri, content)
+}
+
+// documentChanged schedules diagnostics after a document was
+// opened or edited: pushed for uri itself and, when the edit
+// affected shared state, for the other documents too.
+func (s *Server) documentChanged(
+	ctx context.Context,
+	uri protocol.DocumentURI,
+	content string,
+) {
+	if !s.pull {
+		s.diag.request(uri, content)
+	}
+	s.workspaceChanged(ctx, uri)
+}
+
+// DidClose forgets the document. Closing discards unsaved edits,
+// which may affect other documents as much as an edit would.
+func (s *Server) DidClose(
+	ctx context.Context,
+	params *protocol.DidCloseTextDocumentParams,
+) error {
+	uri := params.TextDocument.URI
+	s.docs.close(uri)
+	if h, ok := s.Handler.(DocumentSyncHandler); ok {
+		h.DidClose(ctx, uri)
+	}
+	s.workspaceChanged(ctx, uri)
+	return nil
+}
+
+func (s *Server) DidSave(context.Context, *protocol.DidSaveTextDocumentParams) error {
+	return nil
+}
+
+func (s *Server) WillSave(context.Context, *protocol.WillSaveTextDocumentParams) error {
+	return nil
+}
+
+func (s *Server) WillSaveWaitUntil(
+	context.Context,
+	*protocol.WillSaveTextDocumentParams,
+) ([]protocol.TextEdit, error) {
+	return nil, nil
+}
+
+// --- Optional handler delegation ---
+
+func (s *Server) Hover(
+	ctx context.Context,
+	params *protocol.HoverParams,
+) (*protocol.Hover, error) {
+	if h, ok := s.Handler.(HoverHandler); ok {
+		return h.Hover(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) Completion(
+	ctx context.Context,
+	params *protocol.CompletionParams,
+) (*protocol.CompletionList, error) {
+	if h, ok := s.Handler.(CompletionHandler); ok {
+		return h.Completion(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) Definition(
+	ctx context.Context,
+	params *protocol.DefinitionParams,
+) ([]protocol.Location, error) {
+	if h, ok := s.Handler.(DefinitionHandler); ok {
+		return h.Definition(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) Formatting(
+	ctx context.Context,
+	params *protocol.DocumentFormattingParams,
+) ([]protocol.TextEdit, error) {
+	if h, ok := s.Handler.(FormattingHandler); ok {
+		return h.Formatting(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) RangeFormatting(
+	ctx context.Context,
+	params *protocol.DocumentRangeFormattingParams,
+) ([]protocol.TextEdit, error) {
+	if h, ok := s.Handler.(RangeFormattingHandler); ok {
+		return h.RangeFormatting(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) OnTypeFormatting(
+	ctx context.Context,
+	params *protocol.DocumentOnTypeFormattingParams,
+) ([]protocol.TextEdit, error) {
+	if h, ok := s.Handler.(OnTypeFormattingHandler); ok {
+		return h.OnTypeFormatting(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) CodeAction(
+	ctx context.Context,
+	params *protocol.CodeActionParams,
+) ([]protocol.CodeAction, error) {
+	if h, ok := s.Handler.(CodeActionHandler); ok {
+		return h.CodeAction(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) References(
+	ctx context.Context,
+	params *protocol.ReferenceParams,
+) ([]protocol.Location, error) {
+	if h, ok := s.Handler.(ReferencesHandler); ok {
+		return h.References(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) Rename(
+	ctx context.Context,
+	params *protocol.RenameParams,
+) (*protocol.WorkspaceEdit, error) {
+	if h, ok := s.Handler.(RenameHandler); ok {
+		return h.Rename(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) DocumentSymbol(
+	ctx context.Context,
+	params *protocol.DocumentSymbolParams,
+) ([]any, error) {
+	if h, ok := s.Handler.(DocumentSymbolHandler); ok {
+		return h.DocumentSymbol(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) DocumentColor(
+	ctx context.Context,
+	params *protocol.DocumentColorParams,
+) ([]protocol.ColorInformation, error) {
+	if h, ok := s.Handler.(DocumentColorHandler); ok {
+		return h.DocumentColor(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) ColorPresentation(
+	ctx context.Context,
+	params *protocol.ColorPresentationParams,
+) ([]protocol.ColorPresentation, error) {
+	if h, ok := s.Handler.(ColorPresentationHandler); ok {
+		return h.ColorPresentation(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) DocumentLink(
+	ctx context.Context,
+	params *protocol.DocumentLinkParams,
+) ([]protocol.DocumentLink, error) {
+	if h, ok := s.Handler.(DocumentLinkHandler); ok {
+		return h.DocumentLink(ctx, params)
+	}
+	return nil, nil
+}
+
+// --- Unimplemented methods (no-op stubs for protocol.Server) ---
+
+func (s *Server) LogTrace(context.Context, *protocol.LogTraceParams) error {
+	return nil
+}
+
+func (s *Server) SetTrace(context.Context, *protocol.SetTraceParams) error {
+	return nil
+}
+
+func (s *Server) CodeLens(
+	context.Context,
+	*protocol.CodeLensParams,
+) ([]protocol.CodeLens, error) {
+	return nil, nil
+}
+
+func (s *Server) CodeLensResolve(
+	context.Context,
+	*protocol.CodeLens,
+) (*protocol.CodeLens, error) {
+	return nil, nil
+}
+
+func (s *Server) CompletionResolve(
+	context.Context,
+	*protocol.CompletionItem,
+) (*protocol.CompletionItem, error) {
+	return nil, nil
+}
+
+func (s *Server) Declaration(
+	context.Context,
+	*protocol.DeclarationParams,
+) ([]protocol.Location, error) {
+	return nil, nil
+}
+
+func (s *Server) DidChangeConfiguration(
+	context.Context,
+	*protocol.DidChangeConfigurationParams,
+) error {
+	return nil
+}
+
+// DidChangeWatchedFiles passes file system changes on to the
+// handler, then re-evaluates diagnostics that depend on them.
+func (s *Server) DidChangeWatchedFiles(
+	ctx context.Context,
+	params *protocol.DidChangeWatchedFilesParams,
+) error {
+	h, ok := s.Handler.(WatchedFilesHandler)
+	if !ok {
+		return nil
+	}
+	changes := make([]protocol.FileEvent, 0, len(params.Changes))
+	for _, c := range params.Changes {
+		if c != nil {
+			changes = append(changes, *c)
+		}
+	}
+	h.DidChangeWatchedFiles(ctx, changes)
+	s.workspaceChanged(ctx, "")
+	return nil
+}
+
+// DidChangeWorkspaceFolders passes added and removed workspace
+// folders on to the handler, then re-evaluates diagnostics that
+// depend on the files they contain.
+func (s *Server) DidChangeWorkspaceFolders(
+	ctx context.Context,
+	params *protocol.DidChangeWorkspaceFoldersParams,
+) error {
+	h, ok := s.Handler.(WorkspaceFoldersHandler)
+	if !ok {
+		return nil
+	}
+	h.DidChangeWorkspaceFolders(ctx, params.Event.Added, params.Event.Removed)
+	s.workspaceChanged(ctx, "")
+	return nil
+}
+
+func (s *Server) DocumentHighlight(
+	context.Context,
+	*protocol.DocumentHighlightParams,
+) ([]protocol.DocumentHighlight, error) {
+	return nil, nil
+}
+
+func (s *Server) DocumentLinkResolve(
+	context.Context,
+	*protocol.DocumentLink,
+) (*protocol.DocumentLink, error) {
+	return nil, nil
+}
+
+func (s *Server) ExecuteCommand(
+	context.Context,
+	*protocol.ExecuteCommandParams,
+) (any, error) {
+	return nil, nil
+}
+
+func (s *Server) FoldingRanges(
+	context.Context,
+	*protocol.FoldingRangeParams,
+) ([]protocol.FoldingRange, error) {
+	return nil, nil
+}
+
+func (s *Server) Implementation(
+	context.Context,
+	*protocol.ImplementationParams,
+) ([]protocol.Location, error) {
+	return nil, nil
+}
+
+func (s *Server) PrepareRename(
+	context.Context,
+	*protocol.PrepareRenameParams,
+) (*protocol.Range, error) {
+	return nil, nil
+}
+
+func (s *Server) SignatureHelp(
+	context.Context,
+	*protocol.SignatureHelpParams,
+) (*protocol.SignatureHelp, error) {
+	return nil, nil
+}
+
+func (s *Server) Symbols(
+	context.Context,
+	*protocol.WorkspaceSymbolParams,
+) ([]protocol.SymbolInformation, error) {
+	return nil, nil
+}
+
+func (s *Server) TypeDefinition(
+	context.Context,
+	*protocol.TypeDefinitionParams,
+) ([]protocol.Location, error) {
+	return nil, nil
+}
+
+func (s *Server) ShowDocument(
+	context.Context,
+	*protocol.ShowDocumentParams,
+) (*protocol.ShowDocumentResult, error) {
+	return nil, nil
+}
+
+func (s *Server) WillCreateFiles(
+	context.Context,
+	*protocol.CreateFilesParams,
+) (*protocol.WorkspaceEdit, error) {
+	return nil, nil
+}
+
+func (s *Server) DidCreateFiles(context.Context, *protocol.CreateFilesParams) error {
+	return nil
+}
+
+func (s *Server) WillRenameFiles(
+	ctx context.Context,
+	params *protocol.RenameFilesParams,
+) (*protocol.WorkspaceEdit, error) {
+	if h, ok := s.Handler.(FileRenameHandler); ok {
+		return h.WillRenameFiles(ctx, params)
+	}
+	return nil, nil
+}
+
+func (s *Server) DidRenameFiles(context.Context, *protocol.RenameFilesParams) error {
+	return nil
+}
+
+func (s *Server) WillDeleteFiles(
+	context.Context,
+	*protocol.DeleteFilesParams,
+) (*protocol.WorkspaceEdit, error) {
+	return nil, nil
+}
+
+func (s *Server) DidDeleteFiles(context.Context, *protocol.DeleteFilesParams) error {
+	return nil
+}
+
+func (s *Server) CodeLensRefresh(context.Context) error {
+	return nil
+}
+
+func (s *Server) PrepareCallHierarchy(
+	context.Context,
+	*protocol.CallHierarchyPrepareParams,
+) ([]protocol.CallHierarchyItem, error) {
+	return nil, nil
+}
+
+func (s *Server) IncomingCalls(
+	context.Context,
+	*protocol.CallHierarchyIncomingCallsParams,
+) ([]protocol.CallHierarchyIncomingCall, error) {
+	return nil, nil
+}
+
+func (s *Server) OutgoingCalls(
+	context.Context,
+	*protocol.CallHierarchyOutgoingCallsParams,
+) ([]protocol.CallHierarchyOutgoingCall, error) {
+	return nil, nil
+}
+
+func (s *Server) SemanticTokensFull(
+	context.Context,
+	*protocol.SemanticTokensParams,
+) (*protocol.SemanticTokens, error) {
+	return nil, nil
+}
+
+func (s *Server) SemanticTokensFullDelta(
+	context.Context,
+	*protocol.SemanticTokensDeltaParams,
+) (any, error) {
+	return nil, nil
+}
+
+func (s *Server) SemanticTokensRange(
+	context.Context,
+	*protocol.SemanticTokensRangeParams,
+) (*protocol.SemanticTokens, error) {
+	return nil, nil
+}
+
+func (s *Server) SemanticTokensRefresh(context.Context) error {
+	return nil
+}
+
+func (s *Server) LinkedEditingRange(
+	context.Context,
+	*protocol.LinkedEditingRangeParams,
+) (*protocol.LinkedEditingRanges, error) {
+	return nil, nil
+}
+
+func (s *Server) Moniker(
+	context.Context,
+	*protocol.MonikerParams,
+) ([]protocol.Moniker, error) {
+	return nil, nil
+}
+
+func (s *Server) Request(_ context.Context, _ string, _ any) (any, error) {
+	return nil, nil
+}
@@ -0,0 +1,1526 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/toba/css-lsp/internal/lineindex"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.uber.org/zap"
+)
+
+// testHandler is a minimal Handler for testing.
+type testHandler struct {
+	initCalled     bool
+	initErr        error
+	shutdownCalled bool
+	diagURIs       []protocol.DocumentURI
+}
+
+func (h *testHandler) Initialize(
+	_ context.Context,
+	_ *protocol.InitializeParams,
+) (protocol.ServerCapabilities, error) {
+	h.initCalled = true
+	if h.initErr != nil {
+		return protocol.ServerCapabilities{}, h.initErr
+	}
+	return protocol.ServerCapabilities{
+		HoverProvider: true,
+	}, nil
+}
+
+func (h *testHandler) Diagnostics(
+	_ context.Context,
+	uri protocol.DocumentURI,
+	_ string,
+) ([]protocol.Diagnostic, error) {
+	h.diagURIs = append(h.diagURIs, uri)
+	return []protocol.Diagnostic{
+		{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 0, Character: 0},
+				End:   protocol.Position{Line: 0, Character: 5},
+			},
+			Severity: protocol.DiagnosticSeverityError,
+			Message:  "test diagnostic",
+		},
+	}, nil
+}
+
+func (h *testHandler) Shutdown(context.Context) error {
+	h.shutdownCalled = true
+	return nil
+}
+
+// hoverHandler extends testHandler with Hover support.
+type hoverHandler struct {
+	testHandler
+	hoverCalled bool
+}
+
+func (h *hoverHandler) Hover(
+	_ context.Context,
+	_ *protocol.HoverParams,
+) (*protocol.Hover, error) {
+	h.hoverCalled = true
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{
+			Kind:  protocol.Markdown,
+			Value: "test hover",
+		},
+	}, nil
+}
+
+// startTestServer creates a Server connected via an in-process pipe and
+// returns a protocol.Server proxy to send requests, plus cleanup.
+func startTestServer(t *testing.T, handler Handler) (protocol.Server, func()) {
+	t.Helper()
+	conn, cleanup := startTestConn(t, handler)
+	return protocol.ServerDispatcher(conn, zap.NewNop()), cleanup
+}
+
+// startTestConn is like startTestServer but returns the raw client
+// connection, for messages protocol.Server can't express.
+func startTestConn(t *testing.T, handler Handler) (jsonrpc2.Conn, func()) {
+	t.Helper()
+	return startTestConnWithClient(t, handler, jsonrpc2.MethodNotFoundHandler)
+}
+
+// startTestConnWithClient is like startTestConn, with client
+// handling requests and notifications from the server.
+func startTestConnWithClient(
+	t *testing.T,
+	handler Handler,
+	client jsonrpc2.Handler,
+) (jsonrpc2.Conn, func()) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+
+	srv := &Server{
+		Name:    "test-lsp",
+		Version: "0.0.1",
+		Handler: handler,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Server side: serve wires up the jsonrpc2 handler and starts
+	// the read loop automatically.
+	srv.serve(ctx, jsonrpc2.NewStream(serverConn))
+
+	// Client side: must call Go() to start the read loop.
+	clientStream := jsonrpc2.NewStream(clientConn)
+	clientJSONConn := jsonrpc2.NewConn(clientStream)
+	clientJSONConn.Go(ctx, client)
+
+	cleanup := func() {
+		cancel()
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	}
+
+	return clientJSONConn, cleanup
+}
+
+func TestInitialize(t *testing.T) {
+	h := &testHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	result, err := client.Initialize(context.Background(), &protocol.InitializeParams{
+		ClientInfo: &protocol.ClientInfo{Name: "test-editor"},
+	})
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if !h.initCalled {
+		t.Error("Handler.Initialize was not called")
+	}
+	if result.ServerInfo.Name != "test-lsp" {
+		t.Errorf("expected server name test-lsp, got %s", result.ServerInfo.Name)
+	}
+	if result.ServerInfo.Version != "0.0.1" {
+		t.Errorf("expected version 0.0.1, got %s", result.ServerInfo.Version)
+	}
+	// TextDocumentSync is interface{}; after JSON roundtrip it's a map.
+	syncOpts, ok := result.Capabilities.TextDocumentSync.(map[string]any)
+	if !ok ||
+		protocol.TextDocumentSyncKind(syncOpts["change"].(float64)) !=
+			protocol.TextDocumentSyncKindIncremental {
+		t.Errorf(
+			"expected incremental sync, got %v",
+			result.Capabilities.TextDocumentSync,
+		)
+	}
+	if result.Capabilities.HoverProvider != true {
+		t.Error("expected hover provider to be true")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	h := &testHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	_, err := client.Initialize(context.Background(), &protocol.InitializeParams{})
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	err = client.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if !h.shutdownCalled {
+		t.Error("Handler.Shutdown was not called")
+	}
+}
+
+func TestDidOpenTriggersDiagnostics(t *testing.T) {
+	h := &testHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	_, err := client.Initialize(context.Background(), &protocol.InitializeParams{})
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	err = client.DidOpen(context.Background(), &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        "file:///test.txt",
+			LanguageID: "plaintext",
+			Version:    1,
+			Text:       "hello world",
+		},
+	})
+	if err != nil {
+		t.Fatalf("DidOpen failed: %v", err)
+	}
+
+	// Wait for debounced diagnostics to fire.
+	time.Sleep(300 * time.Millisecond)
+
+	if len(h.diagURIs) == 0 {
+		t.Error("expected Diagnostics to be called after DidOpen")
+	}
+}
+
+func TestDidChangeTriggersDiagnostics(t *testing.T) {
+	h := &testHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	_, err := client.Initialize(context.Background(), &protocol.InitializeParams{})
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	uri := protocol.DocumentURI("file:///test.txt")
+
+	err = client.DidOpen(context.Background(), &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI: uri, LanguageID: "plaintext", Version: 1, Text: "v1",
+		},
+	})
+	if err != nil {
+		t.Fatalf("DidOpen failed: %v", err)
+	}
+
+	// Wait for first diagnostic round.
+	time.Sleep(300 * time.Millisecond)
+	initialCount := len(h.diagURIs)
+
+	err = client.DidChange(context.Background(), &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+			Version:                2,
+		},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{
+			{Text: "v2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("DidChange failed: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	if len(h.diagURIs) <= initialCount {
+		t.Error("expected Diagnostics to be called after DidChange")
+	}
+}
+
+func TestDocumentStore(t *testing.T) {
+	docs := newDocuments()
+
+	uri := protocol.DocumentURI("file:///test.go")
+	docs.open(uri, "package main")
+
+	content, ok := docs.get(uri)
+	if !ok {
+		t.Fatal("expected document to exist")
+	}
+	if content != "package main" {
+		t.Errorf("unexpected content: %s", content)
+	}
+
+	docs.change(uri, []contentChange{{Text: "package foo"}})
+	content, _ = docs.get(uri)
+	if content != "package foo" {
+		t.Errorf("unexpected content after change: %s", content)
+	}
+
+	docs.close(uri)
+	_, ok = docs.get(uri)
+	if ok {
+		t.Error("expected document to be removed after close")
+	}
+}
+
+func TestInitializeError(t *testing.T) {
+	h := &testHandler{initErr: errors.New("init failed")}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	_, err := client.Initialize(
+		context.Background(),
+		&protocol.InitializeParams{},
+	)
+	if err == nil {
+		t.Fatal("expected Initialize to return error")
+	}
+}
+
+func TestInitializeNilClientInfo(t *testing.T) {
+	h := &testHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	// ClientInfo is nil — should not panic.
+	result, err := client.Initialize(
+		context.Background(),
+		&protocol.InitializeParams{},
+	)
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if result.ServerInfo.Name != "test-lsp" {
+		t.Errorf("unexpected server name: %s", result.ServerInfo.Name)
+	}
+}
+
+func TestDidCloseRemovesDocument(t *testing.T) {
+	h := &testHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	_, err := client.Initialize(
+		context.Background(),
+		&protocol.InitializeParams{},
+	)
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	uri := protocol.DocumentURI("file:///close-test.txt")
+
+	err = client.DidOpen(
+		context.Background(),
+		&protocol.DidOpenTextDocumentParams{
+			TextDocument: protocol.TextDocumentItem{
+				URI: uri, LanguageID: "plaintext",
+				Version: 1, Text: "content",
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("DidOpen failed: %v", err)
+	}
+
+	err = client.DidClose(
+		context.Background(),
+		&protocol.DidCloseTextDocumentParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: uri,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("DidClose failed: %v", err)
+	}
+
+	// Give the notification time to process.
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestHoverDelegation(t *testing.T) {
+	h := &hoverHandler{}
+	client, cleanup := startTestServer(t, &h.testHandler)
+	defer cleanup()
+
+	_, err := client.Initialize(
+		context.Background(),
+		&protocol.InitializeParams{},
+	)
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	// Handler was started with testHandler (no Hover), so Hover
+	// should return nil without error.
+	result, err := client.Hover(
+		context.Background(),
+		&protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{
+					URI: "file:///test.txt",
+				},
+				Position: protocol.Position{
+					Line: 0, Character: 0,
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Hover failed: %v", err)
+	}
+	// No HoverHandler implemented on testHandler, so result is nil.
+	if result != nil {
+		t.Errorf("expected nil hover result, got %v", result)
+	}
+}
+
+func TestHoverWithHandler(t *testing.T) {
+	h := &hoverHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	_, err := client.Initialize(
+		context.Background(),
+		&protocol.InitializeParams{},
+	)
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	result, err := client.Hover(
+		context.Background(),
+		&protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{
+					URI: "file:///test.txt",
+				},
+				Position: protocol.Position{
+					Line: 0, Character: 0,
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Hover failed: %v", err)
+	}
+	if !h.hoverCalled {
+		t.Error("expected Hover handler to be called")
+	}
+	if result == nil {
+		t.Fatal("expected non-nil hover result")
+	}
+}
+
+// colorHandler extends testHandler with DocumentColor support.
+type colorHandler struct {
+	testHandler
+}
+
+func (h *colorHandler) DocumentColor(
+	_ context.Context,
+	_ *protocol.DocumentColorParams,
+) ([]protocol.ColorInformation, error) {
+	return []protocol.ColorInformation{{
+		Color: protocol.Color{Red: 1, Alpha: 1},
+	}}, nil
+}
+
+func TestDocumentColorWithHandler(t *testing.T) {
+	client, cleanup := startTestServer(t, &colorHandler{})
+	defer cleanup()
+
+	_, err := client.Initialize(
+		context.Background(),
+		&protocol.InitializeParams{},
+	)
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	colors, err := client.DocumentColor(
+		context.Background(),
+		&protocol.DocumentColorParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: "file:///test.css",
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("DocumentColor failed: %v", err)
+	}
+	if len(colors) != 1 || colors[0].Color.Red != 1 {
+		t.Errorf("colors = %+v", colors)
+	}
+}
+
+// presentationHandler extends testHandler with ColorPresentation
+// support.
+type presentationHandler struct {
+	testHandler
+}
+
+func (h *presentationHandler) ColorPresentation(
+	_ context.Context,
+	params *protocol.ColorPresentationParams,
+) ([]protocol.ColorPresentation, error) {
+	return []protocol.ColorPresentation{{
+		Label:    "#ff0000",
+		TextEdit: &protocol.TextEdit{Range: params.Range, NewText: "#ff0000"},
+	}}, nil
+}
+
+func TestColorPresentationWithHandler(t *testing.T) {
+	client, cleanup := startTestServer(t, &presentationHandler{})
+	defer cleanup()
+
+	_, err := client.Initialize(
+		context.Background(),
+		&protocol.InitializeParams{},
+	)
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	items, err := client.ColorPresentation(
+		context.Background(),
+		&protocol.ColorPresentationParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: "file:///test.css",
+			},
+			Color: protocol.Color{Red: 1, Alpha: 1},
+		},
+	)
+	if err != nil {
+		t.Fatalf("ColorPresentation failed: %v", err)
+	}
+	if len(items) != 1 || items[0].TextEdit == nil ||
+		items[0].TextEdit.NewText != "#ff0000" {
+		t.Errorf("presentations = %+v", items)
+	}
+}
+
+func TestDiagnosticQueueFull(t *testing.T) {
+	h := &testHandler{}
+	pub := newDiagnosticPublisher(h, nil)
+
+	// Fill the channel.
+	for range diagQueueSize {
+		pub.request("file:///fill.txt", "x")
+	}
+
+	// This should not block — it drops silently.
+	pub.request("file:///overflow.txt", "y")
+}
+
+// syncHandler extends testHandler with DocumentSyncHandler support.
+type syncHandler struct {
+	testHandler
+	mu      sync.Mutex
+	opened  string
+	changes []TextChange
+	content string
+	closed  []protocol.DocumentURI
+}
+
+func (h *syncHandler) DidClose(_ context.Context, uri protocol.DocumentURI) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = append(h.closed, uri)
+}
+
+func (h *syncHandler) DidOpen(
+	_ context.Context,
+	_ protocol.DocumentURI,
+	content string,
+) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.opened = content
+}
+
+func (h *syncHandler) DidChange(
+	_ context.Context,
+	_ protocol.DocumentURI,
+	changes []TextChange,
+	content string,
+) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changes = append(h.changes, changes...)
+	h.content = content
+}
+
+func TestDocumentStoreIncremental(t *testing.T) {
+	docs := newDocuments()
+	uri := protocol.DocumentURI("file:///test.css")
+	docs.open(uri, "a {\n  color: red;\n}\n")
+
+	applied, content, ok := docs.change(uri, []contentChange{
+		{
+			Range: &protocol.Range{
+				Start: protocol.Position{Line: 1, Character: 9},
+				End:   protocol.Position{Line: 1, Character: 12},
+			},
+			Text: "blue",
+		},
+		{
+			// Positions refer to the content after the first change.
+			Range: &protocol.Range{
+				Start: protocol.Position{Line: 0, Character: 0},
+				End:   protocol.Position{Line: 0, Character: 0},
+			},
+			Text: "/* x */\n",
+		},
+	})
+	if !ok {
+		t.Fatal("expected change to apply")
+	}
+
+	want := "/* x */\na {\n  color: blue;\n}\n"
+	if content != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+	if got, _ := docs.get(uri); got != want {
+		t.Errorf("stored content = %q, want %q", got, want)
+	}
+
+	wantChanges := []TextChange{
+		{Start: 13, End: 16, Text: "blue"},
+		{Start: 0, End: 0, Text: "/* x */\n"},
+	}
+	if !reflect.DeepEqual(applied, wantChanges) {
+		t.Errorf("applied = %+v, want %+v", applied, wantChanges)
+	}
+}
+
+func TestDocumentStoreClampsPositions(t *testing.T) {
+	docs := newDocuments()
+	uri := protocol.DocumentURI("file:///test.css")
+	docs.open(uri, "ab\ncd")
+
+	_, content, _ := docs.change(uri, []contentChange{{
+		Range: &protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 10},
+			End:   protocol.Position{Line: 7, Character: 0},
+		},
+		Text: "!",
+	}})
+	if content != "ab!" {
+		t.Errorf("content = %q, want %q", content, "ab!")
+	}
+}
+
+func TestDocumentStoreChangeUnopened(t *testing.T) {
+	docs := newDocuments()
+	_, _, ok := docs.change("file:///missing.css", []contentChange{{Text: "x"}})
+	if ok {
+		t.Error("expected change to an unopened document to be ignored")
+	}
+}
+
+func TestContentChangeDecoding(t *testing.T) {
+	var params didChangeParams
+	err := json.Unmarshal([]byte(`{
+		"textDocument": {"uri": "file:///a.css", "version": 2},
+		"contentChanges": [
+			{"text": "full"},
+			{"range": {"start": {"line": 0, "character": 0},
+				"end": {"line": 0, "character": 0}}, "text": "x"}
+		]
+	}`), &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.ContentChanges[0].Range != nil {
+		t.Error("expected change without range to decode as full text")
+	}
+	if params.ContentChanges[1].Range == nil {
+		t.Error("expected empty range at start to decode as an edit")
+	}
+}
+
+func TestDidChangeIncremental(t *testing.T) {
+	h := &syncHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+
+	ctx := context.Background()
+	if _, err := client.Initialize(ctx, &protocol.InitializeParams{}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	uri := protocol.DocumentURI("file:///test.css")
+	err := client.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI: uri, LanguageID: "css", Version: 1, Text: "a { color: red; }",
+		},
+	})
+	if err != nil {
+		t.Fatalf("DidOpen failed: %v", err)
+	}
+
+	err = client.DidChange(ctx, &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+			Version:                2,
+		},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 0, Character: 11},
+				End:   protocol.Position{Line: 0, Character: 14},
+			},
+			Text: "blue",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("DidChange failed: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.opened != "a { color: red; }" {
+		t.Errorf("DidOpen content = %q", h.opened)
+	}
+	if h.content != "a { color: blue; }" {
+		t.Errorf("DidChange content = %q", h.content)
+	}
+	want := []TextChange{{Start: 11, End: 14, Text: "blue"}}
+	if !reflect.DeepEqual(h.changes, want) {
+		t.Errorf("changes = %+v, want %+v", h.changes, want)
+	}
+}
+
+// encodingHandler extends syncHandler with PositionEncodingHandler
+// support.
+type encodingHandler struct {
+	syncHandler
+	encoding lineindex.Encoding
+}
+
+func (h *encodingHandler) SetPositionEncoding(enc lineindex.Encoding) {
+	h.encoding = enc
+}
+
+func TestPositionEncodingNegotiation(t *testing.T) {
+	tests := []struct {
+		name    string
+		general any
+		want    lineindex.Encoding
+	}{
+		{"no offer", nil, lineindex.UTF16},
+		{"prefers client order", map[string]any{
+			"positionEncodings": []string{"utf-32", "utf-8"},
+		}, lineindex.UTF32},
+		{"skips unknown", map[string]any{
+			"positionEncodings": []string{"latin-1", "utf-8"},
+		}, lineindex.UTF8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &encodingHandler{}
+			conn, cleanup := startTestConn(t, h)
+			defer cleanup()
+
+			caps := map[string]any{}
+			if tt.general != nil {
+				caps["general"] = tt.general
+			}
+			var result struct {
+				Capabilities struct {
+					PositionEncoding string `json:"positionEncoding"`
+					HoverProvider    bool   `json:"hoverProvider"`
+				} `json:"capabilities"`
+			}
+			_, err := conn.Call(
+				context.Background(),
+				protocol.MethodInitialize,
+				map[string]any{"capabilities": caps},
+				&result,
+			)
+			if err != nil {
+				t.Fatalf("initialize failed: %v", err)
+			}
+
+			got := lineindex.Encoding(result.Capabilities.PositionEncoding)
+			if got != tt.want {
+				t.Errorf("positionEncoding = %q, want %q", got, tt.want)
+			}
+			if h.encoding != tt.want {
+				t.Errorf("handler encoding = %q, want %q", h.encoding, tt.want)
+			}
+			if !result.Capabilities.HoverProvider {
+				t.Error("handler capabilities were lost")
+			}
+		})
+	}
+}
+
+func TestDocumentStoreEncodings(t *testing.T) {
+	// "😀" is 4 bytes, 2 UTF-16 units and 1 UTF-32 unit.
+	const content = "a { content: \"😀\"; color: red; }"
+	red := len("a { content: \"😀\"; color: ")
+
+	tests := []struct {
+		enc  lineindex.Encoding
+		char uint32
+	}{
+		{lineindex.UTF8, uint32(red)},
+		{lineindex.UTF16, uint32(red - 2)},
+		{lineindex.UTF32, uint32(red - 3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.enc), func(t *testing.T) {
+			docs := newDocuments()
+			docs.encoding = tt.enc
+			uri := protocol.DocumentURI("file:///test.css")
+			docs.open(uri, content)
+
+			applied, got, _ := docs.change(uri, []contentChange{{
+				Range: &protocol.Range{
+					Start: protocol.Position{Line: 0, Character: tt.char},
+					End:   protocol.Position{Line: 0, Character: tt.char + 3},
+				},
+				Text: "blue",
+			}})
+
+			want := "a { content: \"😀\"; color: blue; }"
+			if got != want {
+				t.Errorf("content = %q, want %q", got, want)
+			}
+			if applied[0].Start != red || applied[0].End != red+3 {
+				t.Errorf("applied = %+v, want byte range %d-%d", applied[0], red, red+3)
+			}
+		})
+	}
+}
+
+// workspaceHandler reports a fixed diagnostic for the configured
+// workspace files and implements InterFileHandler.
+type workspaceHandler struct {
+	syncHandler
+	files   []protocol.DocumentURI
+	version atomic.Uint64
+	diagged chan protocol.DocumentURI
+}
+
+func (h *workspaceHandler) Diagnostics(
+	_ context.Context,
+	uri protocol.DocumentURI,
+	content string,
+) ([]protocol.Diagnostic, error) {
+	if h.diagged != nil {
+		h.diagged <- uri
+	}
+	if content == "" {
+		return nil, nil
+	}
+	return []protocol.Diagnostic{{Message: content}}, nil
+}
+
+func (h *workspaceHandler) WorkspaceDiagnostics(
+	context.Context,
+) (map[protocol.DocumentURI][]protocol.Diagnostic, error) {
+	out := make(map[protocol.DocumentURI][]protocol.Diagnostic)
+	for _, uri := range h.files {
+		out[uri] = []protocol.Diagnostic{{Message: "in " + string(uri)}}
+	}
+	return out, nil
+}
+
+func (h *workspaceHandler) WorkspaceVersion() uint64 {
+	return h.version.Load()
+}
+
+func (h *workspaceHandler) DidChange(
+	ctx context.Context,
+	uri protocol.DocumentURI,
+	changes []TextChange,
+	content string,
+) {
+	h.syncHandler.DidChange(ctx, uri, changes, content)
+	if strings.Contains(content, "shared") {
+		h.version.Add(1)
+	}
+}
+
+// pullCapabilities are client capabilities announcing pull
+// diagnostics with refresh support.
+var pullCapabilities = map[string]any{
+	"textDocument": map[string]any{"diagnostic": map[string]any{}},
+	"workspace": map[string]any{
+		"diagnostics": map[string]any{"refreshSupport": true},
+	},
+}
+
+func initializeConn(
+	t *testing.T,
+	conn jsonrpc2.Conn,
+	caps any,
+) map[string]any {
+	t.Helper()
+	var result struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	_, err := conn.Call(
+		context.Background(),
+		protocol.MethodInitialize,
+		map[string]any{"capabilities": caps},
+		&result,
+	)
+	if err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	return result.Capabilities
+}
+
+func openDocument(
+	t *testing.T,
+	conn jsonrpc2.Conn,
+	uri protocol.DocumentURI,
+	text string,
+) {
+	t.Helper()
+	err := conn.Notify(
+		context.Background(),
+		protocol.MethodTextDocumentDidOpen,
+		&protocol.DidOpenTextDocumentParams{
+			TextDocument: protocol.TextDocumentItem{
+				URI: uri, LanguageID: "css", Version: 1, Text: text,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("didOpen failed: %v", err)
+	}
+}
+
+func replaceDocument(
+	t *testing.T,
+	conn jsonrpc2.Conn,
+	uri protocol.DocumentURI,
+	text string,
+) {
+	t.Helper()
+	err := conn.Notify(
+		context.Background(),
+		protocol.MethodTextDocumentDidChange,
+		map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": 2},
+			"contentChanges": []map[string]any{{"text": text}},
+		},
+	)
+	if err != nil {
+		t.Fatalf("didChange failed: %v", err)
+	}
+}
+
+func TestDiagnosticProviderCapability(t *testing.T) {
+	conn, cleanup := startTestConn(t, &workspaceHandler{})
+	defer cleanup()
+
+	caps := initializeConn(t, conn, map[string]any{})
+	provider, ok := caps["diagnosticProvider"].(map[string]any)
+	if !ok {
+		t.Fatalf("diagnosticProvider missing: %v", caps)
+	}
+	if provider["interFileDependencies"] != true ||
+		provider["workspaceDiagnostics"] != true {
+		t.Errorf("diagnosticProvider = %v", provider)
+	}
+}
+
+func TestDocumentDiagnosticPull(t *testing.T) {
+	conn, cleanup := startTestConn(t, &workspaceHandler{})
+	defer cleanup()
+	ctx := context.Background()
+
+	initializeConn(t, conn, pullCapabilities)
+	uri := protocol.DocumentURI("file:///a.css")
+	openDocument(t, conn, uri, "problem")
+
+	pull := func(previous string) documentDiagnosticReport {
+		t.Helper()
+		var r documentDiagnosticReport
+		_, err := conn.Call(ctx, methodTextDocumentDiagnostic, map[string]any{
+			"textDocument":     map[string]any{"uri": uri},
+			"previousResultId": previous,
+		}, &r)
+		if err != nil {
+			t.Fatalf("textDocument/diagnostic failed: %v", err)
+		}
+		return r
+	}
+
+	first := pull("")
+	if first.Kind != reportFull || len(first.Items) != 1 ||
+		first.Items[0].Message != "problem" || first.ResultID == "" {
+		t.Fatalf("first report = %+v", first)
+	}
+
+	second := pull(first.ResultID)
+	if second.Kind != reportUnchanged || second.ResultID != first.ResultID {
+		t.Errorf("second report = %+v, want unchanged", second)
+	}
+
+	replaceDocument(t, conn, uri, "")
+	third := pull(first.ResultID)
+	if third.Kind != reportFull || third.Items == nil || len(third.Items) != 0 {
+		t.Errorf("third report = %+v, want full and empty", third)
+	}
+}
+
+func TestWorkspaceDiagnosticPull(t *testing.T) {
+	h := &workspaceHandler{files: []protocol.DocumentURI{
+		"file:///b.css", "file:///a.css", "file:///open.css",
+	}}
+	conn, cleanup := startTestConn(t, h)
+	defer cleanup()
+	ctx := context.Background()
+
+	initializeConn(t, conn, pullCapabilities)
+	openDocument(t, conn, "file:///open.css", "")
+
+	var first workspaceDiagnosticReport
+	_, err := conn.Call(ctx, methodWorkspaceDiagnostic, map[string]any{
+		"previousResultIds": []any{},
+	}, &first)
+	if err != nil {
+		t.Fatalf("workspace/diagnostic failed: %v", err)
+	}
+
+	// Open documents are left to textDocument/diagnostic.
+	if len(first.Items) != 2 || first.Items[0].URI != "file:///a.css" ||
+		first.Items[1].URI != "file:///b.css" {
+		t.Fatalf("items = %+v", first.Items)
+	}
+	for _, item := range first.Items {
+		if item.Kind != reportFull || len(item.Items) != 1 {
+			t.Errorf("item %s = %+v, want full", item.URI, item)
+		}
+	}
+
+	var second workspaceDiagnosticReport
+	_, err = conn.Call(ctx, methodWorkspaceDiagnostic, map[string]any{
+		"previousResultIds": []map[string]any{
+			{"uri": "file:///a.css", "value": first.Items[0].ResultID},
+		},
+	}, &second)
+	if err != nil {
+		t.Fatalf("workspace/diagnostic failed: %v", err)
+	}
+	if second.Items[0].Kind != reportUnchanged {
+		t.Errorf("a.css = %+v, want unchanged", second.Items[0])
+	}
+	if second.Items[1].Kind != reportFull {
+		t.Errorf("b.css = %+v, want full", second.Items[1])
+	}
+}
+
+func TestInterFileChangeRepublishes(t *testing.T) {
+	h := &workspaceHandler{diagged: make(chan protocol.DocumentURI, 16)}
+	conn, cleanup := startTestConn(t, h)
+	defer cleanup()
+
+	initializeConn(t, conn, map[string]any{})
+	openDocument(t, conn, "file:///a.css", "")
+	openDocument(t, conn, "file:///b.css", "")
+	waitDiagnostics(t, h.diagged, "file:///a.css", "file:///b.css")
+
+	// An edit that changes shared state re-evaluates the other
+	// open document too.
+	replaceDocument(t, conn, "file:///a.css", "shared")
+	waitDiagnostics(t, h.diagged, "file:///a.css", "file:///b.css")
+
+	// One that doesn't only re-evaluates itself.
+	replaceDocument(t, conn, "file:///b.css", "local")
+	waitDiagnostics(t, h.diagged, "file:///b.css")
+	select {
+	case uri := <-h.diagged:
+		t.Errorf("unexpected diagnostics for %s", uri)
+	case <-time.After(2 * debounceInterval):
+	}
+}
+
+func waitDiagnostics(
+	t *testing.T,
+	ch <-chan protocol.DocumentURI,
+	want ...protocol.DocumentURI,
+) {
+	t.Helper()
+	var got []protocol.DocumentURI
+	for range want {
+		select {
+		case uri := <-ch:
+			got = append(got, uri)
+		case <-time.After(time.Second):
+			t.Fatalf("diagnostics for %v, want %v", got, want)
+		}
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("diagnostics for %v, want %v", got, want)
+	}
+}
+
+func TestInterFileChangeRefreshesPullClients(t *testing.T) {
+	refreshed := make(chan struct{}, 1)
+	client := func(
+		ctx context.Context,
+		reply jsonrpc2.Replier,
+		req jsonrpc2.Request,
+	) error {
+		if req.Method() == methodWorkspaceDiagnosticRefresh {
+			refreshed <- struct{}{}
+		}
+		// A failed reply would fail the connection while the
+		// test tears it down.
+		_ = reply(ctx, nil, nil)
+		return nil
+	}
+
+	h := &workspaceHandler{}
+	conn, cleanup := startTestConnWithClient(t, h, client)
+	defer cleanup()
+
+	initializeConn(t, conn, pullCapabilities)
+	openDocument(t, conn, "file:///a.css", "")
+	replaceDocument(t, conn, "file:///a.css", "shared")
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("expected workspace/diagnostic/refresh request")
+	}
+}
+
+// watchHandler extends syncHandler with WatchedFilesHandler
+// support.
+type watchHandler struct {
+	syncHandler
+	events chan []protocol.FileEvent
+}
+
+func (h *watchHandler) WatchPatterns() []string {
+	return []string{"**/*.css"}
+}
+
+func (h *watchHandler) DidChangeWatchedFiles(
+	_ context.Context,
+	changes []protocol.FileEvent,
+) {
+	h.events <- changes
+}
+
+func TestWatchedFilesRegistration(t *testing.T) {
+	registered := make(chan protocol.RegistrationParams, 1)
+	client := func(
+		ctx context.Context,
+		reply jsonrpc2.Replier,
+		req jsonrpc2.Request,
+	) error {
+		if req.Method() == protocol.MethodClientRegisterCapability {
+			var params protocol.RegistrationParams
+			_ = json.Unmarshal(req.Params(), &params)
+			registered <- params
+		}
+		_ = reply(ctx, nil, nil)
+		return nil
+	}
+
+	h := &watchHandler{events: make(chan []protocol.FileEvent, 1)}
+	conn, cleanup := startTestConnWithClient(t, h, client)
+	defer cleanup()
+	ctx := context.Background()
+
+	initializeConn(t, conn, map[string]any{
+		"workspace": map[string]any{
+			"didChangeWatchedFiles": map[string]any{
+				"dynamicRegistration": true,
+			},
+		},
+	})
+	if err := conn.Notify(ctx, protocol.MethodInitialized, map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var params protocol.RegistrationParams
+	select {
+	case params = <-registered:
+	case <-time.After(time.Second):
+		t.Fatal("expected client/registerCapability request")
+	}
+	if len(params.Registrations) != 1 ||
+		params.Registrations[0].Method != protocol.MethodWorkspaceDidChangeWatchedFiles {
+		t.Fatalf("registrations = %+v", params.Registrations)
+	}
+	opts, _ := json.Marshal(params.Registrations[0].RegisterOptions)
+	if !strings.Contains(string(opts), `"globPattern":"**/*.css"`) {
+		t.Errorf("register options = %s", opts)
+	}
+}
+
+func TestWatchedFilesWithoutDynamicRegistration(t *testing.T) {
+	registered := make(chan struct{}, 1)
+	client := func(
+		ctx context.Context,
+		reply jsonrpc2.Replier,
+		req jsonrpc2.Request,
+	) error {
+		if req.Method() == protocol.MethodClientRegisterCapability {
+			registered <- struct{}{}
+		}
+		_ = reply(ctx, nil, nil)
+		return nil
+	}
+
+	h := &watchHandler{events: make(chan []protocol.FileEvent, 1)}
+	conn, cleanup := startTestConnWithClient(t, h, client)
+	defer cleanup()
+
+	initializeConn(t, conn, map[string]any{})
+	err := conn.Notify(context.Background(), protocol.MethodInitialized, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-registered:
+		t.Error("watchers registered with a client that can't accept them")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDidChangeWatchedFiles(t *testing.T) {
+	h := &watchHandler{events: make(chan []protocol.FileEvent, 1)}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, err := client.Initialize(ctx, &protocol.InitializeParams{}); err != nil {
+		t.Fatal(err)
+	}
+	err := client.DidChangeWatchedFiles(ctx, &protocol.DidChangeWatchedFilesParams{
+		Changes: []*protocol.FileEvent{
+			{Type: protocol.FileChangeTypeCreated, URI: "file:///a.css"},
+			{Type: protocol.FileChangeTypeDeleted, URI: "file:///b.css"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case events := <-h.events:
+		if len(events) != 2 || events[0].URI != "file:///a.css" ||
+			events[1].Type != protocol.FileChangeTypeDeleted {
+			t.Errorf("events = %+v", events)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected DidChangeWatchedFiles to reach the handler")
+	}
+}
+
+type foldersHandler struct {
+	syncHandler
+	changes chan [2][]protocol.WorkspaceFolder
+}
+
+func (h *foldersHandler) DidChangeWorkspaceFolders(
+	_ context.Context,
+	added, removed []protocol.WorkspaceFolder,
+) {
+	h.changes <- [2][]protocol.WorkspaceFolder{added, removed}
+}
+
+func TestWorkspaceFoldersCapability(t *testing.T) {
+	conn, cleanup := startTestConn(t, &foldersHandler{})
+	defer cleanup()
+
+	caps := initializeConn(t, conn, map[string]any{})
+	ws, _ := caps["workspace"].(map[string]any)
+	folders, _ := ws["workspaceFolders"].(map[string]any)
+	if folders["supported"] != true || folders["changeNotifications"] != true {
+		t.Errorf("workspace capabilities = %v", caps["workspace"])
+	}
+
+	conn, cleanup = startTestConn(t, &syncHandler{})
+	defer cleanup()
+	if caps := initializeConn(t, conn, map[string]any{}); caps["workspace"] != nil {
+		t.Errorf("workspace folders advertised without a handler: %v", caps["workspace"])
+	}
+}
+
+func TestDidChangeWorkspaceFolders(t *testing.T) {
+	h := &foldersHandler{changes: make(chan [2][]protocol.WorkspaceFolder, 1)}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, err := client.Initialize(ctx, &protocol.InitializeParams{}); err != nil {
+		t.Fatal(err)
+	}
+	params := &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Added:   []protocol.WorkspaceFolder{{URI: "file:///b", Name: "b"}},
+			Removed: []protocol.WorkspaceFolder{{URI: "file:///a", Name: "a"}},
+		},
+	}
+	if err := client.DidChangeWorkspaceFolders(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case change := <-h.changes:
+		added, removed := change[0], change[1]
+		if len(added) != 1 || added[0].Name != "b" ||
+			len(removed) != 1 || removed[0].Name != "a" {
+			t.Errorf("added = %+v, removed = %+v", added, removed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected DidChangeWorkspaceFolders to reach the handler")
+	}
+}
+
+func TestDidCloseNotifiesHandler(t *testing.T) {
+	h := &syncHandler{}
+	client, cleanup := startTestServer(t, h)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, err := client.Initialize(ctx, &protocol.InitializeParams{}); err != nil {
+		t.Fatal(err)
+	}
+	uri := protocol.DocumentURI("file:///a.css")
+	_ = client.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, Text: "a {}"},
+	})
+	_ = client.DidClose(ctx, &protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.closed) != 1 || h.closed[0] != uri {
+		t.Errorf("closed = %v, want [%s]", h.closed, uri)
+	}
+}
+
+// backgroundHandler starts work when the client is initialized
+// that reports progress until it is cancelled.
+type backgroundHandler struct {
+	workspaceHandler
+	cancelled chan struct{}
+}
+
+func (h *backgroundHandler) Initialized(bg *Background) {
+	go func() {
+		ctx, work := bg.StartProgress(bg.Context(), "Indexing")
+		work.Report("1/2", 50)
+		work.Report("", 50) // unchanged, not sent
+		<-ctx.Done()
+		h.version.Add(1)
+		work.End("stopped")
+		bg.WorkspaceChanged()
+		close(h.cancelled)
+	}()
+}
+
+func TestBackgroundWorkProgress(t *testing.T) {
+	progress := make(chan map[string]any, 10)
+	created := make(chan string, 1)
+	client := func(
+		ctx context.Context,
+		reply jsonrpc2.Replier,
+		req jsonrpc2.Request,
+	) error {
+		switch req.Method() {
+		case protocol.MethodWorkDoneProgressCreate:
+			var params struct{ Token string }
+			_ = json.Unmarshal(req.Params(), &params)
+			created <- params.Token
+		case protocol.MethodProgress:
+			var params struct{ Value map[string]any }
+			_ = json.Unmarshal(req.Params(), &params)
+			progress <- params.Value
+		}
+		_ = reply(ctx, nil, nil)
+		return nil
+	}
+
+	h := &backgroundHandler{
+		workspaceHandler: workspaceHandler{diagged: make(chan protocol.DocumentURI, 10)},
+		cancelled:        make(chan struct{}),
+	}
+	conn, cleanup := startTestConnWithClient(t, h, client)
+	defer cleanup()
+	ctx := context.Background()
+
+	initializeConn(t, conn, map[string]any{
+		"window": map[string]any{"workDoneProgress": true},
+	})
+	openDocument(t, conn, "file:///a.css", "")
+	waitDiagnostics(t, h.diagged, "file:///a.css")
+	if err := conn.Notify(ctx, protocol.MethodInitialized, map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var token string
+	select {
+	case token = <-created:
+	case <-time.After(time.Second):
+		t.Fatal("expected window/workDoneProgress/create")
+	}
+
+	next := func() map[string]any {
+		t.Helper()
+		select {
+		case v := <-progress:
+			return v
+		case <-time.After(time.Second):
+			t.Fatal("expected $/progress")
+			return nil
+		}
+	}
+	if v := next(); v["kind"] != "begin" || v["title"] != "Indexing" ||
+		v["cancellable"] != true {
+		t.Errorf("begin = %v", v)
+	}
+	if v := next(); v["kind"] != "report" || v["message"] != "1/2" ||
+		v["percentage"] != float64(50) {
+		t.Errorf("report = %v", v)
+	}
+
+	err := conn.Notify(ctx, protocol.MethodWorkDoneProgressCancel, map[string]any{
+		"token": token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := next(); v["kind"] != "end" || v["message"] != "stopped" {
+		t.Errorf("end = %v", v)
+	}
+
+	// Announcing the change re-evaluates open documents.
+	<-h.cancelled
+	waitDiagnostics(t, h.diagged, "file:///a.css")
+}
+
+func TestBackgroundWorkWithoutProgressSupport(t *testing.T) {
+	called := make(chan string, 1)
+	client := func(
+		ctx context.Context,
+		reply jsonrpc2.Replier,
+		req jsonrpc2.Request,
+	) error {
+		select {
+		case called <- req.Method():
+		default:
+		}
+		_ = reply(ctx, nil, nil)
+		return nil
+	}
+
+	h := &backgroundHandler{cancelled: make(chan struct{})}
+	conn, cleanup := startTestConnWithClient(t, h, client)
+	defer cleanup()
+	initializeConn(t, conn, map[string]any{})
+	err := conn.Notify(context.Background(), protocol.MethodInitialized, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case m := <-called:
+		t.Errorf("unexpected %s to a client without progress support", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Shutting down cancels background work.
+	_, err = conn.Call(context.Background(), protocol.MethodShutdown, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-h.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected shutdown to cancel background work")
+	}
+}
+
+type renameFilesHandler struct {
+	syncHandler
+}
+
+func (h *renameFilesHandler) WillRenameFiles(
+	_ context.Context,
+	params *protocol.RenameFilesParams,
+) (*protocol.WorkspaceEdit, error) {
+	return &protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+			protocol.DocumentURI(params.Files[0].NewURI): nil,
+		},
+	}, nil
+}
+
+func TestWillRenameFiles(t *testing.T) {
+	conn, cleanup := startTestConn(t, &renameFilesHandler{})
+	defer cleanup()
+	caps := initializeConn(t, conn, map[string]any{})
+	ws, _ := caps["workspace"].(map[string]any)
+	ops, _ := ws["fileOperations"].(map[string]any)
+	if ops["willRename"] == nil {
+		t.Errorf("workspace capabilities = %v", caps["workspace"])
+	}
+
+	client, cleanup := startTestServer(t, &renameFilesHandler{})
+	defer cleanup()
+	ctx := context.Background()
+	if _, err := client.Initialize(ctx, &protocol.InitializeParams{}); err != nil {
+		t.Fatal(err)
+	}
+	edit, err := client.WillRenameFiles(ctx, &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{OldURI: "file:///a.css", NewURI: "file:///b.css"}},
+	})
+	if err != nil || edit == nil {
+		t.Fatalf("edit = %v, %v", edit, err)
+	}
+	if _, ok := edit.Changes["file:///b.css"]; !ok {
+		t.Errorf("edit = %+v", edit)
+	}
+}